@@ -0,0 +1,87 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/ymqzj/payment-gateway/internal/audit"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/ratelimit"
+
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminHandler 运维管理处理器
+type AdminHandler struct {
+	gateway  *payment.PaymentGateway
+	inbound  *ratelimit.Inbound
+	outbound *ratelimit.Outbound
+	audits   audit.Store
+}
+
+// NewAdminHandler 创建运维管理处理器，未启用限流时 inbound、outbound 为空，未启用审计时 audits 为空
+func NewAdminHandler(gateway *payment.PaymentGateway, inbound *ratelimit.Inbound, outbound *ratelimit.Outbound, audits audit.Store) *AdminHandler {
+	return &AdminHandler{
+		gateway:  gateway,
+		inbound:  inbound,
+		outbound: outbound,
+		audits:   audits,
+	}
+}
+
+// Certs 查看各渠道当前加载的证书序列号和指纹，用于确认证书轮换是否生效
+func (h *AdminHandler) Certs(c *gin.Context) {
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"certs": h.gateway.GetCertInfos(c.Request.Context()),
+		},
+	})
+}
+
+// RateLimits 查看各维度被限流的累计次数，用于调整限额
+func (h *AdminHandler) RateLimits(c *gin.Context) {
+	data := map[string]interface{}{
+		"enabled": h.inbound != nil,
+	}
+	if h.inbound != nil {
+		data["inbound"] = h.inbound.Stats()
+	}
+	if h.outbound != nil {
+		data["outbound"] = h.outbound.Stats()
+	}
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	})
+}
+
+// Audit 按商户订单号查询审计记录，包含网关与渠道之间的报文往来和渠道推送的通知，用于处理交易争议
+func (h *AdminHandler) Audit(c *gin.Context) {
+	if h.audits == nil {
+		writeErrorCode(c, payment.NewErrorCodeWithDetails(payment.NotFound.Code, payment.NotFound.Message, "audit log is disabled"), nil)
+		return
+	}
+
+	outTradeNo := c.Param("out_trade_no")
+	records, err := h.audits.ByOrder(c.Request.Context(), outTradeNo)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("query audit records failed",
+			zap.String("out_trade_no", outTradeNo), zap.Error(err))
+		writeErrorCode(c, payment.InternalServerError, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"out_trade_no": outTradeNo,
+			"records":      records,
+		},
+	})
+}
@@ -0,0 +1,293 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	paymentv1 "github.com/ymqzj/payment-gateway/api/proto/payment/v1"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testCredentials 绑定商户 m1、m2 的凭证，未绑定商户的运维凭证和只有查询权限的凭证
+var testCredentials = []*auth.Credential{
+	{AppKey: "m1", Secret: []byte("m1-secret"), MerchantID: "m1", Scopes: []auth.Scope{auth.ScopePay, auth.ScopeRefund, auth.ScopeQuery, auth.ScopeAdmin}},
+	{AppKey: "m2", Secret: []byte("m2-secret"), MerchantID: "m2", Scopes: []auth.Scope{auth.ScopePay, auth.ScopeRefund, auth.ScopeQuery, auth.ScopeAdmin}},
+	{AppKey: "ops", Secret: []byte("ops-secret"), Scopes: []auth.Scope{auth.ScopePay, auth.ScopeRefund, auth.ScopeQuery, auth.ScopeAdmin}},
+	{AppKey: "reader", Secret: []byte("reader-secret"), Scopes: []auth.Scope{auth.ScopeQuery}},
+}
+
+// testMaxBody 测试路由的请求体上限
+const testMaxBody = 1024
+
+// newAuthRouter 与 cmd/server 相同的鉴权路由，订单存储中有商户 m1、m2 各一个订单
+func newAuthRouter(t *testing.T) (*gin.Engine, *PaymentHandler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	orders := order.NewMemoryStore()
+	for _, o := range []*order.Order{
+		{OutTradeNo: "ORDER_M1", OrderID: "WX_ORDER_M1", MerchantID: "m1", Channel: payment.ChannelWechat, Status: payment.TradeStatusNotPay},
+		{OutTradeNo: "ORDER_M2", OrderID: "WX_ORDER_M2", MerchantID: "m2", Channel: payment.ChannelWechat, Status: payment.TradeStatusNotPay},
+	} {
+		if err := orders.Create(context.Background(), o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return authRoutes(orders)
+}
+
+// authRoutes 使用指定订单存储的鉴权路由
+func authRoutes(orders order.Store) (*gin.Engine, *PaymentHandler) {
+	gateway := payment.NewPaymentGateway(&specAdapter{})
+	handler := NewPaymentHandler(gateway, nil, orders, nil, nil)
+	orderAdmin := NewOrderAdminHandler(gateway, orders, nil)
+	authenticator := auth.NewAuthenticator(auth.NewStaticStore(testCredentials...), 5*time.Minute)
+
+	r := gin.New()
+	v1 := r.Group("/api/v1")
+	v1.POST("/pay", RequireScope(authenticator, auth.ScopePay, testMaxBody), handler.Pay)
+	v1.POST("/query", RequireScope(authenticator, auth.ScopeQuery, testMaxBody), handler.Query)
+	v1.POST("/refund", RequireScope(authenticator, auth.ScopeRefund, testMaxBody), handler.Refund)
+	v1.POST("/close", RequireScope(authenticator, auth.ScopePay, testMaxBody), handler.Close)
+	admin := r.Group("/admin/v1", RequireScope(authenticator, auth.ScopeAdmin, testMaxBody))
+	admin.GET("/orders", orderAdmin.Search)
+	admin.GET("/orders/:out_trade_no", orderAdmin.Get)
+	admin.POST("/orders/:out_trade_no/close", orderAdmin.Close)
+	return r, handler
+}
+
+// nonceSeq 保证每个请求的 nonce 不重复
+var nonceSeq atomic.Int64
+
+// signRequest 使用 app key 对应的密钥签名请求，appKey 为空时不签名
+func signRequest(r *http.Request, appKey string, body string) {
+	if appKey == "" {
+		return
+	}
+	secret := appKey + "-secret"
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := fmt.Sprintf("nonce-%d", nonceSeq.Add(1))
+	r.Header.Set(auth.HeaderAppKey, appKey)
+	r.Header.Set(auth.HeaderTimestamp, timestamp)
+	r.Header.Set(auth.HeaderNonce, nonce)
+	r.Header.Set(auth.HeaderSignature, auth.Sign([]byte(secret), r.Method, r.URL.RequestURI(), timestamp, nonce, []byte(body)))
+}
+
+func TestRequireScope(t *testing.T) {
+	router, _ := newAuthRouter(t)
+	query := `{"channel":"wechat","out_trade_no":"ORDER_M1"}`
+
+	cases := []struct {
+		name    string
+		appKey  string
+		path    string
+		body    string
+		status  int
+		details string
+	}{
+		{"signed", "ops", "/api/v1/query", query, http.StatusOK, ""},
+		{"unsigned", "", "/api/v1/query", query, http.StatusUnauthorized, auth.ErrMissingCredentials.Error()},
+		{"scope denied", "reader", "/api/v1/refund", `{"channel":"wechat","out_trade_no":"ORDER_M1","out_refund_no":"R1","refund_amount":0.01,"total_amount":0.01}`,
+			http.StatusForbidden, auth.ErrScopeDenied.Error() + ": refund"},
+		{"body at limit", "ops", "/api/v1/query", query + strings.Repeat(" ", testMaxBody-len(query)), http.StatusOK, ""},
+		{"body too large", "ops", "/api/v1/query", query + strings.Repeat(" ", testMaxBody-len(query)+1), http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("request body exceeds %d bytes", testMaxBody)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			signRequest(req, tc.appKey, tc.body)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var resp PayResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.status || resp.Details != tc.details {
+				t.Fatalf("status %d details %q, want %d %q: %s", w.Code, resp.Details, tc.status, tc.details, w.Body)
+			}
+		})
+	}
+}
+
+func TestRequireScopeReplay(t *testing.T) {
+	router, _ := newAuthRouter(t)
+	body := `{"channel":"wechat","out_trade_no":"ORDER_M1"}`
+
+	first := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body))
+	first.Header.Set("Content-Type", gin.MIMEJSON)
+	signRequest(first, "ops", body)
+
+	replay := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body))
+	replay.Header = first.Header.Clone()
+
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, []*http.Request{first, replay}[i])
+		if w.Code != want {
+			t.Fatalf("request %d: status %d, want %d: %s", i, w.Code, want, w.Body)
+		}
+	}
+}
+
+func TestMerchantOwnership(t *testing.T) {
+	router, _ := newAuthRouter(t)
+
+	cases := []struct {
+		name   string
+		appKey string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"query own order", "m1", http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_M1"}`, http.StatusOK},
+		{"query other merchant", "m1", http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_M2"}`, http.StatusForbidden},
+		{"query by channel order id", "m1", http.MethodPost, "/api/v1/query", `{"channel":"wechat","order_id":"WX_ORDER_M2"}`, http.StatusForbidden},
+		{"query mixed ids", "m1", http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_M1","order_id":"WX_ORDER_M2"}`, http.StatusForbidden},
+		{"query unknown order", "m1", http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_X"}`, http.StatusNotFound},
+		{"query unbound key", "ops", http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_M2"}`, http.StatusOK},
+		{"refund other merchant", "m1", http.MethodPost, "/api/v1/refund",
+			`{"channel":"wechat","out_trade_no":"ORDER_M2","out_refund_no":"R1","refund_amount":0.01,"total_amount":0.01}`, http.StatusForbidden},
+		{"close other merchant", "m1", http.MethodPost, "/api/v1/close", `{"channel":"wechat","out_trade_no":"ORDER_M2"}`, http.StatusForbidden},
+		{"pay over other merchant order", "m1", http.MethodPost, "/api/v1/pay",
+			`{"channel":"wechat","out_trade_no":"ORDER_M2","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`, http.StatusForbidden},
+		{"admin get own order", "m1", http.MethodGet, "/admin/v1/orders/ORDER_M1", "", http.StatusOK},
+		{"admin get other merchant", "m1", http.MethodGet, "/admin/v1/orders/ORDER_M2", "", http.StatusForbidden},
+		{"admin close other merchant", "m1", http.MethodPost, "/admin/v1/orders/ORDER_M2/close", "", http.StatusForbidden},
+		{"admin search other merchant", "m1", http.MethodGet, "/admin/v1/orders?merchant_id=m2", "", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", gin.MIMEJSON)
+			}
+			signRequest(req, tc.appKey, tc.body)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tc.status, w.Body)
+			}
+		})
+	}
+
+	// 绑定商户的凭证只能搜到本商户的订单
+	req := httptest.NewRequest(http.MethodGet, "/admin/v1/orders", nil)
+	signRequest(req, "m1", "")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var resp struct {
+		Data struct {
+			Orders []order.Order `json:"orders"`
+			Total  int           `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Total != 1 || resp.Data.Orders[0].OutTradeNo != "ORDER_M1" {
+		t.Fatalf("search result = %s", w.Body)
+	}
+}
+
+// TestMerchantOwnershipAfterRestart 订单保存在订单文件中，重启后绑定商户的凭证仍能操作重启前的订单
+func TestMerchantOwnershipAfterRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	serve := func(orders order.Store, appKey, path, body string) int {
+		router, _ := authRoutes(orders)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", gin.MIMEJSON)
+		signRequest(req, appKey, body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	orders, err := order.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pay := `{"channel":"wechat","out_trade_no":"ORDER_1","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`
+	if status := serve(orders, "m1", "/api/v1/pay", pay); status != http.StatusOK {
+		t.Fatalf("pay: status %d", status)
+	}
+	orders.Close()
+
+	orders, err = order.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer orders.Close()
+
+	cases := []struct {
+		name   string
+		appKey string
+		path   string
+		body   string
+		status int
+	}{
+		{"query own order", "m1", "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_1"}`, http.StatusOK},
+		{"refund own order", "m1", "/api/v1/refund", `{"channel":"wechat","out_trade_no":"ORDER_1","out_refund_no":"R1","refund_amount":0.01,"total_amount":0.01}`, http.StatusOK},
+		{"close own order", "m1", "/api/v1/close", `{"channel":"wechat","out_trade_no":"ORDER_1"}`, http.StatusOK},
+		{"query other merchant", "m2", "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_1"}`, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := serve(orders, tc.appKey, tc.path, tc.body); status != tc.status {
+				t.Fatalf("status %d, want %d", status, tc.status)
+			}
+		})
+	}
+}
+
+func TestGRPCMerchantOwnership(t *testing.T) {
+	_, handler := newAuthRouter(t)
+	server := NewGRPCServer(handler)
+	ctx := auth.WithCredential(context.Background(), testCredentials[0])
+
+	calls := map[string]func() error{
+		"query": func() error {
+			_, err := server.Query(ctx, &paymentv1.QueryRequest{Channel: "wechat", OutTradeNo: "ORDER_M2"})
+			return err
+		},
+		"refund": func() error {
+			_, err := server.Refund(ctx, &paymentv1.RefundRequest{Channel: "wechat", OutTradeNo: "ORDER_M2", OutRefundNo: "R1", RefundAmount: 0.01, TotalAmount: 0.01})
+			return err
+		},
+		"close": func() error {
+			_, err := server.Close(ctx, &paymentv1.CloseRequest{Channel: "wechat", OutTradeNo: "ORDER_M2"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if code := status.Code(call()); code != codes.PermissionDenied {
+				t.Fatalf("code = %s, want %s", code, codes.PermissionDenied)
+			}
+		})
+	}
+
+	if _, err := server.Query(ctx, &paymentv1.QueryRequest{Channel: "wechat", OutTradeNo: "ORDER_M1"}); err != nil {
+		t.Fatalf("query own order: %v", err)
+	}
+}
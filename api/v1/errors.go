@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// httpStatuses 错误码对应的 HTTP 状态码，未列出的返回 500
+var httpStatuses = map[string]int{
+	payment.BadRequest.Code:          http.StatusBadRequest,
+	payment.Unauthorized.Code:        http.StatusUnauthorized,
+	payment.Forbidden.Code:           http.StatusForbidden,
+	payment.NotFound.Code:            http.StatusNotFound,
+	payment.RequestTooLarge.Code:     http.StatusRequestEntityTooLarge,
+	payment.UnprocessableEntity.Code: http.StatusUnprocessableEntity,
+	payment.InvalidChannel.Code:      http.StatusBadRequest,
+	payment.InvalidScene.Code:        http.StatusBadRequest,
+	payment.InvalidAmount.Code:       http.StatusBadRequest,
+	payment.MissingParameter.Code:    http.StatusBadRequest,
+	payment.InvalidParameter.Code:    http.StatusBadRequest,
+	payment.OrderNotFound.Code:       http.StatusNotFound,
+	payment.OrderClosed.Code:         http.StatusConflict,
+	payment.OrderPaid.Code:           http.StatusConflict,
+	payment.OrderExpired.Code:        http.StatusConflict,
+	payment.RefundNotAllowed.Code:    http.StatusConflict,
+	payment.InsufficientBalance.Code: http.StatusUnprocessableEntity,
+	payment.NotSupported.Code:        http.StatusNotImplemented,
+	payment.TooManyRequests.Code:     http.StatusTooManyRequests,
+	payment.InvalidSignature.Code:    http.StatusBadRequest,
+	payment.InvalidNotify.Code:       http.StatusBadRequest,
+	payment.NotifyVerifyFailed.Code:  http.StatusBadRequest,
+	payment.WechatError.Code:         http.StatusBadGateway,
+	payment.AlipayError.Code:         http.StatusBadGateway,
+	payment.UnionPayError.Code:       http.StatusBadGateway,
+	payment.ChannelUnavailable.Code:  http.StatusServiceUnavailable,
+	payment.CircuitOpen.Code:         http.StatusServiceUnavailable,
+	payment.ChannelNetworkError.Code: http.StatusBadGateway,
+	payment.ChannelTimeout.Code:      http.StatusGatewayTimeout,
+	payment.ChannelSystemError.Code:  http.StatusBadGateway,
+}
+
+// HTTPStatus 返回错误码对应的 HTTP 状态码
+func HTTPStatus(code *payment.ErrorCode) int {
+	if status, ok := httpStatuses[code.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError 按错误码目录返回错误，原始错误只记录日志
+func writeError(c *gin.Context, err error) {
+	writeErrorData(c, err, nil)
+}
+
+// writeErrorData 返回错误并附带数据，如自动路由失败时的决策过程
+// 参数校验等本地返回的错误码直接响应，不记录日志
+func writeErrorData(c *gin.Context, err error, data map[string]interface{}) {
+	if code, ok := err.(*payment.ErrorCode); ok {
+		writeErrorCode(c, code, data)
+		return
+	}
+
+	code := payment.ErrorCodeOf(err)
+	status := HTTPStatus(code)
+
+	log := logger.FromContext(c.Request.Context()).Warn
+	if status >= http.StatusInternalServerError {
+		log = logger.FromContext(c.Request.Context()).Error
+	}
+	log("request failed",
+		zap.String("path", c.FullPath()),
+		zap.String("code", code.Code),
+		zap.String("details", code.Details),
+		zap.Error(err))
+
+	var limited *payment.RateLimitError
+	if errors.As(err, &limited) && limited.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	}
+	writeErrorCode(c, code, data)
+}
+
+// writeErrorCode 直接返回指定错误码，用于请求参数校验等不经过渠道的错误
+func writeErrorCode(c *gin.Context, code *payment.ErrorCode, data map[string]interface{}) {
+	n, _ := strconv.Atoi(code.Code)
+	c.JSON(HTTPStatus(code), PayResponse{
+		Code:    n,
+		Message: code.Message,
+		Details: code.Details,
+		Data:    data,
+	})
+}
+
+// invalidParameter 请求参数校验失败，校验信息放在 details 中
+func invalidParameter(err error) *payment.ErrorCode {
+	return payment.NewErrorCodeWithDetails(payment.InvalidParameter.Code, payment.InvalidParameter.Message, err.Error())
+}
@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"go.uber.org/zap"
+)
+
+// recordEvent 向订单时间线追加事件，订单不存在（如未经网关下单）时忽略
+func (h *PaymentHandler) recordEvent(ctx context.Context, outTradeNo string, event order.Event) {
+	if h.orders == nil || outTradeNo == "" {
+		return
+	}
+	_, err := order.RecordEvent(ctx, h.orders, outTradeNo, event)
+	if err != nil && !errors.Is(err, order.ErrOrderNotFound) {
+		logger.FromContext(ctx).Warn("record order event failed",
+			zap.String("out_trade_no", outTradeNo),
+			zap.String("event", event.Type),
+			zap.Error(err))
+	}
+}
+
+// operatorOf 事件触发方，鉴权时为 app key
+func operatorOf(ctx context.Context) string {
+	if credential := auth.FromContext(ctx); credential != nil {
+		return credential.AppKey
+	}
+	return ""
+}
+
+// errAmountMismatch 渠道通知或查询返回的支付成功金额与订单金额不一致
+var errAmountMismatch = errors.New("channel amount does not match order amount")
+
+// recordNotify 将验签通过的渠道通知记录到订单时间线并更新订单状态，返回需要向商户推送事件的订单
+// 找不到订单时只记录日志，不影响给渠道的应答；支付成功通知的金额与订单金额不一致时拒绝通知，订单不变
+// 只有订单状态发生变化时才推送给商户：渠道重试的重复通知和迟到的旧状态通知（如 SUCCESS 之后的 NOTPAY）
+// 只记录在时间线中，同一状态变化只产生一个 payment.updated 事件
+func (h *PaymentHandler) recordNotify(ctx context.Context, result *payment.NotifyResult) (*order.Order, error) {
+	if h.orders == nil {
+		return nil, nil
+	}
+
+	changed := false
+	o, err := h.orders.Update(ctx, result.OutTradeNo, func(o *order.Order) error {
+		if result.Success && !sameAmount(result.TotalAmount, o.TotalAmount) {
+			return fmt.Errorf("%w: notify %.2f, order %.2f", errAmountMismatch, result.TotalAmount, o.TotalAmount)
+		}
+		if o.OrderID == "" {
+			o.OrderID = result.OrderID
+		}
+		changed = o.Record(order.Event{
+			Type:     order.EventNotified,
+			To:       notifyStatus(result),
+			Operator: string(result.Channel),
+			Data: map[string]interface{}{
+				"trade_status": result.TradeStatus,
+				"order_id":     result.OrderID,
+				"total_amount": result.TotalAmount,
+			},
+		})
+		return nil
+	})
+	if errors.Is(err, errAmountMismatch) {
+		logger.FromContext(ctx).Error("reject notify: amount mismatch",
+			zap.String("out_trade_no", result.OutTradeNo),
+			zap.String("channel", string(result.Channel)),
+			zap.Error(err))
+		return nil, payment.NewErrorCodeWithDetails(payment.InvalidNotify.Code, payment.InvalidNotify.Message, err.Error())
+	}
+	if err != nil {
+		logger.FromContext(ctx).Warn("record notify failed: order not found",
+			zap.String("out_trade_no", result.OutTradeNo),
+			zap.Error(err))
+		return nil, nil
+	}
+	if !changed {
+		logger.FromContext(ctx).Info("notify did not change order status, event not dispatched",
+			zap.String("out_trade_no", result.OutTradeNo),
+			zap.String("trade_status", result.TradeStatus),
+			zap.String("order_status", string(o.Status)))
+		return nil, nil
+	}
+	return o, nil
+}
+
+// sameAmount 按分比较金额
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
+
+// notifyStatus 通知对应的订单状态，渠道原始状态无法对应时不改变订单状态
+func notifyStatus(result *payment.NotifyResult) payment.TradeStatus {
+	if result.Success {
+		return payment.TradeStatusSuccess
+	}
+	if status := payment.TradeStatus(result.TradeStatus); status.IsValid() {
+		return status
+	}
+	return ""
+}
+
+// forwardNotify 将验签通过的渠道通知作为 payment.updated 事件推送到下单时的 event_url
+// 订单没有推送地址时不推送
+func (h *PaymentHandler) forwardNotify(ctx context.Context, o *order.Order, result *payment.NotifyResult) {
+	if h.events == nil || o == nil || o.EventURL == "" {
+		return
+	}
+
+	_, err := dispatchPaymentEvent(h.events, o, &webhook.PaymentData{
+		OutTradeNo:  result.OutTradeNo,
+		OrderID:     result.OrderID,
+		MerchantID:  o.MerchantID,
+		Channel:     result.Channel,
+		Success:     result.Success,
+		TradeStatus: result.TradeStatus,
+		TotalAmount: result.TotalAmount,
+		PayTime:     result.PayTime,
+	})
+	if err != nil {
+		logger.FromContext(ctx).Warn("dispatch payment event failed",
+			zap.String("out_trade_no", o.OutTradeNo),
+			zap.Error(err))
+	}
+}
+
+// dispatchPaymentEvent 将 payment.updated 事件写入推送队列，由后台投递，返回事件 ID
+func dispatchPaymentEvent(events *webhook.Dispatcher, o *order.Order, data *webhook.PaymentData) (string, error) {
+	event := webhook.NewEvent(webhook.EventPaymentUpdated, data)
+	if err := events.Dispatch(o.EventURL, event, o.OutTradeNo); err != nil {
+		return "", err
+	}
+	return event.ID, nil
+}
+
+// RecordDeliveries 返回把每次事件投递结果记录到订单时间线的回调，用于 Dispatcher.OnDelivery
+func RecordDeliveries(orders order.Store) func(webhook.Delivery) {
+	return func(d webhook.Delivery) {
+		if d.Ref == "" {
+			return
+		}
+		record := order.Event{
+			Time:     d.Time,
+			Type:     order.EventWebhook,
+			Operator: "system",
+			Data: map[string]interface{}{
+				"event_id": d.EventID,
+				"url":      d.URL,
+				"attempt":  d.Attempt,
+				"final":    d.Final,
+			},
+		}
+		if d.Err != nil {
+			record.Message = d.Err.Error()
+		} else {
+			record.Message = "delivered"
+		}
+		if _, err := order.RecordEvent(context.Background(), orders, d.Ref, record); err != nil {
+			logger.GetLogger().Warn("record webhook delivery failed",
+				zap.String("out_trade_no", d.Ref),
+				zap.Error(err))
+		}
+	}
+}
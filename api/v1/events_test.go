@@ -0,0 +1,261 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/signing"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
+	"github.com/ymqzj/payment-gateway/pkg/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newEventsRouter 带事件推送的下单和通知路由，推送地址限制为 policy
+// 商户 events 地址收到的事件经 SDK 验签后写入返回的通道
+func newEventsRouter(t *testing.T, policy webhook.Policy, orders order.Store) (*gin.Engine, *httptest.Server, chan *sdk.Event) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	signer, err := signing.GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := signer.PublicKey()
+	verifier, err := sdk.NewVerifier(sdk.PublicKey{KeyID: key.KeyID, Algorithm: key.Algorithm, PublicKey: key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *sdk.Event, 4)
+	merchant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := verifier.ParseWebhook(r)
+		if err != nil {
+			t.Errorf("verify webhook: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- event
+	}))
+	t.Cleanup(merchant.Close)
+
+	events := webhook.NewDispatcher(signer, policy, webhook.NewMemoryQueue())
+	events.OnDelivery(RecordDeliveries(orders))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	events.Start(ctx)
+
+	handler := NewPaymentHandler(payment.NewPaymentGateway(&specAdapter{}), nil, orders, events, nil)
+	r := gin.New()
+	r.POST("/api/v1/pay", handler.Pay)
+	r.POST("/api/v1/notify/:channel", handler.HandleNotify)
+	return r, merchant, received
+}
+
+// newNotifyOrder 商户 m1 待支付的订单，specAdapter 的通知金额为 0.01
+func newNotifyOrder(t *testing.T, orders order.Store, amount float64, eventURL string) {
+	t.Helper()
+	err := orders.Create(context.Background(), &order.Order{
+		OutTradeNo:  "ORDER_1",
+		Channel:     payment.ChannelWechat,
+		MerchantID:  "m1",
+		TotalAmount: amount,
+		EventURL:    eventURL,
+		Status:      payment.TradeStatusNotPay,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotifyForwardsEvent(t *testing.T) {
+	orders := order.NewMemoryStore()
+	router, merchant, received := newEventsRouter(t, webhook.Policy{Schemes: []string{"http"}, AllowPrivate: true}, orders)
+	newNotifyOrder(t, orders, 0.01, merchant.URL)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/notify/wechat", strings.NewReader(`{"id":"EV-1"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	select {
+	case event := <-received:
+		var data sdk.PaymentData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != webhook.EventPaymentUpdated || data.OutTradeNo != "ORDER_1" || !data.Success {
+			t.Fatalf("event = %+v, data = %+v", event, data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	// 投递结果记录到订单时间线
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		o, err := orders.Get(context.Background(), "ORDER_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := o.Timeline[len(o.Timeline)-1]; last.Type == order.EventWebhook {
+			if o.Status != payment.TradeStatusSuccess || last.Message != "delivered" {
+				t.Fatalf("order = %+v", o)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery not recorded: %+v", o.Timeline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestNotifyAmountMismatch 支付成功通知的金额与订单不一致时拒绝，订单不变也不推送事件
+func TestNotifyAmountMismatch(t *testing.T) {
+	orders := order.NewMemoryStore()
+	router, merchant, received := newEventsRouter(t, webhook.Policy{Schemes: []string{"http"}, AllowPrivate: true}, orders)
+	newNotifyOrder(t, orders, 100, merchant.URL)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/notify/wechat", strings.NewReader(`{"id":"EV-1"}`)))
+	var resp PayResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || resp.Code != 4001 {
+		t.Fatalf("status = %d, code = %d: %s", w.Code, resp.Code, w.Body)
+	}
+
+	o, err := orders.Get(context.Background(), "ORDER_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != payment.TradeStatusNotPay || len(o.Timeline) != 0 {
+		t.Fatalf("order changed: %+v", o)
+	}
+	select {
+	case event := <-received:
+		t.Fatalf("event delivered: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPayChecksEventURL(t *testing.T) {
+	router, _, _ := newEventsRouter(t, webhook.Policy{}, order.NewMemoryStore())
+
+	cases := []struct {
+		name   string
+		field  string // 为空时不传推送地址
+		url    string
+		status int
+		code   int
+	}{
+		{"public https", "event_url", "https://merchant.example.com/events", http.StatusOK, 0},
+		{"plain http", "event_url", "http://merchant.example.com/events", http.StatusBadRequest, 1005},
+		{"loopback", "event_url", "https://127.0.0.1/events", http.StatusBadRequest, 1005},
+		{"cloud metadata", "event_url", "https://169.254.169.254/latest/meta-data", http.StatusBadRequest, 1005},
+		{"deprecated notify_url", "notify_url", "https://merchant.example.com/events", http.StatusOK, 0},
+		{"deprecated notify_url checked", "notify_url", "https://127.0.0.1/events", http.StatusBadRequest, 1005},
+		{"missing", "", "", http.StatusBadRequest, 1005},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"channel":"wechat","out_trade_no":"ORDER_` + string(rune('A'+i)) + `","total_amount":0.01,"subject":"test","scene":"native"`
+			if tc.field != "" {
+				body += `,"` + tc.field + `":"` + tc.url + `"`
+			}
+			body += `}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/pay", strings.NewReader(body))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var resp PayResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.status || resp.Code != tc.code {
+				t.Fatalf("status = %d, code = %d, want %d, %d: %s", w.Code, resp.Code, tc.status, tc.code, w.Body)
+			}
+		})
+	}
+}
+
+// TestNotifyIgnoresStaleStatus 迟到的通知不回退订单状态，只记录在时间线中，也不推送事件
+func TestNotifyIgnoresStaleStatus(t *testing.T) {
+	orders := order.NewMemoryStore()
+	router, merchant, received := newEventsRouter(t, webhook.Policy{Schemes: []string{"http"}, AllowPrivate: true}, orders)
+	newNotifyOrder(t, orders, 0.01, merchant.URL)
+	if _, err := order.RecordEvent(context.Background(), orders, "ORDER_1", order.Event{Type: order.EventRefund, To: payment.TradeStatusRefund}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/notify/wechat", strings.NewReader(`{"id":"EV-1"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	o, err := orders.Get(context.Background(), "ORDER_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := o.Timeline[len(o.Timeline)-1]
+	if o.Status != payment.TradeStatusRefund || last.Type != order.EventNotified || last.To != "" || last.Data["ignored_status"] != payment.TradeStatusSuccess {
+		t.Fatalf("order = %+v, last event = %+v", o, last)
+	}
+	select {
+	case event := <-received:
+		t.Fatalf("event delivered: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestNotifyDuplicate 渠道重试的重复通知只记录在时间线中，同一状态变化只推送一次事件
+func TestNotifyDuplicate(t *testing.T) {
+	orders := order.NewMemoryStore()
+	router, merchant, received := newEventsRouter(t, webhook.Policy{Schemes: []string{"http"}, AllowPrivate: true}, orders)
+	newNotifyOrder(t, orders, 0.01, merchant.URL)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/notify/wechat", strings.NewReader(`{"id":"EV-1"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("notify %d: status = %d: %s", i, w.Code, w.Body)
+		}
+	}
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	select {
+	case event := <-received:
+		t.Fatalf("duplicate event delivered: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	o, err := orders.Get(context.Background(), "ORDER_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notified int
+	for _, e := range o.Timeline {
+		if e.Type == order.EventNotified {
+			notified++
+		}
+	}
+	if o.Status != payment.TradeStatusSuccess || notified != 3 {
+		t.Fatalf("status = %s with %d notify events, want %s with 3", o.Status, notified, payment.TradeStatusSuccess)
+	}
+}
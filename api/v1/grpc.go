@@ -0,0 +1,392 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	paymentv1 "github.com/ymqzj/payment-gateway/api/proto/payment/v1"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorDomain gRPC 错误详情 ErrorInfo 的 domain
+const errorDomain = "payment-gateway"
+
+// GRPCServer gRPC 支付服务，与 HTTP 接口共用 PaymentHandler 的参数校验、下单流程和错误码
+type GRPCServer struct {
+	paymentv1.UnimplementedPaymentServiceServer
+	handler *PaymentHandler
+}
+
+// NewGRPCServer 创建 gRPC 支付服务
+func NewGRPCServer(handler *PaymentHandler) *GRPCServer {
+	return &GRPCServer{handler: handler}
+}
+
+// Pay 统一下单
+func (s *GRPCServer) Pay(ctx context.Context, in *paymentv1.PayRequest) (*paymentv1.PayResponse, error) {
+	req := &PayRequest{
+		Channel:     in.GetChannel(),
+		OutTradeNo:  in.GetOutTradeNo(),
+		TotalAmount: in.GetTotalAmount(),
+		Subject:     in.GetSubject(),
+		Scene:       in.GetScene(),
+		EventURL:    in.GetEventUrl(),
+		ReturnURL:   in.GetReturnUrl(),
+		OpenID:      in.GetOpenid(),
+		Attach:      in.GetAttach(),
+		App:         in.GetApp(),
+		MerchantID:  in.GetMerchantId(),
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	resp, decision, err := s.handler.pay(ctx, req, userAgentOf(ctx))
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	out := &paymentv1.PayResponse{
+		OrderId:    resp.OrderID,
+		OutTradeNo: resp.OutTradeNo,
+		QrCode:     resp.QRCode,
+		PayUrl:     resp.PayURL,
+		Channel:    string(resp.Channel),
+	}
+	if resp.PayData != nil {
+		if out.PayData, err = toValue(resp.PayData); err != nil {
+			return nil, grpcError(ctx, err)
+		}
+	}
+	if decision != nil {
+		if out.Route, err = toStruct(decision); err != nil {
+			return nil, grpcError(ctx, err)
+		}
+	}
+	return out, nil
+}
+
+// Query 查询订单
+func (s *GRPCServer) Query(ctx context.Context, in *paymentv1.QueryRequest) (*paymentv1.QueryResponse, error) {
+	req := &QueryRequest{
+		Channel:    in.GetChannel(),
+		OrderID:    in.GetOrderId(),
+		OutTradeNo: in.GetOutTradeNo(),
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	resp, err := s.handler.query(ctx, req)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	return &paymentv1.QueryResponse{
+		OrderId:     resp.OrderID,
+		OutTradeNo:  resp.OutTradeNo,
+		TradeStatus: string(resp.TradeStatus),
+		TotalAmount: resp.TotalAmount,
+		PayTime:     toTimestamp(resp.PayTime),
+		Channel:     string(resp.Channel),
+	}, nil
+}
+
+// Refund 退款
+func (s *GRPCServer) Refund(ctx context.Context, in *paymentv1.RefundRequest) (*paymentv1.RefundResponse, error) {
+	req := &RefundRequest{
+		Channel:      in.GetChannel(),
+		OrderID:      in.GetOrderId(),
+		OutTradeNo:   in.GetOutTradeNo(),
+		OutRefundNo:  in.GetOutRefundNo(),
+		RefundAmount: in.GetRefundAmount(),
+		TotalAmount:  in.GetTotalAmount(),
+		RefundReason: in.GetRefundReason(),
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	resp, err := s.handler.refund(ctx, req)
+	if err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	return &paymentv1.RefundResponse{
+		RefundId:     resp.RefundID,
+		OutRefundNo:  resp.OutRefundNo,
+		RefundAmount: resp.RefundAmount,
+		RefundStatus: resp.RefundStatus,
+		RefundTime:   toTimestamp(resp.RefundTime),
+		Channel:      string(resp.Channel),
+	}, nil
+}
+
+// Close 关闭订单
+func (s *GRPCServer) Close(ctx context.Context, in *paymentv1.CloseRequest) (*paymentv1.CloseResponse, error) {
+	req := &CloseRequest{
+		Channel:    in.GetChannel(),
+		OrderID:    in.GetOrderId(),
+		OutTradeNo: in.GetOutTradeNo(),
+	}
+	if err := validateRequest(req); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+
+	if err := s.handler.close(ctx, req); err != nil {
+		return nil, grpcError(ctx, err)
+	}
+	return &paymentv1.CloseResponse{}, nil
+}
+
+// GetChannels 当前可用的渠道及状态，与 HTTP 接口返回的内容一致
+func (s *GRPCServer) GetChannels(ctx context.Context, _ *paymentv1.GetChannelsRequest) (*paymentv1.GetChannelsResponse, error) {
+	gateway := s.handler.gateway
+
+	channels := gateway.GetSupportedChannels()
+	out := &paymentv1.GetChannelsResponse{Channels: make([]string, len(channels))}
+	for i, ch := range channels {
+		out.Channels[i] = string(ch)
+	}
+	sort.Strings(out.Channels)
+
+	for _, st := range gateway.GetChannelStatuses() {
+		item := &paymentv1.ChannelStatus{
+			Channel:   string(st.Channel),
+			State:     string(st.State),
+			Error:     st.Error,
+			Attempts:  int32(st.Attempts),
+			UpdatedAt: timestamppb.New(st.UpdatedAt),
+		}
+		if st.Breaker != nil {
+			breaker, err := toStruct(st.Breaker)
+			if err != nil {
+				return nil, grpcError(ctx, err)
+			}
+			item.Breaker = breaker
+		}
+		out.Status = append(out.Status, item)
+	}
+	return out, nil
+}
+
+// WatchOrder 订阅订单状态变化
+// 先推送订单当前状态和完整时间线，之后每次订单变更推送新状态和新增的事件，直到调用方取消或服务关闭
+func (s *GRPCServer) WatchOrder(in *paymentv1.WatchOrderRequest, stream paymentv1.PaymentService_WatchOrderServer) error {
+	ctx := stream.Context()
+	if in.GetOutTradeNo() == "" {
+		return grpcError(ctx, payment.NewErrorCodeWithDetails(payment.MissingParameter.Code, payment.MissingParameter.Message, "out_trade_no is required"))
+	}
+
+	watcher, ok := s.handler.orders.(order.Watcher)
+	if !ok {
+		return status.Error(codes.Unimplemented, "order store does not support watching")
+	}
+
+	// 先订阅再读取当前状态，避免两步之间的变更丢失
+	updates, cancel := watcher.Watch(in.GetOutTradeNo())
+	defer cancel()
+
+	current, err := s.handler.orders.Get(ctx, in.GetOutTradeNo())
+	if err != nil {
+		if errors.Is(err, order.ErrOrderNotFound) {
+			return grpcError(ctx, payment.OrderNotFound)
+		}
+		return grpcError(ctx, err)
+	}
+
+	// 凭证绑定商户时只能订阅本商户的订单
+	if credential := auth.FromContext(ctx); credential != nil && credential.MerchantID != "" && credential.MerchantID != current.MerchantID {
+		return grpcError(ctx, payment.Forbidden)
+	}
+
+	sent := 0
+	send := func(o *order.Order) error {
+		if sent > 0 && len(o.Timeline) <= sent {
+			return nil
+		}
+		update, err := toOrderUpdate(o, sent)
+		if err != nil {
+			return err
+		}
+		sent = len(o.Timeline)
+		return stream.Send(update)
+	}
+
+	if err := send(current); err != nil {
+		return grpcError(ctx, err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case o, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := send(o); err != nil {
+				return grpcError(ctx, err)
+			}
+		}
+	}
+}
+
+// toOrderUpdate 订单状态推送，只包含时间线中 from 之后的事件
+func toOrderUpdate(o *order.Order, from int) (*paymentv1.OrderUpdate, error) {
+	update := &paymentv1.OrderUpdate{
+		OutTradeNo:  o.OutTradeNo,
+		OrderId:     o.OrderID,
+		Channel:     string(o.Channel),
+		MerchantId:  o.MerchantID,
+		Status:      string(o.Status),
+		TotalAmount: o.TotalAmount,
+		PaidAt:      toTimestamp(o.PaidAt),
+		UpdatedAt:   timestamppb.New(o.UpdatedAt),
+	}
+	for _, event := range o.Timeline[from:] {
+		item := &paymentv1.OrderEvent{
+			Time:     timestamppb.New(event.Time),
+			Type:     event.Type,
+			From:     string(event.From),
+			To:       string(event.To),
+			Operator: event.Operator,
+			Message:  event.Message,
+		}
+		if len(event.Data) > 0 {
+			data, err := toStruct(event.Data)
+			if err != nil {
+				return nil, err
+			}
+			item.Data = data
+		}
+		update.Events = append(update.Events, item)
+	}
+	return update, nil
+}
+
+// validateRequest 按 binding 标签校验请求，与 HTTP 接口绑定请求体时的校验规则一致
+func validateRequest(req interface{}) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return invalidParameter(err)
+	}
+	return nil
+}
+
+// grpcError 将错误转换为 gRPC 状态，状态码由错误码对应的 HTTP 状态码决定
+// 错误码放在 ErrorInfo 详情中，原始错误只记录日志
+func grpcError(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := payment.ErrorCodeOf(err)
+	httpStatus := HTTPStatus(code)
+
+	// 参数校验等本地返回的错误码不记录日志，与 writeErrorData 一致
+	if _, local := err.(*payment.ErrorCode); !local {
+		log := logger.FromContext(ctx).Warn
+		if httpStatus >= http.StatusInternalServerError {
+			log = logger.FromContext(ctx).Error
+		}
+		log("request failed",
+			zap.String("code", code.Code),
+			zap.String("details", code.Details),
+			zap.Error(err))
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason: code.Code,
+		Domain: errorDomain,
+	}
+	if code.Details != "" {
+		info.Metadata = map[string]string{"details": code.Details}
+	}
+	details := []protoadapt.MessageV1{info}
+
+	var limited *payment.RateLimitError
+	if errors.As(err, &limited) && limited.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(limited.RetryAfter)})
+	}
+
+	st, detailErr := status.New(grpcCode(httpStatus), code.Message).WithDetails(details...)
+	if detailErr != nil {
+		return status.Error(grpcCode(httpStatus), code.Message)
+	}
+	return st.Err()
+}
+
+// grpcCode HTTP 状态码对应的 gRPC 状态码
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict, http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// toTimestamp 转换可选时间
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+// toStruct 按 JSON 序列化结果转换为 Struct，字段名与 HTTP 接口一致
+func toStruct(v interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := &structpb.Struct{}
+	if err := protojson.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// toValue 按 JSON 序列化结果转换为 Value
+func toValue(v interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := &structpb.Value{}
+	if err := protojson.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,384 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	paymentv1 "github.com/ymqzj/payment-gateway/api/proto/payment/v1"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/ratelimit"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// grpcMethod gRPC 方法的授权范围和限流接口名
+type grpcMethod struct {
+	scope    auth.Scope
+	public   bool   // 不需要签名的方法
+	endpoint string // rate_limit.endpoints 中的接口名，为空时不限流
+}
+
+// grpcMethods 按完整方法名配置的 gRPC 方法，与 HTTP 路由保持一致，两种协议共用 rate_limit.endpoints 中的限额
+// 不在表中的方法一律拒绝，新增方法时需在此声明授权范围或显式标记为 public
+var grpcMethods = map[string]grpcMethod{
+	paymentv1.PaymentService_Pay_FullMethodName:         {scope: auth.ScopePay, endpoint: "pay"},
+	paymentv1.PaymentService_Query_FullMethodName:       {scope: auth.ScopeQuery, endpoint: "query"},
+	paymentv1.PaymentService_Refund_FullMethodName:      {scope: auth.ScopeRefund, endpoint: "refund"},
+	paymentv1.PaymentService_Close_FullMethodName:       {scope: auth.ScopePay, endpoint: "close"},
+	paymentv1.PaymentService_WatchOrder_FullMethodName:  {scope: auth.ScopeQuery, endpoint: "watch_order"},
+	paymentv1.PaymentService_GetChannels_FullMethodName: {public: true},
+}
+
+// lookupMethod 查找方法配置，未声明的方法返回 PermissionDenied
+func lookupMethod(ctx context.Context, fullMethod string) (grpcMethod, error) {
+	method, ok := grpcMethods[fullMethod]
+	if !ok {
+		logger.FromContext(ctx).Warn("method not allowed", zap.String("method", fullMethod))
+		return method, grpcError(ctx, payment.NewErrorCodeWithDetails(payment.Forbidden.Code, payment.Forbidden.Message,
+			"method is not exposed: "+fullMethod))
+	}
+	return method, nil
+}
+
+// GRPCRecovery 捕获 gRPC 处理器的 panic，记录日志后返回 Internal
+func GRPCRecovery() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	recovered := func(ctx context.Context, method string, err *error) {
+		if r := recover(); r != nil {
+			logger.FromContext(ctx).Error("panic recovered",
+				zap.Any("panic", r),
+				zap.String("method", method))
+			*err = status.Error(codes.Internal, payment.InternalServerError.Message)
+		}
+	}
+
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recovered(ctx, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recovered(ss.Context(), info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+	return unary, stream
+}
+
+// GRPCLogger 与 RequestLogger 相同：从 metadata 读取或生成请求 ID，放入带请求 ID 的日志，调用结束时记录访问日志
+// 请求 ID 通过响应 header 返回
+func GRPCLogger() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = withRequestLogger(ctx)
+		resp, err := handler(ctx, req)
+		logAccess(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := withRequestLogger(ss.Context())
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logAccess(ctx, info.FullMethod, start, err)
+		return err
+	}
+	return unary, stream
+}
+
+// withRequestLogger 设置请求 ID 并将带请求 ID 的日志放入上下文
+func withRequestLogger(ctx context.Context) context.Context {
+	requestID := metadataValue(ctx, logger.HeaderRequestID)
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = newRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logger.HeaderRequestID, requestID))
+
+	return logger.WithLogger(ctx, logger.GetLogger().With(zap.String("request_id", requestID)))
+}
+
+// logAccess 记录 gRPC 访问日志，服务端错误记录为 error，调用方错误记录为 warn
+func logAccess(ctx context.Context, method string, start time.Time, err error) {
+	st := status.Convert(err)
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("code", st.Code().String()),
+		zap.Duration("latency", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields = append(fields, zap.String("client_ip", p.Addr.String()))
+	}
+	if credential := auth.FromContext(ctx); credential != nil {
+		fields = append(fields, zap.String("app_key", credential.AppKey))
+	}
+
+	access := logger.FromContext(ctx).WithOptions(zap.AddStacktrace(zapcore.FatalLevel))
+	switch st.Code() {
+	case codes.OK:
+		access.Info("grpc request", fields...)
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DeadlineExceeded, codes.Unimplemented:
+		access.Error("grpc request", fields...)
+	default:
+		access.Warn("grpc request", fields...)
+	}
+}
+
+// GRPCAuth 校验请求签名和授权范围，authenticator 为空时不鉴权，但未在 grpcMethods 中声明的方法始终拒绝
+// 签名规则与 HTTP 接口相同：鉴权信息放在 metadata 中（x-app-key 等），method 固定为 POST，
+// path 为 gRPC 完整方法名（如 /payment.v1.PaymentService/Pay），body 为请求消息的规范编码（见 canonicalBody）
+// 流式方法在收到第一条请求消息时鉴权
+func GRPCAuth(authenticator *auth.Authenticator) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, authenticator, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method, err := lookupMethod(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if authenticator == nil || method.public {
+			return handler(srv, ss)
+		}
+		return handler(srv, &authStream{
+			serverStream:  &serverStream{ServerStream: ss, ctx: ss.Context()},
+			authenticator: authenticator,
+			method:        info.FullMethod,
+		})
+	}
+	return unary, stream
+}
+
+// authenticate 校验签名并将凭证放入上下文
+func authenticate(ctx context.Context, authenticator *auth.Authenticator, fullMethod string, req interface{}) (context.Context, error) {
+	method, err := lookupMethod(ctx, fullMethod)
+	if err != nil {
+		return ctx, err
+	}
+	if authenticator == nil || method.public {
+		return ctx, nil
+	}
+	scope := method.scope
+
+	var body []byte
+	if msg, ok := req.(proto.Message); ok {
+		if body, err = canonicalBody(msg); err != nil {
+			return ctx, grpcError(ctx, payment.NewErrorCodeWithDetails(payment.BadRequest.Code, payment.BadRequest.Message, err.Error()))
+		}
+	}
+
+	r := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: fullMethod},
+		Header: http.Header{},
+	}
+	for _, key := range []string{auth.HeaderAppKey, auth.HeaderTimestamp, auth.HeaderNonce, auth.HeaderSignature} {
+		r.Header.Set(key, metadataValue(ctx, key))
+	}
+
+	credential, err := authenticator.Verify(r, body)
+	if err != nil {
+		logger.FromContext(ctx).Warn("authentication failed",
+			zap.String("method", fullMethod),
+			zap.String("app_key", r.Header.Get(auth.HeaderAppKey)),
+			zap.Error(err))
+		return ctx, grpcError(ctx, authErrorCode(err))
+	}
+
+	if !credential.Allows(scope) {
+		logger.FromContext(ctx).Warn("scope denied",
+			zap.String("method", fullMethod),
+			zap.String("app_key", credential.AppKey),
+			zap.String("scope", string(scope)))
+		return ctx, grpcError(ctx, payment.NewErrorCodeWithDetails(payment.Forbidden.Code, payment.Forbidden.Message,
+			auth.ErrScopeDenied.Error()+": "+string(scope)))
+	}
+
+	return auth.WithCredential(ctx, credential), nil
+}
+
+// canonicalBody 请求消息的规范编码，作为待签名串中的请求体，不依赖各语言 protobuf 库的序列化实现：
+// 按字段编号升序逐个编码取值不是默认值的字段（零值、空串不编码），每个字段为 tag 加值，
+// string/bytes 为 varint 长度前缀加原始字节，double/float 为小端定长，整数、bool 和枚举为 varint。
+// 对只含标量字段的消息，结果与 protobuf 标准二进制编码相同；包含未知字段、repeated、map 或嵌套消息时返回错误
+func canonicalBody(msg proto.Message) ([]byte, error) {
+	m := msg.ProtoReflect()
+	if len(m.GetUnknown()) > 0 {
+		return nil, errors.New("request contains unknown fields")
+	}
+
+	fields := m.Descriptor().Fields()
+	ordered := make([]protoreflect.FieldDescriptor, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		ordered = append(ordered, fields.Get(i))
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Number() < ordered[j].Number() })
+
+	var b []byte
+	for _, fd := range ordered {
+		if fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("field %s: repeated and map fields cannot be signed", fd.Name())
+		}
+		if !m.Has(fd) {
+			continue
+		}
+		v := m.Get(fd)
+		if (fd.Kind() == protoreflect.DoubleKind || fd.Kind() == protoreflect.FloatKind) && v.Float() == 0 {
+			continue // -0 与 0 相同，不编码
+		}
+		switch fd.Kind() {
+		case protoreflect.StringKind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+			b = protowire.AppendString(b, v.String())
+		case protoreflect.BytesKind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.BytesType)
+			b = protowire.AppendBytes(b, v.Bytes())
+		case protoreflect.DoubleKind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(v.Float()))
+		case protoreflect.FloatKind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.Fixed32Type)
+			b = protowire.AppendFixed32(b, math.Float32bits(float32(v.Float())))
+		case protoreflect.BoolKind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(v.Bool()))
+		case protoreflect.EnumKind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v.Enum()))
+		case protoreflect.Int32Kind, protoreflect.Int64Kind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v.Int()))
+		case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+			b = protowire.AppendTag(b, fd.Number(), protowire.VarintType)
+			b = protowire.AppendVarint(b, v.Uint())
+		default:
+			return nil, fmt.Errorf("field %s: %s fields cannot be signed", fd.Name(), fd.Kind())
+		}
+	}
+	return b, nil
+}
+
+// authStream 在收到第一条消息时鉴权的服务端流
+type authStream struct {
+	*serverStream
+	authenticator *auth.Authenticator
+	method        string
+	verified      bool
+}
+
+func (s *authStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.verified {
+		return nil
+	}
+
+	ctx, err := authenticate(s.ctx, s.authenticator, s.method, m)
+	if err != nil {
+		return err
+	}
+	s.ctx, s.verified = ctx, true
+	return nil
+}
+
+// GRPCRateLimit 入站限流，limits 为空时不限流
+// 需注册在 GRPCAuth 之后，与 HTTP 接口相同按 app key 计数，未鉴权的方法按客户端 IP 计数
+// 流式方法只在建立时计数一次
+func GRPCRateLimit(limits *ratelimit.Inbound) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := allowGRPC(ctx, limits, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if limits == nil {
+			return handler(srv, ss)
+		}
+		return handler(srv, &rateLimitStream{ServerStream: ss, limits: limits, method: info.FullMethod})
+	}
+	return unary, stream
+}
+
+// allowGRPC 检查调用方是否超过限额
+func allowGRPC(ctx context.Context, limits *ratelimit.Inbound, fullMethod string) error {
+	endpoint := grpcMethods[fullMethod].endpoint
+	if limits == nil || endpoint == "" {
+		return nil
+	}
+
+	key := "ip:"
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			key += host
+		}
+	}
+	if credential := auth.FromContext(ctx); credential != nil {
+		key = credential.AppKey
+	}
+
+	if err := limits.Allow(key, endpoint); err != nil {
+		return grpcError(ctx, err)
+	}
+	return nil
+}
+
+// rateLimitStream 在鉴权完成（收到第一条消息）后限流的服务端流
+type rateLimitStream struct {
+	grpc.ServerStream
+	limits  *ratelimit.Inbound
+	method  string
+	checked bool
+}
+
+func (s *rateLimitStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.checked {
+		return nil
+	}
+	s.checked = true
+	return allowGRPC(s.Context(), s.limits, s.method)
+}
+
+// serverStream 替换上下文的服务端流
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataValue 读取请求 metadata 中的第一个值，key 不区分大小写
+func metadataValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// userAgentOf 调用方的 User-Agent，用于路由规则
+func userAgentOf(ctx context.Context) string {
+	return metadataValue(ctx, "user-agent")
+}
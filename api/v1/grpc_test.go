@@ -0,0 +1,230 @@
+package v1
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	paymentv1 "github.com/ymqzj/payment-gateway/api/proto/payment/v1"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestGRPCMethods 服务的每个方法都必须声明授权范围或显式标记为 public
+func TestGRPCMethods(t *testing.T) {
+	desc := paymentv1.PaymentService_ServiceDesc
+	var names []string
+	for _, m := range desc.Methods {
+		names = append(names, m.MethodName)
+	}
+	for _, s := range desc.Streams {
+		names = append(names, s.StreamName)
+	}
+
+	for _, name := range names {
+		fullMethod := "/" + desc.ServiceName + "/" + name
+		method, ok := grpcMethods[fullMethod]
+		if !ok {
+			t.Errorf("%s is not declared in grpcMethods", fullMethod)
+			continue
+		}
+		if method.public == (method.scope != "") {
+			t.Errorf("%s: scope = %q, public = %v, want exactly one", fullMethod, method.scope, method.public)
+		}
+	}
+	if len(grpcMethods) != len(names) {
+		t.Errorf("grpcMethods has %d methods, service has %d", len(grpcMethods), len(names))
+	}
+}
+
+// TestCanonicalBody 规范编码按字段编号升序只编码非默认值，对请求消息与 protobuf 标准编码一致
+func TestCanonicalBody(t *testing.T) {
+	unknown := &paymentv1.QueryRequest{OutTradeNo: "O1"}
+	unknown.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 99, protowire.VarintType), 1))
+
+	cases := []struct {
+		name     string
+		msg      proto.Message
+		hex      string
+		standard bool // 与 proto.Marshal 结果相同
+		err      bool
+	}{
+		{"scalars", &paymentv1.PayRequest{Channel: "wechat", OutTradeNo: "O1", TotalAmount: 0.01},
+			"0a06776563686174" + "12024f31" + "197b14ae47e17a843f", true, false},
+		{"default values omitted", &paymentv1.QueryRequest{OutTradeNo: "O1"}, "1a024f31", true, false},
+		{"empty message", &paymentv1.GetChannelsRequest{}, "", true, false},
+		{"negative zero", &paymentv1.RefundRequest{OutRefundNo: "R1", RefundAmount: math.Copysign(0, -1)}, "2202" + "5231", false, false},
+		{"unknown fields", unknown, "", false, true},
+		{"repeated field", &paymentv1.GetChannelsResponse{Channels: []string{"wechat"}}, "", false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := canonicalBody(tc.msg)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("body = %x, want error", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(body); got != tc.hex {
+				t.Fatalf("body = %s, want %s", got, tc.hex)
+			}
+			if tc.standard {
+				standard, _ := proto.Marshal(tc.msg)
+				if hex.EncodeToString(standard) != tc.hex {
+					t.Fatalf("standard encoding = %x, want %s", standard, tc.hex)
+				}
+			}
+		})
+	}
+}
+
+// newGRPCClient 带鉴权拦截器的 gRPC 服务，订单与 newAuthRouter 相同
+func newGRPCClient(t *testing.T) paymentv1.PaymentServiceClient {
+	t.Helper()
+	_, handler := newAuthRouter(t)
+	authenticator := auth.NewAuthenticator(auth.NewStaticStore(testCredentials...), 5*time.Minute)
+	authUnary, authStream := GRPCAuth(authenticator)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(authUnary), grpc.ChainStreamInterceptor(authStream))
+	paymentv1.RegisterPaymentServiceServer(srv, NewGRPCServer(handler))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return paymentv1.NewPaymentServiceClient(conn)
+}
+
+// signGRPC 按 HTTP 接口的规则签名 gRPC 请求，请求体为客户端 protobuf 库的标准编码，appKey 为空时不签名
+func signGRPC(t *testing.T, appKey, fullMethod string, req proto.Message) context.Context {
+	t.Helper()
+	ctx := context.Background()
+	if appKey == "" {
+		return ctx
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := fmt.Sprintf("nonce-%d", nonceSeq.Add(1))
+	return metadata.NewOutgoingContext(ctx, metadata.Pairs(
+		auth.HeaderAppKey, appKey,
+		auth.HeaderTimestamp, timestamp,
+		auth.HeaderNonce, nonce,
+		auth.HeaderSignature, auth.Sign([]byte(appKey+"-secret"), "POST", fullMethod, timestamp, nonce, body),
+	))
+}
+
+func TestGRPCAuth(t *testing.T) {
+	client := newGRPCClient(t)
+	query := &paymentv1.QueryRequest{Channel: "wechat", OutTradeNo: "ORDER_M1"}
+	refund := &paymentv1.RefundRequest{Channel: "wechat", OutTradeNo: "ORDER_M1", OutRefundNo: "R1", RefundAmount: 0.01, TotalAmount: 0.01}
+	watch := &paymentv1.WatchOrderRequest{OutTradeNo: "ORDER_M1"}
+
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"signed", func() error {
+			_, err := client.Query(signGRPC(t, "ops", paymentv1.PaymentService_Query_FullMethodName, query), query)
+			return err
+		}, codes.OK},
+		{"unsigned", func() error {
+			_, err := client.Query(signGRPC(t, "", paymentv1.PaymentService_Query_FullMethodName, query), query)
+			return err
+		}, codes.Unauthenticated},
+		{"body changed after signing", func() error {
+			other := &paymentv1.QueryRequest{Channel: "wechat", OutTradeNo: "ORDER_M2"}
+			_, err := client.Query(signGRPC(t, "ops", paymentv1.PaymentService_Query_FullMethodName, query), other)
+			return err
+		}, codes.Unauthenticated},
+		{"signed for another method", func() error {
+			_, err := client.Close(signGRPC(t, "ops", paymentv1.PaymentService_Query_FullMethodName, query), &paymentv1.CloseRequest{Channel: "wechat", OutTradeNo: "ORDER_M1"})
+			return err
+		}, codes.Unauthenticated},
+		{"scope denied", func() error {
+			_, err := client.Refund(signGRPC(t, "reader", paymentv1.PaymentService_Refund_FullMethodName, refund), refund)
+			return err
+		}, codes.PermissionDenied},
+		{"public method", func() error {
+			_, err := client.GetChannels(context.Background(), &paymentv1.GetChannelsRequest{})
+			return err
+		}, codes.OK},
+		{"stream signed", func() error {
+			stream, err := client.WatchOrder(signGRPC(t, "reader", paymentv1.PaymentService_WatchOrder_FullMethodName, watch), watch)
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.OK},
+		{"stream unsigned", func() error {
+			stream, err := client.WatchOrder(context.Background(), watch)
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.Unauthenticated},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.call(); status.Code(err) != tc.code {
+				t.Fatalf("err = %v, want %s", err, tc.code)
+			}
+		})
+	}
+}
+
+// TestGRPCUndeclaredMethod 未在 grpcMethods 中声明的方法无论是否启用鉴权都拒绝
+func TestGRPCUndeclaredMethod(t *testing.T) {
+	const method = "/payment.v1.PaymentService/Debug"
+	authenticator := auth.NewAuthenticator(auth.NewStaticStore(testCredentials...), 5*time.Minute)
+
+	for _, a := range []*auth.Authenticator{authenticator, nil} {
+		unary, stream := GRPCAuth(a)
+		called := false
+		_, err := unary(context.Background(), &paymentv1.GetChannelsRequest{}, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})
+		if status.Code(err) != codes.PermissionDenied || called {
+			t.Fatalf("unary: err = %v, handler called = %v", err, called)
+		}
+
+		err = stream(nil, &serverStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: method, IsServerStream: true},
+			func(srv interface{}, ss grpc.ServerStream) error {
+				called = true
+				return nil
+			})
+		if status.Code(err) != codes.PermissionDenied || called {
+			t.Fatalf("stream: err = %v, handler called = %v", err, called)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/health"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler 存活和就绪检查处理器
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler 创建存活和就绪检查处理器
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Liveness 存活检查，进程能处理请求即返回 200
+// 不检查渠道和外部依赖，避免渠道故障导致实例被反复重启
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "ok",
+		Data: map[string]interface{}{
+			"status":    health.StatusUp,
+			"timestamp": time.Now().Unix(),
+		},
+	})
+}
+
+// Readiness 就绪检查，返回各渠道和外部依赖的检查结果
+// 必需渠道或依赖不可用、或没有任何可用渠道时返回 503，负载均衡据此摘除实例
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.checker.Ready(c.Request.Context())
+	if !report.Ready {
+		n, _ := strconv.Atoi(payment.ServiceUnavailable.Code)
+		c.JSON(http.StatusServiceUnavailable, PayResponse{
+			Code:    n,
+			Message: payment.ServiceUnavailable.Message,
+			Data:    reportData(report),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "ok",
+		Data:    reportData(report),
+	})
+}
+
+// reportData 就绪检查报告的响应数据
+func reportData(report health.Report) map[string]interface{} {
+	return map[string]interface{}{
+		"status":     report.Status,
+		"ready":      report.Ready,
+		"components": report.Components,
+		"timestamp":  report.Timestamp,
+	}
+}
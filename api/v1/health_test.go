@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/health"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name     string
+		adapters []payment.PaymentAdapter
+		status   int
+		code     int
+		ready    bool
+	}{
+		{"channel available", []payment.PaymentAdapter{&specAdapter{}}, http.StatusOK, 0, true},
+		{"no channel available", nil, http.StatusServiceUnavailable, 503, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gateway := payment.NewPaymentGateway(tc.adapters...)
+			checker := health.NewChecker(gateway, configs.HealthConfig{}, func() *configs.Config { return &configs.Config{} })
+			handler := NewHealthHandler(checker)
+			r := gin.New()
+			r.GET("/health/live", handler.Liveness)
+			r.GET("/health/ready", handler.Readiness)
+
+			// 存活检查不受渠道状态影响
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("liveness status = %d", w.Code)
+			}
+
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			var resp struct {
+				Code int `json:"code"`
+				Data struct {
+					Ready      bool               `json:"ready"`
+					Components []health.Component `json:"components"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.status || resp.Code != tc.code || resp.Data.Ready != tc.ready {
+				t.Fatalf("status = %d, code = %d, ready = %v: %s", w.Code, resp.Code, resp.Data.Ready, w.Body)
+			}
+			if len(resp.Data.Components) != 3 {
+				t.Fatalf("components = %+v, want one per channel", resp.Data.Components)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// requestIDPattern 调用方传入的请求 ID 格式，不符合时重新生成，避免日志注入
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestLogger 为每个请求生成请求 ID，将带请求 ID 的日志放入请求上下文，并在请求结束时记录访问日志
+// 处理器和网关通过 logger.FromContext 获取该日志
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(logger.HeaderRequestID)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(logger.HeaderRequestID, requestID)
+
+		log := logger.GetLogger().With(zap.String("request_id", requestID))
+		c.Request = c.Request.WithContext(logger.WithLogger(c.Request.Context(), log))
+
+		c.Next()
+
+		ctx := c.Request.Context()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("size", c.Writer.Size()),
+		}
+		if credential := auth.FromContext(ctx); credential != nil {
+			fields = append(fields, zap.String("app_key", credential.AppKey))
+		}
+
+		// 访问日志的调用栈没有排查价值
+		access := logger.FromContext(ctx).WithOptions(zap.AddStacktrace(zapcore.FatalLevel))
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			access.Error("request", fields...)
+		case status >= http.StatusBadRequest:
+			access.Warn("request", fields...)
+		default:
+			access.Info("request", fields...)
+		}
+	}
+}
+
+// Recovery 捕获处理器的 panic，记录日志（error 级别自带调用栈）后返回 500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered interface{}) {
+		logger.FromContext(c.Request.Context()).Error("panic recovered",
+			zap.Any("panic", recovered),
+			zap.String("path", c.Request.URL.Path))
+		writeErrorCode(c, payment.InternalServerError, nil)
+		c.Abort()
+	})
+}
+
+// newRequestID 生成请求 ID
+func newRequestID() string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// openAPISpec 公开接口的 OpenAPI 3 文档，与 PayRequest 等请求类型及处理器的响应由 openapi_test.go 校验一致
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func init() {
+	// 支付表单页面按字符串校验
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.PlainBodyDecoder)
+}
+
+// OpenAPI 公开接口的 OpenAPI 文档，用于发布接口描述和按文档校验请求、响应
+type OpenAPI struct {
+	doc     *openapi3.T
+	options *openapi3filter.Options
+
+	// mismatch 响应不符合文档时调用，默认记录日志
+	mismatch func(c *gin.Context, status int, err error)
+}
+
+// LoadOpenAPI 加载并校验内置的 OpenAPI 文档
+func LoadOpenAPI() (*OpenAPI, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(openAPISpec)
+	if err != nil {
+		return nil, fmt.Errorf("load openapi spec failed: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec: %w", err)
+	}
+
+	return &OpenAPI{
+		doc: doc,
+		options: &openapi3filter.Options{
+			// 签名由 RequireScope 校验，文档中的 security 仅用于说明
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			SkipSettingDefaults: true,
+		},
+		mismatch: logMismatch,
+	}, nil
+}
+
+// Serve 发布 OpenAPI 文档
+func (o *OpenAPI) Serve(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+}
+
+// Validate 按 OpenAPI 文档校验请求和响应的中间件，注册在鉴权和限流之后
+// 请求不符合文档时返回参数错误，不进入处理器；响应不符合文档时只记录日志，不影响返回给调用方的内容
+// 文档中没有描述的路由直接放行
+func (o *OpenAPI) Validate(requests, responses bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requests && !responses {
+			c.Next()
+			return
+		}
+
+		route := o.route(c)
+		if route == nil {
+			c.Next()
+			return
+		}
+
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options:    o.options,
+		}
+
+		if requests {
+			// 与 ShouldBindJSON 一致，未指定 Content-Type 时按 JSON 处理
+			if c.Request.ContentLength != 0 && c.GetHeader("Content-Type") == "" {
+				c.Request.Header.Set("Content-Type", gin.MIMEJSON)
+			}
+			if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+				writeErrorCode(c, invalidParameter(errors.New(specErrorDetails(err))), nil)
+				c.Abort()
+				return
+			}
+		}
+
+		if !responses {
+			c.Next()
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		output := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 w.Status(),
+			Header:                 w.Header(),
+			Options:                o.options,
+		}
+		if err := openapi3filter.ValidateResponse(c.Request.Context(), output.SetBodyBytes(w.body.Bytes())); err != nil {
+			o.mismatch(c, w.Status(), err)
+		}
+	}
+}
+
+// logMismatch 记录不符合文档的响应
+func logMismatch(c *gin.Context, status int, err error) {
+	logger.FromContext(c.Request.Context()).Warn("response does not match openapi spec",
+		zap.String("path", c.FullPath()),
+		zap.Int("status", status),
+		zap.String("details", specErrorDetails(err)))
+}
+
+// route 当前请求在文档中对应的操作，gin 路由参数 :name 对应文档中的 {name}
+func (o *OpenAPI) route(c *gin.Context) *routers.Route {
+	path := c.FullPath()
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	path = strings.Join(segments, "/")
+
+	item := o.doc.Paths.Value(path)
+	if item == nil {
+		return nil
+	}
+	operation := item.GetOperation(c.Request.Method)
+	if operation == nil {
+		return nil
+	}
+	return &routers.Route{
+		Spec:      o.doc,
+		Path:      path,
+		PathItem:  item,
+		Method:    c.Request.Method,
+		Operation: operation,
+	}
+}
+
+// specErrorDetails 校验错误的简短说明，不包含文档中的 schema
+func specErrorDetails(err error) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		// allOf 等组合 schema 的错误取最内层的原因
+		for inner := schemaErr; inner.Origin != nil && errors.As(inner.Origin, &inner); {
+			schemaErr = inner
+		}
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			return strings.Join(pointer, ".") + ": " + schemaErr.Reason
+		}
+		return schemaErr.Reason
+	}
+
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.Error()
+	}
+
+	var responseErr *openapi3filter.ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr.Reason
+	}
+	return err.Error()
+}
+
+// recordingWriter 写入响应的同时保留一份 body，用于响应校验
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
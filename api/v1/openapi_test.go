@@ -0,0 +1,338 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/routing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// TestOpenAPIRequestTypes 请求类型的 json 字段、binding 规则与文档中的请求 schema 一致
+func TestOpenAPIRequestTypes(t *testing.T) {
+	spec, err := LoadOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, typ := range map[string]interface{}{
+		"/api/v1/pay":           PayRequest{},
+		"/api/v1/query":         QueryRequest{},
+		"/api/v1/refund":        RefundRequest{},
+		"/api/v1/close":         CloseRequest{},
+		"/api/v1/route/explain": RouteRequest{},
+	} {
+		item := spec.doc.Paths.Value(path)
+		if item == nil || item.Post == nil || item.Post.RequestBody == nil {
+			t.Errorf("%s: no POST request body in spec", path)
+			continue
+		}
+		media := item.Post.RequestBody.Value.Content.Get(gin.MIMEJSON)
+		if media == nil {
+			t.Errorf("%s: no %s request body in spec", path, gin.MIMEJSON)
+			continue
+		}
+		checkSchema(t, path, reflect.TypeOf(typ), media.Schema.Value)
+	}
+}
+
+// checkSchema 对比结构体字段和 schema 属性
+func checkSchema(t *testing.T, path string, typ reflect.Type, schema *openapi3.Schema) {
+	t.Helper()
+
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	fields := make(map[string]bool, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		fields[name] = true
+
+		prop := schema.Properties[name]
+		if prop == nil {
+			t.Errorf("%s: field %s (%s) is not in spec", path, name, field.Name)
+			continue
+		}
+
+		want := map[reflect.Kind]string{reflect.String: openapi3.TypeString, reflect.Float64: openapi3.TypeNumber}[field.Type.Kind()]
+		if !prop.Value.Type.Is(want) {
+			t.Errorf("%s: field %s is %s in spec, want %s", path, name, prop.Value.Type, want)
+		}
+
+		rules := strings.Split(field.Tag.Get("binding"), ",")
+		isRequired := contains(rules, "required")
+		if isRequired != required[name] {
+			t.Errorf("%s: field %s required=%v in spec, binding required=%v", path, name, required[name], isRequired)
+		}
+		// binding 的 required 对字符串要求非空
+		if isRequired && field.Type.Kind() == reflect.String && prop.Value.MinLength != 1 {
+			t.Errorf("%s: field %s should have minLength 1", path, name)
+		}
+		if gt := contains(rules, "gt=0"); gt != (prop.Value.Min != nil && *prop.Value.Min == 0 && prop.Value.ExclusiveMin) {
+			t.Errorf("%s: field %s gt=0 binding and exclusive minimum 0 in spec differ", path, name)
+		}
+	}
+
+	for name := range schema.Properties {
+		if !fields[name] {
+			t.Errorf("%s: spec property %s has no field in %s", path, name, typ.Name())
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TestOpenAPIHandlers 处理器的响应符合文档，文档中的每个操作都有对应的路由并被覆盖
+func TestOpenAPIHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := LoadOpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.mismatch = func(c *gin.Context, status int, err error) {
+		t.Errorf("%s %s: response %d does not match spec: %s", c.Request.Method, c.Request.URL.Path, status, specErrorDetails(err))
+	}
+
+	router := newSpecRouter(spec)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   int
+	}{
+		{http.MethodPost, "/api/v1/pay", `{"channel":"wechat","out_trade_no":"ORDER_1","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`, http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"auto","out_trade_no":"ORDER_2","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`, http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"auto","out_trade_no":"ORDER_3","total_amount":0.01,"subject":"test","scene":"pc","event_url":"https://merchant.example.com/events"}`, http.StatusServiceUnavailable, 2004},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"paypal","out_trade_no":"ORDER_4","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`, http.StatusBadRequest, 1001},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"wechat","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`, http.StatusBadRequest, 1005},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"wechat","out_trade_no":"ORDER_5","total_amount":0,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`, http.StatusBadRequest, 1005},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"wechat","out_trade_no":"ORDER_6","total_amount":0.01,"subject":"test","scene":"native","notify_url":"https://merchant.example.com/events"}`, http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/pay", `{"channel":"wechat","out_trade_no":"ORDER_7","total_amount":0.01,"subject":"test","scene":"native"}`, http.StatusBadRequest, 1005},
+		{http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_1"}`, http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/refund", `{"channel":"wechat","out_trade_no":"ORDER_1","out_refund_no":"REFUND_1","refund_amount":0.01,"total_amount":0.01}`, http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/refund", `{"channel":"wechat","out_trade_no":"ORDER_1","out_refund_no":"DENIED","refund_amount":0.01,"total_amount":0.01}`, http.StatusConflict, 1010},
+		{http.MethodPost, "/api/v1/close", `{"channel":"wechat","out_trade_no":"ORDER_1"}`, http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/route/explain", `{"scene":"native","total_amount":0.01}`, http.StatusOK, 0},
+		{http.MethodGet, "/api/v1/channels", "", http.StatusOK, 0},
+		{http.MethodGet, "/api/v1/health", "", http.StatusOK, 0},
+		{http.MethodGet, "/api/v1/openapi.json", "", http.StatusOK, -1},
+		{http.MethodGet, "/api/v1/pay/form/wechat/0123456789abcdef0123456789abcdef", "", http.StatusOK, -1},
+		{http.MethodPost, "/api/v1/return/wechat", "out_trade_no=ORDER_1", http.StatusOK, 0},
+		{http.MethodPost, "/api/v1/return/wechat", "out_trade_no=ORDER_1&redirect=1", http.StatusFound, -1},
+		{http.MethodPost, "/api/v1/notify/wechat", `{"id":"EV-1"}`, http.StatusOK, 0},
+	}
+
+	covered := make(map[string]bool)
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		switch {
+		case strings.Contains(tc.path, "/return/"):
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		case tc.body != "":
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s %s: status %d, want %d: %s", tc.method, tc.path, tc.body, w.Code, tc.status, w.Body)
+			continue
+		}
+		if tc.code >= 0 {
+			var resp PayResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != tc.code {
+				t.Errorf("%s %s %s: code %d, want %d: %s", tc.method, tc.path, tc.body, resp.Code, tc.code, w.Body)
+			}
+		}
+		covered[tc.method+" "+specPath(router, tc.method, tc.path)] = true
+	}
+
+	// 文档中的操作与路由一一对应
+	routes := make(map[string]bool)
+	for _, r := range router.Routes() {
+		routes[r.Method+" "+toSpecPath(r.Path)] = true
+	}
+	for path, item := range spec.doc.Paths.Map() {
+		for method := range item.Operations() {
+			key := method + " " + path
+			if !routes[key] {
+				t.Errorf("%s is in spec but has no route", key)
+			}
+			if !covered[key] {
+				t.Errorf("%s is not covered by the test", key)
+			}
+			delete(routes, key)
+		}
+	}
+	for key := range routes {
+		t.Errorf("%s has a route but is not in spec", key)
+	}
+}
+
+// newSpecRouter 与 cmd/server 相同的 /api/v1 路由，不鉴权、不限流
+// 渠道通知和同步跳转只校验响应，与服务中不校验其请求的做法一致
+func newSpecRouter(spec *OpenAPI) *gin.Engine {
+	gateway := payment.NewPaymentGateway(&specAdapter{})
+	router := routing.NewEngine(configs.RoutingConfig{
+		Default: []string{"wechat"},
+		Rules: []configs.RoutingRule{
+			{Name: "pc", Scenes: []string{"pc"}, Channels: []string{"unionpay"}},
+		},
+	}, gateway)
+	handler := NewPaymentHandler(gateway, router, order.NewMemoryStore(), nil, nil)
+
+	validate := spec.Validate(true, true)
+	responses := spec.Validate(false, true)
+
+	r := gin.New()
+	v1 := r.Group("/api/v1")
+	v1.POST("/pay", validate, handler.Pay)
+	v1.POST("/query", validate, handler.Query)
+	v1.POST("/refund", validate, handler.Refund)
+	v1.POST("/close", validate, handler.Close)
+	v1.GET("/channels", validate, handler.GetChannels)
+	v1.GET("/health", validate, handler.Health)
+	v1.GET("/openapi.json", validate, spec.Serve)
+	v1.POST("/route/explain", validate, handler.ExplainRoute)
+	v1.GET("/pay/form/:channel/:token", validate, handler.PayForm)
+	v1.POST("/return/:channel", responses, handler.HandleReturn)
+	v1.POST("/notify/:channel", responses, handler.HandleNotify)
+	return r
+}
+
+// specPath 请求匹配的路由在文档中的路径
+func specPath(router *gin.Engine, method, path string) string {
+	for _, r := range router.Routes() {
+		if r.Method == method && matchRoute(r.Path, path) {
+			return toSpecPath(r.Path)
+		}
+	}
+	return path
+}
+
+// matchRoute 路由模板是否匹配请求路径
+func matchRoute(route, path string) bool {
+	routeSegments, pathSegments := strings.Split(route, "/"), strings.Split(path, "/")
+	if len(routeSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range routeSegments {
+		if !strings.HasPrefix(segment, ":") && segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// toSpecPath gin 路由参数 :name 转换为文档中的 {name}
+func toSpecPath(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// specAdapter 返回固定结果的微信渠道
+type specAdapter struct{}
+
+func (a *specAdapter) GetChannel() payment.ChannelType {
+	return payment.ChannelWechat
+}
+
+func (a *specAdapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	return &payment.UnifiedPayResponse{
+		OrderID:    "WX_" + req.OutTradeNo,
+		OutTradeNo: req.OutTradeNo,
+		PayData:    map[string]interface{}{"code_url": "weixin://wxpay/bizpayurl?pr=test"},
+		QRCode:     "weixin://wxpay/bizpayurl?pr=test",
+		Channel:    payment.ChannelWechat,
+	}, nil
+}
+
+func (a *specAdapter) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
+	now := time.Now()
+	return &payment.NotifyResult{
+		Success:     true,
+		OutTradeNo:  "ORDER_1",
+		TotalAmount: 0.01,
+		TradeStatus: string(payment.TradeStatusSuccess),
+		Channel:     payment.ChannelWechat,
+		OrderID:     "WX_ORDER_1",
+		PayTime:     &now,
+	}, nil
+}
+
+func (a *specAdapter) Query(ctx context.Context, req *payment.QueryRequest) (*payment.QueryResponse, error) {
+	now := time.Now()
+	return &payment.QueryResponse{
+		OrderID:     "WX_" + req.OutTradeNo,
+		OutTradeNo:  req.OutTradeNo,
+		TradeStatus: payment.TradeStatusSuccess,
+		TotalAmount: 0.01,
+		PayTime:     &now,
+		Channel:     payment.ChannelWechat,
+	}, nil
+}
+
+func (a *specAdapter) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResponse, error) {
+	if req.OutRefundNo == "DENIED" {
+		return nil, payment.RefundNotAllowed
+	}
+	return &payment.RefundResponse{
+		RefundID:     "WXR_" + req.OutRefundNo,
+		OutRefundNo:  req.OutRefundNo,
+		RefundAmount: req.RefundAmount,
+		RefundStatus: "PROCESSING",
+		Channel:      payment.ChannelWechat,
+	}, nil
+}
+
+func (a *specAdapter) Close(ctx context.Context, req *payment.CloseRequest) error {
+	return nil
+}
+
+func (a *specAdapter) GetPayForm(ctx context.Context, token string) (string, error) {
+	return `<form action="https://example.com/pay" method="post"></form>`, nil
+}
+
+func (a *specAdapter) HandleReturn(ctx context.Context, data []byte) (*payment.ReturnResult, error) {
+	result := &payment.ReturnResult{NotifyResult: payment.NotifyResult{
+		Success:     true,
+		OutTradeNo:  "ORDER_1",
+		TotalAmount: 0.01,
+		TradeStatus: string(payment.TradeStatusSuccess),
+		Channel:     payment.ChannelWechat,
+		OrderID:     "WX_ORDER_1",
+	}}
+	if strings.Contains(string(data), "redirect=1") {
+		result.ReturnURL = "https://merchant.example.com/return"
+	}
+	return result, nil
+}
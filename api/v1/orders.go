@@ -0,0 +1,331 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSearchLimit 订单搜索默认每页条数
+	defaultSearchLimit = 20
+	// maxSearchLimit 订单搜索每页最大条数
+	maxSearchLimit = 200
+)
+
+// OrderAdminHandler 订单运维处理器：搜索订单、查看时间线、与渠道同步、关单和重推商户事件
+type OrderAdminHandler struct {
+	gateway *payment.PaymentGateway
+	orders  order.Store
+	events  *webhook.Dispatcher
+}
+
+// NewOrderAdminHandler 创建订单运维处理器，events 为空时不支持重推商户事件
+func NewOrderAdminHandler(gateway *payment.PaymentGateway, orders order.Store, events *webhook.Dispatcher) *OrderAdminHandler {
+	return &OrderAdminHandler{
+		gateway: gateway,
+		orders:  orders,
+		events:  events,
+	}
+}
+
+// Search 按商户订单号、渠道订单号、下单时间范围、状态、渠道和商户搜索订单，按下单时间倒序分页
+// 时间参数支持 RFC3339 和 2006-01-02 格式，from 含、to 不含；列表不返回时间线
+func (h *OrderAdminHandler) Search(c *gin.Context) {
+	filter, err := parseFilter(c)
+	if err != nil {
+		writeErrorCode(c, invalidParameter(err), nil)
+		return
+	}
+	// 凭证绑定商户时只能搜索本商户的订单
+	merchantID, ok := merchantOf(c.Request.Context(), filter.MerchantID)
+	if !ok {
+		writeErrorCode(c, payment.Forbidden, nil)
+		return
+	}
+	filter.MerchantID = merchantID
+
+	orders, total, err := h.orders.Search(c.Request.Context(), filter)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("search orders failed", zap.Error(err))
+		writeErrorCode(c, payment.InternalServerError, nil)
+		return
+	}
+	for _, o := range orders {
+		o.Timeline = nil
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"orders": orders,
+			"total":  total,
+			"offset": filter.Offset,
+			"limit":  filter.Limit,
+		},
+	})
+}
+
+// parseFilter 解析搜索参数
+func parseFilter(c *gin.Context) (order.Filter, error) {
+	filter := order.Filter{
+		OutTradeNo: c.Query("out_trade_no"),
+		OrderID:    c.Query("order_id"),
+		Channel:    payment.ChannelType(c.Query("channel")),
+		Status:     payment.TradeStatus(c.Query("status")),
+		MerchantID: c.Query("merchant_id"),
+		Limit:      defaultSearchLimit,
+	}
+	if filter.Channel != "" && !filter.Channel.IsValid() {
+		return filter, fmt.Errorf("invalid channel %q", filter.Channel)
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, fmt.Errorf("invalid status %q", filter.Status)
+	}
+
+	var err error
+	if filter.From, err = parseTime(c.Query("from")); err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	if filter.To, err = parseTime(c.Query("to")); err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if value := c.Query("offset"); value != "" {
+		if filter.Offset, err = strconv.Atoi(value); err != nil || filter.Offset < 0 {
+			return filter, fmt.Errorf("invalid offset %q", value)
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit <= 0 || filter.Limit > maxSearchLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+	}
+	return filter, nil
+}
+
+// parseTime 解析 RFC3339 或日期格式的时间，日期按服务器本地时区的零点计
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+// Get 查看订单详情，包含完整时间线
+func (h *OrderAdminHandler) Get(c *gin.Context) {
+	o, ok := h.load(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"order": o,
+		},
+	})
+}
+
+// Timeline 查看订单时间线：状态变化、渠道通知、退款、关单和商户事件投递
+func (h *OrderAdminHandler) Timeline(c *gin.Context) {
+	o, ok := h.load(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"out_trade_no": o.OutTradeNo,
+			"status":       o.Status,
+			"timeline":     o.Timeline,
+		},
+	})
+}
+
+// Sync 查询渠道并以渠道状态更新订单，用于通知丢失或延迟时手动补单
+// 与支付通知一致，渠道返回支付成功但金额与订单金额不一致时拒绝补单，订单不变
+func (h *OrderAdminHandler) Sync(c *gin.Context) {
+	o, ok := h.load(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp, err := h.gateway.Query(ctx, &payment.QueryRequest{
+		Channel:    o.Channel,
+		OrderID:    o.OrderID,
+		OutTradeNo: o.OutTradeNo,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	outTradeNo, channel := o.OutTradeNo, o.Channel
+	o, err = h.orders.Update(ctx, outTradeNo, func(o *order.Order) error {
+		if resp.TradeStatus == payment.TradeStatusSuccess && !sameAmount(resp.TotalAmount, o.TotalAmount) {
+			return fmt.Errorf("%w: query %.2f, order %.2f", errAmountMismatch, resp.TotalAmount, o.TotalAmount)
+		}
+		if o.OrderID == "" {
+			o.OrderID = resp.OrderID
+		}
+		event := order.Event{
+			Type:     order.EventSynced,
+			Operator: operatorOf(ctx),
+			Data: map[string]interface{}{
+				"trade_status": resp.TradeStatus,
+				"order_id":     resp.OrderID,
+				"total_amount": resp.TotalAmount,
+			},
+		}
+		if resp.TradeStatus.IsValid() {
+			event.To = resp.TradeStatus
+		}
+		// 支付时间以渠道为准，只在本次补单将订单更新为支付成功时写入
+		if o.Record(event) && o.Status == payment.TradeStatusSuccess && resp.PayTime != nil {
+			o.PaidAt = resp.PayTime
+		}
+		return nil
+	})
+	if errors.Is(err, errAmountMismatch) {
+		logger.FromContext(ctx).Error("reject sync: amount mismatch",
+			zap.String("out_trade_no", outTradeNo),
+			zap.String("channel", string(channel)),
+			zap.Error(err))
+		writeErrorCode(c, payment.NewErrorCodeWithDetails(payment.InvalidAmount.Code, payment.InvalidAmount.Message, err.Error()), nil)
+		return
+	}
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"order": o,
+		},
+	})
+}
+
+// Close 在渠道关闭订单并更新订单状态
+func (h *OrderAdminHandler) Close(c *gin.Context) {
+	o, ok := h.load(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.gateway.Close(ctx, &payment.CloseRequest{
+		Channel:    o.Channel,
+		OrderID:    o.OrderID,
+		OutTradeNo: o.OutTradeNo,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	o, err = order.RecordEvent(ctx, h.orders, o.OutTradeNo, order.Event{
+		Type:     order.EventClosed,
+		To:       payment.TradeStatusClosed,
+		Operator: operatorOf(ctx),
+	})
+	if err != nil {
+		writeOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"order": o,
+		},
+	})
+}
+
+// Redeliver 按订单当前状态重新推送 payment.updated 事件到商户，投递结果记录在时间线中
+func (h *OrderAdminHandler) Redeliver(c *gin.Context) {
+	if h.events == nil {
+		writeErrorCode(c, payment.NewErrorCodeWithDetails(payment.NotFound.Code, payment.NotFound.Message, "merchant events are disabled"), nil)
+		return
+	}
+
+	o, ok := h.load(c)
+	if !ok {
+		return
+	}
+	if o.EventURL == "" {
+		writeErrorCode(c, payment.NewErrorCodeWithDetails(payment.UnprocessableEntity.Code, payment.UnprocessableEntity.Message, "order has no event_url"), nil)
+		return
+	}
+
+	eventID, err := dispatchPaymentEvent(h.events, o, &webhook.PaymentData{
+		OutTradeNo:  o.OutTradeNo,
+		OrderID:     o.OrderID,
+		MerchantID:  o.MerchantID,
+		Channel:     o.Channel,
+		Success:     o.Status == payment.TradeStatusSuccess,
+		TradeStatus: string(o.Status),
+		TotalAmount: o.TotalAmount,
+		PayTime:     o.PaidAt,
+	})
+	if errors.Is(err, webhook.ErrURLNotAllowed) {
+		writeErrorCode(c, payment.NewErrorCodeWithDetails(payment.UnprocessableEntity.Code, payment.UnprocessableEntity.Message, err.Error()), nil)
+		return
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, PayResponse{
+		Code:    0,
+		Message: "accepted",
+		Data: map[string]interface{}{
+			"event_id":  eventID,
+			"event_url": o.EventURL,
+		},
+	})
+}
+
+// load 按路径参数加载订单，失败时写入错误响应；凭证绑定商户时不能访问其他商户的订单
+func (h *OrderAdminHandler) load(c *gin.Context) (*order.Order, bool) {
+	o, err := h.orders.Get(c.Request.Context(), c.Param("out_trade_no"))
+	if err != nil {
+		writeOrderError(c, err)
+		return nil, false
+	}
+	if !ownsOrder(c.Request.Context(), o) {
+		writeErrorCode(c, payment.Forbidden, nil)
+		return nil, false
+	}
+	return o, true
+}
+
+// writeOrderError 订单存储错误响应，订单存储的 ErrOrderNotFound 与渠道返回的订单不存在使用同一错误码
+func writeOrderError(c *gin.Context, err error) {
+	if errors.Is(err, order.ErrOrderNotFound) {
+		writeErrorCode(c, payment.OrderNotFound, nil)
+		return
+	}
+	logger.FromContext(c.Request.Context()).Error("load order failed", zap.Error(err))
+	writeErrorCode(c, payment.InternalServerError, nil)
+}
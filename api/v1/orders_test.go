@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryAdapter 查询返回预设状态的渠道，err 不为空时查询和关单都返回 err
+type queryAdapter struct {
+	specAdapter
+	channel payment.ChannelType
+	status  payment.TradeStatus
+	err     error
+}
+
+func (a *queryAdapter) GetChannel() payment.ChannelType {
+	return a.channel
+}
+
+func (a *queryAdapter) Query(ctx context.Context, req *payment.QueryRequest) (*payment.QueryResponse, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	resp := &payment.QueryResponse{OutTradeNo: req.OutTradeNo, TradeStatus: a.status, TotalAmount: 0.01}
+	if a.status == payment.TradeStatusSuccess {
+		resp.PayTime = &channelPayTime
+	}
+	return resp, nil
+}
+
+var (
+	// channelPayTime 渠道查询返回的支付时间
+	channelPayTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	// orderPaidAt 已支付订单记录的支付时间
+	orderPaidAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func (a *queryAdapter) Close(ctx context.Context, req *payment.CloseRequest) error {
+	return a.err
+}
+
+// TestOrderAdmin 补单不会回退已支付的订单，支付成功但金额不一致时拒绝补单，渠道不支持查询或关单时拒绝操作，订单不变
+// 支付时间只在补单将订单更新为支付成功时取渠道返回的时间
+func TestOrderAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name    string
+		action  string
+		channel payment.ChannelType
+		current payment.TradeStatus // 操作前的订单状态
+		amount  float64             // 订单金额，渠道查询返回 0.01
+		query   payment.TradeStatus // 渠道查询返回的状态
+		status  int
+		code    int
+		want    payment.TradeStatus
+		events  int
+		paidAt  *time.Time // 操作后的支付时间
+	}{
+		{"sync paid", "sync", payment.ChannelWechat, payment.TradeStatusNotPay, 0.01, payment.TradeStatusSuccess, http.StatusOK, 0, payment.TradeStatusSuccess, 1, &channelPayTime},
+		{"sync amount mismatch", "sync", payment.ChannelWechat, payment.TradeStatusNotPay, 0.02, payment.TradeStatusSuccess, http.StatusBadRequest, 1003, payment.TradeStatusNotPay, 0, nil},
+		{"sync not paid amount ignored", "sync", payment.ChannelWechat, payment.TradeStatusNotPay, 0.02, payment.TradeStatusClosed, http.StatusOK, 0, payment.TradeStatusClosed, 1, nil},
+		{"sync stale status", "sync", payment.ChannelWechat, payment.TradeStatusSuccess, 0.01, payment.TradeStatusNotPay, http.StatusOK, 0, payment.TradeStatusSuccess, 1, &orderPaidAt},
+		{"sync paid again", "sync", payment.ChannelWechat, payment.TradeStatusSuccess, 0.01, payment.TradeStatusSuccess, http.StatusOK, 0, payment.TradeStatusSuccess, 1, &orderPaidAt},
+		{"sync not supported", "sync", payment.ChannelUnionPay, payment.TradeStatusNotPay, 0.01, "", http.StatusNotImplemented, 1012, payment.TradeStatusNotPay, 0, nil},
+		{"close", "close", payment.ChannelWechat, payment.TradeStatusNotPay, 0.01, "", http.StatusOK, 0, payment.TradeStatusClosed, 1, nil},
+		{"close paid", "close", payment.ChannelWechat, payment.TradeStatusSuccess, 0.01, "", http.StatusOK, 0, payment.TradeStatusSuccess, 1, &orderPaidAt},
+		{"close not supported", "close", payment.ChannelUnionPay, payment.TradeStatusNotPay, 0.01, "", http.StatusNotImplemented, 1012, payment.TradeStatusNotPay, 0, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			adapter := &queryAdapter{channel: tc.channel, status: tc.query}
+			if tc.channel == payment.ChannelUnionPay {
+				adapter.err = fmt.Errorf("unionpay %s: %w", tc.action, payment.ErrNotSupported)
+			}
+			created := &order.Order{OutTradeNo: "ORDER_1", Channel: tc.channel, TotalAmount: tc.amount, Status: tc.current}
+			if tc.current == payment.TradeStatusSuccess {
+				created.PaidAt = &orderPaidAt
+			}
+			orders := order.NewMemoryStore()
+			if err := orders.Create(context.Background(), created); err != nil {
+				t.Fatal(err)
+			}
+			handler := NewOrderAdminHandler(payment.NewPaymentGateway(adapter), orders, nil)
+			r := gin.New()
+			r.POST("/admin/v1/orders/:out_trade_no/sync", handler.Sync)
+			r.POST("/admin/v1/orders/:out_trade_no/close", handler.Close)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/v1/orders/ORDER_1/"+tc.action, nil))
+			var resp PayResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.status || resp.Code != tc.code {
+				t.Fatalf("status = %d, code = %d, want %d, %d: %s", w.Code, resp.Code, tc.status, tc.code, w.Body)
+			}
+
+			o, err := orders.Get(context.Background(), "ORDER_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o.Status != tc.want || len(o.Timeline) != tc.events {
+				t.Fatalf("order status = %s with %d events, want %s with %d", o.Status, len(o.Timeline), tc.want, tc.events)
+			}
+			if (o.PaidAt == nil) != (tc.paidAt == nil) || o.PaidAt != nil && !o.PaidAt.Equal(*tc.paidAt) {
+				t.Fatalf("paid at = %v, want %v", o.PaidAt, tc.paidAt)
+			}
+		})
+	}
+}
+
+// TestAdminRequiresAuth 未启用鉴权时运维接口一律返回 403，其他接口不受影响
+func TestAdminRequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	orders := order.NewMemoryStore()
+	if err := orders.Create(context.Background(), &order.Order{OutTradeNo: "ORDER_1", Channel: payment.ChannelWechat, Status: payment.TradeStatusNotPay}); err != nil {
+		t.Fatal(err)
+	}
+	gateway := payment.NewPaymentGateway(&specAdapter{})
+	handler := NewPaymentHandler(gateway, nil, orders, nil, nil)
+	orderAdmin := NewOrderAdminHandler(gateway, orders, nil)
+
+	r := gin.New()
+	r.POST("/api/v1/query", RequireScope(nil, auth.ScopeQuery, testMaxBody), handler.Query)
+	admin := r.Group("/admin/v1", RequireScope(nil, auth.ScopeAdmin, testMaxBody))
+	admin.GET("/orders/:out_trade_no", orderAdmin.Get)
+	admin.POST("/orders/:out_trade_no/sync", orderAdmin.Sync)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"query", http.MethodPost, "/api/v1/query", `{"channel":"wechat","out_trade_no":"ORDER_1"}`, http.StatusOK},
+		{"admin get", http.MethodGet, "/admin/v1/orders/ORDER_1", "", http.StatusForbidden},
+		{"admin sync", http.MethodPost, "/admin/v1/orders/ORDER_1/sync", "", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.status, w.Body)
+			}
+		})
+	}
+
+	// 被拒绝的补单没有查询渠道，也没有改写订单
+	if o, _ := orders.Get(context.Background(), "ORDER_1"); o.Status != payment.TradeStatusNotPay || len(o.Timeline) != 0 {
+		t.Fatalf("order changed: %+v", o)
+	}
+}
@@ -1,10 +1,20 @@
 package v1
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/ymqzj/payment-gateway/internal/audit"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/order"
 	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/routing"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,32 +22,44 @@ import (
 // PaymentHandler 支付处理器
 type PaymentHandler struct {
 	gateway *payment.PaymentGateway
+	router  *routing.Engine
+	orders  order.Store
+	events  *webhook.Dispatcher
+	audits  audit.Store
 }
 
-// NewPaymentHandler 创建支付处理器
-func NewPaymentHandler(gateway *payment.PaymentGateway) *PaymentHandler {
+// NewPaymentHandler 创建支付处理器，events 为空时不向商户推送事件，audits 为空时不记录渠道通知
+func NewPaymentHandler(gateway *payment.PaymentGateway, router *routing.Engine, orders order.Store, events *webhook.Dispatcher, audits audit.Store) *PaymentHandler {
 	return &PaymentHandler{
 		gateway: gateway,
+		router:  router,
+		orders:  orders,
+		events:  events,
+		audits:  audits,
 	}
 }
 
 // PayRequest 支付请求
 type PayRequest struct {
-	Channel     string  `json:"channel" binding:"required"`
+	Channel     string  `json:"channel" binding:"required"` // 渠道，auto 表示由路由规则选择
 	OutTradeNo  string  `json:"out_trade_no" binding:"required"`
 	TotalAmount float64 `json:"total_amount" binding:"required,gt=0"`
 	Subject     string  `json:"subject" binding:"required"`
 	Scene       string  `json:"scene" binding:"required"`
-	NotifyURL   string  `json:"notify_url" binding:"required"`
+	EventURL    string  `json:"event_url" binding:"required_without=NotifyURL"` // 商户接收事件推送的地址，渠道通知固定发往网关
+	NotifyURL   string  `json:"notify_url,omitempty"`                           // Deprecated: 旧版字段，event_url 为空时作为 event_url
 	ReturnURL   string  `json:"return_url,omitempty"`
 	OpenID      string  `json:"openid,omitempty"`
 	Attach      string  `json:"attach,omitempty"`
+	App         string  `json:"app,omitempty"`         // 调用方应用标识，用于路由规则
+	MerchantID  string  `json:"merchant_id,omitempty"` // 商户标识，用于路由规则
 }
 
 // PayResponse 支付响应
 type PayResponse struct {
 	Code    int                    `json:"code"`
 	Message string                 `json:"message"`
+	Details string                 `json:"details,omitempty"` // 渠道原始错误码或参数校验信息，仅用于排查
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
@@ -45,31 +67,101 @@ type PayResponse struct {
 func (h *PaymentHandler) Pay(c *gin.Context) {
 	var req PayRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: err.Error(),
-		})
+		writeErrorCode(c, invalidParameter(err), nil)
 		return
 	}
 
-	// 转换渠道类型
-	channel := payment.ChannelType(req.Channel)
-	if !channel.IsValid() {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "invalid channel",
-		})
+	resp, decision, err := h.pay(c.Request.Context(), &req, c.GetHeader("User-Agent"))
+	if err != nil {
+		var routeErr *routeError
+		if errors.As(err, &routeErr) {
+			writeErrorData(c, err, map[string]interface{}{
+				"route": routeErr.decision,
+			})
+			return
+		}
+		writeError(c, err)
 		return
 	}
 
+	data := map[string]interface{}{
+		"order_id":     resp.OrderID,
+		"out_trade_no": resp.OutTradeNo,
+		"pay_data":     resp.PayData,
+		"qr_code":      resp.QRCode,
+		"pay_url":      resp.PayURL,
+		"channel":      resp.Channel,
+	}
+	if decision != nil {
+		data["route"] = decision
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	})
+}
+
+// routeError 自动路由失败，附带决策过程
+type routeError struct {
+	err      error
+	decision *routing.Decision
+}
+
+func (e *routeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *routeError) Unwrap() error {
+	return e.err
+}
+
+// pay 下单流程，HTTP 和 gRPC 接口共用；req 已通过 binding 标签校验
+// 校验商户、场景和渠道，channel 为 auto 时由路由引擎选择渠道，下单成功后记录订单
+func (h *PaymentHandler) pay(ctx context.Context, req *PayRequest, userAgent string) (*payment.UnifiedPayResponse, *routing.Decision, error) {
+	// 凭证绑定商户时不允许以其他商户身份调用
+	merchantID, ok := merchantOf(ctx, req.MerchantID)
+	if !ok {
+		return nil, nil, payment.Forbidden
+	}
+	req.MerchantID = merchantID
+	if req.EventURL == "" {
+		req.EventURL = req.NotifyURL
+	}
+
+	// 商户订单号已被其他商户使用时不允许覆盖
+	if h.orders != nil {
+		if existing, err := h.orders.Get(ctx, req.OutTradeNo); err == nil && !ownsOrder(ctx, existing) {
+			return nil, nil, payment.Forbidden
+		}
+	}
+
 	// 转换场景类型
 	scene := payment.PayScene(req.Scene)
 	if !scene.IsValid() {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "invalid scene",
-		})
-		return
+		return nil, nil, payment.InvalidScene
+	}
+
+	// 事件推送地址只允许公网地址，不允许的地址在下单时拒绝
+	if h.events != nil {
+		if err := h.events.CheckURL(req.EventURL); err != nil {
+			return nil, nil, payment.NewErrorCodeWithDetails(payment.InvalidParameter.Code, payment.InvalidParameter.Message, err.Error())
+		}
+	}
+
+	// 转换渠道类型，auto 由路由引擎选择
+	channel := payment.ChannelType(req.Channel)
+	var decision *routing.Decision
+	if channel == routing.ChannelAuto && h.router != nil {
+		var err error
+		decision, err = h.router.Route(routeRequest(userAgent, scene, req.TotalAmount, req.App, req.MerchantID))
+		if err != nil {
+			return nil, decision, &routeError{err: err, decision: decision}
+		}
+		channel = decision.Channel
+	} else if !channel.IsValid() {
+		return nil, nil, payment.InvalidChannel
 	}
 
 	// 构建支付请求
@@ -79,34 +171,23 @@ func (h *PaymentHandler) Pay(c *gin.Context) {
 		TotalAmount: req.TotalAmount,
 		Subject:     req.Subject,
 		Scene:       scene,
-		NotifyURL:   req.NotifyURL,
 		ReturnURL:   req.ReturnURL,
 		OpenID:      req.OpenID,
 		Attach:      req.Attach,
 	}
 
 	// 调用支付网关
-	resp, err := h.gateway.Pay(c.Request.Context(), payReq)
+	resp, err := h.gateway.Pay(ctx, payReq)
+	if h.router != nil {
+		h.router.Record(channel, err)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, PayResponse{
-			Code:    500,
-			Message: err.Error(),
-		})
-		return
+		return nil, decision, err
 	}
 
-	c.JSON(http.StatusOK, PayResponse{
-		Code:    0,
-		Message: "success",
-		Data: map[string]interface{}{
-			"order_id":     resp.OrderID,
-			"out_trade_no": resp.OutTradeNo,
-			"pay_data":     resp.PayData,
-			"qr_code":      resp.QRCode,
-			"pay_url":      resp.PayURL,
-			"channel":      resp.Channel,
-		},
-	})
+	// 记录订单及渠道选择结果
+	h.saveOrder(ctx, payReq, resp, req, decision)
+	return resp, decision, nil
 }
 
 // QueryRequest 查询请求
@@ -120,34 +201,13 @@ type QueryRequest struct {
 func (h *PaymentHandler) Query(c *gin.Context) {
 	var req QueryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: err.Error(),
-		})
-		return
-	}
-
-	channel := payment.ChannelType(req.Channel)
-	if !channel.IsValid() {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "invalid channel",
-		})
+		writeErrorCode(c, invalidParameter(err), nil)
 		return
 	}
 
-	queryReq := &payment.QueryRequest{
-		Channel:    channel,
-		OrderID:    req.OrderID,
-		OutTradeNo: req.OutTradeNo,
-	}
-
-	resp, err := h.gateway.Query(c.Request.Context(), queryReq)
+	resp, err := h.query(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, PayResponse{
-			Code:    500,
-			Message: err.Error(),
-		})
+		writeError(c, err)
 		return
 	}
 
@@ -170,6 +230,23 @@ func (h *PaymentHandler) Query(c *gin.Context) {
 	})
 }
 
+// query 查询流程，HTTP 和 gRPC 接口共用
+func (h *PaymentHandler) query(ctx context.Context, req *QueryRequest) (*payment.QueryResponse, error) {
+	channel := payment.ChannelType(req.Channel)
+	if !channel.IsValid() {
+		return nil, payment.InvalidChannel
+	}
+	if err := h.authorizeOrder(ctx, req.OutTradeNo, req.OrderID); err != nil {
+		return nil, err
+	}
+
+	return h.gateway.Query(ctx, &payment.QueryRequest{
+		Channel:    channel,
+		OrderID:    req.OrderID,
+		OutTradeNo: req.OutTradeNo,
+	})
+}
+
 // RefundRequest 退款请求
 type RefundRequest struct {
 	Channel      string  `json:"channel" binding:"required"`
@@ -185,38 +262,13 @@ type RefundRequest struct {
 func (h *PaymentHandler) Refund(c *gin.Context) {
 	var req RefundRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: err.Error(),
-		})
-		return
-	}
-
-	channel := payment.ChannelType(req.Channel)
-	if !channel.IsValid() {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "invalid channel",
-		})
+		writeErrorCode(c, invalidParameter(err), nil)
 		return
 	}
 
-	refundReq := &payment.RefundRequest{
-		Channel:      channel,
-		OrderID:      req.OrderID,
-		OutTradeNo:   req.OutTradeNo,
-		OutRefundNo:  req.OutRefundNo,
-		RefundAmount: req.RefundAmount,
-		TotalAmount:  req.TotalAmount,
-		RefundReason: req.RefundReason,
-	}
-
-	resp, err := h.gateway.Refund(c.Request.Context(), refundReq)
+	resp, err := h.refund(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, PayResponse{
-			Code:    500,
-			Message: err.Error(),
-		})
+		writeError(c, err)
 		return
 	}
 
@@ -239,6 +291,42 @@ func (h *PaymentHandler) Refund(c *gin.Context) {
 	})
 }
 
+// refund 退款流程，HTTP 和 gRPC 接口共用，退款成功后记录到订单时间线
+func (h *PaymentHandler) refund(ctx context.Context, req *RefundRequest) (*payment.RefundResponse, error) {
+	channel := payment.ChannelType(req.Channel)
+	if !channel.IsValid() {
+		return nil, payment.InvalidChannel
+	}
+	if err := h.authorizeOrder(ctx, req.OutTradeNo, req.OrderID); err != nil {
+		return nil, err
+	}
+
+	resp, err := h.gateway.Refund(ctx, &payment.RefundRequest{
+		Channel:      channel,
+		OrderID:      req.OrderID,
+		OutTradeNo:   req.OutTradeNo,
+		OutRefundNo:  req.OutRefundNo,
+		RefundAmount: req.RefundAmount,
+		TotalAmount:  req.TotalAmount,
+		RefundReason: req.RefundReason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.recordEvent(ctx, req.OutTradeNo, order.Event{
+		Type:     order.EventRefund,
+		Operator: operatorOf(ctx),
+		Data: map[string]interface{}{
+			"out_refund_no": resp.OutRefundNo,
+			"refund_id":     resp.RefundID,
+			"refund_amount": resp.RefundAmount,
+			"refund_status": resp.RefundStatus,
+		},
+	})
+	return resp, nil
+}
+
 // CloseRequest 关闭订单请求
 type CloseRequest struct {
 	Channel    string `json:"channel" binding:"required"`
@@ -250,67 +338,120 @@ type CloseRequest struct {
 func (h *PaymentHandler) Close(c *gin.Context) {
 	var req CloseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: err.Error(),
-		})
+		writeErrorCode(c, invalidParameter(err), nil)
+		return
+	}
+
+	if err := h.close(c.Request.Context(), &req); err != nil {
+		writeError(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+	})
+}
+
+// close 关单流程，HTTP 和 gRPC 接口共用，关单成功后更新订单状态
+func (h *PaymentHandler) close(ctx context.Context, req *CloseRequest) error {
 	channel := payment.ChannelType(req.Channel)
 	if !channel.IsValid() {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "invalid channel",
-		})
-		return
+		return payment.InvalidChannel
+	}
+	if err := h.authorizeOrder(ctx, req.OutTradeNo, req.OrderID); err != nil {
+		return err
 	}
 
-	closeReq := &payment.CloseRequest{
+	err := h.gateway.Close(ctx, &payment.CloseRequest{
 		Channel:    channel,
 		OrderID:    req.OrderID,
 		OutTradeNo: req.OutTradeNo,
-	}
-
-	err := h.gateway.Close(c.Request.Context(), closeReq)
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, PayResponse{
-			Code:    500,
-			Message: err.Error(),
-		})
-		return
+		return err
 	}
 
-	c.JSON(http.StatusOK, PayResponse{
-		Code:    0,
-		Message: "success",
+	h.recordEvent(ctx, req.OutTradeNo, order.Event{
+		Type:     order.EventClosed,
+		To:       payment.TradeStatusClosed,
+		Operator: operatorOf(ctx),
 	})
+	return nil
+}
+
+// authorizeOrder 凭证绑定了商户时，校验要操作的订单属于该商户
+// 按商户订单号查找订单，只传渠道订单号时按渠道订单号查找，两者都传时必须属于同一订单；
+// 订单记录保存在订单存储中（cmd/server 使用 order.FileStore，重启后不丢失），没有记录的订单（如未经网关下单）无法确认归属，返回订单不存在
+func (h *PaymentHandler) authorizeOrder(ctx context.Context, outTradeNo, orderID string) error {
+	if credential := auth.FromContext(ctx); credential == nil || credential.MerchantID == "" {
+		return nil
+	}
+	if h.orders == nil {
+		return payment.Forbidden
+	}
+
+	var o *order.Order
+	if outTradeNo != "" {
+		found, err := h.orders.Get(ctx, outTradeNo)
+		if errors.Is(err, order.ErrOrderNotFound) {
+			return payment.OrderNotFound
+		}
+		if err != nil {
+			return err
+		}
+		o = found
+	} else {
+		found, _, err := h.orders.Search(ctx, order.Filter{OrderID: orderID, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(found) == 0 {
+			return payment.OrderNotFound
+		}
+		o = found[0]
+	}
+
+	if !ownsOrder(ctx, o) || (orderID != "" && o.OrderID != orderID) {
+		return payment.Forbidden
+	}
+	return nil
 }
 
 // GetChannels 获取支持的支付渠道
+// channels 为当前可用的渠道，status 包含已启用但初始化失败的渠道及失败原因
 func (h *PaymentHandler) GetChannels(c *gin.Context) {
 	channels := h.gateway.GetSupportedChannels()
 	channelNames := make([]string, len(channels))
 	for i, ch := range channels {
 		channelNames[i] = string(ch)
 	}
+	sort.Strings(channelNames)
 
 	c.JSON(http.StatusOK, PayResponse{
 		Code:    0,
 		Message: "success",
 		Data: map[string]interface{}{
 			"channels": channelNames,
+			"status":   h.gateway.GetChannelStatuses(),
 		},
 	})
 }
 
 // Health 健康检查接口
+// 有已启用渠道不可用时返回 degraded，其余渠道仍正常服务
 func (h *PaymentHandler) Health(c *gin.Context) {
+	status := "healthy"
+	if h.gateway.Degraded() {
+		status = "degraded"
+	}
+
 	c.JSON(http.StatusOK, PayResponse{
 		Code:    0,
 		Message: "ok",
 		Data: map[string]interface{}{
-			"status":    "healthy",
+			"status":    status,
+			"channels":  h.gateway.GetChannelStatuses(),
 			"timestamp": time.Now().Unix(),
 		},
 	})
@@ -320,32 +461,35 @@ func (h *PaymentHandler) Health(c *gin.Context) {
 func (h *PaymentHandler) HandleNotify(c *gin.Context) {
 	channel := c.Param("channel")
 	if channel == "" {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "channel parameter is required",
-		})
+		writeErrorCode(c, payment.MissingParameter, nil)
 		return
 	}
 
 	// 读取请求体
 	body, err := c.GetRawData()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: "failed to read request body",
-		})
+		writeErrorCode(c, payment.BadRequest, nil)
 		return
 	}
 
-	// 处理通知
+	// 处理通知，原始报文无论验签是否通过都写入审计记录
+	start := time.Now()
 	result, err := h.gateway.HandleNotify(c.Request.Context(), payment.ChannelType(channel), body)
+	if h.audits != nil {
+		audit.Notification(c.Request.Context(), h.audits, payment.ChannelType(channel), c.Request.Header, body, result, err, time.Since(start))
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// 验签通过后更新订单并向商户推送事件
+	o, err := h.recordNotify(c.Request.Context(), result)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, PayResponse{
-			Code:    400,
-			Message: err.Error(),
-		})
+		writeError(c, err)
 		return
 	}
+	h.forwardNotify(c.Request.Context(), o, result)
 
 	// Format time if exists
 	var payTimeStr string
@@ -368,3 +512,76 @@ func (h *PaymentHandler) HandleNotify(c *gin.Context) {
 		},
 	})
 }
+
+// PayForm 输出前台支付表单（自动提交到渠道网关），表单按下单返回的 pay_url 中的令牌获取
+func (h *PaymentHandler) PayForm(c *gin.Context) {
+	channel := payment.ChannelType(c.Param("channel"))
+	if !channel.IsValid() {
+		writeErrorCode(c, payment.InvalidChannel, nil)
+		return
+	}
+
+	form, err := h.gateway.GetPayForm(c.Request.Context(), channel, c.Param("token"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(form))
+}
+
+// HandleReturn 处理渠道的同步跳转（前台通知）
+// 验签通过后跳转到下单时传入的 return_url，未传入时返回 JSON 结果
+func (h *PaymentHandler) HandleReturn(c *gin.Context) {
+	channel := payment.ChannelType(c.Param("channel"))
+	if !channel.IsValid() {
+		writeErrorCode(c, payment.InvalidChannel, nil)
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		writeErrorCode(c, payment.BadRequest, nil)
+		return
+	}
+
+	result, err := h.gateway.HandleReturn(c.Request.Context(), channel, body)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if result.ReturnURL != "" {
+		c.Redirect(http.StatusFound, buildReturnURL(result))
+		return
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"success":      result.Success,
+			"out_trade_no": result.OutTradeNo,
+			"total_amount": result.TotalAmount,
+			"trade_status": result.TradeStatus,
+			"channel":      result.Channel,
+			"order_id":     result.OrderID,
+		},
+	})
+}
+
+// buildReturnURL 在商户跳转地址上附加订单号和交易状态
+// 前台跳转结果仅用于展示，最终状态应以异步通知或查询接口为准
+func buildReturnURL(result *payment.ReturnResult) string {
+	query := url.Values{}
+	query.Set("channel", string(result.Channel))
+	query.Set("out_trade_no", result.OutTradeNo)
+	query.Set("trade_status", result.TradeStatus)
+
+	sep := "?"
+	if strings.Contains(result.ReturnURL, "?") {
+		sep = "&"
+	}
+	return result.ReturnURL + sep + query.Encode()
+}
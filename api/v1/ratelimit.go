@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"strings"
+
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 入站限流中间件，limits 为空时不限流
+// 需注册在 RequireScope 之后，按鉴权后的 app key 计数，避免伪造 app key 耗尽他人额度；
+// 未鉴权的接口按客户端 IP 计数。接口名为 /api/v1 下的路由路径，如 pay、route/explain
+func RateLimit(limits *ratelimit.Inbound) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limits == nil {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if credential := auth.FromContext(c.Request.Context()); credential != nil {
+			key = credential.AppKey
+		}
+		endpoint := strings.TrimPrefix(c.FullPath(), "/api/v1/")
+
+		if err := limits.Allow(key, endpoint); err != nil {
+			writeError(c, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
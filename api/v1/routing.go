@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/routing"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RouteRequest 路由试算请求
+type RouteRequest struct {
+	Scene       string  `json:"scene" binding:"required"`
+	TotalAmount float64 `json:"total_amount" binding:"required,gt=0"`
+	App         string  `json:"app,omitempty"`
+	MerchantID  string  `json:"merchant_id,omitempty"`
+}
+
+// ExplainRoute 路由试算接口，返回 channel=auto 时会选择的渠道及决策过程，不会下单
+func (h *PaymentHandler) ExplainRoute(c *gin.Context) {
+	var req RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeErrorCode(c, invalidParameter(err), nil)
+		return
+	}
+
+	// 凭证绑定商户时不允许以其他商户身份调用
+	merchantID, ok := merchantOf(c.Request.Context(), req.MerchantID)
+	if !ok {
+		writeErrorCode(c, payment.Forbidden, nil)
+		return
+	}
+	req.MerchantID = merchantID
+
+	scene := payment.PayScene(req.Scene)
+	if !scene.IsValid() {
+		writeErrorCode(c, payment.InvalidScene, nil)
+		return
+	}
+
+	if h.router == nil {
+		c.JSON(http.StatusNotImplemented, PayResponse{
+			Code:    501,
+			Message: "routing is not configured",
+		})
+		return
+	}
+
+	decision, err := h.router.Route(routeRequest(c.GetHeader("User-Agent"), scene, req.TotalAmount, req.App, req.MerchantID))
+	message := "success"
+	if err != nil {
+		message = err.Error()
+	}
+
+	c.JSON(http.StatusOK, PayResponse{
+		Code:    0,
+		Message: message,
+		Data: map[string]interface{}{
+			"channel": decision.Channel,
+			"route":   decision,
+		},
+	})
+}
+
+// routeRequest 构建路由输入，userAgent 为调用方的 User-Agent
+func routeRequest(userAgent string, scene payment.PayScene, amount float64, app, merchantID string) *routing.Request {
+	return &routing.Request{
+		Scene:      scene,
+		Amount:     amount,
+		UserAgent:  userAgent,
+		App:        app,
+		MerchantID: merchantID,
+	}
+}
+
+// saveOrder 记录下单结果，同一商户订单号重复下单时更新渠道信息
+// 订单记录失败不影响支付结果，只记录日志
+func (h *PaymentHandler) saveOrder(ctx context.Context, payReq *payment.UnifiedPayRequest, resp *payment.UnifiedPayResponse, req *PayRequest, decision *routing.Decision) {
+	if h.orders == nil {
+		return
+	}
+
+	route := &order.Route{}
+	if decision != nil {
+		route = &order.Route{
+			Auto:   true,
+			Rule:   decision.Rule,
+			Reason: decision.Reason(),
+		}
+	}
+
+	o := &order.Order{
+		OutTradeNo:  payReq.OutTradeNo,
+		Channel:     payReq.Channel,
+		OrderID:     resp.OrderID,
+		MerchantID:  req.MerchantID,
+		Scene:       payReq.Scene,
+		TotalAmount: payReq.TotalAmount,
+		Subject:     payReq.Subject,
+		EventURL:    req.EventURL,
+		Status:      payment.TradeStatusNotPay,
+		Route:       route,
+	}
+
+	created := order.Event{
+		Type:     order.EventCreated,
+		Operator: operatorOf(ctx),
+		Data: map[string]interface{}{
+			"channel":      payReq.Channel,
+			"scene":        payReq.Scene,
+			"order_id":     resp.OrderID,
+			"total_amount": payReq.TotalAmount,
+		},
+	}
+	o.Record(created)
+
+	err := h.orders.Create(ctx, o)
+	if errors.Is(err, order.ErrOrderExists) {
+		_, err = h.orders.Update(ctx, o.OutTradeNo, func(existing *order.Order) error {
+			existing.Channel = o.Channel
+			existing.OrderID = o.OrderID
+			existing.Route = o.Route
+			existing.EventURL = o.EventURL
+			existing.Record(created)
+			return nil
+		})
+	}
+	if err != nil {
+		logger.FromContext(ctx).Warn("save order failed",
+			zap.String("out_trade_no", o.OutTradeNo),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/ymqzj/payment-gateway/internal/signing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SignResponses 对响应签名的中间件
+// 响应先写入缓冲区，处理完成后计算签名并写入签名头，商户可用网关公钥校验响应未被篡改
+func SignResponses(signer *signing.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &signingWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		signer.SignHeaders(w.Header(), w.body.Bytes())
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// signingWriter 缓存响应状态码和 body
+type signingWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *signingWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *signingWriter) WriteHeaderNow() {}
+
+func (w *signingWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *signingWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *signingWriter) Status() int {
+	return w.status
+}
+
+// PublicKeys 发布网关签名公钥，商户用于校验响应和事件签名
+func PublicKeys(signer *signing.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, signer.KeySet())
+	}
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/signing"
+	"github.com/ymqzj/payment-gateway/pkg/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newSignedServer 与 cmd/server 相同的响应签名和公钥发布，不鉴权
+func newSignedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	signer, err := signing.GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := payment.NewPaymentGateway(&specAdapter{})
+	handler := NewPaymentHandler(gateway, nil, order.NewMemoryStore(), nil, nil)
+
+	r := gin.New()
+	r.GET(signing.WellKnownPath, PublicKeys(signer))
+	v1 := r.Group("/api/v1", SignResponses(signer))
+	v1.POST("/query", handler.Query)
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// tamper 修改响应 body 的 Transport
+type tamper struct{}
+
+func (tamper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(bytes.Replace(body, []byte("SUCCESS"), []byte("CLOSED"), 1)))
+	return resp, nil
+}
+
+// TestSignedResponses SDK 从公钥发布地址获取公钥，校验成功和错误响应的签名
+func TestSignedResponses(t *testing.T) {
+	srv := newSignedServer(t)
+	verifier, err := sdk.FetchVerifier(context.Background(), nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sdk.FetchVerifier(context.Background(), nil, newSignedServer(t).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		client  *sdk.Client
+		channel string
+		err     error
+		code    int
+	}{
+		{"success", sdk.NewClient(srv.URL, "app", "secret", sdk.WithVerifier(verifier)), "wechat", nil, 0},
+		{"error response", sdk.NewClient(srv.URL, "app", "secret", sdk.WithVerifier(verifier)), "paypal", nil, 1001},
+		{"tampered response", sdk.NewClient(srv.URL, "app", "secret", sdk.WithVerifier(verifier),
+			sdk.WithHTTPClient(&http.Client{Transport: tamper{}})), "wechat", sdk.ErrInvalidSignature, 0},
+		{"key of another gateway", sdk.NewClient(srv.URL, "app", "secret", sdk.WithVerifier(other)), "wechat", sdk.ErrUnknownKey, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := tc.client.Query(context.Background(), &sdk.QueryRequest{Channel: tc.channel, OutTradeNo: "ORDER_1"})
+			var apiErr *sdk.APIError
+			switch {
+			case tc.err != nil:
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("err = %v, want %v", err, tc.err)
+				}
+			case tc.code != 0:
+				if !errors.As(err, &apiErr) || apiErr.Code != tc.code {
+					t.Fatalf("err = %v, want API error %d", err, tc.code)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.TradeStatus != string(payment.TradeStatusSuccess) || result.OutTradeNo != "ORDER_1" {
+					t.Fatalf("result = %+v", result)
+				}
+			}
+		})
+	}
+}
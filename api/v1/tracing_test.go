@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/internal/order"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedAdapter 下单时经 tracing.HTTPClient 请求渠道地址的 specAdapter
+type tracedAdapter struct {
+	specAdapter
+	channelURL string
+}
+
+func (a *tracedAdapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.channelURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := tracing.HTTPClient(0).Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return a.specAdapter.Pay(ctx, req)
+}
+
+// TestTracingSpans 上游 traceparent 经 HTTP、网关和适配器传到渠道请求，各层 span 依次为父子关系
+func TestTracingSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter), sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	upstream := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	cases := []struct {
+		name        string
+		traceparent string
+		traceID     string // 为空表示新建 trace
+		remote      string // server span 的父 span
+	}{
+		{"upstream trace", upstream, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"},
+		{"new trace", "", "", ""},
+		{"malformed traceparent", "00-not-a-trace-01", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+			var channelTraceparent string
+			channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				channelTraceparent = r.Header.Get("traceparent")
+			}))
+			defer channel.Close()
+
+			gateway := payment.NewPaymentGateway(&tracedAdapter{channelURL: channel.URL})
+			gateway.Use(tracing.Middleware())
+			handler := NewPaymentHandler(gateway, nil, order.NewMemoryStore(), nil, nil)
+			r := gin.New()
+			r.Use(Tracing())
+			r.POST("/api/v1/pay", handler.Pay)
+
+			body := `{"channel":"wechat","out_trade_no":"ORDER_1","total_amount":0.01,"subject":"test","scene":"native","event_url":"https://merchant.example.com/events"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/pay", strings.NewReader(body))
+			req.Header.Set("Content-Type", gin.MIMEJSON)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+
+			// span 按结束顺序导出：渠道请求、网关调用、HTTP 请求
+			spans := exporter.GetSpans()
+			if len(spans) != 3 {
+				t.Fatalf("spans = %d, want 3", len(spans))
+			}
+			client, call, server := spans[0], spans[1], spans[2]
+			if server.Name != "POST /api/v1/pay" || server.SpanKind != trace.SpanKindServer {
+				t.Fatalf("server span = %s (%v)", server.Name, server.SpanKind)
+			}
+			if call.Name != "payment.pay" || call.Parent.SpanID() != server.SpanContext.SpanID() {
+				t.Fatalf("gateway span = %s, parent %s, want child of server span", call.Name, call.Parent.SpanID())
+			}
+			if client.SpanKind != trace.SpanKindClient || client.Parent.SpanID() != call.SpanContext.SpanID() {
+				t.Fatalf("channel span = %s (%v), parent %s, want child of gateway span", client.Name, client.SpanKind, client.Parent.SpanID())
+			}
+
+			traceID := server.SpanContext.TraceID()
+			for _, span := range spans {
+				if span.SpanContext.TraceID() != traceID {
+					t.Fatalf("span %s in trace %s, want %s", span.Name, span.SpanContext.TraceID(), traceID)
+				}
+			}
+			switch {
+			case tc.traceID != "":
+				if traceID.String() != tc.traceID || !server.Parent.IsRemote() || server.Parent.SpanID().String() != tc.remote {
+					t.Fatalf("server span trace %s parent %s, want upstream %s %s", traceID, server.Parent.SpanID(), tc.traceID, tc.remote)
+				}
+			case server.Parent.IsValid():
+				t.Fatalf("server span has parent %s, want new trace", server.Parent.SpanID())
+			}
+
+			want := "00-" + traceID.String() + "-" + client.SpanContext.SpanID().String() + "-01"
+			if channelTraceparent != want {
+				t.Fatalf("channel traceparent = %q, want %q", channelTraceparent, want)
+			}
+		})
+	}
+}
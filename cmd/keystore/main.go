@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+)
+
+// keystore 管理本地加密密钥库
+//
+//	keystore -file ./certs/keystore.json put wechat_key ./certs/wechat/apiclient_key.pem
+//	echo -n "$APIV3KEY" | keystore -file ./certs/keystore.json put wechat_api_v3_key -
+//	keystore -file ./certs/keystore.json list
+//	keystore -file ./certs/keystore.json delete wechat_key
+//
+// 口令从 -passphrase-env 指定的环境变量读取，配置中使用 keystore:<name> 引用条目
+func main() {
+	file := flag.String("file", "./certs/keystore.json", "keystore file path")
+	passphraseEnv := flag.String("passphrase-env", "KEYSTORE_PASSPHRASE", "environment variable holding the keystore passphrase")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	ks, err := secret.OpenKeystore(*file, os.Getenv(*passphraseEnv))
+	if err != nil {
+		log.Fatalf("open keystore failed: %v", err)
+	}
+
+	switch args[0] {
+	case "put":
+		if len(args) != 3 {
+			usage()
+		}
+		data, err := readInput(args[2])
+		if err != nil {
+			log.Fatalf("read %s failed: %v", args[2], err)
+		}
+		if err := ks.Put(args[1], data); err != nil {
+			log.Fatalf("put %s failed: %v", args[1], err)
+		}
+		if err := ks.Save(); err != nil {
+			log.Fatalf("save keystore failed: %v", err)
+		}
+		fmt.Printf("stored keystore:%s\n", args[1])
+	case "list":
+		for _, name := range ks.Names() {
+			fmt.Println(name)
+		}
+	case "delete":
+		if len(args) != 2 {
+			usage()
+		}
+		ks.Delete(args[1])
+		if err := ks.Save(); err != nil {
+			log.Fatalf("save keystore failed: %v", err)
+		}
+	default:
+		usage()
+	}
+}
+
+// readInput 读取文件内容，"-" 表示标准输入
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: keystore [-file path] [-passphrase-env NAME] put <name> <file|->|list|delete <name>")
+	os.Exit(2)
+}
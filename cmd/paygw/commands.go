@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/audit"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// newFlagSet 创建子命令参数，参数错误时退出码为 2
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ExitOnError)
+}
+
+// required 按顺序检查必填参数，pairs 为参数名和值交替排列，缺失时返回 MissingParameter 错误
+func required(pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == "" {
+			return payment.NewErrorCodeWithDetails(payment.MissingParameter.Code, payment.MissingParameter.Message, "-"+pairs[i]+" is required")
+		}
+	}
+	return nil
+}
+
+// pay 在渠道下单，与 POST /api/v1/pay 相同，但不经过路由规则，也不记录订单
+func (a *app) pay(ctx context.Context, args []string) int {
+	fs := newFlagSet("pay")
+	channel := fs.String("channel", "", "payment channel: wechat, alipay, unionpay")
+	outTradeNo := fs.String("out-trade-no", "", "merchant order number")
+	amount := fs.Float64("amount", 0, "total amount in yuan")
+	subject := fs.String("subject", "", "order subject")
+	scene := fs.String("scene", "", "pay scene: app, h5, jsapi, native, pc")
+	returnURL := fs.String("return-url", "", "return url for h5/pc")
+	openID := fs.String("openid", "", "wechat jsapi openid")
+	attach := fs.String("attach", "", "attach data")
+	_ = fs.Parse(args)
+
+	if err := required("channel", *channel, "out-trade-no", *outTradeNo, "subject", *subject, "scene", *scene); err != nil {
+		return failure(err)
+	}
+	if *amount <= 0 {
+		return failure(payment.InvalidAmount)
+	}
+	if !payment.PayScene(*scene).IsValid() {
+		return failure(payment.InvalidScene)
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	resp, err := gateway.Pay(ctx, &payment.UnifiedPayRequest{
+		Channel:     payment.ChannelType(*channel),
+		OutTradeNo:  *outTradeNo,
+		TotalAmount: *amount,
+		Subject:     *subject,
+		Scene:       payment.PayScene(*scene),
+		ReturnURL:   *returnURL,
+		OpenID:      *openID,
+		Attach:      *attach,
+	})
+	if err != nil {
+		return failure(err)
+	}
+
+	return success(map[string]interface{}{
+		"order_id":     resp.OrderID,
+		"out_trade_no": resp.OutTradeNo,
+		"pay_data":     resp.PayData,
+		"qr_code":      resp.QRCode,
+		"pay_url":      resp.PayURL,
+		"channel":      resp.Channel,
+	})
+}
+
+// query 查询订单
+func (a *app) query(ctx context.Context, args []string) int {
+	fs := newFlagSet("query")
+	channel := fs.String("channel", "", "payment channel")
+	outTradeNo := fs.String("out-trade-no", "", "merchant order number")
+	orderID := fs.String("order-id", "", "channel order number")
+	_ = fs.Parse(args)
+
+	if err := required("channel", *channel); err != nil {
+		return failure(err)
+	}
+	if *outTradeNo == "" && *orderID == "" {
+		return failure(required("out-trade-no", ""))
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	resp, err := gateway.Query(ctx, &payment.QueryRequest{
+		Channel:    payment.ChannelType(*channel),
+		OrderID:    *orderID,
+		OutTradeNo: *outTradeNo,
+	})
+	if err != nil {
+		return failure(err)
+	}
+
+	return success(map[string]interface{}{
+		"order_id":     resp.OrderID,
+		"out_trade_no": resp.OutTradeNo,
+		"trade_status": resp.TradeStatus,
+		"total_amount": resp.TotalAmount,
+		"pay_time":     formatTime(resp.PayTime),
+		"channel":      resp.Channel,
+	})
+}
+
+// refund 发起退款，out-refund-no 为幂等键，重复执行不会重复退款
+func (a *app) refund(ctx context.Context, args []string) int {
+	fs := newFlagSet("refund")
+	channel := fs.String("channel", "", "payment channel")
+	outTradeNo := fs.String("out-trade-no", "", "merchant order number")
+	orderID := fs.String("order-id", "", "channel order number")
+	outRefundNo := fs.String("out-refund-no", "", "merchant refund number, used as idempotency key")
+	amount := fs.Float64("amount", 0, "refund amount in yuan")
+	total := fs.Float64("total", 0, "order total amount in yuan")
+	reason := fs.String("reason", "", "refund reason")
+	_ = fs.Parse(args)
+
+	if err := required("channel", *channel, "out-trade-no", *outTradeNo, "out-refund-no", *outRefundNo); err != nil {
+		return failure(err)
+	}
+	if *amount <= 0 || *total <= 0 || *amount > *total {
+		return failure(payment.InvalidAmount)
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	resp, err := gateway.Refund(ctx, &payment.RefundRequest{
+		Channel:      payment.ChannelType(*channel),
+		OrderID:      *orderID,
+		OutTradeNo:   *outTradeNo,
+		OutRefundNo:  *outRefundNo,
+		RefundAmount: *amount,
+		TotalAmount:  *total,
+		RefundReason: *reason,
+	})
+	if err != nil {
+		return failure(err)
+	}
+
+	return success(refundData(resp))
+}
+
+// refundQuery 查询退款
+func (a *app) refundQuery(ctx context.Context, args []string) int {
+	fs := newFlagSet("refund-query")
+	channel := fs.String("channel", "", "payment channel")
+	outTradeNo := fs.String("out-trade-no", "", "merchant order number, required by alipay unless -order-id is set")
+	orderID := fs.String("order-id", "", "channel order number")
+	outRefundNo := fs.String("out-refund-no", "", "merchant refund number")
+	_ = fs.Parse(args)
+
+	if err := required("channel", *channel, "out-refund-no", *outRefundNo); err != nil {
+		return failure(err)
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	resp, err := gateway.QueryRefund(ctx, &payment.RefundQueryRequest{
+		Channel:     payment.ChannelType(*channel),
+		OrderID:     *orderID,
+		OutTradeNo:  *outTradeNo,
+		OutRefundNo: *outRefundNo,
+	})
+	if err != nil {
+		return failure(err)
+	}
+
+	return success(refundData(resp))
+}
+
+// refundData 退款结果
+func refundData(resp *payment.RefundResponse) map[string]interface{} {
+	return map[string]interface{}{
+		"refund_id":     resp.RefundID,
+		"out_refund_no": resp.OutRefundNo,
+		"refund_amount": resp.RefundAmount,
+		"refund_status": resp.RefundStatus,
+		"refund_time":   formatTime(resp.RefundTime),
+		"channel":       resp.Channel,
+	}
+}
+
+// close 关闭订单
+func (a *app) close(ctx context.Context, args []string) int {
+	fs := newFlagSet("close")
+	channel := fs.String("channel", "", "payment channel")
+	outTradeNo := fs.String("out-trade-no", "", "merchant order number")
+	orderID := fs.String("order-id", "", "channel order number")
+	_ = fs.Parse(args)
+
+	if err := required("channel", *channel, "out-trade-no", *outTradeNo); err != nil {
+		return failure(err)
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	err = gateway.Close(ctx, &payment.CloseRequest{
+		Channel:    payment.ChannelType(*channel),
+		OrderID:    *orderID,
+		OutTradeNo: *outTradeNo,
+	})
+	if err != nil {
+		return failure(err)
+	}
+
+	return success(map[string]interface{}{
+		"out_trade_no": *outTradeNo,
+		"channel":      *channel,
+	})
+}
+
+// bill 对账单命令，目前只有 download
+func (a *app) bill(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] != "download" {
+		usage()
+	}
+
+	fs := newFlagSet("bill download")
+	channel := fs.String("channel", "", "payment channel")
+	date := fs.String("date", time.Now().AddDate(0, 0, -1).Format(time.DateOnly), "bill date, defaults to yesterday")
+	billType := fs.String("type", "", "channel bill type, defaults to the trade bill")
+	output := fs.String("o", "", "output file, defaults to the channel's file name in the current directory")
+	_ = fs.Parse(args[1:])
+
+	if err := required("channel", *channel); err != nil {
+		return failure(err)
+	}
+	day, err := time.ParseInLocation(time.DateOnly, *date, time.Local)
+	if err != nil {
+		return failure(payment.NewErrorCodeWithDetails(payment.InvalidParameter.Code, payment.InvalidParameter.Message, "-date must be in 2006-01-02 format"))
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	resp, err := gateway.DownloadBill(ctx, &payment.BillRequest{
+		Channel:  payment.ChannelType(*channel),
+		Date:     day,
+		BillType: *billType,
+	})
+	if err != nil {
+		return failure(err)
+	}
+
+	path := *output
+	if path == "" {
+		path = resp.FileName
+	}
+	if err := os.WriteFile(path, resp.Data, 0o600); err != nil {
+		return failure(fmt.Errorf("write bill file failed: %w", err))
+	}
+
+	return success(map[string]interface{}{
+		"channel":   resp.Channel,
+		"bill_date": resp.Date.Format(time.DateOnly),
+		"bill_type": resp.BillType,
+		"file":      path,
+		"size":      len(resp.Data),
+	})
+}
+
+// notify 通知命令，目前只有 verify
+// verify 使用渠道公钥重新验签保存下来的通知报文，不更新订单也不推送事件
+func (a *app) notify(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		usage()
+	}
+
+	fs := newFlagSet("notify verify")
+	channel := fs.String("channel", "", "payment channel, defaults to the channel of the audit record")
+	record := fs.Bool("record", false, "the file is an audit record (JSON) instead of the raw notify body")
+	_ = fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: paygw notify verify [-channel name] [-record] <file|->")
+		os.Exit(2)
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return failure(fmt.Errorf("read %s failed: %w", fs.Arg(0), err))
+	}
+	if *record {
+		var r audit.Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return failure(payment.NewErrorCodeWithDetails(payment.InvalidParameter.Code, payment.InvalidParameter.Message, "invalid audit record: "+err.Error()))
+		}
+		if *channel == "" {
+			*channel = string(r.Channel)
+		}
+		data = []byte(r.Body)
+	}
+	if err := required("channel", *channel); err != nil {
+		return failure(err)
+	}
+
+	gateway, err := a.gateway(payment.ChannelType(*channel))
+	if err != nil {
+		return failure(err)
+	}
+
+	ctx, cancel := a.callContext(ctx)
+	defer cancel()
+	result, err := gateway.HandleNotify(ctx, payment.ChannelType(*channel), data)
+	if err != nil {
+		return failure(err)
+	}
+
+	return success(map[string]interface{}{
+		"verified":     true,
+		"success":      result.Success,
+		"out_trade_no": result.OutTradeNo,
+		"total_amount": result.TotalAmount,
+		"trade_status": result.TradeStatus,
+		"channel":      result.Channel,
+		"order_id":     result.OrderID,
+		"pay_time":     formatTime(result.PayTime),
+	})
+}
+
+// readInput 读取文件内容，"-" 表示标准输入
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// config 配置命令，目前只有 check
+// check 加载并校验配置，输出全部问题；存在无法降级运行的错误时 fatal 为 true
+func (a *app) config(ctx context.Context, args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		usage()
+	}
+	_ = newFlagSet("config check").Parse(args[1:])
+
+	if err := a.loadConfig(); err != nil {
+		return failure(payment.NewErrorCodeWithDetails(payment.InvalidParameter.Code, payment.InvalidParameter.Message, err.Error()))
+	}
+
+	data := map[string]interface{}{
+		"path":   a.path,
+		"valid":  true,
+		"fatal":  false,
+		"errors": []map[string]string{},
+	}
+
+	err := a.cfg.Validate()
+	var verr *configs.ValidationError
+	switch {
+	case err == nil:
+		return success(data)
+	case errors.As(err, &verr):
+		errs := make([]map[string]string, len(verr.Errors))
+		for i, fe := range verr.Errors {
+			errs[i] = map[string]string{
+				"section": fe.Section,
+				"field":   fe.Field,
+				"message": fe.Message,
+			}
+		}
+		data["valid"] = false
+		data["fatal"] = verr.Fatal()
+		data["errors"] = errs
+		write(result{Code: 1, Message: "invalid config", Data: data})
+		return 1
+	default:
+		return failure(err)
+	}
+}
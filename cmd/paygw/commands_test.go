@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alipayKey 测试用的支付宝密钥，同时作为应用私钥和支付宝公钥，用于给通知签名
+type alipayKey struct {
+	key *rsa.PrivateKey
+}
+
+func newAlipayKey(t *testing.T) *alipayKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &alipayKey{key: key}
+}
+
+// config 启用支付宝渠道的配置节
+func (k *alipayKey) config(t *testing.T) string {
+	t.Helper()
+	private, err := x509.MarshalPKCS8PrivateKey(k.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&k.key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return `
+alipay:
+  enabled: true
+  app_id: "2021000000000000"
+  private_key: "` + base64.StdEncoding.EncodeToString(private) + `"
+  alipay_public_key: "` + base64.StdEncoding.EncodeToString(public) + `"
+  sandbox: true
+  notify_url: "https://pay.example.com/api/v1/notify/alipay"
+`
+}
+
+// notify 按支付宝规则签名的异步通知报文
+func (k *alipayKey) notify(t *testing.T, values url.Values) string {
+	t.Helper()
+	var pairs []string
+	for key := range values {
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+	digest := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	sign, err := rsa.SignPKCS1v15(rand.Reader, k.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	values.Set("sign_type", "RSA2")
+	values.Set("sign", base64.StdEncoding.EncodeToString(sign))
+	return values.Encode()
+}
+
+// writeFile 在临时目录中写入文件，返回文件路径
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runCommand 使用 path 处的配置执行命令，返回退出码和输出结果
+func runCommand(t *testing.T, path string, args ...string) (int, result) {
+	t.Helper()
+	var out bytes.Buffer
+	stdout = &out
+	t.Cleanup(func() { stdout = os.Stdout })
+
+	app := &app{file: path, timeout: 5 * time.Second}
+	exit := app.run(context.Background(), args)
+
+	var res result
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("decode output %q: %v", out.String(), err)
+	}
+	return exit, res
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	key := newAlipayKey(t)
+	base := `
+server:
+  port: 8080
+wechat:
+  enabled: false
+unionpay:
+  enabled: false
+auth:
+  keys:
+    - app_key: "app"
+      secret: "secret"
+      scopes: ["pay", "query"]
+`
+	valid := writeFile(t, dir, "valid.yaml", base+key.config(t))
+	invalid := writeFile(t, dir, "invalid.yaml", base+`
+alipay:
+  enabled: true
+  app_id: "2021000000000000"
+`)
+	fatal := writeFile(t, dir, "fatal.yaml", strings.Replace(base, "port: 8080", "port: 0", 1)+key.config(t))
+
+	notify := key.notify(t, url.Values{
+		"app_id":       {"2021000000000000"},
+		"notify_type":  {"trade_status_sync"},
+		"out_trade_no": {"ORDER_1"},
+		"trade_no":     {"2024010122001"},
+		"trade_status": {"TRADE_SUCCESS"},
+		"total_amount": {"0.01"},
+		"gmt_payment":  {"2024-01-01 12:00:00"},
+	})
+	notifyFile := writeFile(t, dir, "notify.txt", notify)
+	tamperedFile := writeFile(t, dir, "tampered.txt", strings.Replace(notify, "total_amount=0.01", "total_amount=100", 1))
+	record, _ := json.Marshal(map[string]interface{}{"channel": "alipay", "direction": "inbound", "body": notify})
+	recordFile := writeFile(t, dir, "record.json", string(record))
+	brokenRecord := writeFile(t, dir, "broken.json", "{")
+
+	cases := []struct {
+		name    string
+		config  string
+		args    []string
+		exit    int
+		code    int
+		details string                 // 为空时不检查
+		data    map[string]interface{} // 需要检查的输出字段
+	}{
+		{"pay missing channel", valid, []string{"pay", "-out-trade-no", "ORDER_1"}, 1, 1004, "-channel is required", nil},
+		{"pay invalid amount", valid, []string{"pay", "-channel", "alipay", "-out-trade-no", "ORDER_1", "-subject", "test", "-scene", "pc"}, 1, 1003, "", nil},
+		{"pay invalid scene", valid, []string{"pay", "-channel", "alipay", "-out-trade-no", "ORDER_1", "-subject", "test", "-scene", "tv", "-amount", "1"}, 1, 1002, "", nil},
+		{"query without order number", valid, []string{"query", "-channel", "alipay"}, 1, 1004, "-out-trade-no is required", nil},
+		{"refund exceeds total", valid, []string{"refund", "-channel", "alipay", "-out-trade-no", "ORDER_1", "-out-refund-no", "R1", "-amount", "2", "-total", "1"}, 1, 1003, "", nil},
+		{"bill invalid date", valid, []string{"bill", "download", "-channel", "alipay", "-date", "20240101"}, 1, 1005, "-date must be in 2006-01-02 format", nil},
+		{"unknown channel", valid, []string{"query", "-channel", "paypal", "-out-trade-no", "ORDER_1"}, 1, 1001, "", nil},
+		{"channel not enabled", valid, []string{"close", "-channel", "wechat", "-out-trade-no", "ORDER_1"}, 1, 2004, "", nil},
+		{"channel config invalid", invalid, []string{"close", "-channel", "alipay", "-out-trade-no", "ORDER_1"}, 1, 2004, "", nil},
+		{"notify verify", valid, []string{"notify", "verify", "-channel", "alipay", notifyFile}, 0, 0, "", map[string]interface{}{
+			"verified": true, "success": true, "out_trade_no": "ORDER_1", "order_id": "2024010122001", "total_amount": 0.01,
+		}},
+		{"notify verify tampered", valid, []string{"notify", "verify", "-channel", "alipay", tamperedFile}, 1, 3001, "", nil},
+		{"notify verify audit record", valid, []string{"notify", "verify", "-record", recordFile}, 0, 0, "", map[string]interface{}{
+			"verified": true, "channel": "alipay", "out_trade_no": "ORDER_1",
+		}},
+		{"notify verify broken record", valid, []string{"notify", "verify", "-record", brokenRecord}, 1, 1005, "", nil},
+		{"config check", valid, []string{"config", "check"}, 0, 0, "", map[string]interface{}{"valid": true, "fatal": false}},
+		{"config check channel errors", invalid, []string{"config", "check"}, 1, 1, "", map[string]interface{}{"valid": false, "fatal": false}},
+		{"config check fatal errors", fatal, []string{"config", "check"}, 1, 1, "", map[string]interface{}{"valid": false, "fatal": true}},
+		{"config file missing", filepath.Join(dir, "missing.yaml"), []string{"config", "check"}, 1, 1005, "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exit, res := runCommand(t, tc.config, tc.args...)
+			if exit != tc.exit || res.Code != tc.code {
+				t.Fatalf("exit = %d, code = %d, want %d, %d: %+v", exit, res.Code, tc.exit, tc.code, res)
+			}
+			if tc.details != "" && res.Details != tc.details {
+				t.Fatalf("details = %q, want %q", res.Details, tc.details)
+			}
+			for field, want := range tc.data {
+				if got := res.Data[field]; got != want {
+					t.Errorf("%s = %v, want %v", field, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestConfigCheckErrors 配置错误按配置节和字段逐条输出
+func TestConfigCheckErrors(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "invalid.yaml", `
+server:
+  port: 8080
+alipay:
+  enabled: true
+  app_id: "2021000000000000"
+  notify_url: "https://pay.example.com/api/v1/notify/alipay"
+`)
+	_, res := runCommand(t, path, "config", "check")
+
+	errs, _ := res.Data["errors"].([]interface{})
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fe := e.(map[string]interface{})
+		fields[fe["section"].(string)+"."+fe["field"].(string)] = fe["message"] != ""
+	}
+	for _, field := range []string{"alipay.private_key", "alipay.alipay_public_key"} {
+		if !fields[field] {
+			t.Errorf("%s not reported: %+v", field, errs)
+		}
+	}
+	if res.Data["path"] != path {
+		t.Fatalf("path = %v, want %s", res.Data["path"], path)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/adapters"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	logger "github.com/ymqzj/payment-gateway/logs"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+)
+
+// paygw 运维命令行：使用与服务相同的配置和适配器直接调用渠道，结果以 JSON 输出到标准输出
+//
+//	paygw -env prod query -channel wechat -out-trade-no ORDER_001
+//	paygw -env prod refund -channel alipay -out-trade-no ORDER_001 -out-refund-no R001 -amount 1 -total 1
+//	paygw -env prod bill download -channel wechat -date 2024-01-01
+//	paygw -env prod notify verify -channel alipay ./notify.txt
+//	paygw -config ./configs/prod.yaml config check
+//
+// 日志输出到标准错误；调用失败时退出码为 1，参数错误时为 2
+func main() {
+	env := flag.String("env", configs.GetEnv(), "environment name, loads configs/<env>.yaml")
+	file := flag.String("config", "", "config file path, overrides -env")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout for channel calls")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := &app{env: *env, file: *file, timeout: *timeout}
+	os.Exit(app.run(ctx, args))
+}
+
+// app 命令执行环境，配置和网关在命令需要时才加载
+type app struct {
+	env     string
+	file    string
+	timeout time.Duration
+
+	path string
+	cfg  *configs.Config
+}
+
+// command 子命令，返回进程退出码
+type command func(ctx context.Context, args []string) int
+
+// run 按子命令分发
+func (a *app) run(ctx context.Context, args []string) int {
+	commands := map[string]command{
+		"pay":          a.pay,
+		"query":        a.query,
+		"refund":       a.refund,
+		"refund-query": a.refundQuery,
+		"close":        a.close,
+		"bill":         a.bill,
+		"notify":       a.notify,
+		"config":       a.config,
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage()
+	}
+	return cmd(ctx, args[1:])
+}
+
+// loadConfig 加载配置并初始化日志，日志固定输出到标准错误，不与 JSON 结果混在一起
+func (a *app) loadConfig() error {
+	if a.cfg != nil {
+		return nil
+	}
+
+	path := a.file
+	if path == "" {
+		var err error
+		if path, err = configs.Path(a.env); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := configs.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	logging := cfg.Logging
+	logging.Output = "stderr"
+	if err := logger.Init(logging); err != nil {
+		return fmt.Errorf("init logger failed: %w", err)
+	}
+
+	a.path, a.cfg = path, cfg
+	return nil
+}
+
+// gateway 创建只包含指定渠道的网关
+// 渠道未启用或配置有误时返回错误；配置启用重试时与服务使用相同的重试策略
+func (a *app) gateway(channel payment.ChannelType) (*payment.PaymentGateway, error) {
+	if !channel.IsValid() {
+		return nil, payment.InvalidChannel
+	}
+	if err := a.loadConfig(); err != nil {
+		return nil, err
+	}
+	if !adapters.Enabled(channel, a.cfg) {
+		return nil, fmt.Errorf("%w: %s is not enabled in %s", payment.ErrChannelUnavailable, channel, a.path)
+	}
+
+	var verr *configs.ValidationError
+	if err := a.cfg.Validate(); errors.As(err, &verr) {
+		if errs := verr.Section(string(channel)); len(errs) > 0 {
+			return nil, fmt.Errorf("%w: %s config is invalid: %s", payment.ErrChannelUnavailable, channel, errs[0].Error())
+		}
+	}
+
+	secrets, err := secret.NewFromConfig(a.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("create secret provider failed: %w", err)
+	}
+
+	adapter, err := adapters.Build(channel, a.cfg, secrets, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: init %s adapter failed: %w", payment.ErrChannelUnavailable, channel, err)
+	}
+
+	gateway := payment.NewPaymentGateway(adapter)
+	if a.cfg.Retry.Enabled {
+		gateway.Use(payment.RetryMiddleware(payment.RetryOptions{
+			MaxAttempts:    a.cfg.Retry.MaxAttempts,
+			InitialBackoff: a.cfg.Retry.InitialBackoff,
+			MaxBackoff:     a.cfg.Retry.MaxBackoff,
+			Jitter:         a.cfg.Retry.Jitter,
+		}))
+	}
+	return gateway, nil
+}
+
+// callContext 渠道调用的超时 context
+func (a *app) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, a.timeout)
+}
+
+// result 命令输出，字段与 HTTP 接口的响应一致
+type result struct {
+	Code    int                    `json:"code"`
+	Message string                 `json:"message"`
+	Details string                 `json:"details,omitempty"`
+	Error   string                 `json:"error,omitempty"` // 原始错误信息，HTTP 接口不返回
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// success 输出成功结果
+func success(data map[string]interface{}) int {
+	write(result{Code: 0, Message: "success", Data: data})
+	return 0
+}
+
+// failure 按错误码目录输出错误，退出码为 1
+func failure(err error) int {
+	code := payment.ErrorCodeOf(err)
+	n, _ := strconv.Atoi(code.Code)
+	res := result{
+		Code:    n,
+		Message: code.Message,
+		Details: code.Details,
+	}
+	if !errors.Is(err, code) {
+		res.Error = err.Error()
+	}
+	write(res)
+	return 1
+}
+
+// stdout 命令结果的输出位置
+var stdout io.Writer = os.Stdout
+
+// write 以缩进格式输出 JSON
+func write(res result) {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(res); err != nil {
+		fmt.Fprintf(os.Stderr, "write result failed: %v\n", err)
+	}
+}
+
+// formatTime 格式化可选时间，为空时返回空字符串
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: paygw [-env name] [-config path] [-timeout 30s] <command> [flags]
+
+commands:
+  pay           create an order on a channel
+  query         query an order
+  refund        refund an order
+  refund-query  query a refund
+  close         close an order
+  bill download download a daily bill
+  notify verify verify a channel notification saved to a file
+  config check  load and validate the config
+
+run "paygw <command> -h" for command flags`)
+	os.Exit(2)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ymqzj/payment-gateway/configs"
+)
+
+// runConfigCheck 执行 config check 子命令: 加载并校验配置，不启动服务
+// 返回进程退出码，配置有误时为 1
+func runConfigCheck(args []string) int {
+	fs := flag.NewFlagSet("config check", flag.ExitOnError)
+	env := fs.String("env", configs.GetEnv(), "environment name, loads configs/<env>.yaml")
+	file := fs.String("file", "", "config file path, overrides -env")
+	_ = fs.Parse(args)
+
+	path := *file
+	if path == "" {
+		var err error
+		path, err = configs.Path(*env)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	cfg, err := configs.LoadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = cfg.Validate()
+	var verr *configs.ValidationError
+	switch {
+	case err == nil:
+		fmt.Printf("✅ %s: ok\n", path)
+		return 0
+	case errors.As(err, &verr):
+		fmt.Printf("❌ %s: %d errors\n", path, len(verr.Errors))
+		for _, fe := range verr.Errors {
+			fmt.Printf("  %s\n", fe.Error())
+		}
+		return 1
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	stdlog "log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,76 +12,262 @@ import (
 	"syscall"
 	"time"
 
+	paymentv1 "github.com/ymqzj/payment-gateway/api/proto/payment/v1"
 	v1 "github.com/ymqzj/payment-gateway/api/v1"
 	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/adapters"
+	"github.com/ymqzj/payment-gateway/internal/audit"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/certmon"
+	"github.com/ymqzj/payment-gateway/internal/health"
+	"github.com/ymqzj/payment-gateway/internal/metrics"
+	"github.com/ymqzj/payment-gateway/internal/order"
 	"github.com/ymqzj/payment-gateway/internal/payment"
-	"github.com/ymqzj/payment-gateway/pkg/payadapter/alipay"
-	"github.com/ymqzj/payment-gateway/pkg/payadapter/unionpay"
-	"github.com/ymqzj/payment-gateway/pkg/payadapter/wechat"
+	"github.com/ymqzj/payment-gateway/internal/ratelimit"
+	"github.com/ymqzj/payment-gateway/internal/routing"
+	"github.com/ymqzj/payment-gateway/internal/signing"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
+	logger "github.com/ymqzj/payment-gateway/logs"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	// 加载配置
+	// config check 子命令只校验配置，不启动服务
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "check" {
+		os.Exit(runConfigCheck(os.Args[3:]))
+	}
+
+	// 加载并校验配置，渠道配置错误不阻止启动，该渠道以不可用状态降级运行
+	// 日志在配置校验通过后初始化，此前的致命错误通过标准库输出
 	cfg := configs.Load(configs.GetEnv())
+	validateErr := cfg.Validate()
+	var verr *configs.ValidationError
+	if validateErr != nil && (!errors.As(validateErr, &verr) || verr.Fatal()) {
+		stdlog.Fatalf("Invalid config: %v", validateErr)
+	}
+	if err := logger.Init(cfg.Logging); err != nil {
+		stdlog.Fatalf("Failed to init logger: %v", err)
+	}
+	log := logger.GetLogger()
+	defer log.Sync()
+	if validateErr != nil {
+		log.Warn("部分渠道配置无效，相关渠道不可用", zap.Error(validateErr))
+	}
 
 	// 设置Gin模式
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// 创建支付适配器
-	wechatAdapter, err := wechat.NewAdapter(cfg)
+	// 创建密钥提供者
+	secrets, err := secret.NewFromConfig(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create wechat adapter: %v", err)
+		log.Fatal("Failed to create secret provider", zap.Error(err))
 	}
 
-	// 因 alipay.NewAdapter 未定义，需检查是否缺少包引
-	if err != nil {
-		log.Fatalf("Failed to create alipay adapter: %v", err)
+	// 加载商户 API 凭证
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		credentials, err := auth.LoadCredentials(context.Background(), cfg.Auth, secrets)
+		if err != nil {
+			log.Fatal("Failed to load API credentials", zap.Error(err))
+		}
+		authenticator = auth.NewAuthenticator(credentials, cfg.Auth.MaxSkew)
+	} else {
+		log.Warn("商户接口鉴权未启用，任何人都可以调用支付接口")
 	}
-	alipayAdapter, err := alipay.NewAdapter(cfg)
+
+	// 加载网关签名密钥，用于响应签名和商户事件推送
+	signer, err := signing.LoadSigner(context.Background(), cfg.Signing, secrets)
 	if err != nil {
-		log.Fatalf("Failed to create alipay adapter: %v", err)
+		log.Fatal("Failed to load signing key", zap.Error(err))
+	}
+	if cfg.Signing.PrivateKey == "" {
+		log.Warn("未配置 signing.private_key，使用临时签名密钥，重启后会变化", zap.String("key_id", signer.KeyID()))
 	}
 
-	unionpayAdapter, err := unionpay.NewAdapter(cfg)
+	// 初始化链路追踪，trace context 从 HTTP 请求经网关传递到渠道 SDK 的请求
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatalf("Failed to create unionpay adapter: %v", err)
+		log.Fatal("Failed to init tracing", zap.Error(err))
 	}
 
-	// 创建支付网关
-	gateway := payment.NewPaymentGateway(
-		wechatAdapter,
-		alipayAdapter,
-		unionpayAdapter,
-	)
+	// 打开审计存储，记录与渠道之间的报文往来，用于处理交易争议
+	var audits audit.Store
+	if cfg.Audit.Enabled {
+		auditStore, err := audit.OpenFileStore(cfg.Audit.Path)
+		if err != nil {
+			log.Fatal("Failed to open audit log", zap.Error(err))
+		}
+		defer auditStore.Close()
+		audits = auditStore
+	}
+
+	// 创建支付网关，构建已启用渠道的适配器，失败的渠道在后台重试
+	gateway := payment.NewPaymentGateway()
+	var inbound *ratelimit.Inbound
+	var outbound *ratelimit.Outbound
+	if cfg.RateLimit.Enabled {
+		inbound = ratelimit.NewInbound(cfg.RateLimit)
+		outbound = ratelimit.NewOutbound(cfg.RateLimit)
+	}
+	// 指标在最外层，统计包含被限流、熔断拒绝的调用和重试耗时
+	var gatewayMetrics *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		gatewayMetrics = metrics.New()
+		gatewayMetrics.Register(metrics.NewRateLimitCollector(inbound, outbound), metrics.NewCertCollector(gateway))
+		gateway.Use(gatewayMetrics.Middleware())
+	}
+	// 一次渠道调用的所有重试归在同一个 span 下
+	gateway.Use(tracing.Middleware())
+	// 出站限流在重试和熔断外层，被限流的调用不重试也不计入熔断统计
+	if outbound != nil {
+		gateway.Use(outbound.Middleware())
+	}
+	// 审计在重试外层，一次调用的所有尝试记录在同一条记录中；被限流的调用未发往渠道，不记录
+	if audits != nil {
+		gateway.Use(audit.Middleware(audits, cfg.Audit.MaxBody))
+	}
+	// 重试在熔断外层，每次尝试都计入熔断统计
+	if cfg.Retry.Enabled {
+		gateway.Use(payment.RetryMiddleware(retryOptions(cfg.Retry)))
+	}
+	if cfg.Breaker.Enabled {
+		gateway.SetCircuitBreaker(payment.NewCircuitBreaker(breakerOptions(cfg.Breaker)))
+	}
+
+	adaptersCtx, stopAdapters := context.WithCancel(context.Background())
+	defer stopAdapters()
+
+	reloader := adapters.NewReloader(gateway, configs.ConfigFileUsed(), cfg, secrets)
+	reloader.Init(adaptersCtx)
+	if gateway.Degraded() {
+		log.Warn("部分渠道初始化失败，以降级模式启动")
+	}
+
+	// 定期检查证书有效期，剩余天数不超过阈值时告警
+	if cfg.CertMonitor.Enabled {
+		sinks := []certmon.Sink{certmon.LogSink{}}
+		if cfg.CertMonitor.WebhookURL != "" {
+			// 告警地址由运维配置而不是商户提供，允许推送到内网
+			alerts := webhook.NewDispatcher(signer, webhook.Policy{Schemes: []string{"http", "https"}, AllowPrivate: true}, nil)
+			sinks = append(sinks, certmon.NewWebhookSink(alerts, cfg.CertMonitor.WebhookURL))
+		}
+		certmon.NewMonitor(gateway, cfg.CertMonitor, sinks...).Start(adaptersCtx)
+	}
+
+	// 监听配置和证书文件，变更后热加载适配器
+	if err := reloader.Start(adaptersCtx); err != nil {
+		log.Warn("证书热加载未启用", zap.Error(err))
+	}
 
 	// 创建HTTP处理器
-	handler := v1.NewPaymentHandler(gateway)
+	routeEngine := routing.NewEngine(cfg.Routing, gateway)
+	// 订单持久化，重启后仍能按下单时的 event_url 推送渠道通知
+	orders, err := order.OpenFileStore(cfg.Order.Path)
+	if err != nil {
+		log.Fatal("Failed to open order store", zap.Error(err))
+	}
+	defer orders.Close()
+	// 商户事件推送，待投递事件持久化，重启后继续投递
+	eventQueue, err := webhook.OpenFileQueue(cfg.Webhook.QueuePath)
+	if err != nil {
+		log.Fatal("Failed to open webhook queue", zap.Error(err))
+	}
+	defer eventQueue.Close()
+	events := webhook.NewDispatcher(signer, webhook.Policy{
+		Schemes:      cfg.Webhook.AllowedSchemes,
+		Hosts:        cfg.Webhook.AllowedHosts,
+		AllowPrivate: cfg.Webhook.AllowPrivate,
+	}, eventQueue)
+	events.OnDelivery(v1.RecordDeliveries(orders))
+	// 先停止投递再关闭队列
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	defer stopEvents()
+	events.Start(eventsCtx)
+	handler := v1.NewPaymentHandler(gateway, routeEngine, orders, events, audits)
+	adminHandler := v1.NewAdminHandler(gateway, inbound, outbound, audits)
+	orderAdmin := v1.NewOrderAdminHandler(gateway, orders, events)
+
+	// 就绪检查覆盖各渠道适配器和外部依赖，审计存储不可用不影响支付，不作为必需依赖
+	checker := health.NewChecker(gateway, cfg.Health, reloader.Config)
+	if pinger, ok := audits.(health.Pinger); ok {
+		checker.AddDependency("audit", pinger, false)
+	}
+	healthHandler := v1.NewHealthHandler(checker)
+
+	// 公开接口的 OpenAPI 文档，发布在 /api/v1/openapi.json
+	spec, err := v1.LoadOpenAPI()
+	if err != nil {
+		log.Fatal("加载 OpenAPI 文档失败", zap.Error(err))
+	}
 
-	// 创建Gin路由
-	router := gin.Default()
+	// 创建Gin路由，访问日志和 panic 恢复使用统一的结构化日志
+	router := gin.New()
 
 	// 设置中间件
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	router.Use(v1.Tracing())
+	router.Use(v1.RequestLogger())
+	router.Use(v1.Recovery())
+
+	// 商户接口按授权范围鉴权，支付表单、同步跳转和渠道通知由用户浏览器或渠道发起，不鉴权
+	requirePay := v1.RequireScope(authenticator, auth.ScopePay, cfg.Auth.MaxBody)
+	requireRefund := v1.RequireScope(authenticator, auth.ScopeRefund, cfg.Auth.MaxBody)
+	requireQuery := v1.RequireScope(authenticator, auth.ScopeQuery, cfg.Auth.MaxBody)
+	requireAdmin := v1.RequireScope(authenticator, auth.ScopeAdmin, cfg.Auth.MaxBody)
+	// 入站限流在鉴权之后，渠道通知不限流
+	rateLimit := v1.RateLimit(inbound)
+	// 按 OpenAPI 文档校验请求和响应，在鉴权和限流之后；渠道通知和同步跳转的报文格式由渠道定义，不校验
+	validate := spec.Validate(cfg.OpenAPI.ValidateRequests, cfg.OpenAPI.ValidateResponses)
+
+	// 存活和就绪检查，供容器编排和负载均衡使用，不鉴权
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
 
-	// 设置路由
-	v1 := router.Group("/api/v1")
+	// 网关签名公钥
+	router.GET(signing.WellKnownPath, v1.PublicKeys(signer))
+
+	// 设置路由，所有响应都经过网关签名
+	v1 := router.Group("/api/v1", v1.SignResponses(signer))
 	{
-		v1.POST("/pay", handler.Pay)
-		v1.POST("/query", handler.Query)
-		v1.POST("/refund", handler.Refund)
-		v1.POST("/close", handler.Close)
-		v1.GET("/channels", handler.GetChannels)
-		v1.GET("/health", handler.Health)
+		v1.POST("/pay", requirePay, rateLimit, validate, handler.Pay)
+		v1.POST("/query", requireQuery, rateLimit, validate, handler.Query)
+		v1.POST("/refund", requireRefund, rateLimit, validate, handler.Refund)
+		v1.POST("/close", requirePay, rateLimit, validate, handler.Close)
+		v1.GET("/channels", validate, handler.GetChannels)
+		v1.GET("/health", validate, handler.Health)
+		v1.GET("/openapi.json", spec.Serve)
+
+		// 路由试算
+		v1.POST("/route/explain", requirePay, rateLimit, validate, handler.ExplainRoute)
+
+		// 前台跳转支付
+		v1.GET("/pay/form/:channel/:token", rateLimit, validate, handler.PayForm)
+		v1.POST("/return/:channel", rateLimit, handler.HandleReturn)
 
 		// 通知接口
 		v1.POST("/notify/:channel", handler.HandleNotify)
 	}
 
+	// 运维接口
+	admin := router.Group("/admin/v1", requireAdmin)
+	{
+		admin.GET("/certs", adminHandler.Certs)
+		admin.GET("/ratelimits", adminHandler.RateLimits)
+		admin.GET("/audit/:out_trade_no", adminHandler.Audit)
+		admin.GET("/orders", orderAdmin.Search)
+		admin.GET("/orders/:out_trade_no", orderAdmin.Get)
+		admin.GET("/orders/:out_trade_no/timeline", orderAdmin.Timeline)
+		admin.POST("/orders/:out_trade_no/sync", orderAdmin.Sync)
+		admin.POST("/orders/:out_trade_no/close", orderAdmin.Close)
+		admin.POST("/orders/:out_trade_no/redeliver", orderAdmin.Redeliver)
+	}
+
 	// 创建HTTP服务器
 	srv := &http.Server{
 		Addr:    ":" + strconv.Itoa(cfg.Server.Port),
@@ -88,25 +276,122 @@ func main() {
 
 	// 启动服务器
 	go func() {
-		log.Printf("🚀 服务器启动在端口 %d", cfg.Server.Port)
+		log.Info("服务器启动", zap.Int("port", cfg.Server.Port))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("服务器启动失败: %v", err)
+			log.Fatal("服务器启动失败", zap.Error(err))
 		}
 	}()
 
+	// gRPC 接口与 HTTP 接口共用处理流程、鉴权和限流
+	var grpcSrv *grpc.Server
+	if cfg.GRPC.Enabled {
+		grpcSrv = newGRPCServer(handler, authenticator, inbound)
+		lis, err := net.Listen("tcp", ":"+strconv.Itoa(cfg.GRPC.Port))
+		if err != nil {
+			log.Fatal("gRPC 服务监听失败", zap.Error(err))
+		}
+		go func() {
+			log.Info("gRPC 服务启动", zap.Int("port", cfg.GRPC.Port))
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Fatal("gRPC 服务启动失败", zap.Error(err))
+			}
+		}()
+	}
+
+	// 指标在独立端口暴露，不经过 API 鉴权，不应对公网开放
+	var metricsSrv *http.Server
+	if gatewayMetrics != nil {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.Metrics.Path, gatewayMetrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:    ":" + strconv.Itoa(cfg.Metrics.Port),
+			Handler: mux,
+		}
+		go func() {
+			log.Info("指标服务启动", zap.Int("port", cfg.Metrics.Port), zap.String("path", cfg.Metrics.Path))
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal("指标服务启动失败", zap.Error(err))
+			}
+		}()
+	}
+
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("🔄 服务器关闭中...")
+	log.Info("服务器关闭中")
 
 	// 优雅关闭
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("服务器关闭失败:", err)
+		log.Fatal("服务器关闭失败", zap.Error(err))
+	}
+	if grpcSrv != nil {
+		stopGRPC(ctx, grpcSrv)
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(ctx); err != nil {
+			log.Warn("指标服务关闭失败", zap.Error(err))
+		}
 	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Warn("链路追踪关闭失败", zap.Error(err))
+	}
+
+	log.Info("服务器已关闭")
+}
+
+// retryOptions 重试配置转换为重试参数
+func retryOptions(cfg configs.RetryConfig) payment.RetryOptions {
+	return payment.RetryOptions{
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Jitter:         cfg.Jitter,
+	}
+}
+
+// breakerOptions 熔断配置转换为熔断参数
+func breakerOptions(cfg configs.BreakerConfig) payment.BreakerOptions {
+	return payment.BreakerOptions{
+		Window:           cfg.Window,
+		MinRequests:      cfg.MinRequests,
+		ErrorRate:        cfg.ErrorRate,
+		SlowCall:         cfg.SlowCall,
+		SlowCallRate:     cfg.SlowCallRate,
+		OpenTimeout:      cfg.OpenTimeout,
+		HalfOpenRequests: cfg.HalfOpenRequests,
+	}
+}
 
-	log.Println("✅ 服务器已关闭")
+// newGRPCServer 创建 gRPC 服务，拦截器顺序与 HTTP 中间件一致：日志、恢复、鉴权、限流
+func newGRPCServer(handler *v1.PaymentHandler, authenticator *auth.Authenticator, inbound *ratelimit.Inbound) *grpc.Server {
+	loggerUnary, loggerStream := v1.GRPCLogger()
+	recoveryUnary, recoveryStream := v1.GRPCRecovery()
+	authUnary, authStream := v1.GRPCAuth(authenticator)
+	limitUnary, limitStream := v1.GRPCRateLimit(inbound)
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(loggerUnary, recoveryUnary, authUnary, limitUnary),
+		grpc.ChainStreamInterceptor(loggerStream, recoveryStream, authStream, limitStream),
+	)
+	paymentv1.RegisterPaymentServiceServer(srv, v1.NewGRPCServer(handler))
+	return srv
+}
+
+// stopGRPC 优雅关闭 gRPC 服务，WatchOrder 等长连接在 ctx 结束时强制断开
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+	}
 }
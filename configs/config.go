@@ -1,24 +1,48 @@
 package configs
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config 全局配置结构体
 type Config struct {
-	Wechat   WechatConfig   `mapstructure:"wechat"`
-	Alipay   AlipayConfig   `mapstructure:"alipay"`
-	UnionPay UnionPayConfig `mapstructure:"unionpay"`
-	Server   ServerConfig   `mapstructure:"server"`
-	Logging  LoggingConfig  `mapstructure:"logging"`
-	Database DatabaseConfig `mapstructure:"database"`
+	Wechat      WechatConfig      `mapstructure:"wechat"`
+	Alipay      AlipayConfig      `mapstructure:"alipay"`
+	UnionPay    UnionPayConfig    `mapstructure:"unionpay"`
+	Server      ServerConfig      `mapstructure:"server"`
+	GRPC        GRPCConfig        `mapstructure:"grpc"`
+	OpenAPI     OpenAPIConfig     `mapstructure:"openapi"`
+	Logging     LoggingConfig     `mapstructure:"logging"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Secrets     SecretsConfig     `mapstructure:"secrets"`
+	Routing     RoutingConfig     `mapstructure:"routing"`
+	Breaker     BreakerConfig     `mapstructure:"breaker"`
+	Retry       RetryConfig       `mapstructure:"retry"`
+	Auth        AuthConfig        `mapstructure:"auth"`
+	Signing     SigningConfig     `mapstructure:"signing"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Metrics     MetricsConfig     `mapstructure:"metrics"`
+	Tracing     TracingConfig     `mapstructure:"tracing"`
+	Audit       AuditConfig       `mapstructure:"audit"`
+	Health      HealthConfig      `mapstructure:"health"`
+	CertMonitor CertMonitorConfig `mapstructure:"cert_monitor"`
+	Webhook     WebhookConfig     `mapstructure:"webhook"`
+	Order       OrderConfig       `mapstructure:"order"`
+
+	unsetEnv []string // 配置文件中引用但未设置的环境变量
 }
 
 // WechatConfig 微信支付配置
 type WechatConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
 	AppID        string `mapstructure:"app_id"`
 	MchID        string `mapstructure:"mch_id"`
 	APIKey       string `mapstructure:"api_key"`
@@ -31,24 +55,32 @@ type WechatConfig struct {
 
 // AlipayConfig 支付宝配置
 type AlipayConfig struct {
-	AppID           string `mapstructure:"app_id"`
-	PrivateKey      string `mapstructure:"private_key"`
-	AlipayPublicKey string `mapstructure:"alipay_public_key"`
-	GatewayURL      string `mapstructure:"gateway_url"`
-	Charset         string `mapstructure:"charset"`
-	SignType        string `mapstructure:"sign_type"`
-	NotifyURL       string `mapstructure:"notify_url"`
-	ReturnURL       string `mapstructure:"return_url"`
+	Enabled              bool   `mapstructure:"enabled"`
+	AppID                string `mapstructure:"app_id"`
+	PrivateKey           string `mapstructure:"private_key"`
+	AlipayPublicKey      string `mapstructure:"alipay_public_key"`
+	AppPublicCertPath    string `mapstructure:"app_public_cert_path"`
+	AlipayPublicCertPath string `mapstructure:"alipay_public_cert_path"`
+	AlipayRootCertPath   string `mapstructure:"alipay_root_cert_path"`
+	GatewayURL           string `mapstructure:"gateway_url"`
+	Charset              string `mapstructure:"charset"`
+	SignType             string `mapstructure:"sign_type"`
+	NotifyURL            string `mapstructure:"notify_url"`
+	ReturnURL            string `mapstructure:"return_url"`
+	Sandbox              bool   `mapstructure:"sandbox"`
 }
 
 // UnionPayConfig 银联配置
 type UnionPayConfig struct {
+	Enabled        bool   `mapstructure:"enabled"`
 	MerID          string `mapstructure:"mer_id"`
 	AppId          string `mapstructure:"app_id"`
 	CertPath       string `mapstructure:"cert_path"`
 	CertPwd        string `mapstructure:"cert_pwd"`
 	PrivateKeyPath string `mapstructure:"private_key_path"`
 	PublicKeyPath  string `mapstructure:"public_key_path"`
+	RootCertPath   string `mapstructure:"root_cert_path"`
+	MiddleCertPath string `mapstructure:"middle_cert_path"`
 	Gateway        string `mapstructure:"gateway"`
 	BackURL        string `mapstructure:"back_url"`
 	FrontURL       string `mapstructure:"front_url"`
@@ -56,15 +88,86 @@ type UnionPayConfig struct {
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port      int    `mapstructure:"port"`
+	Mode      string `mapstructure:"mode"`
+	PublicURL string `mapstructure:"public_url"` // 对外访问地址，如 https://pay.yourdomain.com
+}
+
+// GRPCConfig gRPC 接口配置，与 HTTP 接口共用鉴权、限流和订单存储
+type GRPCConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Port    int  `mapstructure:"port"`
+}
+
+// OpenAPIConfig 按 OpenAPI 文档校验公开接口的请求和响应
+type OpenAPIConfig struct {
+	ValidateRequests  bool `mapstructure:"validate_requests"`  // 请求不符合文档时返回参数错误
+	ValidateResponses bool `mapstructure:"validate_responses"` // 响应不符合文档时记录日志，会缓存响应 body
+}
+
+// MetricsConfig Prometheus 指标配置，指标在独立端口上暴露，不经过 API 鉴权
+type MetricsConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Port    int    `mapstructure:"port"`
+	Path    string `mapstructure:"path"`
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Exporter    string  `mapstructure:"exporter"`     // otlp：通过 OTLP/HTTP 导出；none：只传播 trace context，不导出
+	Endpoint    string  `mapstructure:"endpoint"`     // OTLP 接收地址，如 http://otel-collector:4318，为空时读取 OTEL_EXPORTER_OTLP_ENDPOINT
+	ServiceName string  `mapstructure:"service_name"` // 上报的服务名
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 根 span 采样比例，上游已采样的请求始终采样
 }
 
 // LoggingConfig 日志配置
 type LoggingConfig struct {
-	Level  string `mapstructure:"level"`
-	Format string `mapstructure:"format"`
-	Output string `mapstructure:"output"`
+	Level      string `mapstructure:"level"`       // debug、info、warn、error
+	Format     string `mapstructure:"format"`      // json 或 console
+	Output     string `mapstructure:"output"`      // stdout、stderr 或文件路径，写文件时按大小轮转
+	MaxSize    int    `mapstructure:"max_size"`    // 单个日志文件的最大大小，单位 MB
+	MaxBackups int    `mapstructure:"max_backups"` // 保留的轮转文件数，0 表示不限
+	MaxAge     int    `mapstructure:"max_age"`     // 轮转文件保留天数，0 表示不限
+	Compress   bool   `mapstructure:"compress"`    // 是否 gzip 压缩轮转文件
+}
+
+// AuditConfig 渠道调用审计日志配置
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`     // 审计记录文件，只追加写入，每行一条 JSON 记录
+	MaxBody int    `mapstructure:"max_body"` // 单个报文保留的最大字节数，超出部分截断
+}
+
+// HealthConfig 就绪检查配置
+type HealthConfig struct {
+	RequiredChannels []string      `mapstructure:"required_channels"` // 不可用时就绪检查失败的渠道，其余渠道不可用只报告降级
+	CertWarnDays     int           `mapstructure:"cert_warn_days"`    // 证书剩余有效天数低于该值时报告 warn
+	Probe            bool          `mapstructure:"probe"`             // 是否以查询不存在订单的方式探测渠道
+	ProbeInterval    time.Duration `mapstructure:"probe_interval"`    // 探测结果缓存时间，避免频繁的就绪检查调用渠道接口
+	ProbeTimeout     time.Duration `mapstructure:"probe_timeout"`
+}
+
+// CertMonitorConfig 证书有效期监控配置
+type CertMonitorConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Interval   time.Duration `mapstructure:"interval"`    // 检查间隔
+	Thresholds []int         `mapstructure:"thresholds"`  // 剩余天数告警阈值，每张证书在每个阈值只告警一次，过期时再告警一次
+	WebhookURL string        `mapstructure:"webhook_url"` // 告警推送地址，请求体使用网关密钥签名，为空时只记录日志
+}
+
+// WebhookConfig 商户事件推送配置
+// 推送地址由商户下单时提供，只允许公网地址，可进一步限制协议和主机
+type WebhookConfig struct {
+	QueuePath      string   `mapstructure:"queue_path"`      // 待投递事件文件，重启后继续投递未完成的事件
+	AllowedSchemes []string `mapstructure:"allowed_schemes"` // 允许的推送地址协议
+	AllowedHosts   []string `mapstructure:"allowed_hosts"`   // 允许的推送主机，*.example.com 匹配其子域名，为空时不限制主机
+	AllowPrivate   bool     `mapstructure:"allow_private"`   // 是否允许推送到内网、回环等非公网地址，仅用于本地联调
+}
+
+// OrderConfig 订单存储配置
+type OrderConfig struct {
+	Path string `mapstructure:"path"` // 订单文件，只追加写入，重启后恢复订单和商户的事件推送地址
 }
 
 // DatabaseConfig 数据库配置
@@ -79,19 +182,106 @@ type DatabaseConfig struct {
 	MaxIdleConns int    `mapstructure:"max_idle_conns"`
 }
 
+// SecretsConfig 密钥来源配置
+// 渠道配置中的密钥项可以写成 env:VAR、file:/path、keystore:name、kms:keyID/密文 形式的引用
+type SecretsConfig struct {
+	KeystorePath          string `mapstructure:"keystore_path"`
+	KeystorePassphraseEnv string `mapstructure:"keystore_passphrase_env"`
+}
+
+// searchPaths 配置文件的查找目录
+var searchPaths = []string{"./configs", "../configs", "../../configs", "."}
+
+// RoutingConfig 自动选择渠道（channel=auto）的路由配置
+type RoutingConfig struct {
+	Default        []string            `mapstructure:"default"`          // 未命中规则时的候选渠道，按优先级排列
+	Merchants      map[string][]string `mapstructure:"merchants"`        // 商户偏好渠道，优先于规则中的顺序
+	MinSuccessRate float64             `mapstructure:"min_success_rate"` // 成功率低于该值的渠道不参与选择
+	MinSamples     int                 `mapstructure:"min_samples"`      // 统计样本少于该值时不按成功率过滤
+	Window         time.Duration       `mapstructure:"window"`           // 成功率统计窗口
+	Rules          []RoutingRule       `mapstructure:"rules"`
+}
+
+// RoutingRule 路由规则，条件之间为"与"关系，同一条件的多个取值为"或"关系，未配置的条件不限制
+type RoutingRule struct {
+	Name       string   `mapstructure:"name"`
+	Scenes     []string `mapstructure:"scenes"`
+	MinAmount  float64  `mapstructure:"min_amount"`  // 含
+	MaxAmount  float64  `mapstructure:"max_amount"`  // 不含，0 表示不限
+	UserAgents []string `mapstructure:"user_agents"` // User-Agent 包含任一关键字，不区分大小写
+	Apps       []string `mapstructure:"apps"`
+	Merchants  []string `mapstructure:"merchants"`
+	Channels   []string `mapstructure:"channels"` // 候选渠道，按优先级排列
+}
+
+// BreakerConfig 渠道熔断配置
+type BreakerConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Window           time.Duration `mapstructure:"window"`             // 错误率统计窗口
+	MinRequests      int           `mapstructure:"min_requests"`       // 窗口内请求数达到该值才判断是否熔断
+	ErrorRate        float64       `mapstructure:"error_rate"`         // 错误率阈值
+	SlowCall         time.Duration `mapstructure:"slow_call"`          // 慢调用耗时阈值
+	SlowCallRate     float64       `mapstructure:"slow_call_rate"`     // 慢调用比例阈值，0 表示不按耗时熔断
+	OpenTimeout      time.Duration `mapstructure:"open_timeout"`       // 熔断持续时间，之后进入半开状态
+	HalfOpenRequests int           `mapstructure:"half_open_requests"` // 半开状态放行的探测请求数
+}
+
+// RetryConfig 渠道调用重试配置，只重试查询、关单和退款等幂等操作
+type RetryConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // 最大尝试次数，包含首次调用
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"` // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`     // 单次等待时间上限
+	Jitter         float64       `mapstructure:"jitter"`          // 等待时间随机浮动比例，避免重试同时打到渠道
+}
+
+// AuthConfig 商户接口鉴权配置
+type AuthConfig struct {
+	Enabled bool           `mapstructure:"enabled"`
+	MaxSkew time.Duration  `mapstructure:"max_skew"` // 请求时间戳与服务器时间的最大偏差，同时是 nonce 的保留时间
+	MaxBody int64          `mapstructure:"max_body"` // 验签时读取的请求体最大字节数，超出返回 413
+	Keys    []APIKeyConfig `mapstructure:"keys"`
+}
+
+// APIKeyConfig 商户 API 凭证
+type APIKeyConfig struct {
+	AppKey     string   `mapstructure:"app_key"`
+	Secret     string   `mapstructure:"secret"`      // 签名密钥，支持 env:、keystore:、kms: 引用
+	MerchantID string   `mapstructure:"merchant_id"` // 绑定的商户，设置后只能以该商户身份下单
+	Scopes     []string `mapstructure:"scopes"`      // 授权范围：pay、refund、query、admin
+}
+
+// SigningConfig 网关响应和商户事件的签名配置
+type SigningConfig struct {
+	KeyID      string `mapstructure:"key_id"`      // 密钥标识，为空时取公钥指纹
+	PrivateKey string `mapstructure:"private_key"` // Ed25519 私钥（PKCS#8 PEM），文件路径或机密引用；为空时启动时生成临时密钥
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Enabled     bool                   `mapstructure:"enabled"`
+	PerKey      LimitConfig            `mapstructure:"per_key"`      // 每个 app key 的总限额
+	Endpoints   map[string]LimitConfig `mapstructure:"endpoints"`    // 每个 app key 在单个接口上的限额，键为 /api/v1 下的路径，如 pay、refund
+	Channels    map[string]LimitConfig `mapstructure:"channels"`     // 调用渠道接口的总限额，对应渠道的 QPS 配额
+	PerMerchant LimitConfig            `mapstructure:"per_merchant"` // 每个商户在单个渠道上的限额
+}
+
+// LimitConfig 令牌桶参数，rate 为 0 表示不限
+type LimitConfig struct {
+	Rate  float64 `mapstructure:"rate"`  // 每秒补充的令牌数
+	Burst int     `mapstructure:"burst"` // 桶容量，为 0 时取 rate 向上取整
+}
+
 // Load 加载配置
 func Load(env string) *Config {
-	config := &Config{}
-
 	// 设置配置文件路径
 	viper.SetConfigName(env)
 	viper.SetConfigType("yaml")
 
 	// 添加多个可能的配置文件路径
-	viper.AddConfigPath("./configs")
-	viper.AddConfigPath("../configs")
-	viper.AddConfigPath("../../configs")
-	viper.AddConfigPath(".")
+	for _, dir := range searchPaths {
+		viper.AddConfigPath(dir)
+	}
 
 	// 读取环境变量
 	viper.AutomaticEnv()
@@ -101,14 +291,132 @@ func Load(env string) *Config {
 		log.Fatalf("Error reading config file, %s", err)
 	}
 
-	// 解析配置
-	if err := viper.Unmarshal(config); err != nil {
+	// 展开占位符后重新读取并解析配置
+	config, err := decode(viper.GetViper(), viper.ConfigFileUsed())
+	if err != nil {
 		log.Fatalf("Unable to decode into struct, %v", err)
 	}
 
 	return config
 }
 
+// LoadFile 从指定文件加载配置
+// 使用独立的 viper 实例，不影响全局配置，可用于热加载
+func LoadFile(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+	v.AutomaticEnv()
+
+	return decode(v, path)
+}
+
+// decode 读取配置文件，展开环境变量占位符后解析为 Config
+func decode(v *viper.Viper, path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file %s failed: %w", path, err)
+	}
+
+	// 先解析配置文件，再展开解析后的配置值，环境变量中的特殊字符不会被当作 YAML 解析
+	raw := viper.New()
+	raw.SetConfigType(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err := raw.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("parse config file %s failed: %w", path, err)
+	}
+	settings := raw.AllSettings()
+	unset := ExpandEnv(settings)
+
+	// 用展开后的配置替换 v 中已读取的原始配置
+	setDefaults(v)
+	if err := v.ReadConfig(bytes.NewReader(nil)); err != nil {
+		return nil, fmt.Errorf("parse config file %s failed: %w", path, err)
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("parse config file %s failed: %w", path, err)
+	}
+
+	config := &Config{unsetEnv: unset}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, fmt.Errorf("decode config file %s failed: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// setDefaults 设置配置默认值
+func setDefaults(v *viper.Viper) {
+	// 渠道默认启用，兼容没有 enabled 配置项的旧配置
+	v.SetDefault("wechat.enabled", true)
+	v.SetDefault("alipay.enabled", true)
+	v.SetDefault("unionpay.enabled", true)
+
+	v.SetDefault("routing.min_samples", 20)
+	v.SetDefault("routing.window", "10m")
+
+	v.SetDefault("breaker.enabled", true)
+	v.SetDefault("breaker.window", "30s")
+	v.SetDefault("breaker.min_requests", 20)
+	v.SetDefault("breaker.error_rate", 0.5)
+	v.SetDefault("breaker.slow_call", "5s")
+	v.SetDefault("breaker.slow_call_rate", 0.8)
+	v.SetDefault("breaker.open_timeout", "30s")
+	v.SetDefault("breaker.half_open_requests", 3)
+	v.SetDefault("retry.enabled", true)
+	v.SetDefault("retry.max_attempts", 3)
+	v.SetDefault("retry.initial_backoff", "200ms")
+	v.SetDefault("retry.max_backoff", "2s")
+	v.SetDefault("retry.jitter", 0.2)
+	v.SetDefault("auth.enabled", true)
+	v.SetDefault("auth.max_skew", "5m")
+	v.SetDefault("auth.max_body", 1048576)
+	v.SetDefault("rate_limit.enabled", true)
+	v.SetDefault("grpc.enabled", true)
+	v.SetDefault("grpc.port", 50051)
+	v.SetDefault("openapi.validate_requests", true)
+	v.SetDefault("openapi.validate_responses", false)
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.port", 9090)
+	v.SetDefault("metrics.path", "/metrics")
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "json")
+	v.SetDefault("logging.output", "stdout")
+	v.SetDefault("logging.max_size", 100)
+	v.SetDefault("logging.max_backups", 10)
+	v.SetDefault("logging.max_age", 30)
+	v.SetDefault("logging.compress", true)
+	v.SetDefault("tracing.exporter", "otlp")
+	v.SetDefault("tracing.service_name", "payment-gateway")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("audit.enabled", true)
+	v.SetDefault("audit.path", "data/audit.jsonl")
+	v.SetDefault("audit.max_body", 65536)
+	v.SetDefault("health.cert_warn_days", 30)
+	v.SetDefault("health.probe_interval", "1m")
+	v.SetDefault("health.probe_timeout", "5s")
+	v.SetDefault("cert_monitor.enabled", true)
+	v.SetDefault("cert_monitor.interval", "1h")
+	v.SetDefault("cert_monitor.thresholds", []int{30, 14, 7, 1})
+	v.SetDefault("webhook.queue_path", "data/webhook_queue.jsonl")
+	v.SetDefault("webhook.allowed_schemes", []string{"https"})
+	v.SetDefault("order.path", "data/orders.jsonl")
+}
+
+// Path 按 Load 的查找顺序返回环境对应的配置文件路径
+func Path(env string) (string, error) {
+	for _, dir := range searchPaths {
+		path := filepath.Join(dir, env+".yaml")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("config file %s.yaml not found in %s", env, strings.Join(searchPaths, ", "))
+}
+
+// ConfigFileUsed 返回 Load 实际读取的配置文件路径
+func ConfigFileUsed() string {
+	return viper.ConfigFileUsed()
+}
+
 // GetEnv 获取当前环境
 func GetEnv() string {
 	env := os.Getenv("ENV")
@@ -0,0 +1,80 @@
+package configs
+
+import (
+	"os"
+	"regexp"
+	"sort"
+)
+
+// placeholderPattern 匹配 ${VAR}、${VAR:-default}、${VAR-default}
+var placeholderPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?-)([^}]*))?\}`)
+
+// ExpandEnv 展开已解析配置中字符串值的环境变量占位符
+//   - ${VAR}          变量值，未设置时为空
+//   - ${VAR:-default} 变量未设置或为空时使用 default
+//   - ${VAR-default}  仅变量未设置时使用 default
+//
+// 在解析后的配置值上展开，变量值中的引号、#、换行等字符原样保留，不会改变配置结构；
+// 整个值只有一个占位符且展开为空时删除该配置项，与未填写的配置项一样使用默认值
+// 原地修改 settings，返回未设置且没有默认值的变量名
+func ExpandEnv(settings map[string]interface{}) []string {
+	e := &expander{seen: make(map[string]bool)}
+	e.expandMap(settings)
+	sort.Strings(e.missing)
+	return e.missing
+}
+
+// expander 记录展开过程中未设置的变量
+type expander struct {
+	missing []string
+	seen    map[string]bool
+}
+
+func (e *expander) expandMap(m map[string]interface{}) {
+	for key, value := range m {
+		if s, ok := value.(string); ok && placeholderPattern.FindString(s) == s && s != "" {
+			if expanded := e.expandString(s); expanded != "" {
+				m[key] = expanded
+			} else {
+				delete(m, key)
+			}
+			continue
+		}
+		m[key] = e.expandValue(value)
+	}
+}
+
+func (e *expander) expandValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return e.expandString(v)
+	case map[string]interface{}:
+		e.expandMap(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = e.expandValue(v[i])
+		}
+	}
+	return value
+}
+
+func (e *expander) expandString(s string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := placeholderPattern.FindStringSubmatch(match)
+		name, op, def := groups[1], groups[2], groups[3]
+
+		value, ok := os.LookupEnv(name)
+		switch {
+		case op == ":-" && value == "":
+			return def
+		case op == "-" && !ok:
+			return def
+		case !ok && op == "":
+			if !e.seen[name] {
+				e.seen[name] = true
+				e.missing = append(e.missing, name)
+			}
+		}
+		return value
+	})
+}
@@ -0,0 +1,778 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 配置节名称，同时作为校验错误的分组
+const (
+	SectionEnv         = "env"
+	SectionWechat      = "wechat"
+	SectionAlipay      = "alipay"
+	SectionUnionPay    = "unionpay"
+	SectionServer      = "server"
+	SectionGRPC        = "grpc"
+	SectionSecrets     = "secrets"
+	SectionRouting     = "routing"
+	SectionBreaker     = "breaker"
+	SectionRetry       = "retry"
+	SectionAuth        = "auth"
+	SectionSigning     = "signing"
+	SectionRateLimit   = "rate_limit"
+	SectionMetrics     = "metrics"
+	SectionTracing     = "tracing"
+	SectionLogging     = "logging"
+	SectionAudit       = "audit"
+	SectionHealth      = "health"
+	SectionCertMonitor = "cert_monitor"
+	SectionWebhook     = "webhook"
+	SectionOrder       = "order"
+)
+
+// knownChannels 路由配置中可用的渠道名
+var knownChannels = map[string]bool{
+	"wechat":   true,
+	"alipay":   true,
+	"unionpay": true,
+}
+
+// knownScenes 路由配置中可用的支付场景
+var knownScenes = map[string]bool{
+	"app":    true,
+	"h5":     true,
+	"jsapi":  true,
+	"native": true,
+	"pc":     true,
+}
+
+// knownScopes 支持的 API 授权范围
+var knownScopes = map[string]bool{
+	"pay":    true,
+	"refund": true,
+	"query":  true,
+	"admin":  true,
+}
+
+// 银联网关取值
+const (
+	UnionPayGatewaySandbox = "sandbox"
+	UnionPayGatewayProd    = "prod"
+)
+
+// alipayGatewayHosts 已知的支付宝网关域名，值表示是否为沙箱
+var alipayGatewayHosts = map[string]bool{
+	"openapi.alipay.com":               false,
+	"openapi-sandbox.dl.alipaydev.com": true,
+	"openapi.alipaydev.com":            true,
+}
+
+// FieldError 单个配置项的错误
+type FieldError struct {
+	Section string
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Section, e.Message)
+	}
+	return fmt.Sprintf("%s.%s: %s", e.Section, e.Field, e.Message)
+}
+
+// ValidationError 配置校验错误，包含全部问题而不是遇到第一个就返回
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = fe.Error()
+	}
+	return fmt.Sprintf("invalid config (%d errors):\n  %s", len(e.Errors), strings.Join(lines, "\n  "))
+}
+
+// Fatal 是否存在与渠道无关、无法降级运行的错误
+func (e *ValidationError) Fatal() bool {
+	for _, fe := range e.Errors {
+		if fe.Section == SectionServer || fe.Section == SectionGRPC || fe.Section == SectionSecrets ||
+			fe.Section == SectionRouting || fe.Section == SectionBreaker ||
+			fe.Section == SectionRetry || fe.Section == SectionAuth || fe.Section == SectionSigning ||
+			fe.Section == SectionRateLimit || fe.Section == SectionMetrics ||
+			fe.Section == SectionTracing || fe.Section == SectionLogging ||
+			fe.Section == SectionAudit || fe.Section == SectionHealth || fe.Section == SectionCertMonitor ||
+			fe.Section == SectionWebhook || fe.Section == SectionOrder {
+			return true
+		}
+	}
+	return false
+}
+
+// Section 返回指定配置节的错误
+func (e *ValidationError) Section(section string) []FieldError {
+	var errs []FieldError
+	for _, fe := range e.Errors {
+		if fe.Section == section {
+			errs = append(errs, fe)
+		}
+	}
+	return errs
+}
+
+// validator 收集校验错误
+type validator struct {
+	section string
+	errs    []FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Section: v.section, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// required 校验必填项
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+		return false
+	}
+	return true
+}
+
+// url 校验 http(s) 绝对地址，空值跳过
+func (v *validator) url(field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "must be an absolute http(s) URL, got %q", value)
+	}
+}
+
+// file 校验密钥/证书配置项: 本地路径需要存在且可读，机密引用需要来源可用，空值跳过
+func (v *validator) file(field, value string, cfg *Config) {
+	if value == "" {
+		return
+	}
+	if !v.ref(field, value, cfg) {
+		path := strings.TrimPrefix(strings.TrimPrefix(value, "file:"), "//")
+		v.readable(field, path)
+	}
+}
+
+// secret 校验字面量类配置项: 只检查机密引用，字面量原样使用
+func (v *validator) secret(field, value string, cfg *Config) {
+	if value != "" {
+		v.ref(field, value, cfg)
+	}
+}
+
+// ref 校验 env:、keystore:、kms: 引用，返回值表示是否已按引用处理
+// 引用格式与 pkg/secret 保持一致
+func (v *validator) ref(field, value string, cfg *Config) bool {
+	scheme, rest, ok := strings.Cut(value, ":")
+	if !ok {
+		return false
+	}
+	rest = strings.TrimPrefix(rest, "//")
+
+	switch scheme {
+	case "env":
+		if os.Getenv(rest) == "" {
+			v.add(field, "environment variable %s is not set", rest)
+		}
+	case "keystore":
+		if cfg.Secrets.KeystorePath == "" {
+			v.add(field, "references keystore entry %q but secrets.keystore_path is not set", rest)
+		}
+	case "kms":
+		if keyID, ciphertext, ok := strings.Cut(rest, "/"); !ok || keyID == "" || ciphertext == "" {
+			v.add(field, "invalid kms reference, expect kms:<keyID>/<ciphertext>")
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// readable 校验本地文件存在且可读
+func (v *validator) readable(field, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			v.add(field, "file %s does not exist", path)
+		default:
+			v.add(field, "file %s is not readable: %v", path, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		v.add(field, "%s is a directory", path)
+	}
+}
+
+// Validate 校验配置，返回 *ValidationError 汇总所有问题，未启用的渠道不校验
+func (c *Config) Validate() error {
+	var errs []FieldError
+	for _, check := range []func() []FieldError{
+		c.validateEnv,
+		c.validateServer,
+		c.validateSecrets,
+		c.validateWechat,
+		c.validateAlipay,
+		c.validateUnionPay,
+		c.validateRouting,
+		c.validateBreaker,
+		c.validateRetry,
+		c.validateAuth,
+		c.validateSigning,
+		c.validateRateLimit,
+		c.validateGRPC,
+		c.validateMetrics,
+		c.validateTracing,
+		c.validateLogging,
+		c.validateAudit,
+		c.validateHealth,
+		c.validateCertMonitor,
+		c.validateWebhook,
+		c.validateOrder,
+	} {
+		errs = append(errs, check()...)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (c *Config) validateEnv() []FieldError {
+	v := &validator{section: SectionEnv}
+	for _, name := range c.unsetEnv {
+		v.add(name, "referenced by config file but not set")
+	}
+	return v.errs
+}
+
+func (c *Config) validateServer() []FieldError {
+	v := &validator{section: SectionServer}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		v.add("port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	switch c.Server.Mode {
+	case "", "debug", "release", "test":
+	default:
+		v.add("mode", "must be one of debug, release, test, got %q", c.Server.Mode)
+	}
+	v.url("public_url", c.Server.PublicURL)
+	return v.errs
+}
+
+func (c *Config) validateGRPC() []FieldError {
+	v := &validator{section: SectionGRPC}
+	if !c.GRPC.Enabled {
+		return nil
+	}
+	switch {
+	case c.GRPC.Port <= 0 || c.GRPC.Port > 65535:
+		v.add("port", "must be between 1 and 65535, got %d", c.GRPC.Port)
+	case c.GRPC.Port == c.Server.Port:
+		v.add("port", "must differ from server.port %d", c.Server.Port)
+	case c.Metrics.Enabled && c.GRPC.Port == c.Metrics.Port:
+		v.add("port", "must differ from metrics.port %d", c.Metrics.Port)
+	}
+	return v.errs
+}
+
+func (c *Config) validateMetrics() []FieldError {
+	v := &validator{section: SectionMetrics}
+	if !c.Metrics.Enabled {
+		return nil
+	}
+	if c.Metrics.Port <= 0 || c.Metrics.Port > 65535 {
+		v.add("port", "must be between 1 and 65535, got %d", c.Metrics.Port)
+	} else if c.Metrics.Port == c.Server.Port {
+		v.add("port", "must differ from server.port %d", c.Server.Port)
+	}
+	if !strings.HasPrefix(c.Metrics.Path, "/") {
+		v.add("path", "must start with /, got %q", c.Metrics.Path)
+	}
+	return v.errs
+}
+
+func (c *Config) validateTracing() []FieldError {
+	cfg := c.Tracing
+	v := &validator{section: SectionTracing}
+	if !cfg.Enabled {
+		return nil
+	}
+	switch cfg.Exporter {
+	case "otlp":
+		v.url("endpoint", cfg.Endpoint)
+	case "none":
+	default:
+		v.add("exporter", "must be one of otlp, none, got %q", cfg.Exporter)
+	}
+	if cfg.ServiceName == "" {
+		v.add("service_name", "is required")
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		v.add("sample_ratio", "must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+	return v.errs
+}
+
+func (c *Config) validateLogging() []FieldError {
+	cfg := c.Logging
+	v := &validator{section: SectionLogging}
+	switch cfg.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		v.add("level", "must be one of debug, info, warn, error, got %q", cfg.Level)
+	}
+	switch cfg.Format {
+	case "json", "console":
+	default:
+		v.add("format", "must be one of json, console, got %q", cfg.Format)
+	}
+	if cfg.Output == "" {
+		v.add("output", "is required")
+	}
+	if cfg.MaxSize <= 0 {
+		v.add("max_size", "must be positive, got %d", cfg.MaxSize)
+	}
+	if cfg.MaxBackups < 0 {
+		v.add("max_backups", "must not be negative, got %d", cfg.MaxBackups)
+	}
+	if cfg.MaxAge < 0 {
+		v.add("max_age", "must not be negative, got %d", cfg.MaxAge)
+	}
+	return v.errs
+}
+
+func (c *Config) validateAudit() []FieldError {
+	cfg := c.Audit
+	v := &validator{section: SectionAudit}
+	if !cfg.Enabled {
+		return nil
+	}
+	v.required("path", cfg.Path)
+	if cfg.MaxBody <= 0 {
+		v.add("max_body", "must be positive, got %d", cfg.MaxBody)
+	}
+	return v.errs
+}
+
+func (c *Config) validateHealth() []FieldError {
+	cfg := c.Health
+	v := &validator{section: SectionHealth}
+	for _, channel := range cfg.RequiredChannels {
+		if !knownChannels[channel] {
+			v.add("required_channels", "unknown channel %q", channel)
+		}
+	}
+	if cfg.CertWarnDays < 0 {
+		v.add("cert_warn_days", "must not be negative, got %d", cfg.CertWarnDays)
+	}
+	if cfg.Probe {
+		if cfg.ProbeInterval <= 0 {
+			v.add("probe_interval", "must be positive")
+		}
+		if cfg.ProbeTimeout <= 0 {
+			v.add("probe_timeout", "must be positive")
+		}
+	}
+	return v.errs
+}
+
+func (c *Config) validateCertMonitor() []FieldError {
+	cfg := c.CertMonitor
+	v := &validator{section: SectionCertMonitor}
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		v.add("interval", "must be positive")
+	}
+	if len(cfg.Thresholds) == 0 {
+		v.add("thresholds", "is required")
+	}
+	for _, days := range cfg.Thresholds {
+		if days <= 0 {
+			v.add("thresholds", "must be positive, got %d", days)
+		}
+	}
+	v.url("webhook_url", cfg.WebhookURL)
+	return v.errs
+}
+
+func (c *Config) validateWebhook() []FieldError {
+	cfg := c.Webhook
+	v := &validator{section: SectionWebhook}
+	v.required("queue_path", cfg.QueuePath)
+	if len(cfg.AllowedSchemes) == 0 {
+		v.add("allowed_schemes", "is required")
+	}
+	for _, scheme := range cfg.AllowedSchemes {
+		if scheme != "http" && scheme != "https" {
+			v.add("allowed_schemes", "must be http or https, got %q", scheme)
+		}
+	}
+	for _, host := range cfg.AllowedHosts {
+		if strings.TrimSpace(host) == "" || strings.Contains(host, "/") {
+			v.add("allowed_hosts", "invalid host %q", host)
+		}
+	}
+	return v.errs
+}
+
+func (c *Config) validateOrder() []FieldError {
+	v := &validator{section: SectionOrder}
+	v.required("path", c.Order.Path)
+	return v.errs
+}
+
+func (c *Config) validateSecrets() []FieldError {
+	v := &validator{section: SectionSecrets}
+	if c.Secrets.KeystorePath != "" {
+		env := c.Secrets.KeystorePassphraseEnv
+		if env == "" {
+			env = "KEYSTORE_PASSPHRASE"
+		}
+		if os.Getenv(env) == "" {
+			v.add("keystore_passphrase_env", "environment variable %s is not set", env)
+		}
+	}
+	return v.errs
+}
+
+func (c *Config) validateWechat() []FieldError {
+	cfg := c.Wechat
+	v := &validator{section: SectionWechat}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	v.required("app_id", cfg.AppID)
+	v.required("mch_id", cfg.MchID)
+	v.required("cert_serial_no", cfg.CertSerialNo)
+	if v.required("key_path", cfg.KeyPath) {
+		v.file("key_path", cfg.KeyPath, c)
+	}
+	v.file("cert_path", cfg.CertPath, c)
+	if v.required("api_v3_key", cfg.APIV3Key) {
+		if !v.ref("api_v3_key", cfg.APIV3Key, c) && len(cfg.APIV3Key) != 32 {
+			v.add("api_v3_key", "must be 32 bytes, got %d", len(cfg.APIV3Key))
+		}
+	}
+	if v.required("notify_url", cfg.NotifyURL) {
+		v.url("notify_url", cfg.NotifyURL)
+	}
+	return v.errs
+}
+
+func (c *Config) validateAlipay() []FieldError {
+	cfg := c.Alipay
+	v := &validator{section: SectionAlipay}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	v.required("app_id", cfg.AppID)
+	if v.required("private_key", cfg.PrivateKey) {
+		v.secret("private_key", cfg.PrivateKey, c)
+	}
+
+	// 公钥模式和公钥证书模式二选一，证书模式需要三个证书同时配置
+	certs := map[string]string{
+		"app_public_cert_path":    cfg.AppPublicCertPath,
+		"alipay_public_cert_path": cfg.AlipayPublicCertPath,
+		"alipay_root_cert_path":   cfg.AlipayRootCertPath,
+	}
+	var configured int
+	for _, value := range certs {
+		if value != "" {
+			configured++
+		}
+	}
+	switch {
+	case configured == len(certs):
+		for _, field := range []string{"app_public_cert_path", "alipay_public_cert_path", "alipay_root_cert_path"} {
+			v.file(field, certs[field], c)
+		}
+	case configured > 0:
+		for _, field := range []string{"app_public_cert_path", "alipay_public_cert_path", "alipay_root_cert_path"} {
+			if certs[field] == "" {
+				v.add(field, "is required in public key certificate mode")
+			}
+		}
+	case cfg.AlipayPublicKey == "":
+		v.add("alipay_public_key", "is required unless public key certificate paths are configured")
+	default:
+		v.secret("alipay_public_key", cfg.AlipayPublicKey, c)
+	}
+
+	if cfg.GatewayURL != "" {
+		v.url("gateway_url", cfg.GatewayURL)
+		if u, err := url.Parse(cfg.GatewayURL); err == nil && u.Host != "" {
+			sandbox, known := alipayGatewayHosts[u.Host]
+			switch {
+			case !known:
+				v.add("gateway_url", "unknown alipay gateway host %q", u.Host)
+			case sandbox != cfg.Sandbox:
+				v.add("gateway_url", "host %q does not match sandbox=%t", u.Host, cfg.Sandbox)
+			}
+		}
+	}
+	if cfg.Charset != "" && !strings.EqualFold(cfg.Charset, "UTF-8") {
+		v.add("charset", "only UTF-8 is supported, got %q", cfg.Charset)
+	}
+	if cfg.SignType != "" && cfg.SignType != "RSA2" {
+		v.add("sign_type", "only RSA2 is supported, got %q", cfg.SignType)
+	}
+	if v.required("notify_url", cfg.NotifyURL) {
+		v.url("notify_url", cfg.NotifyURL)
+	}
+	v.url("return_url", cfg.ReturnURL)
+	return v.errs
+}
+
+func (c *Config) validateUnionPay() []FieldError {
+	cfg := c.UnionPay
+	v := &validator{section: SectionUnionPay}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	v.required("mer_id", cfg.MerID)
+
+	// 签名私钥: PFX 签名证书优先，其次 PEM 私钥
+	switch {
+	case cfg.CertPath != "":
+		v.file("cert_path", cfg.CertPath, c)
+		v.secret("cert_pwd", cfg.CertPwd, c)
+	case cfg.PrivateKeyPath != "":
+		v.file("private_key_path", cfg.PrivateKeyPath, c)
+	default:
+		v.add("cert_path", "either cert_path or private_key_path is required")
+	}
+
+	// 验签: 根证书链校验或静态公钥至少一种
+	if cfg.RootCertPath == "" && cfg.PublicKeyPath == "" {
+		v.add("root_cert_path", "either root_cert_path or public_key_path is required")
+	}
+	v.file("root_cert_path", cfg.RootCertPath, c)
+	v.file("middle_cert_path", cfg.MiddleCertPath, c)
+	v.file("public_key_path", cfg.PublicKeyPath, c)
+
+	switch cfg.Gateway {
+	case "", UnionPayGatewaySandbox, UnionPayGatewayProd:
+	default:
+		v.add("gateway", "unknown gateway %q, must be %s or %s", cfg.Gateway, UnionPayGatewaySandbox, UnionPayGatewayProd)
+	}
+	if v.required("back_url", cfg.BackURL) {
+		v.url("back_url", cfg.BackURL)
+	}
+	v.url("front_url", cfg.FrontURL)
+	return v.errs
+}
+
+func (c *Config) validateRouting() []FieldError {
+	cfg := c.Routing
+	v := &validator{section: SectionRouting}
+
+	channels := func(field string, values []string) {
+		for _, ch := range values {
+			if !knownChannels[ch] {
+				v.add(field, "unknown channel %q", ch)
+			}
+		}
+	}
+
+	channels("default", cfg.Default)
+	merchants := make([]string, 0, len(cfg.Merchants))
+	for merchant := range cfg.Merchants {
+		merchants = append(merchants, merchant)
+	}
+	sort.Strings(merchants)
+	for _, merchant := range merchants {
+		channels(fmt.Sprintf("merchants.%s", merchant), cfg.Merchants[merchant])
+	}
+	if cfg.MinSuccessRate < 0 || cfg.MinSuccessRate > 1 {
+		v.add("min_success_rate", "must be between 0 and 1, got %v", cfg.MinSuccessRate)
+	}
+	if cfg.MinSuccessRate > 0 && cfg.Window <= 0 {
+		v.add("window", "must be positive when min_success_rate is set")
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range cfg.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		if rule.Name == "" {
+			v.add(field+".name", "is required")
+		} else if names[rule.Name] {
+			v.add(field+".name", "duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if len(rule.Channels) == 0 {
+			v.add(field+".channels", "is required")
+		}
+		channels(field+".channels", rule.Channels)
+		for _, scene := range rule.Scenes {
+			if !knownScenes[scene] {
+				v.add(field+".scenes", "unknown scene %q", scene)
+			}
+		}
+		if rule.MinAmount < 0 || rule.MaxAmount < 0 {
+			v.add(field, "amount range must not be negative")
+		}
+		if rule.MaxAmount > 0 && rule.MinAmount >= rule.MaxAmount {
+			v.add(field, "min_amount %v must be less than max_amount %v", rule.MinAmount, rule.MaxAmount)
+		}
+	}
+	return v.errs
+}
+
+func (c *Config) validateBreaker() []FieldError {
+	cfg := c.Breaker
+	v := &validator{section: SectionBreaker}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Window <= 0 {
+		v.add("window", "must be positive")
+	}
+	if cfg.ErrorRate <= 0 || cfg.ErrorRate > 1 {
+		v.add("error_rate", "must be in (0, 1], got %v", cfg.ErrorRate)
+	}
+	if cfg.SlowCallRate < 0 || cfg.SlowCallRate > 1 {
+		v.add("slow_call_rate", "must be between 0 and 1, got %v", cfg.SlowCallRate)
+	}
+	if cfg.SlowCallRate > 0 && cfg.SlowCall <= 0 {
+		v.add("slow_call", "must be positive when slow_call_rate is set")
+	}
+	if cfg.OpenTimeout <= 0 {
+		v.add("open_timeout", "must be positive")
+	}
+	return v.errs
+}
+
+func (c *Config) validateRetry() []FieldError {
+	cfg := c.Retry
+	v := &validator{section: SectionRetry}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.MaxAttempts < 1 {
+		v.add("max_attempts", "must be at least 1, got %d", cfg.MaxAttempts)
+	}
+	if cfg.InitialBackoff <= 0 {
+		v.add("initial_backoff", "must be positive")
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		v.add("max_backoff", "must not be less than initial_backoff %s", cfg.InitialBackoff)
+	}
+	if cfg.Jitter < 0 || cfg.Jitter > 1 {
+		v.add("jitter", "must be between 0 and 1, got %v", cfg.Jitter)
+	}
+	return v.errs
+}
+
+func (c *Config) validateAuth() []FieldError {
+	cfg := c.Auth
+	v := &validator{section: SectionAuth}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.MaxSkew <= 0 {
+		v.add("max_skew", "must be positive")
+	}
+	if cfg.MaxBody <= 0 {
+		v.add("max_body", "must be positive, got %d", cfg.MaxBody)
+	}
+	if len(cfg.Keys) == 0 {
+		v.add("keys", "at least one key is required when auth is enabled")
+	}
+
+	appKeys := make(map[string]bool)
+	for i, key := range cfg.Keys {
+		field := fmt.Sprintf("keys[%d]", i)
+		if v.required(field+".app_key", key.AppKey) {
+			if appKeys[key.AppKey] {
+				v.add(field+".app_key", "duplicate app_key %q", key.AppKey)
+			}
+			appKeys[key.AppKey] = true
+		}
+		if v.required(field+".secret", key.Secret) {
+			v.secret(field+".secret", key.Secret, c)
+		}
+		if len(key.Scopes) == 0 {
+			v.add(field+".scopes", "is required")
+		}
+		for _, scope := range key.Scopes {
+			if !knownScopes[scope] {
+				v.add(field+".scopes", "unknown scope %q", scope)
+			}
+		}
+	}
+	return v.errs
+}
+
+func (c *Config) validateSigning() []FieldError {
+	v := &validator{section: SectionSigning}
+	v.file("private_key", c.Signing.PrivateKey, c)
+	return v.errs
+}
+
+func (c *Config) validateRateLimit() []FieldError {
+	cfg := c.RateLimit
+	v := &validator{section: SectionRateLimit}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	limit := func(field string, l LimitConfig) {
+		if l.Rate < 0 {
+			v.add(field+".rate", "must not be negative, got %v", l.Rate)
+		}
+		if l.Burst < 0 {
+			v.add(field+".burst", "must not be negative, got %d", l.Burst)
+		}
+	}
+	limit("per_key", cfg.PerKey)
+	limit("per_merchant", cfg.PerMerchant)
+
+	endpoints := make([]string, 0, len(cfg.Endpoints))
+	for endpoint := range cfg.Endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		limit("endpoints."+endpoint, cfg.Endpoints[endpoint])
+	}
+
+	channels := make([]string, 0, len(cfg.Channels))
+	for channel := range cfg.Channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	for _, channel := range channels {
+		if !knownChannels[channel] {
+			v.add("channels."+channel, "unknown channel %q", channel)
+		}
+		limit("channels."+channel, cfg.Channels[channel])
+	}
+	return v.errs
+}
@@ -0,0 +1,163 @@
+package configs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig 加载只启用银联渠道的最小配置，默认值与正式加载一致
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{"sign.pfx", "root.cer"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("placeholder"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "test.yaml")
+	err := os.WriteFile(path, []byte(`
+server:
+  port: 8080
+wechat:
+  enabled: false
+alipay:
+  enabled: false
+unionpay:
+  mer_id: "777290058110048"
+  cert_path: "`+filepath.Join(dir, "sign.pfx")+`"
+  cert_pwd: "000000"
+  root_cert_path: "file:`+filepath.Join(dir, "root.cer")+`"
+  back_url: "https://pay.example.com/api/v1/notify/unionpay"
+auth:
+  keys:
+    - app_key: "app"
+      secret: "secret"
+      scopes: ["pay", "query"]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig(t).Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		update func(cfg *Config)
+		want   []string // 期望的 section.field 错误前缀
+		fatal  bool
+	}{
+		{"unset env", func(cfg *Config) { cfg.unsetEnv = []string{"WECHAT_APP_ID"} }, []string{"env.WECHAT_APP_ID"}, false},
+		{"server port", func(cfg *Config) { cfg.Server.Port = 70000 }, []string{"server.port"}, true},
+		{"public url", func(cfg *Config) { cfg.Server.PublicURL = "pay.example.com" }, []string{"server.public_url"}, true},
+		{"grpc port conflict", func(cfg *Config) { cfg.GRPC.Port = cfg.Server.Port }, []string{"grpc.port"}, true},
+		{"grpc disabled skips port", func(cfg *Config) { cfg.GRPC.Enabled, cfg.GRPC.Port = false, 0 }, nil, false},
+		{"metrics port conflict", func(cfg *Config) { cfg.Metrics.Port = cfg.Server.Port }, []string{"metrics.port"}, true},
+		{"missing cert file", func(cfg *Config) { cfg.UnionPay.CertPath = "/nonexistent/sign.pfx" }, []string{"unionpay.cert_path"}, false},
+		{"cert path is directory", func(cfg *Config) { cfg.UnionPay.CertPath = os.TempDir() }, []string{"unionpay.cert_path"}, false},
+		{"unset env reference", func(cfg *Config) { cfg.UnionPay.CertPwd = "env:VALIDATE_TEST_UNSET" }, []string{"unionpay.cert_pwd"}, false},
+		{"keystore without path", func(cfg *Config) { cfg.UnionPay.CertPwd = "keystore:unionpay_pwd" }, []string{"unionpay.cert_pwd"}, false},
+		{"invalid kms reference", func(cfg *Config) { cfg.UnionPay.CertPwd = "kms:key-1" }, []string{"unionpay.cert_pwd"}, false},
+		{"no verify key", func(cfg *Config) { cfg.UnionPay.RootCertPath = "" }, []string{"unionpay.root_cert_path"}, false},
+		{"unknown gateway", func(cfg *Config) { cfg.UnionPay.Gateway = "staging" }, []string{"unionpay.gateway"}, false},
+		{"missing back url", func(cfg *Config) { cfg.UnionPay.BackURL = "" }, []string{"unionpay.back_url"}, false},
+		{"disabled channel not validated", func(cfg *Config) { cfg.UnionPay = UnionPayConfig{} }, nil, false},
+		{"wechat required fields", func(cfg *Config) { cfg.Wechat.Enabled = true }, []string{
+			"wechat.app_id", "wechat.mch_id", "wechat.cert_serial_no", "wechat.key_path", "wechat.api_v3_key", "wechat.notify_url",
+		}, false},
+		{"alipay partial cert mode", func(cfg *Config) {
+			cfg.Alipay = AlipayConfig{Enabled: true, AppID: "app", PrivateKey: "key", NotifyURL: "https://pay.example.com/n", AppPublicCertPath: "a.crt"}
+		}, []string{"alipay.alipay_public_cert_path", "alipay.alipay_root_cert_path"}, false},
+		{"alipay sandbox mismatch", func(cfg *Config) {
+			cfg.Alipay = AlipayConfig{Enabled: true, AppID: "app", PrivateKey: "key", AlipayPublicKey: "pub",
+				NotifyURL: "https://pay.example.com/n", GatewayURL: "https://openapi.alipay.com/gateway.do", Sandbox: true}
+		}, []string{"alipay.gateway_url"}, false},
+		{"routing unknown channel", func(cfg *Config) { cfg.Routing.Default = []string{"paypal"} }, []string{"routing.default"}, true},
+		{"routing rule", func(cfg *Config) {
+			cfg.Routing.Rules = []RoutingRule{{Name: "r", Scenes: []string{"tv"}, MinAmount: 10, MaxAmount: 5}}
+		}, []string{"routing.rules[0].channels", "routing.rules[0].scenes", "routing.rules[0]"}, true},
+		{"breaker error rate", func(cfg *Config) { cfg.Breaker.ErrorRate = 1.5 }, []string{"breaker.error_rate"}, true},
+		{"retry backoff", func(cfg *Config) { cfg.Retry.MaxBackoff = time.Millisecond }, []string{"retry.max_backoff"}, true},
+		{"auth keys", func(cfg *Config) {
+			cfg.Auth.Keys = append(cfg.Auth.Keys, APIKeyConfig{AppKey: "app", Secret: "s", Scopes: []string{"root"}})
+		}, []string{"auth.keys[1].app_key", "auth.keys[1].scopes"}, true},
+		{"auth max body", func(cfg *Config) { cfg.Auth.MaxBody = 0 }, []string{"auth.max_body"}, true},
+		{"auth disabled skips keys", func(cfg *Config) { cfg.Auth = AuthConfig{} }, nil, false},
+		{"rate limit", func(cfg *Config) {
+			cfg.RateLimit.Channels = map[string]LimitConfig{"paypal": {Rate: -1}}
+		}, []string{"rate_limit.channels.paypal", "rate_limit.channels.paypal.rate"}, true},
+		{"logging level", func(cfg *Config) { cfg.Logging.Level = "trace" }, []string{"logging.level"}, true},
+		{"tracing exporter", func(cfg *Config) { cfg.Tracing.Enabled, cfg.Tracing.Exporter = true, "jaeger" }, []string{"tracing.exporter"}, true},
+		{"cert monitor thresholds", func(cfg *Config) { cfg.CertMonitor.Thresholds = []int{30, 0} }, []string{"cert_monitor.thresholds"}, true},
+		{"webhook schemes", func(cfg *Config) {
+			cfg.Webhook.AllowedSchemes = []string{"https", "ftp"}
+			cfg.Webhook.AllowedHosts = []string{"merchant.example.com/events"}
+		}, []string{"webhook.allowed_schemes", "webhook.allowed_hosts"}, true},
+		{"order path", func(cfg *Config) { cfg.Order.Path = "" }, []string{"order.path"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validConfig(t)
+			tc.update(cfg)
+
+			err := cfg.Validate()
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("err = %v, want *ValidationError", err)
+			}
+			if len(verr.Errors) != len(tc.want) {
+				t.Fatalf("got %d errors, want %d:\n%v", len(verr.Errors), len(tc.want), err)
+			}
+			for i, want := range tc.want {
+				if got := verr.Errors[i].Error(); !strings.HasPrefix(got, want+": ") {
+					t.Errorf("error %d = %q, want prefix %q", i, got, want)
+				}
+			}
+			if verr.Fatal() != tc.fatal {
+				t.Errorf("fatal = %v, want %v", verr.Fatal(), tc.fatal)
+			}
+		})
+	}
+}
+
+func TestValidationErrorSection(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.UnionPay.BackURL = ""
+	cfg.Logging.Format = "xml"
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("want *ValidationError")
+	}
+	if errs := verr.Section(SectionUnionPay); len(errs) != 1 || errs[0].Field != "back_url" {
+		t.Fatalf("unionpay errors = %v", errs)
+	}
+	if errs := verr.Section(SectionWechat); len(errs) != 0 {
+		t.Fatalf("wechat errors = %v", errs)
+	}
+	if !strings.Contains(verr.Error(), "invalid config (2 errors)") {
+		t.Fatalf("error = %s", verr.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/pkg/payadapter/alipay"
+	"github.com/ymqzj/payment-gateway/pkg/payadapter/unionpay"
+	"github.com/ymqzj/payment-gateway/pkg/payadapter/wechat"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+)
+
+// Channels 网关支持的全部渠道
+var Channels = []payment.ChannelType{
+	payment.ChannelWechat,
+	payment.ChannelAlipay,
+	payment.ChannelUnionPay,
+}
+
+// State 适配器重建后需要保留的运行时状态，由网关持有并注入每次构建的适配器
+type State struct {
+	UnionPayForms *unionpay.FormStore // 银联前台支付表单
+}
+
+// NewState 创建适配器运行时状态
+func NewState() *State {
+	return &State{
+		UnionPayForms: unionpay.NewFormStore(),
+	}
+}
+
+// Build 根据配置创建指定渠道的适配器，state 为空时适配器使用各自新建的状态
+func Build(channel payment.ChannelType, cfg *configs.Config, secrets secret.SecretProvider, state *State) (payment.PaymentAdapter, error) {
+	if state == nil {
+		state = NewState()
+	}
+
+	switch channel {
+	case payment.ChannelWechat:
+		return wechat.NewAdapter(cfg, secrets)
+	case payment.ChannelAlipay:
+		return alipay.NewAdapter(cfg, secrets)
+	case payment.ChannelUnionPay:
+		return unionpay.NewAdapter(cfg, secrets, state.UnionPayForms)
+	default:
+		return nil, fmt.Errorf("unsupported payment channel: %s", channel)
+	}
+}
+
+// Enabled 渠道是否在配置中启用
+func Enabled(channel payment.ChannelType, cfg *configs.Config) bool {
+	switch channel {
+	case payment.ChannelWechat:
+		return cfg.Wechat.Enabled
+	case payment.ChannelAlipay:
+		return cfg.Alipay.Enabled
+	case payment.ChannelUnionPay:
+		return cfg.UnionPay.Enabled
+	default:
+		return false
+	}
+}
+
+// Files 返回渠道依赖的本地密钥和证书文件，用于监听变更
+// 非 file: 前缀的机密引用（env、keystore、kms）不在此列
+func Files(channel payment.ChannelType, cfg *configs.Config) []string {
+	var candidates []string
+	switch channel {
+	case payment.ChannelWechat:
+		candidates = []string{cfg.Wechat.KeyPath, cfg.Wechat.CertPath}
+	case payment.ChannelAlipay:
+		candidates = []string{cfg.Alipay.AppPublicCertPath, cfg.Alipay.AlipayPublicCertPath, cfg.Alipay.AlipayRootCertPath}
+	case payment.ChannelUnionPay:
+		candidates = []string{
+			cfg.UnionPay.CertPath,
+			cfg.UnionPay.PrivateKeyPath,
+			cfg.UnionPay.PublicKeyPath,
+			cfg.UnionPay.RootCertPath,
+			cfg.UnionPay.MiddleCertPath,
+		}
+	}
+
+	files := make([]string, 0, len(candidates))
+	for _, value := range candidates {
+		if path, ok := localPath(value); ok {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// localPath 配置值对应的本地文件路径
+func localPath(value string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+	if !secret.IsRef(value) {
+		return value, true
+	}
+
+	if rest, ok := strings.CutPrefix(value, secret.SchemeFile+":"); ok {
+		return strings.TrimPrefix(rest, "//"), true
+	}
+	return "", false
+}
@@ -0,0 +1,390 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	logger "github.com/ymqzj/payment-gateway/logs"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+)
+
+const (
+	defaultDebounce = 500 * time.Millisecond
+
+	// 初始化失败渠道的后台重试间隔
+	retryMinBackoff = 2 * time.Second
+	retryMaxBackoff = 5 * time.Minute
+)
+
+// SecretsFactory 根据配置创建密钥提供者，密钥库配置变化时调用
+type SecretsFactory func(cfg *configs.Config) (secret.SecretProvider, error)
+
+// watchTarget 被监听文件的用途
+type watchTarget struct {
+	config   bool
+	keystore bool
+	channels []payment.ChannelType
+}
+
+// Reloader 负责渠道适配器的生命周期: 启动时构建已启用的渠道，初始化失败的渠道在后台重试；
+// 监听配置文件和渠道密钥/证书文件，变更后重建受影响的适配器并原子替换
+// 重建失败时保留原适配器继续服务；正在处理的请求持有旧适配器，不受替换影响
+// 适配器的运行时状态（如银联前台支付表单）由 Reloader 持有并注入重建的适配器，替换后仍然有效
+type Reloader struct {
+	gateway        *payment.PaymentGateway
+	configPath     string
+	secretsFactory SecretsFactory
+	debounce       time.Duration
+	logger         *zap.Logger
+
+	mu       sync.Mutex
+	ctx      context.Context
+	cfg      *configs.Config
+	secrets  secret.SecretProvider
+	state    *State
+	watcher  *fsnotify.Watcher
+	dirs     map[string]bool
+	retrying map[payment.ChannelType]bool
+}
+
+// NewReloader 创建热加载器
+func NewReloader(gateway *payment.PaymentGateway, configPath string, cfg *configs.Config, secrets secret.SecretProvider) *Reloader {
+	return &Reloader{
+		gateway:    gateway,
+		configPath: configPath,
+		secretsFactory: func(cfg *configs.Config) (secret.SecretProvider, error) {
+			return secret.NewFromConfig(cfg)
+		},
+		debounce: defaultDebounce,
+		logger:   logger.GetLogger().Named("reloader"),
+		cfg:      cfg,
+		secrets:  secrets,
+		state:    NewState(),
+		dirs:     make(map[string]bool),
+		retrying: make(map[payment.ChannelType]bool),
+	}
+}
+
+// SetSecretsFactory 设置密钥提供者工厂，用于注册了 KMS 等自定义提供者的场景
+func (r *Reloader) SetSecretsFactory(factory SecretsFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.secretsFactory = factory
+}
+
+// Config 返回当前生效的配置
+func (r *Reloader) Config() *configs.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.cfg
+}
+
+// Init 构建所有已启用渠道的适配器
+// 初始化失败的渠道标记为不可用并在后台重试，不影响其他渠道；ctx 取消后停止重试
+func (r *Reloader) Init(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ctx = ctx
+	for _, channel := range Channels {
+		if err := r.load(channel); err != nil {
+			r.logger.Error("init adapter failed, retry in background",
+				zap.String("channel", channel.String()), zap.Error(err))
+		}
+	}
+}
+
+// Start 开始监听文件变更，ctx 取消后停止
+func (r *Reloader) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create file watcher failed: %w", err)
+	}
+
+	r.mu.Lock()
+	r.watcher = watcher
+	r.syncWatches()
+	r.mu.Unlock()
+
+	go r.loop(ctx)
+	return nil
+}
+
+// Reload 使用当前配置立即重建指定渠道的适配器
+func (r *Reloader) Reload(channel payment.ChannelType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.load(channel)
+}
+
+// loop 收集文件事件，合并短时间内的多次变更后统一处理
+func (r *Reloader) loop(ctx context.Context) {
+	defer r.watcher.Close()
+
+	pending := make(map[string]bool)
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[filepath.Clean(event.Name)] = true
+			timer = time.After(r.debounce)
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			r.logger.Warn("file watcher error", zap.Error(err))
+		case <-timer:
+			r.apply(pending)
+			pending = make(map[string]bool)
+			timer = nil
+		}
+	}
+}
+
+// apply 处理一批文件变更
+func (r *Reloader) apply(changed map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	targets := r.targets()
+	var matched []watchTarget
+	for path := range changed {
+		if target, ok := targets[path]; ok {
+			matched = append(matched, target)
+			continue
+		}
+
+		// Kubernetes 挂载的 Secret 通过替换 ..data 符号链接更新，视为目录下所有文件变更
+		if strings.HasPrefix(filepath.Base(path), "..") {
+			for p, target := range targets {
+				if filepath.Dir(p) == filepath.Dir(path) {
+					matched = append(matched, target)
+				}
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	cfg := r.cfg
+	rebuild := make(map[payment.ChannelType]bool)
+	reloadSecrets := false
+
+	for _, target := range matched {
+		for _, channel := range target.channels {
+			rebuild[channel] = true
+		}
+		if target.keystore {
+			reloadSecrets = true
+		}
+		if target.config {
+			newCfg, err := configs.LoadFile(r.configPath)
+			if err != nil {
+				r.logger.Error("reload config failed, keep current config", zap.Error(err))
+				continue
+			}
+			if !reflect.DeepEqual(newCfg.Secrets, cfg.Secrets) {
+				reloadSecrets = true
+			}
+			for _, channel := range changedChannels(cfg, newCfg) {
+				rebuild[channel] = true
+			}
+			cfg = newCfg
+		}
+	}
+
+	r.cfg = cfg
+	if reloadSecrets {
+		secrets, err := r.secretsFactory(cfg)
+		if err != nil {
+			r.logger.Error("reload secrets failed, keep current provider", zap.Error(err))
+		} else {
+			r.secrets = secrets
+			for _, channel := range Channels {
+				rebuild[channel] = true
+			}
+		}
+	}
+
+	for _, channel := range Channels {
+		if rebuild[channel] {
+			if err := r.load(channel); err != nil {
+				r.logger.Error("rebuild adapter failed",
+					zap.String("channel", channel.String()),
+					zap.String("state", string(r.gateway.ChannelState(channel))),
+					zap.Error(err))
+			}
+		}
+	}
+
+	r.syncWatches()
+}
+
+// load 按当前配置构建渠道适配器并替换到网关，调用方需持有锁
+// 未启用的渠道会被移除；构建失败时保留原适配器，没有可用适配器的渠道转入后台重试
+func (r *Reloader) load(channel payment.ChannelType) error {
+	if !Enabled(channel, r.cfg) {
+		if r.gateway.ChannelState(channel) != payment.ChannelStateDisabled {
+			r.gateway.MarkDisabled(channel)
+			r.logger.Info("adapter disabled", zap.String("channel", channel.String()))
+		}
+		return nil
+	}
+
+	adapter, err := Build(channel, r.cfg, r.secrets, r.state)
+	if err != nil {
+		r.gateway.MarkFailed(channel, err)
+		if r.gateway.ChannelState(channel) == payment.ChannelStateFailed {
+			r.scheduleRetry(channel)
+		}
+		return err
+	}
+
+	r.gateway.SetAdapter(adapter)
+	r.logger.Info("adapter loaded", zap.String("channel", channel.String()))
+	return nil
+}
+
+// scheduleRetry 启动渠道的后台重试，同一渠道只有一个重试任务，调用方需持有锁
+func (r *Reloader) scheduleRetry(channel payment.ChannelType) {
+	if r.ctx == nil || r.retrying[channel] {
+		return
+	}
+	r.retrying[channel] = true
+	go r.retry(channel)
+}
+
+// retry 按指数退避重试初始化，成功、渠道被禁用或 ctx 取消后退出
+func (r *Reloader) retry(channel payment.ChannelType) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.retrying, channel)
+		r.mu.Unlock()
+	}()
+
+	backoff := retryMinBackoff
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if r.gateway.ChannelState(channel) != payment.ChannelStateFailed {
+			return
+		}
+
+		r.mu.Lock()
+		err := r.load(channel)
+		r.mu.Unlock()
+		if err == nil {
+			return
+		}
+
+		backoff = min(backoff*2, retryMaxBackoff)
+		r.logger.Warn("retry adapter init failed",
+			zap.String("channel", channel.String()),
+			zap.Duration("next_retry", backoff),
+			zap.Error(err))
+	}
+}
+
+// changedChannels 比较两份配置，返回配置发生变化的渠道
+func changedChannels(old, cfg *configs.Config) []payment.ChannelType {
+	var channels []payment.ChannelType
+	if !reflect.DeepEqual(old.Wechat, cfg.Wechat) {
+		channels = append(channels, payment.ChannelWechat)
+	}
+	if !reflect.DeepEqual(old.Alipay, cfg.Alipay) {
+		channels = append(channels, payment.ChannelAlipay)
+	}
+	// 银联前台表单链接依赖网关对外地址
+	if !reflect.DeepEqual(old.UnionPay, cfg.UnionPay) || old.Server.PublicURL != cfg.Server.PublicURL {
+		channels = append(channels, payment.ChannelUnionPay)
+	}
+	return channels
+}
+
+// targets 当前需要监听的文件，键为绝对路径，调用方需持有锁
+func (r *Reloader) targets() map[string]watchTarget {
+	targets := make(map[string]watchTarget)
+	add := func(path string, update func(t *watchTarget)) {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return
+		}
+		t := targets[abs]
+		update(&t)
+		targets[abs] = t
+	}
+
+	if r.configPath != "" {
+		add(r.configPath, func(t *watchTarget) { t.config = true })
+	}
+	if r.cfg.Secrets.KeystorePath != "" {
+		add(r.cfg.Secrets.KeystorePath, func(t *watchTarget) { t.keystore = true })
+	}
+	for _, channel := range Channels {
+		if !Enabled(channel, r.cfg) {
+			continue
+		}
+		channel := channel
+		for _, file := range Files(channel, r.cfg) {
+			add(file, func(t *watchTarget) { t.channels = append(t.channels, channel) })
+		}
+	}
+	return targets
+}
+
+// syncWatches 监听目标文件所在目录（兼容编辑器和证书轮换工具的替换写入），调用方需持有锁
+func (r *Reloader) syncWatches() {
+	if r.watcher == nil {
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for path := range r.targets() {
+		wanted[filepath.Dir(path)] = true
+	}
+
+	for dir := range wanted {
+		if r.dirs[dir] {
+			continue
+		}
+		if err := r.watcher.Add(dir); err != nil {
+			r.logger.Warn("watch directory failed", zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+		r.dirs[dir] = true
+	}
+
+	for dir := range r.dirs {
+		if !wanted[dir] {
+			_ = r.watcher.Remove(dir)
+			delete(r.dirs, dir)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package adapters
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/pkg/payadapter/unionpay"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testKey 测试用的签名私钥
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// writeSignCert 写入序列号为 serial 的银联 PFX 签名证书
+func writeSignCert(t *testing.T, path string, serial int64) {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "reloader test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &testKey.PublicKey, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx, err := pkcs12.Modern.Encode(testKey, cert, nil, "000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, pfx)
+}
+
+// writeFile 先写临时文件再重命名，与证书轮换工具的替换写入一致
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestConfig 只启用银联渠道的配置，签名证书和验签公钥放在 dir 下
+func newTestConfig(t *testing.T, dir string) *configs.Config {
+	t.Helper()
+
+	pub, err := x509.MarshalPKIXPublicKey(&testKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "unionpay_public.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+
+	return &configs.Config{
+		UnionPay: configs.UnionPayConfig{
+			Enabled:       true,
+			MerID:         "777290058110048",
+			CertPath:      filepath.Join(dir, "sign.pfx"),
+			CertPwd:       "000000",
+			PublicKeyPath: filepath.Join(dir, "unionpay_public.pem"),
+			Gateway:       configs.UnionPayGatewaySandbox,
+		},
+	}
+}
+
+// signSerial 网关当前银联适配器签名证书的序列号
+func signSerial(gateway *payment.PaymentGateway) string {
+	for _, info := range gateway.GetCertInfos(context.Background()) {
+		if info.Channel == payment.ChannelUnionPay && info.Name == "sign" {
+			return info.Serial
+		}
+	}
+	return ""
+}
+
+func TestReloaderSwapsAdapter(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, dir)
+	writeSignCert(t, cfg.UnionPay.CertPath, 0x100)
+
+	gateway := payment.NewPaymentGateway()
+	r := NewReloader(gateway, "", cfg, secret.NewRegistry())
+	r.Init(context.Background())
+
+	if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateReady {
+		t.Fatalf("state = %s, want ready", state)
+	}
+	if state := gateway.ChannelState(payment.ChannelWechat); state != payment.ChannelStateDisabled {
+		t.Fatalf("wechat state = %s, want disabled", state)
+	}
+	if serial := signSerial(gateway); serial != "100" {
+		t.Fatalf("serial = %s, want 100", serial)
+	}
+
+	// 重建前生成的前台支付表单在重建后仍可访问
+	token, err := r.state.UnionPayForms.Save(&unionpay.FrontForm{OutTradeNo: "ORDER_1", HTML: "<form></form>", ExpireAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath, err := filepath.Abs(cfg.UnionPay.CertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		write  func()
+		serial string
+	}{
+		{"rotated cert", func() { writeSignCert(t, certPath, 0x200) }, "200"},
+		{"corrupt cert keeps old adapter", func() { writeFile(t, certPath, []byte("garbage")) }, "200"},
+		{"wrong password keeps old adapter", func() {
+			writeSignCert(t, certPath, 0x300)
+			r.mu.Lock()
+			r.cfg.UnionPay.CertPwd = "wrong"
+			r.mu.Unlock()
+		}, "200"},
+		{"removed cert keeps old adapter", func() {
+			if err := os.Remove(certPath); err != nil {
+				t.Fatal(err)
+			}
+		}, "200"},
+		{"restored cert", func() {
+			writeSignCert(t, certPath, 0x400)
+			r.mu.Lock()
+			r.cfg.UnionPay.CertPwd = "000000"
+			r.mu.Unlock()
+		}, "400"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.write()
+			r.apply(map[string]bool{certPath: true})
+
+			if serial := signSerial(gateway); serial != tc.serial {
+				t.Fatalf("serial = %s, want %s", serial, tc.serial)
+			}
+			if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateReady {
+				t.Fatalf("state = %s, want ready", state)
+			}
+			if _, err := gateway.GetPayForm(context.Background(), payment.ChannelUnionPay, token); err != nil {
+				t.Fatalf("pay form lost after reload: %v", err)
+			}
+		})
+	}
+
+	// 与渠道无关的文件变更不触发重建
+	writeSignCert(t, certPath, 0x500)
+	r.apply(map[string]bool{filepath.Join(dir, "unrelated.txt"): true})
+	if serial := signSerial(gateway); serial != "400" {
+		t.Fatalf("serial = %s after unrelated change, want 400", serial)
+	}
+}
+
+func TestReloaderRetriesFailedInit(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gateway := payment.NewPaymentGateway()
+	r := NewReloader(gateway, "", cfg, secret.NewRegistry())
+	r.Init(ctx)
+
+	status := gateway.GetChannelStatus(payment.ChannelUnionPay)
+	if status.State != payment.ChannelStateFailed || status.Error == "" {
+		t.Fatalf("status = %+v, want failed", status)
+	}
+	if _, err := gateway.Query(ctx, &payment.QueryRequest{Channel: payment.ChannelUnionPay}); err == nil {
+		t.Fatal("query on failed channel succeeded")
+	}
+
+	writeSignCert(t, cfg.UnionPay.CertPath, 0x100)
+	if err := r.Reload(payment.ChannelUnionPay); err != nil {
+		t.Fatal(err)
+	}
+	if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateReady {
+		t.Fatalf("state = %s, want ready", state)
+	}
+}
+
+func TestReloaderWatchesFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, dir)
+	writeSignCert(t, cfg.UnionPay.CertPath, 0x100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gateway := payment.NewPaymentGateway()
+	r := NewReloader(gateway, "", cfg, secret.NewRegistry())
+	r.debounce = 10 * time.Millisecond
+	r.Init(ctx)
+	if err := r.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	writeSignCert(t, cfg.UnionPay.CertPath, 0x200)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for signSerial(gateway) != "200" {
+		if time.Now().After(deadline) {
+			t.Fatalf("serial = %s, adapter not reloaded after file change", signSerial(gateway))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestChangedChannels(t *testing.T) {
+	base := configs.Config{}
+	base.Wechat.MchID = "1"
+	base.UnionPay.MerID = "1"
+
+	cases := []struct {
+		name   string
+		update func(cfg *configs.Config)
+		want   string
+	}{
+		{"unchanged", func(cfg *configs.Config) {}, "[]"},
+		{"wechat", func(cfg *configs.Config) { cfg.Wechat.MchID = "2" }, "[wechat]"},
+		{"unionpay public url", func(cfg *configs.Config) { cfg.Server.PublicURL = "https://pay.example.com" }, "[unionpay]"},
+		{"unrelated section", func(cfg *configs.Config) { cfg.Server.Port = 9090 }, "[]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := base
+			tc.update(&cfg)
+			if got := fmt.Sprint(changedChannels(&base, &cfg)); got != tc.want {
+				t.Fatalf("changed = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReloaderBackgroundRetry(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gateway := payment.NewPaymentGateway()
+	r := NewReloader(gateway, "", cfg, secret.NewRegistry())
+	r.Init(ctx)
+	if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateFailed {
+		t.Fatalf("state = %s, want failed", state)
+	}
+
+	writeSignCert(t, cfg.UnionPay.CertPath, 0x100)
+
+	deadline := time.Now().Add(retryMinBackoff + 3*time.Second)
+	for gateway.ChannelState(payment.ChannelUnionPay) != payment.ChannelStateReady {
+		if time.Now().After(deadline) {
+			t.Fatal("channel not recovered by background retry")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestReloaderDisablesChannel(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, dir)
+	writeSignCert(t, cfg.UnionPay.CertPath, 0x100)
+
+	configPath := filepath.Join(dir, "test.yaml")
+	writeConfig := func(enabled bool) {
+		writeFile(t, configPath, []byte(fmt.Sprintf(`
+wechat:
+  enabled: false
+alipay:
+  enabled: false
+unionpay:
+  enabled: %t
+  mer_id: "777290058110048"
+  cert_path: %q
+  cert_pwd: "000000"
+  public_key_path: %q
+`, enabled, cfg.UnionPay.CertPath, cfg.UnionPay.PublicKeyPath)))
+	}
+	writeConfig(true)
+	loaded, err := configs.LoadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := payment.NewPaymentGateway()
+	r := NewReloader(gateway, configPath, loaded, secret.NewRegistry())
+	r.Init(context.Background())
+	if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateReady {
+		t.Fatalf("state = %s, want ready", state)
+	}
+
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfig(false)
+	r.apply(map[string]bool{absConfig: true})
+	if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateDisabled {
+		t.Fatalf("state = %s, want disabled", state)
+	}
+	if len(gateway.GetSupportedChannels()) != 0 {
+		t.Fatalf("supported channels = %v after disable", gateway.GetSupportedChannels())
+	}
+
+	writeConfig(true)
+	r.apply(map[string]bool{absConfig: true})
+	if state := gateway.ChannelState(payment.ChannelUnionPay); state != payment.ChannelStateReady {
+		t.Fatalf("state = %s after re-enable, want ready", state)
+	}
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"go.uber.org/zap"
+)
+
+// Middleware 审计中间件，每次出站渠道调用写入一条记录，包含适配器经 Transport 发出的全部 HTTP 请求
+// 应注册在重试之外，一次调用的所有尝试记录在同一条记录中；写入失败只记日志，不影响调用结果
+func Middleware(store Store, maxBody int) payment.Middleware {
+	return func(next payment.Handler) payment.Handler {
+		return func(ctx context.Context, call *payment.Call) error {
+			if !call.Operation.Outbound() {
+				return next(ctx, call)
+			}
+
+			c := &collector{maxBody: maxBody}
+			start := time.Now()
+			err := next(context.WithValue(ctx, collectorKey{}, c), call)
+
+			record := &Record{
+				Time:       start,
+				Direction:  DirectionOutbound,
+				Channel:    call.Channel,
+				Operation:  call.Operation,
+				MerchantID: auth.MerchantFromContext(ctx),
+				TraceID:    tracing.TraceID(ctx),
+				Request:    redactValue(call.Request),
+				Exchanges:  c.list(),
+				LatencyMs:  time.Since(start).Milliseconds(),
+			}
+			if err == nil {
+				record.Response = redactValue(call.Result)
+			}
+			record.OutTradeNo, record.OrderID = orderOf(call)
+			if n := len(record.Exchanges); n > 0 {
+				record.HTTPStatus = record.Exchanges[n-1].Status
+			}
+			record.setError(err)
+
+			if appendErr := store.Append(ctx, record); appendErr != nil {
+				logger.FromContext(ctx).Error("append audit record failed",
+					zap.String("channel", string(call.Channel)),
+					zap.String("operation", string(call.Operation)),
+					zap.String("out_trade_no", record.OutTradeNo),
+					zap.Error(appendErr))
+			}
+			return err
+		}
+	}
+}
+
+// Notification 记录渠道推送的异步通知，body 和 header 原样保存以便重新验签
+// result 为验签通过后的通知结果，验签失败时为空，此时记录无法按订单号查询
+func Notification(ctx context.Context, store Store, channel payment.ChannelType, header http.Header, body []byte, result *payment.NotifyResult, err error, latency time.Duration) {
+	record := &Record{
+		Time:      time.Now().Add(-latency),
+		Direction: DirectionInbound,
+		Channel:   channel,
+		Operation: payment.OpNotify,
+		TraceID:   tracing.TraceID(ctx),
+		LatencyMs: latency.Milliseconds(),
+		Headers:   header.Clone(),
+		Body:      string(body),
+	}
+	if result != nil {
+		record.OutTradeNo = result.OutTradeNo
+		record.OrderID = result.OrderID
+		record.Response = redactValue(result)
+	}
+	record.setError(err)
+
+	if appendErr := store.Append(ctx, record); appendErr != nil {
+		logger.FromContext(ctx).Error("append audit record failed",
+			zap.String("channel", string(channel)),
+			zap.String("operation", string(payment.OpNotify)),
+			zap.String("out_trade_no", record.OutTradeNo),
+			zap.Error(appendErr))
+	}
+}
+
+// orderOf 从调用的请求和结果中取商户订单号和渠道订单号
+func orderOf(call *payment.Call) (outTradeNo, orderID string) {
+	switch req := call.Request.(type) {
+	case *payment.UnifiedPayRequest:
+		outTradeNo = req.OutTradeNo
+	case *payment.QueryRequest:
+		outTradeNo, orderID = req.OutTradeNo, req.OrderID
+	case *payment.RefundRequest:
+		outTradeNo, orderID = req.OutTradeNo, req.OrderID
+	case *payment.RefundQueryRequest:
+		outTradeNo, orderID = req.OutTradeNo, req.OrderID
+	case *payment.CloseRequest:
+		outTradeNo, orderID = req.OutTradeNo, req.OrderID
+	}
+
+	switch res := call.Result.(type) {
+	case *payment.UnifiedPayResponse:
+		outTradeNo, orderID = fallback(outTradeNo, res.OutTradeNo), fallback(orderID, res.OrderID)
+	case *payment.QueryResponse:
+		outTradeNo, orderID = fallback(outTradeNo, res.OutTradeNo), fallback(orderID, res.OrderID)
+	}
+	return outTradeNo, orderID
+}
+
+// fallback value 为空时返回 alt
+func fallback(value, alt string) string {
+	if value == "" {
+		return alt
+	}
+	return value
+}
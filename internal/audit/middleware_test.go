@@ -0,0 +1,166 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// memoryStore 保存在内存中的审计记录
+type memoryStore struct {
+	mu      sync.Mutex
+	records []*Record
+}
+
+func (s *memoryStore) Append(ctx context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record.fill()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memoryStore) ByOrder(ctx context.Context, outTradeNo string) ([]*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []*Record
+	for _, r := range s.records {
+		if r.OutTradeNo == outTradeNo {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// TestMiddleware 一次渠道调用的全部 HTTP 往来记录在同一条记录中，报文脱敏，调用方读到的报文不变
+func TestMiddleware(t *testing.T) {
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "accNo=6216261000000000018&txnAmt=100" {
+			t.Errorf("channel received %q", body)
+		}
+		if r.URL.Query().Get("attempt") == "1" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"trade_state":"SUCCESS","payer":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"padding":"`+strings.Repeat("x", 64)+`"}`)
+	}))
+	defer channel.Close()
+
+	var received []string
+	client := &http.Client{Transport: Transport(nil)}
+	store := &memoryStore{}
+	handler := Middleware(store, 1024)(func(ctx context.Context, call *payment.Call) error {
+		for _, attempt := range []string{"1", "2"} {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, channel.URL+"?attempt="+attempt+"&sign=abc",
+				strings.NewReader("accNo=6216261000000000018&txnAmt=100"))
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			received = append(received, string(body))
+		}
+		call.Result = &payment.QueryResponse{OutTradeNo: "ORDER_1", OrderID: "WX_1", TradeStatus: payment.TradeStatusSuccess}
+		return nil
+	})
+
+	ctx := auth.WithCredential(context.Background(), &auth.Credential{AppKey: "app", MerchantID: "m1"})
+	call := &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpQuery, Request: &payment.QueryRequest{OutTradeNo: "ORDER_1"}}
+	if err := handler(ctx, call); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(received[1], "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o") || !strings.HasSuffix(received[1], `"}`) {
+		t.Fatalf("caller received %q, want the original response", received[1])
+	}
+
+	records, _ := store.ByOrder(ctx, "ORDER_1")
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	r := records[0]
+	if r.Direction != DirectionOutbound || r.MerchantID != "m1" || r.OrderID != "WX_1" || r.HTTPStatus != http.StatusOK || r.Error != "" {
+		t.Fatalf("record = %+v", r)
+	}
+	if len(r.Exchanges) != 2 || r.Exchanges[0].Status != http.StatusServiceUnavailable {
+		t.Fatalf("exchanges = %+v, want the failed and the successful attempt", r.Exchanges)
+	}
+	exchange := r.Exchanges[1]
+	if strings.Contains(exchange.URL, "sign=abc") {
+		t.Fatalf("url not redacted: %s", exchange.URL)
+	}
+	if got := jsonOf(t, exchange.Request); got != `{"accNo":"6216****0018","txnAmt":"100"}` {
+		t.Fatalf("request = %s", got)
+	}
+	if got := jsonOf(t, exchange.Response); !strings.Contains(got, `"openid":"oUpF****eS6o"`) {
+		t.Fatalf("response = %s", got)
+	}
+}
+
+func TestMiddlewareTruncatesBody(t *testing.T) {
+	channel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"}`)
+	}))
+	defer channel.Close()
+
+	var received string
+	store := &memoryStore{}
+	handler := Middleware(store, 16)(func(ctx context.Context, call *payment.Call) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, channel.URL, nil)
+		resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		received = string(body)
+		return payment.ChannelSystemError
+	})
+
+	call := &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpQuery, Request: &payment.QueryRequest{OutTradeNo: "ORDER_1"}}
+	if err := handler(context.Background(), call); !errors.Is(err, payment.ChannelSystemError) {
+		t.Fatalf("err = %v", err)
+	}
+	if received != `{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"}` {
+		t.Fatalf("caller received %q", received)
+	}
+	r := store.records[0]
+	if got := jsonOf(t, r.Exchanges[0].Response); got != `"<16+ bytes, truncated>"` {
+		t.Fatalf("response = %s", got)
+	}
+	if r.ErrorCode != payment.ChannelSystemError.Code || r.Response != nil {
+		t.Fatalf("record = %+v", r)
+	}
+}
+
+// TestNotification 入站通知保留原始报文和请求头，验签失败的通知同样记录
+func TestNotification(t *testing.T) {
+	store := &memoryStore{}
+	header := http.Header{"Wechatpay-Signature": {"c2lnbmF0dXJl"}}
+	body := []byte(`{"id":"EV-1","resource":{"ciphertext":"abc"}}`)
+	result := &payment.NotifyResult{Success: true, OutTradeNo: "ORDER_1", OrderID: "WX_1", Channel: payment.ChannelWechat}
+
+	Notification(context.Background(), store, payment.ChannelWechat, header, body, result, nil, 0)
+	Notification(context.Background(), store, payment.ChannelWechat, header, body, nil, payment.InvalidSignature, 0)
+
+	records, _ := store.ByOrder(context.Background(), "ORDER_1")
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	r := records[0]
+	if r.Direction != DirectionInbound || r.Body != string(body) || r.Headers["Wechatpay-Signature"][0] != "c2lnbmF0dXJl" || r.OrderID != "WX_1" {
+		t.Fatalf("record = %+v", r)
+	}
+	if failed := store.records[1]; failed.OutTradeNo != "" || failed.ErrorCode != payment.InvalidSignature.Code || failed.Body != string(body) {
+		t.Fatalf("failed notification = %+v", failed)
+	}
+}
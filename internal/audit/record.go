@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// Direction 报文方向
+type Direction string
+
+const (
+	DirectionOutbound Direction = "outbound" // 网关调用渠道接口
+	DirectionInbound  Direction = "inbound"  // 渠道推送的异步通知
+)
+
+// Record 一条审计记录，用于交易争议时还原与渠道之间的报文往来
+// 出站记录中的报文均已脱敏；入站通知保留原始报文和请求头，以便事后重新验签
+type Record struct {
+	ID          string              `json:"id"`
+	Time        time.Time           `json:"time"`
+	Direction   Direction           `json:"direction"`
+	Channel     payment.ChannelType `json:"channel"`
+	Operation   payment.Operation   `json:"operation"`
+	MerchantID  string              `json:"merchant_id,omitempty"` // 发起调用的商户，取自 API 凭证
+	OutTradeNo  string              `json:"out_trade_no,omitempty"`
+	OrderID     string              `json:"order_id,omitempty"` // 渠道订单号
+	TraceID     string              `json:"trace_id,omitempty"`
+	Request     interface{}         `json:"request,omitempty"`   // 网关侧请求，已脱敏
+	Response    interface{}         `json:"response,omitempty"`  // 网关侧结果，已脱敏
+	Exchanges   []*Exchange         `json:"exchanges,omitempty"` // 本次调用发出的 HTTP 请求，含重试
+	HTTPStatus  int                 `json:"http_status,omitempty"`
+	LatencyMs   int64               `json:"latency_ms"`
+	ErrorCode   string              `json:"error_code,omitempty"`   // 网关统一错误码
+	ChannelCode string              `json:"channel_code,omitempty"` // 渠道原始错误码
+	Error       string              `json:"error,omitempty"`
+	Headers     map[string][]string `json:"headers,omitempty"` // 入站通知的请求头
+	Body        string              `json:"body,omitempty"`    // 入站通知的原始报文
+}
+
+// Exchange 一次与渠道的 HTTP 往来
+type Exchange struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Status    int         `json:"status,omitempty"`
+	LatencyMs int64       `json:"latency_ms"`
+	Request   interface{} `json:"request,omitempty"`  // 请求报文，已脱敏
+	Response  interface{} `json:"response,omitempty"` // 响应报文，已脱敏
+	Error     string      `json:"error,omitempty"`    // 网络错误
+}
+
+// Store 审计记录存储，只追加不修改
+type Store interface {
+	// Append 追加记录，ID 和 Time 为空时自动填充
+	Append(ctx context.Context, record *Record) error
+	// ByOrder 按商户订单号查询记录，按写入顺序返回
+	ByOrder(ctx context.Context, outTradeNo string) ([]*Record, error)
+}
+
+// fill 填充记录 ID 和时间
+func (r *Record) fill() {
+	if r.ID == "" {
+		r.ID = newID()
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+}
+
+// setError 记录调用错误，渠道错误同时保留渠道原始错误码
+func (r *Record) setError(err error) {
+	if err == nil {
+		return
+	}
+	r.Error = err.Error()
+	r.ErrorCode = payment.ErrorCodeOf(err).Code
+	var channelErr *payment.ChannelError
+	if errors.As(err, &channelErr) {
+		r.ChannelCode = channelErr.Code
+	}
+}
+
+// newID 生成记录 ID
+func newID() string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
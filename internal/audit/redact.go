@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	logger "github.com/ymqzj/payment-gateway/logs"
+)
+
+// redactValue 将网关侧请求/结果序列化后脱敏
+func redactValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	if data, ok := v.([]byte); ok {
+		return redactBody(data, false)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<%T>", v)
+	}
+	return redactBody(data, false)
+}
+
+// redactBody 脱敏渠道报文，支持 JSON 和表单格式
+// 无法解析的报文（截断的报文、账单文件等）无法逐字段脱敏，只记录长度
+func redactBody(body []byte, truncated bool) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if truncated {
+		return fmt.Sprintf("<%d+ bytes, truncated>", len(body))
+	}
+	if v, ok := redactJSON(body); ok {
+		return v
+	}
+	if v, ok := redactForm(string(body)); ok {
+		return v
+	}
+	return fmt.Sprintf("<%d bytes>", len(body))
+}
+
+// redactJSON 脱敏 JSON 报文
+func redactJSON(body []byte) (interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return nil, false
+	}
+	return redactTree(v), true
+}
+
+// redactTree 递归脱敏 JSON 对象和数组
+func redactTree(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return logger.MaskMap(val)
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = redactTree(item)
+		}
+		return items
+	}
+	return v
+}
+
+// redactForm 脱敏表单报文，如支付宝、银联的请求和应答
+// 参数值本身是 JSON 对象时（如支付宝 biz_content）展开后脱敏
+func redactForm(body string) (interface{}, bool) {
+	if !strings.Contains(body, "=") || strings.ContainsAny(body, " \r\n\t") {
+		return nil, false
+	}
+	values, err := url.ParseQuery(body)
+	if err != nil {
+		return nil, false
+	}
+	form := make(map[string]interface{}, len(values))
+	for key, vals := range values {
+		value := strings.Join(vals, ",")
+		if logger.IsSensitive(key) {
+			form[key] = logger.MaskValue(key, value)
+			continue
+		}
+		if strings.HasPrefix(value, "{") {
+			if v, ok := redactJSON([]byte(value)); ok {
+				form[key] = v
+				continue
+			}
+		}
+		form[key] = value
+	}
+	return form, true
+}
+
+// redactURL 脱敏 URL 查询参数
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	copied := *u
+	query := copied.Query()
+	for key, vals := range query {
+		for i, v := range vals {
+			vals[i] = logger.MaskValue(key, v)
+		}
+		query[key] = vals
+	}
+	copied.RawQuery = query.Encode()
+	return copied.String()
+}
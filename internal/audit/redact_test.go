@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// jsonOf 序列化后比较，不转义 HTML 字符便于阅读
+func jsonOf(t *testing.T, v interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func TestRedactBody(t *testing.T) {
+	cases := []struct {
+		name      string
+		body      string
+		truncated bool
+		want      string
+	}{
+		{"empty", "", false, `null`},
+		{"json", `{"appid":"wx1","payer":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"amount":{"total":100}}`, false,
+			`{"amount":{"total":100},"appid":"wx1","payer":{"openid":"oUpF****eS6o"}}`},
+		{"json array", `[{"sign":"abc"},{"out_trade_no":"ORDER_1"}]`, false,
+			`[{"sign":"******"},{"out_trade_no":"ORDER_1"}]`},
+		{"json number kept exact", `{"total_amount":12345678901234567890}`, false, `{"total_amount":12345678901234567890}`},
+		{"form", `accNo=6216261000000000018&signature=abc%3D&txnAmt=100`, false,
+			`{"accNo":"6216****0018","signature":"******","txnAmt":"100"}`},
+		{"form with json value", `app_id=2021&biz_content=%7B%22buyer_id%22%3A%222088102175953034%22%7D&sign=abc`, false,
+			`{"app_id":"2021","biz_content":{"buyer_id":"2088****3034"},"sign":"******"}`},
+		{"truncated", `{"sign":"abc"`, true, `"<13+ bytes, truncated>"`},
+		{"unparsable", "<xml><sign>abc</sign></xml>", false, `"<27 bytes>"`},
+		{"bill file", "交易时间,公众账号ID\n2024-01-01,wx1\n", false, `"<43 bytes>"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := jsonOf(t, redactBody([]byte(tc.body), tc.truncated)); got != tc.want {
+				t.Fatalf("redactBody = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	req := &payment.QueryRequest{Channel: payment.ChannelWechat, OutTradeNo: "ORDER_1"}
+	got := redactValue(map[string]interface{}{"request": req, "openid": "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"})
+	want := `{"openid":"oUpF****eS6o","request":` + jsonOf(t, req) + `}`
+	if s := jsonOf(t, got); s != want {
+		t.Fatalf("redactValue = %s, want %s", s, want)
+	}
+	if s := jsonOf(t, redactValue([]byte(`cvn2=123`))); s != `{"cvn2":"******"}` {
+		t.Fatalf("redactValue(bytes) = %s", s)
+	}
+	if redactValue(nil) != nil {
+		t.Fatal("redactValue(nil) != nil")
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.mch.weixin.qq.com/v3/pay/transactions/out-trade-no/ORDER_1?mchid=1900000001",
+			"https://api.mch.weixin.qq.com/v3/pay/transactions/out-trade-no/ORDER_1?mchid=1900000001"},
+		{"https://openapi.alipay.com/gateway.do?method=alipay.trade.query&sign=abc&app_id=2021",
+			"https://openapi.alipay.com/gateway.do?app_id=2021&method=alipay.trade.query&sign=%2A%2A%2A%2A%2A%2A"},
+		{"https://gateway.95516.com/gateway/api/queryTrans.do", "https://gateway.95516.com/gateway/api/queryTrans.do"},
+	}
+	for _, tc := range cases {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := redactURL(u); got != tc.want {
+			t.Errorf("redactURL(%s) = %s, want %s", tc.url, got, tc.want)
+		}
+		if u.String() != tc.url {
+			t.Errorf("redactURL modified its input: %s", u)
+		}
+	}
+	if redactURL(nil) != "" {
+		t.Fatal("redactURL(nil) != \"\"")
+	}
+}
@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// span 记录在文件中的位置
+type span struct {
+	offset int64
+	length int
+}
+
+// FileStore 基于本地文件的审计存储，每行一条 JSON 记录，只追加写入
+// 按商户订单号的索引保存在内存中，启动时扫描文件重建
+type FileStore struct {
+	mu    sync.RWMutex
+	path  string
+	file  *os.File
+	size  int64
+	index map[string][]span
+}
+
+// OpenFileStore 打开审计文件，文件不存在时创建
+func OpenFileStore(path string) (*FileStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("create audit directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open audit file: %w", err)
+	}
+
+	s := &FileStore{
+		path:  path,
+		file:  file,
+		index: make(map[string][]span),
+	}
+	if err := s.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load 扫描文件建立索引，末尾不完整的记录（如写入时进程退出）会被忽略
+func (s *FileStore) load() error {
+	reader := bufio.NewReader(io.NewSectionReader(s.file, 0, 1<<62))
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var record Record
+			if json.Unmarshal(line, &record) == nil {
+				s.add(&record, span{offset: offset, length: len(line) - 1})
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read audit file: %w", err)
+		}
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+	s.size = info.Size()
+	if s.size > offset {
+		// 补齐换行，避免新记录与残缺记录拼在同一行
+		n, err := s.file.Write([]byte("\n"))
+		if err != nil {
+			return fmt.Errorf("write audit file: %w", err)
+		}
+		s.size += int64(n)
+	}
+	return nil
+}
+
+// add 将记录加入索引
+func (s *FileStore) add(record *Record, sp span) {
+	if record.OutTradeNo != "" {
+		s.index[record.OutTradeNo] = append(s.index[record.OutTradeNo], sp)
+	}
+}
+
+// Append 追加记录
+func (s *FileStore) Append(ctx context.Context, record *Record) error {
+	record.fill()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.file.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("write audit file: %w", err)
+	}
+	s.add(record, span{offset: s.size, length: len(data)})
+	s.size += int64(n)
+	return nil
+}
+
+// ByOrder 按商户订单号查询记录
+func (s *FileStore) ByOrder(ctx context.Context, outTradeNo string) ([]*Record, error) {
+	s.mu.RLock()
+	spans := s.index[outTradeNo]
+	s.mu.RUnlock()
+
+	records := make([]*Record, 0, len(spans))
+	for _, sp := range spans {
+		buf := make([]byte, sp.length)
+		if _, err := s.file.ReadAt(buf, sp.offset); err != nil {
+			return nil, fmt.Errorf("read audit file: %w", err)
+		}
+		var record Record
+		if err := json.Unmarshal(buf, &record); err != nil {
+			return nil, fmt.Errorf("decode audit record: %w", err)
+		}
+		records = append(records, &record)
+	}
+	return records, nil
+}
+
+// Ping 检查审计文件仍然存在，文件被删除或移走后写入的记录无法再从路径读取
+func (s *FileStore) Ping(ctx context.Context) error {
+	current, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+	opened, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat audit file: %w", err)
+	}
+	if !os.SameFile(current, opened) {
+		return fmt.Errorf("audit file %s has been replaced, restart to reopen", s.path)
+	}
+	return nil
+}
+
+// Close 同步并关闭审计文件
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("sync audit file: %w", err)
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, r := range []*Record{
+		{Direction: DirectionOutbound, OutTradeNo: "ORDER_1", Operation: "pay"},
+		{Direction: DirectionOutbound, OutTradeNo: "ORDER_2", Operation: "pay"},
+		{Direction: DirectionInbound, Operation: "notify", Error: "invalid signature"}, // 验签失败，没有订单号
+		{Direction: DirectionInbound, OutTradeNo: "ORDER_1", Operation: "notify", Body: "{\"id\":\"EV-1\"}\n"},
+	} {
+		if err := store.Append(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		if r.ID == "" || r.Time.IsZero() {
+			t.Fatalf("record not filled: %+v", r)
+		}
+	}
+	if err := store.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// 末尾不完整的记录被忽略，之后追加的记录不受影响
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"out_trade_no":"ORDER_1","direction":`)
+	file.Close()
+
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Append(ctx, &Record{Direction: DirectionOutbound, OutTradeNo: "ORDER_1", Operation: "query"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		outTradeNo string
+		operations []string
+	}{
+		{"ORDER_1", []string{"pay", "notify", "query"}},
+		{"ORDER_2", []string{"pay"}},
+		{"ORDER_3", nil},
+	}
+	for _, tc := range cases {
+		records, err := store.ByOrder(ctx, tc.outTradeNo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != len(tc.operations) {
+			t.Fatalf("%s: %d records, want %d", tc.outTradeNo, len(records), len(tc.operations))
+		}
+		for i, r := range records {
+			if string(r.Operation) != tc.operations[i] || r.OutTradeNo != tc.outTradeNo {
+				t.Fatalf("%s record %d = %+v, want %s", tc.outTradeNo, i, r, tc.operations[i])
+			}
+		}
+	}
+
+	records, _ := store.ByOrder(ctx, "ORDER_1")
+	if records[1].Body != "{\"id\":\"EV-1\"}\n" {
+		t.Fatalf("notify body = %q, want raw body", records[1].Body)
+	}
+}
+
+func TestFileStorePing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := os.Rename(path, filepath.Join(dir, "audit.jsonl.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Ping(context.Background()); err == nil {
+		t.Fatal("ping succeeded after the file was moved")
+	}
+	if err := os.WriteFile(path, nil, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Ping(context.Background()); err == nil {
+		t.Fatal("ping succeeded after the file was replaced")
+	}
+}
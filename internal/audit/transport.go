@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// collector 收集一次渠道调用中发出的 HTTP 请求，由 Middleware 放入上下文
+type collector struct {
+	mu        sync.Mutex
+	maxBody   int
+	exchanges []*Exchange
+}
+
+type collectorKey struct{}
+
+// add 追加一次 HTTP 往来
+func (c *collector) add(exchange *Exchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exchanges = append(c.exchanges, exchange)
+}
+
+// list 已收集的 HTTP 往来
+func (c *collector) list() []*Exchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Exchange(nil), c.exchanges...)
+}
+
+// Transport 记录渠道 HTTP 请求和响应报文的 RoundTripper，base 为空时使用 http.DefaultTransport
+// 只在经过 Middleware 的渠道调用中记录，报文超过上限时截断记录，实际收发的报文不受影响
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c, ok := req.Context().Value(collectorKey{}).(*collector)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	exchange := &Exchange{
+		Method: req.Method,
+		URL:    redactURL(req.URL),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		// 请求体已读出，需要重新设置，RoundTripper 不能修改调用方的请求
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		exchange.Request = capture(body, c.maxBody)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	exchange.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		exchange.Error = err.Error()
+		c.add(exchange)
+		return nil, err
+	}
+
+	exchange.Status = resp.StatusCode
+	if resp.Body != nil {
+		head, readErr := io.ReadAll(io.LimitReader(resp.Body, int64(c.maxBody)+1))
+		exchange.Response = capture(head, c.maxBody)
+		// 已读取的部分与剩余部分拼接，调用方仍读到完整的响应
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{
+			Reader: io.MultiReader(bytes.NewReader(head), errReader{readErr, resp.Body}),
+			Closer: resp.Body,
+		}
+	}
+	c.add(exchange)
+	return resp, nil
+}
+
+// errReader 读取响应头部出错时将错误返回给调用方
+type errReader struct {
+	err  error
+	rest io.Reader
+}
+
+// Read 实现 io.Reader
+func (r errReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.rest.Read(p)
+}
+
+// capture 截取报文并脱敏
+func capture(body []byte, maxBody int) interface{} {
+	if len(body) > maxBody {
+		return redactBody(body[:maxBody], true)
+	}
+	return redactBody(body, false)
+}
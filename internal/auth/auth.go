@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxNonceLength nonce 最大长度
+const maxNonceLength = 64
+
+var (
+	ErrMissingCredentials = errors.New("missing authentication headers")
+	ErrUnknownAppKey      = errors.New("unknown app key")
+	ErrTimestampSkew      = errors.New("timestamp out of range")
+	ErrInvalidSignature   = errors.New("invalid request signature")
+	ErrReplayedNonce      = errors.New("nonce already used")
+	ErrScopeDenied        = errors.New("scope not granted")
+)
+
+// Authenticator 校验商户请求签名
+// 时间戳与服务器时间相差超过 maxSkew 的请求直接拒绝，窗口内同一 app key 的 nonce 只能使用一次
+type Authenticator struct {
+	store   Store
+	maxSkew time.Duration
+	nonces  *NonceCache
+	now     func() time.Time
+}
+
+// NewAuthenticator 创建鉴权器
+func NewAuthenticator(store Store, maxSkew time.Duration) *Authenticator {
+	return &Authenticator{
+		store:   store,
+		maxSkew: maxSkew,
+		// 时间戳在 [now-maxSkew, now+maxSkew] 内有效，nonce 需保留两个偏差的时长
+		nonces: NewNonceCache(2 * maxSkew),
+		now:    time.Now,
+	}
+}
+
+// Verify 校验请求签名，body 为完整请求体，成功时返回凭证
+func (a *Authenticator) Verify(r *http.Request, body []byte) (*Credential, error) {
+	appKey := r.Header.Get(HeaderAppKey)
+	timestamp := r.Header.Get(HeaderTimestamp)
+	nonce := r.Header.Get(HeaderNonce)
+	signature := r.Header.Get(HeaderSignature)
+	if appKey == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, ErrMissingCredentials
+	}
+	if len(nonce) > maxNonceLength {
+		return nil, fmt.Errorf("%w: nonce longer than %d", ErrMissingCredentials, maxNonceLength)
+	}
+
+	credential, ok := a.store.Get(appKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAppKey, appKey)
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid timestamp %q", ErrTimestampSkew, timestamp)
+	}
+	now := a.now()
+	if skew := now.Sub(time.Unix(ts, 0)); skew > a.maxSkew || skew < -a.maxSkew {
+		return nil, fmt.Errorf("%w: skew %s exceeds %s", ErrTimestampSkew, skew.Truncate(time.Second), a.maxSkew)
+	}
+
+	if !verifySignature(credential.Secret, signature, r.Method, r.URL.RequestURI(), timestamp, nonce, body) {
+		return nil, ErrInvalidSignature
+	}
+
+	// 签名通过后才记录 nonce，避免伪造请求占用合法 nonce
+	if !a.nonces.Use(appKey+":"+nonce, now) {
+		return nil, ErrReplayedNonce
+	}
+	return credential, nil
+}
+
+type credentialKey struct{}
+
+// WithCredential 将鉴权通过的凭证放入上下文
+func WithCredential(ctx context.Context, credential *Credential) context.Context {
+	return context.WithValue(ctx, credentialKey{}, credential)
+}
+
+// FromContext 获取上下文中的凭证，未鉴权时返回 nil
+func FromContext(ctx context.Context) *Credential {
+	credential, _ := ctx.Value(credentialKey{}).(*Credential)
+	return credential
+}
+
+// MerchantFromContext 上下文中凭证对应的商户，凭证未绑定商户时返回 app key，未鉴权时返回空
+func MerchantFromContext(ctx context.Context) string {
+	credential := FromContext(ctx)
+	if credential == nil {
+		return ""
+	}
+	if credential.MerchantID != "" {
+		return credential.MerchantID
+	}
+	return credential.AppKey
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNow 测试使用的服务器时间
+var testNow = time.Unix(1700000000, 0)
+
+// newTestAuthenticator 使用固定时钟的鉴权器
+func newTestAuthenticator() *Authenticator {
+	a := NewAuthenticator(NewStaticStore(
+		&Credential{AppKey: "app", Secret: []byte("secret"), Scopes: []Scope{ScopePay}},
+		&Credential{AppKey: "other", Secret: []byte("other-secret"), MerchantID: "m1"},
+	), 5*time.Minute)
+	a.now = func() time.Time { return testNow }
+	return a
+}
+
+// signedRequest 按 StringToSign 签名的请求
+func signedRequest(appKey, secret string, ts time.Time, nonce, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/pay?debug=1", strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	r.Header.Set(HeaderAppKey, appKey)
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, Sign([]byte(secret), r.Method, r.URL.RequestURI(), timestamp, nonce, []byte(body)))
+	return r
+}
+
+func TestStringToSign(t *testing.T) {
+	got := StringToSign("post", "/api/v1/pay?a=1", "1700000000", "n1", []byte(""))
+	want := "POST\n/api/v1/pay?a=1\n1700000000\nn1\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got != want {
+		t.Fatalf("string to sign = %q, want %q", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := `{"out_trade_no":"ORDER_1"}`
+
+	cases := []struct {
+		name    string
+		request func() (*http.Request, string)
+		err     error
+	}{
+		{"valid", func() (*http.Request, string) {
+			return signedRequest("app", "secret", testNow, "n-valid", body), body
+		}, nil},
+		{"uppercase signature", func() (*http.Request, string) {
+			r := signedRequest("app", "secret", testNow, "n-upper", body)
+			r.Header.Set(HeaderSignature, strings.ToUpper(r.Header.Get(HeaderSignature)))
+			return r, body
+		}, nil},
+		{"timestamp within skew", func() (*http.Request, string) {
+			return signedRequest("app", "secret", testNow.Add(-4*time.Minute), "n-skew", body), body
+		}, nil},
+		{"missing signature", func() (*http.Request, string) {
+			r := signedRequest("app", "secret", testNow, "n-missing", body)
+			r.Header.Del(HeaderSignature)
+			return r, body
+		}, ErrMissingCredentials},
+		{"nonce too long", func() (*http.Request, string) {
+			return signedRequest("app", "secret", testNow, strings.Repeat("n", maxNonceLength+1), body), body
+		}, ErrMissingCredentials},
+		{"unknown app key", func() (*http.Request, string) {
+			return signedRequest("nobody", "secret", testNow, "n-unknown", body), body
+		}, ErrUnknownAppKey},
+		{"expired timestamp", func() (*http.Request, string) {
+			return signedRequest("app", "secret", testNow.Add(-6*time.Minute), "n-old", body), body
+		}, ErrTimestampSkew},
+		{"future timestamp", func() (*http.Request, string) {
+			return signedRequest("app", "secret", testNow.Add(6*time.Minute), "n-future", body), body
+		}, ErrTimestampSkew},
+		{"malformed timestamp", func() (*http.Request, string) {
+			r := signedRequest("app", "secret", testNow, "n-ts", body)
+			r.Header.Set(HeaderTimestamp, "yesterday")
+			return r, body
+		}, ErrTimestampSkew},
+		{"wrong secret", func() (*http.Request, string) {
+			return signedRequest("app", "other-secret", testNow, "n-secret", body), body
+		}, ErrInvalidSignature},
+		{"tampered body", func() (*http.Request, string) {
+			return signedRequest("app", "secret", testNow, "n-body", body), `{"out_trade_no":"ORDER_2"}`
+		}, ErrInvalidSignature},
+		{"tampered query", func() (*http.Request, string) {
+			r := signedRequest("app", "secret", testNow, "n-query", body)
+			r.URL.RawQuery = "debug=0"
+			return r, body
+		}, ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, body := tc.request()
+			credential, err := newTestAuthenticator().Verify(r, []byte(body))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if err == nil && credential.AppKey != "app" {
+				t.Fatalf("credential = %+v", credential)
+			}
+		})
+	}
+}
+
+func TestVerifyReplay(t *testing.T) {
+	a := newTestAuthenticator()
+	body := `{}`
+
+	if _, err := a.Verify(signedRequest("app", "secret", testNow, "n1", body), []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Verify(signedRequest("app", "secret", testNow, "n1", body), []byte(body)); !errors.Is(err, ErrReplayedNonce) {
+		t.Fatalf("replay err = %v, want %v", err, ErrReplayedNonce)
+	}
+
+	// nonce 按 app key 区分
+	if _, err := a.Verify(signedRequest("other", "other-secret", testNow, "n1", body), []byte(body)); err != nil {
+		t.Fatalf("same nonce of another app key: %v", err)
+	}
+
+	// 签名错误的请求不占用 nonce
+	if _, err := a.Verify(signedRequest("app", "wrong", testNow, "n2", body), []byte(body)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidSignature)
+	}
+	if _, err := a.Verify(signedRequest("app", "secret", testNow, "n2", body), []byte(body)); err != nil {
+		t.Fatalf("nonce taken by forged request: %v", err)
+	}
+
+	// 超过两个偏差的时长后 nonce 过期，但原时间戳已不被接受
+	a.now = func() time.Time { return testNow.Add(10 * time.Minute) }
+	if _, err := a.Verify(signedRequest("app", "secret", testNow, "n1", body), []byte(body)); !errors.Is(err, ErrTimestampSkew) {
+		t.Fatalf("err = %v, want %v", err, ErrTimestampSkew)
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	cache := NewNonceCache(time.Minute)
+
+	if !cache.Use("app:n1", testNow) {
+		t.Fatal("first use rejected")
+	}
+	if cache.Use("app:n1", testNow.Add(59*time.Second)) {
+		t.Fatal("reuse within ttl accepted")
+	}
+	if !cache.Use("app:n1", testNow.Add(time.Minute)) {
+		t.Fatal("reuse after ttl rejected")
+	}
+
+	// 过期的 nonce 在下一次清理时删除
+	cache.Use("app:n2", testNow.Add(time.Minute))
+	cache.Use("app:n3", testNow.Add(3*time.Minute))
+	if _, ok := cache.seen["app:n2"]; ok || len(cache.seen) != 1 {
+		t.Fatalf("seen = %v after sweep", cache.seen)
+	}
+}
+
+func TestCredentialAllows(t *testing.T) {
+	credential := &Credential{Scopes: []Scope{ScopePay, ScopeQuery}}
+	for scope, want := range map[Scope]bool{ScopePay: true, ScopeQuery: true, ScopeRefund: false, ScopeAdmin: false} {
+		if got := credential.Allows(scope); got != want {
+			t.Errorf("allows %s = %v, want %v", scope, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+)
+
+// Scope API 授权范围
+type Scope string
+
+const (
+	ScopePay    Scope = "pay"    // 下单、关单、路由试算
+	ScopeRefund Scope = "refund" // 退款
+	ScopeQuery  Scope = "query"  // 查询订单
+	ScopeAdmin  Scope = "admin"  // 运维接口
+)
+
+// Credential 商户 API 凭证
+type Credential struct {
+	AppKey     string
+	Secret     []byte
+	MerchantID string // 绑定的商户，为空表示不限制
+	Scopes     []Scope
+}
+
+// Allows 凭证是否拥有指定授权范围
+func (c *Credential) Allows(scope Scope) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Store 凭证存储
+type Store interface {
+	Get(appKey string) (*Credential, bool)
+}
+
+// StaticStore 从配置加载的凭证，加载后只读
+type StaticStore struct {
+	credentials map[string]*Credential
+}
+
+// NewStaticStore 创建凭证存储
+func NewStaticStore(credentials ...*Credential) *StaticStore {
+	s := &StaticStore{credentials: make(map[string]*Credential, len(credentials))}
+	for _, c := range credentials {
+		s.credentials[c.AppKey] = c
+	}
+	return s
+}
+
+// Get 按 app key 获取凭证
+func (s *StaticStore) Get(appKey string) (*Credential, bool) {
+	c, ok := s.credentials[appKey]
+	return c, ok
+}
+
+// LoadCredentials 从配置加载凭证，签名密钥通过 secrets 解析引用
+func LoadCredentials(ctx context.Context, cfg configs.AuthConfig, secrets secret.SecretProvider) (*StaticStore, error) {
+	credentials := make([]*Credential, 0, len(cfg.Keys))
+	for _, key := range cfg.Keys {
+		value, err := secret.LoadValue(ctx, secrets, key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("load secret of app key %s failed: %w", key.AppKey, err)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("secret of app key %s is empty", key.AppKey)
+		}
+
+		scopes := make([]Scope, len(key.Scopes))
+		for i, scope := range key.Scopes {
+			scopes[i] = Scope(scope)
+		}
+		credentials = append(credentials, &Credential{
+			AppKey:     key.AppKey,
+			Secret:     []byte(value),
+			MerchantID: key.MerchantID,
+			Scopes:     scopes,
+		})
+	}
+	return NewStaticStore(credentials...), nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// NonceCache 记录时间窗口内已使用的 nonce，用于防重放
+// 时间戳超出窗口的请求会被直接拒绝，因此 nonce 只需保留到对应的时间戳过期
+type NonceCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewNonceCache 创建 nonce 缓存
+func NewNonceCache(ttl time.Duration) *NonceCache {
+	return &NonceCache{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+	}
+}
+
+// Use 记录 nonce，已在窗口内使用过时返回 false
+func (n *NonceCache) Use(key string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if now.Sub(n.lastSweep) >= n.ttl {
+		n.sweep(now)
+	}
+
+	if expires, ok := n.seen[key]; ok && now.Before(expires) {
+		return false
+	}
+	n.seen[key] = now.Add(n.ttl)
+	return true
+}
+
+// sweep 清理过期的 nonce，调用方需持有锁
+func (n *NonceCache) sweep(now time.Time) {
+	for key, expires := range n.seen {
+		if !now.Before(expires) {
+			delete(n.seen, key)
+		}
+	}
+	n.lastSweep = now
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// 签名相关请求头
+const (
+	HeaderAppKey    = "X-App-Key"
+	HeaderTimestamp = "X-Timestamp" // Unix 秒
+	HeaderNonce     = "X-Nonce"     // 每个请求唯一的随机串
+	HeaderSignature = "X-Signature" // 十六进制 HMAC-SHA256
+)
+
+// StringToSign 待签名串，各部分以换行连接：
+//
+//	METHOD
+//	请求 URI（路径和原始查询串）
+//	时间戳
+//	nonce
+//	请求体 SHA-256 十六进制
+func StringToSign(method, requestURI, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		requestURI,
+		timestamp,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+// Sign 计算请求签名
+func Sign(secret []byte, method, requestURI, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(StringToSign(method, requestURI, timestamp, nonce, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature 常量时间比较签名
+func verifySignature(secret []byte, signature, method, requestURI, timestamp, nonce string, body []byte) bool {
+	expected := Sign(secret, method, requestURI, timestamp, nonce, body)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
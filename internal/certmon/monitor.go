@@ -0,0 +1,158 @@
+package certmon
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"go.uber.org/zap"
+)
+
+// stageExpired 证书已过期或未生效，排在所有阈值之前
+const stageExpired = 0
+
+// sentKey 已发出告警的记录，按输出分别记录，某个输出失败不影响其他输出
+type sentKey struct {
+	sink int
+	cert string
+}
+
+// Monitor 定期检查各渠道已加载的证书，剩余天数不超过阈值时发出告警
+// 每张证书在每个阈值只告警一次，过期时再告警一次；证书轮换后按新证书重新计算
+// 告警记录保存在内存中，重启后会对仍低于阈值的证书重新告警一次
+type Monitor struct {
+	gateway    *payment.PaymentGateway
+	interval   time.Duration
+	thresholds []int // 升序
+	sinks      []Sink
+
+	mu   sync.Mutex
+	sent map[sentKey]int // 已告警的最低阶段
+}
+
+// NewMonitor 创建证书监控，sinks 为告警输出
+func NewMonitor(gateway *payment.PaymentGateway, cfg configs.CertMonitorConfig, sinks ...Sink) *Monitor {
+	thresholds := append([]int(nil), cfg.Thresholds...)
+	sort.Ints(thresholds)
+	return &Monitor{
+		gateway:    gateway,
+		interval:   cfg.Interval,
+		thresholds: thresholds,
+		sinks:      sinks,
+		sent:       make(map[sentKey]int),
+	}
+}
+
+// Start 启动后台检查，立即检查一次，之后按间隔检查，ctx 取消后停止
+func (m *Monitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			m.Check(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Check 检查一次所有证书，返回本次发出的告警
+func (m *Monitor) Check(ctx context.Context) []*Alert {
+	now := time.Now()
+	infos := m.gateway.GetCertInfos(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := make(map[string]bool, len(infos))
+	var alerts []*Alert
+	for _, info := range infos {
+		key := certKey(info)
+		current[key] = true
+
+		alert := m.evaluate(info, now)
+		if alert == nil {
+			continue
+		}
+		stage := alert.Threshold
+		sent := false
+		for i, sink := range m.sinks {
+			sk := sentKey{sink: i, cert: key}
+			if last, ok := m.sent[sk]; ok && last <= stage {
+				continue
+			}
+			if err := sink.Send(ctx, alert); err != nil {
+				logger.FromContext(ctx).Warn("send cert alert failed",
+					zap.String("channel", string(info.Channel)),
+					zap.String("cert", info.Name),
+					zap.String("sink", fmt.Sprintf("%T", sink)),
+					zap.Error(err))
+				continue
+			}
+			m.sent[sk] = stage
+			sent = true
+		}
+		if sent {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	// 已轮换或移除的证书不再跟踪
+	for sk := range m.sent {
+		if !current[sk.cert] {
+			delete(m.sent, sk)
+		}
+	}
+	return alerts
+}
+
+// evaluate 计算证书所处的告警阶段，无需告警时返回空
+func (m *Monitor) evaluate(info payment.CertInfo, now time.Time) *Alert {
+	days := info.DaysToExpiry(now)
+	alert := &Alert{
+		Level:        LevelWarning,
+		Channel:      info.Channel,
+		Name:         info.Name,
+		Serial:       info.Serial,
+		Subject:      info.Subject,
+		NotAfter:     info.NotAfter,
+		DaysToExpiry: days,
+	}
+
+	switch {
+	case now.After(info.NotAfter):
+		alert.Level = LevelCritical
+		alert.Threshold = stageExpired
+		alert.Message = fmt.Sprintf("%s %s certificate expired", info.Channel, info.Name)
+		return alert
+	case now.Before(info.NotBefore):
+		alert.Level = LevelCritical
+		alert.Threshold = stageExpired
+		alert.Message = fmt.Sprintf("%s %s certificate is not yet valid", info.Channel, info.Name)
+		return alert
+	}
+
+	for _, threshold := range m.thresholds {
+		if days <= threshold {
+			alert.Threshold = threshold
+			alert.Message = fmt.Sprintf("%s %s certificate expires in %d days", info.Channel, info.Name, days)
+			return alert
+		}
+	}
+	return nil
+}
+
+// certKey 证书标识，同一用途的证书轮换后序列号变化
+func certKey(info payment.CertInfo) string {
+	return string(info.Channel) + "/" + info.Name + "/" + info.Serial
+}
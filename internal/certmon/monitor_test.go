@@ -0,0 +1,193 @@
+package certmon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/signing"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
+)
+
+// certAdapter 报告预设证书的微信适配器
+type certAdapter struct {
+	mu    sync.Mutex
+	certs []payment.CertInfo
+}
+
+func (a *certAdapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) GetChannel() payment.ChannelType {
+	return payment.ChannelWechat
+}
+
+func (a *certAdapter) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) Query(ctx context.Context, req *payment.QueryRequest) (*payment.QueryResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) Close(ctx context.Context, req *payment.CloseRequest) error {
+	return errors.New("not implemented")
+}
+
+func (a *certAdapter) CertInfos(ctx context.Context) []payment.CertInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]payment.CertInfo(nil), a.certs...)
+}
+
+// set 替换加载的证书
+func (a *certAdapter) set(certs ...payment.CertInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.certs = certs
+}
+
+// cert 剩余 days 天过期的平台证书
+func cert(serial string, days int) payment.CertInfo {
+	now := time.Now()
+	return payment.CertInfo{
+		Channel:   payment.ChannelWechat,
+		Name:      "platform",
+		Serial:    serial,
+		NotBefore: now.AddDate(-1, 0, 0),
+		NotAfter:  now.Add(time.Duration(days)*24*time.Hour + time.Hour),
+	}
+}
+
+// recordSink 记录收到的告警，err 不为空时发送失败
+type recordSink struct {
+	err    error
+	alerts []*Alert
+}
+
+func (s *recordSink) Send(ctx context.Context, alert *Alert) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+// TestCheck 每张证书在每个阈值只告警一次，过期时再告警一次，轮换后的证书重新计算
+func TestCheck(t *testing.T) {
+	adapter := &certAdapter{}
+	sink := &recordSink{}
+	monitor := NewMonitor(payment.NewPaymentGateway(adapter), configs.CertMonitorConfig{Thresholds: []int{30, 7}}, sink)
+
+	steps := []struct {
+		name      string
+		certs     []payment.CertInfo
+		level     Level // 为空表示不告警
+		threshold int
+	}{
+		{"far from expiry", []payment.CertInfo{cert("01", 100)}, "", 0},
+		{"below 30 days", []payment.CertInfo{cert("01", 25)}, LevelWarning, 30},
+		{"same stage", []payment.CertInfo{cert("01", 20)}, "", 0},
+		{"below 7 days", []payment.CertInfo{cert("01", 5)}, LevelWarning, 7},
+		{"back above 7 days", []payment.CertInfo{cert("01", 10)}, "", 0},
+		{"expired", []payment.CertInfo{cert("01", -1)}, LevelCritical, 0},
+		{"still expired", []payment.CertInfo{cert("01", -2)}, "", 0},
+		{"rotated", []payment.CertInfo{cert("02", 365)}, "", 0},
+		{"rotated cert expiring", []payment.CertInfo{cert("02", 29)}, LevelWarning, 30},
+		{"not yet valid", []payment.CertInfo{func() payment.CertInfo {
+			c := cert("03", 365)
+			c.NotBefore = time.Now().Add(time.Hour)
+			return c
+		}()}, LevelCritical, 0},
+	}
+	for _, step := range steps {
+		adapter.set(step.certs...)
+		before := len(sink.alerts)
+		alerts := monitor.Check(context.Background())
+
+		if step.level == "" {
+			if len(alerts) != 0 || len(sink.alerts) != before {
+				t.Fatalf("%s: alerts = %+v, want none", step.name, alerts)
+			}
+			continue
+		}
+		if len(alerts) != 1 || len(sink.alerts) != before+1 {
+			t.Fatalf("%s: alerts = %+v, want one", step.name, alerts)
+		}
+		alert := alerts[0]
+		if alert.Level != step.level || alert.Threshold != step.threshold || alert.Serial != step.certs[0].Serial || alert.Message == "" {
+			t.Fatalf("%s: alert = %+v, want %s at %d", step.name, alert, step.level, step.threshold)
+		}
+	}
+}
+
+// TestCheckRetriesFailedSink 发送失败的输出在下一轮重试，已成功的输出不重复告警
+func TestCheckRetriesFailedSink(t *testing.T) {
+	adapter := &certAdapter{}
+	adapter.set(cert("01", 5))
+	ok := &recordSink{}
+	failing := &recordSink{err: errors.New("connection refused")}
+	monitor := NewMonitor(payment.NewPaymentGateway(adapter), configs.CertMonitorConfig{Thresholds: []int{7}}, ok, failing)
+
+	if alerts := monitor.Check(context.Background()); len(alerts) != 1 {
+		t.Fatalf("alerts = %d, want 1", len(alerts))
+	}
+	failing.err = nil
+	monitor.Check(context.Background())
+	monitor.Check(context.Background())
+	if len(ok.alerts) != 1 || len(failing.alerts) != 1 {
+		t.Fatalf("sink alerts = %d, %d, want 1 each", len(ok.alerts), len(failing.alerts))
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	signer, err := signing.GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(signing.HeaderSignature) == "" {
+			t.Error("alert not signed")
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	dispatcher := webhook.NewDispatcher(signer, webhook.Policy{Schemes: []string{"http"}, AllowPrivate: true}, nil)
+	sink := NewWebhookSink(dispatcher, srv.URL)
+	alert := &Alert{Level: LevelCritical, Channel: payment.ChannelWechat, Name: "platform", Serial: "01", Message: "wechat platform certificate expired"}
+	if err := sink.Send(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	var event struct {
+		Type string `json:"type"`
+		Data Alert  `json:"data"`
+	}
+	if err := json.Unmarshal(<-received, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != webhook.EventCertExpiring || event.Data.Serial != "01" || event.Data.Level != LevelCritical {
+		t.Fatalf("event = %+v", event)
+	}
+
+	srv.Close()
+	if err := sink.Send(context.Background(), alert); err == nil {
+		t.Fatal("failed delivery not reported")
+	}
+}
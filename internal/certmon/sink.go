@@ -0,0 +1,79 @@
+package certmon
+
+import (
+	"context"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/webhook"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"go.uber.org/zap"
+)
+
+// Level 告警级别
+type Level string
+
+const (
+	LevelWarning  Level = "warning"  // 剩余天数低于阈值
+	LevelCritical Level = "critical" // 已过期或未生效
+)
+
+// Alert 证书告警
+type Alert struct {
+	Level        Level               `json:"level"`
+	Channel      payment.ChannelType `json:"channel"`
+	Name         string              `json:"name"` // 证书用途: merchant、platform、sign 等
+	Serial       string              `json:"serial"`
+	Subject      string              `json:"subject"`
+	NotAfter     time.Time           `json:"not_after"`
+	DaysToExpiry int                 `json:"days_to_expiry"`
+	Threshold    int                 `json:"threshold,omitempty"` // 触发告警的阈值，过期告警为 0
+	Message      string              `json:"message"`
+}
+
+// Sink 告警输出，如日志、webhook，可自行实现接入短信、IM 等
+type Sink interface {
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// LogSink 将告警写入日志，warning 级别为 warn 日志，critical 级别为 error 日志
+type LogSink struct{}
+
+// Send 实现 Sink
+func (LogSink) Send(ctx context.Context, alert *Alert) error {
+	log := logger.FromContext(ctx).Named("certmon")
+	fields := []zap.Field{
+		zap.String("channel", string(alert.Channel)),
+		zap.String("cert", alert.Name),
+		zap.String("serial", alert.Serial),
+		zap.String("subject", alert.Subject),
+		zap.Time("not_after", alert.NotAfter),
+		zap.Int("days_to_expiry", alert.DaysToExpiry),
+	}
+	if alert.Level == LevelCritical {
+		log.Error(alert.Message, fields...)
+	} else {
+		log.Warn(alert.Message, fields...)
+	}
+	return nil
+}
+
+// WebhookSink 将告警以 cert.expiring 事件推送到指定地址，与商户事件一样使用网关密钥签名
+type WebhookSink struct {
+	dispatcher *webhook.Dispatcher
+	url        string
+}
+
+// NewWebhookSink 创建 webhook 告警输出
+func NewWebhookSink(dispatcher *webhook.Dispatcher, url string) *WebhookSink {
+	return &WebhookSink{
+		dispatcher: dispatcher,
+		url:        url,
+	}
+}
+
+// Send 实现 Sink，投递失败时返回错误，由下一轮检查重试
+func (s *WebhookSink) Send(ctx context.Context, alert *Alert) error {
+	return s.dispatcher.Deliver(ctx, s.url, webhook.NewEvent(webhook.EventCertExpiring, alert))
+}
@@ -0,0 +1,302 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/adapters"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// Status 检查结果
+type Status string
+
+const (
+	StatusUp       Status = "up"       // 正常
+	StatusWarn     Status = "warn"     // 可用但需要处理，如证书即将过期、熔断器打开
+	StatusDown     Status = "down"     // 不可用
+	StatusDisabled Status = "disabled" // 配置中未启用
+)
+
+// severity 状态的严重程度，用于汇总
+func (s Status) severity() int {
+	switch s {
+	case StatusWarn:
+		return 1
+	case StatusDown:
+		return 2
+	}
+	return 0
+}
+
+// worse 返回更严重的状态
+func worse(a, b Status) Status {
+	if b.severity() > a.severity() {
+		return b
+	}
+	return a
+}
+
+// Check 单项检查结果
+type Check struct {
+	Name    string                 `json:"name"`
+	Status  Status                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Component 组件检查结果，组件状态为各检查项中最严重的状态
+type Component struct {
+	Name     string  `json:"name"`
+	Kind     string  `json:"kind"` // channel 或 dependency
+	Status   Status  `json:"status"`
+	Required bool    `json:"required"` // 不可用时就绪检查是否失败
+	Checks   []Check `json:"checks,omitempty"`
+}
+
+// add 追加检查项并更新组件状态
+func (c *Component) add(check Check) {
+	c.Checks = append(c.Checks, check)
+	c.Status = worse(c.Status, check.Status)
+}
+
+// Report 就绪检查报告
+type Report struct {
+	Status     Status      `json:"status"`
+	Ready      bool        `json:"ready"`
+	Components []Component `json:"components"`
+	Timestamp  int64       `json:"timestamp"`
+}
+
+// Pinger 可检查连通性的外部依赖，如数据库、缓存
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// dependency 注册的外部依赖
+type dependency struct {
+	name     string
+	pinger   Pinger
+	required bool
+}
+
+// probeResult 缓存的渠道探测结果
+type probeResult struct {
+	err error
+	at  time.Time
+}
+
+// Checker 就绪检查，逐个检查渠道适配器和外部依赖
+type Checker struct {
+	gateway *payment.PaymentGateway
+	cfg     configs.HealthConfig
+	config  func() *configs.Config // 当前生效的配置，热加载后随之变化
+
+	mu     sync.Mutex
+	deps   []dependency
+	probes map[payment.ChannelType]probeResult
+}
+
+// NewChecker 创建就绪检查
+func NewChecker(gateway *payment.PaymentGateway, cfg configs.HealthConfig, config func() *configs.Config) *Checker {
+	return &Checker{
+		gateway: gateway,
+		cfg:     cfg,
+		config:  config,
+		probes:  make(map[payment.ChannelType]probeResult),
+	}
+}
+
+// AddDependency 注册外部依赖，required 为 true 时不可达会使就绪检查失败
+func (c *Checker) AddDependency(name string, pinger Pinger, required bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deps = append(c.deps, dependency{name: name, pinger: pinger, required: required})
+}
+
+// Ready 执行就绪检查
+// 必需组件不可用或没有任何可用渠道时 Ready 为 false；非必需组件不可用时状态为 warn，仍然就绪
+func (c *Checker) Ready(ctx context.Context) Report {
+	required := make(map[string]bool, len(c.cfg.RequiredChannels))
+	for _, channel := range c.cfg.RequiredChannels {
+		required[channel] = true
+	}
+
+	var validation *configs.ValidationError
+	if err := c.config().Validate(); err != nil {
+		errors.As(err, &validation)
+	}
+
+	report := Report{Status: StatusUp, Ready: true, Timestamp: time.Now().Unix()}
+	anyChannelUp := false
+	for _, channel := range adapters.Channels {
+		component := c.checkChannel(ctx, channel, validation, required[string(channel)])
+		if component.Status != StatusDisabled && component.Status != StatusDown {
+			anyChannelUp = true
+		}
+		report.add(component)
+	}
+	for _, component := range c.checkDependencies(ctx) {
+		report.add(component)
+	}
+
+	if !anyChannelUp {
+		report.Ready = false
+		report.Status = StatusDown
+	}
+	return report
+}
+
+// add 追加组件并更新总体状态
+func (r *Report) add(component Component) {
+	r.Components = append(r.Components, component)
+	switch {
+	case component.Status == StatusDown && component.Required:
+		r.Ready = false
+		r.Status = StatusDown
+	case component.Status == StatusDown, component.Status == StatusWarn:
+		r.Status = worse(r.Status, StatusWarn)
+	}
+}
+
+// checkChannel 检查渠道: 运行状态、配置完整性、密钥、证书有效期以及可选的渠道探测
+func (c *Checker) checkChannel(ctx context.Context, channel payment.ChannelType, validation *configs.ValidationError, required bool) Component {
+	component := Component{Name: string(channel), Kind: "channel", Status: StatusUp, Required: required}
+
+	status := c.gateway.GetChannelStatus(channel)
+	if status.State == payment.ChannelStateDisabled {
+		if required {
+			component.add(Check{Name: "state", Status: StatusDown, Message: "required channel is disabled"})
+			return component
+		}
+		component.Status = StatusDisabled
+		return component
+	}
+
+	// 配置完整性
+	config := Check{Name: "config", Status: StatusUp}
+	if validation != nil {
+		if errs := validation.Section(string(channel)); len(errs) > 0 {
+			config.Status = StatusDown
+			config.Message = errs[0].Error()
+			config.Details = map[string]interface{}{"errors": len(errs)}
+		}
+	}
+	component.add(config)
+
+	// 运行状态，初始化失败时后续检查无法进行
+	state := Check{Name: "state", Status: StatusUp, Details: map[string]interface{}{"state": status.State}}
+	if status.State == payment.ChannelStateFailed {
+		state.Status = StatusDown
+		state.Message = status.Error
+		state.Details["attempts"] = status.Attempts
+		component.add(state)
+		return component
+	}
+	if status.Breaker != nil && status.Breaker.State != payment.BreakerClosed {
+		state.Status = StatusWarn
+		state.Message = fmt.Sprintf("circuit breaker is %s", status.Breaker.State)
+	}
+	component.add(state)
+
+	keys := Check{Name: "keys", Status: StatusUp}
+	if err := c.gateway.CheckKeys(ctx, channel); err != nil {
+		keys.Status = StatusDown
+		keys.Message = err.Error()
+	}
+	component.add(keys)
+
+	for _, info := range c.gateway.ChannelCertInfos(ctx, channel) {
+		component.add(c.checkCert(info))
+	}
+
+	if c.cfg.Probe {
+		probe := Check{Name: "probe", Status: StatusUp}
+		result := c.probe(ctx, channel)
+		switch {
+		case errors.Is(result.err, payment.ErrNotSupported):
+			// 渠道未实现查询，无法探测
+			probe.Status = StatusDisabled
+			probe.Message = result.err.Error()
+		case result.err != nil:
+			probe.Status = StatusDown
+			probe.Message = result.err.Error()
+		}
+		probe.Details = map[string]interface{}{"checked_at": result.at.Unix()}
+		component.add(probe)
+	}
+	return component
+}
+
+// checkCert 检查证书有效期，已过期或未生效为 down，剩余天数低于 cert_warn_days 为 warn
+func (c *Checker) checkCert(info payment.CertInfo) Check {
+	now := time.Now()
+	days := info.DaysToExpiry(now)
+	check := Check{
+		Name:   "cert:" + info.Name,
+		Status: StatusUp,
+		Details: map[string]interface{}{
+			"serial":         info.Serial,
+			"not_after":      info.NotAfter,
+			"days_to_expiry": days,
+		},
+	}
+	switch {
+	case now.After(info.NotAfter):
+		check.Status = StatusDown
+		check.Message = "certificate expired"
+	case now.Before(info.NotBefore):
+		check.Status = StatusDown
+		check.Message = "certificate is not yet valid"
+	case days < c.cfg.CertWarnDays:
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("certificate expires in %d days", days)
+	}
+	return check
+}
+
+// probe 返回渠道探测结果，缓存 probe_interval 内的结果
+func (c *Checker) probe(ctx context.Context, channel payment.ChannelType) probeResult {
+	c.mu.Lock()
+	cached, ok := c.probes[channel]
+	c.mu.Unlock()
+	if ok && time.Since(cached.at) < c.cfg.ProbeInterval {
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.ProbeTimeout)
+	defer cancel()
+	result := probeResult{err: c.gateway.Probe(ctx, channel), at: time.Now()}
+
+	c.mu.Lock()
+	c.probes[channel] = result
+	c.mu.Unlock()
+	return result
+}
+
+// checkDependencies 检查外部依赖的连通性
+func (c *Checker) checkDependencies(ctx context.Context) []Component {
+	c.mu.Lock()
+	deps := append([]dependency(nil), c.deps...)
+	c.mu.Unlock()
+
+	components := make([]Component, 0, len(deps))
+	for _, dep := range deps {
+		component := Component{Name: dep.name, Kind: "dependency", Status: StatusUp, Required: dep.required}
+		check := Check{Name: "ping", Status: StatusUp}
+		start := time.Now()
+		if err := dep.pinger.Ping(ctx); err != nil {
+			check.Status = StatusDown
+			check.Message = err.Error()
+		}
+		check.Details = map[string]interface{}{"latency_ms": time.Since(start).Milliseconds()}
+		component.add(check)
+		components = append(components, component)
+	}
+	return components
+}
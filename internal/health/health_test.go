@@ -0,0 +1,205 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// healthAdapter 返回预设密钥检查、证书和查询结果的适配器
+type healthAdapter struct {
+	channel  payment.ChannelType
+	keysErr  error
+	certs    []payment.CertInfo
+	queryErr error
+	queries  int
+}
+
+func (a *healthAdapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *healthAdapter) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *healthAdapter) GetChannel() payment.ChannelType {
+	return a.channel
+}
+
+func (a *healthAdapter) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *healthAdapter) Query(ctx context.Context, req *payment.QueryRequest) (*payment.QueryResponse, error) {
+	a.queries++
+	if a.queryErr != nil {
+		return nil, a.queryErr
+	}
+	return &payment.QueryResponse{OutTradeNo: req.OutTradeNo, TradeStatus: payment.TradeStatusNotPay}, nil
+}
+
+func (a *healthAdapter) Close(ctx context.Context, req *payment.CloseRequest) error {
+	return errors.New("not implemented")
+}
+
+func (a *healthAdapter) CheckKeys(ctx context.Context) error {
+	return a.keysErr
+}
+
+func (a *healthAdapter) CertInfos(ctx context.Context) []payment.CertInfo {
+	return a.certs
+}
+
+// cert 剩余 days 天过期的证书
+func cert(days int) payment.CertInfo {
+	now := time.Now()
+	return payment.CertInfo{Name: "platform", Serial: "01", NotBefore: now.AddDate(-1, 0, 0), NotAfter: now.Add(time.Duration(days)*24*time.Hour + time.Hour)}
+}
+
+// pinger 返回预设结果的外部依赖
+type pinger struct{ err error }
+
+func (p pinger) Ping(ctx context.Context) error { return p.err }
+
+func TestReady(t *testing.T) {
+	cases := []struct {
+		name     string
+		setup    func(g *payment.PaymentGateway, c *Checker)
+		config   func(cfg *configs.Config)
+		required []string
+		status   Status
+		ready    bool
+		checks   map[string]Status // 组件名/检查项 -> 状态
+	}{
+		{"all channels up", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat, certs: []payment.CertInfo{cert(365)}})
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelAlipay})
+		}, nil, nil, StatusUp, true, map[string]Status{
+			"wechat/keys": StatusUp, "wechat/cert:platform": StatusUp, "alipay/state": StatusUp,
+		}},
+		{"optional channel failed", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat})
+			g.MarkFailed(payment.ChannelAlipay, errors.New("load key failed"))
+		}, nil, nil, StatusWarn, true, map[string]Status{
+			"wechat/state": StatusUp, "alipay/state": StatusDown,
+		}},
+		{"required channel failed", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat})
+			g.MarkFailed(payment.ChannelAlipay, errors.New("load key failed"))
+		}, nil, []string{"alipay"}, StatusDown, false, map[string]Status{
+			"alipay/state": StatusDown,
+		}},
+		{"required channel disabled", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat})
+		}, nil, []string{"unionpay"}, StatusDown, false, map[string]Status{
+			"unionpay/state": StatusDown,
+		}},
+		{"no channel available", func(g *payment.PaymentGateway, c *Checker) {
+			g.MarkFailed(payment.ChannelWechat, errors.New("load key failed"))
+		}, nil, nil, StatusDown, false, map[string]Status{
+			"wechat/state": StatusDown,
+		}},
+		{"keys unavailable", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat, keysErr: errors.New("platform certificates not downloaded")})
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelAlipay})
+		}, nil, nil, StatusWarn, true, map[string]Status{
+			"wechat/keys": StatusDown,
+		}},
+		{"certificate expiring", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat, certs: []payment.CertInfo{cert(10)}})
+		}, nil, nil, StatusWarn, true, map[string]Status{
+			"wechat/cert:platform": StatusWarn,
+		}},
+		{"certificate expired", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat, certs: []payment.CertInfo{cert(-2)}})
+		}, nil, []string{"wechat"}, StatusDown, false, map[string]Status{
+			"wechat/cert:platform": StatusDown,
+		}},
+		{"invalid channel config", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat})
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelAlipay})
+		}, func(cfg *configs.Config) { cfg.Wechat.Enabled = true }, nil, StatusWarn, true, map[string]Status{
+			"wechat/config": StatusDown, "alipay/config": StatusUp,
+		}},
+		{"optional dependency down", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat})
+			c.AddDependency("audit", pinger{errors.New("audit file removed")}, false)
+		}, nil, nil, StatusWarn, true, map[string]Status{
+			"audit/ping": StatusDown,
+		}},
+		{"required dependency down", func(g *payment.PaymentGateway, c *Checker) {
+			g.SetAdapter(&healthAdapter{channel: payment.ChannelWechat})
+			c.AddDependency("orders", pinger{}, true)
+			c.AddDependency("audit", pinger{errors.New("audit file removed")}, true)
+		}, nil, nil, StatusDown, false, map[string]Status{
+			"orders/ping": StatusUp, "audit/ping": StatusDown,
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &configs.Config{}
+			if tc.config != nil {
+				tc.config(cfg)
+			}
+			gateway := payment.NewPaymentGateway()
+			checker := NewChecker(gateway, configs.HealthConfig{RequiredChannels: tc.required, CertWarnDays: 30},
+				func() *configs.Config { return cfg })
+			tc.setup(gateway, checker)
+
+			report := checker.Ready(context.Background())
+			if report.Status != tc.status || report.Ready != tc.ready {
+				t.Fatalf("status = %s, ready = %v, want %s, %v: %+v", report.Status, report.Ready, tc.status, tc.ready, report.Components)
+			}
+			checks := make(map[string]Status)
+			for _, component := range report.Components {
+				for _, check := range component.Checks {
+					checks[component.Name+"/"+check.Name] = check.Status
+				}
+			}
+			for name, want := range tc.checks {
+				if got := checks[name]; got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestProbe 渠道返回订单不存在视为正常，结果在 probe_interval 内缓存
+func TestProbe(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status Status
+	}{
+		{"order not found", fmt.Errorf("query order: %w", payment.ErrOrderNotFound), StatusUp},
+		{"not paid", nil, StatusUp},
+		{"channel error", errors.New("connection refused"), StatusDown},
+		{"query not supported", fmt.Errorf("unionpay query: %w", payment.ErrNotSupported), StatusDisabled},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			adapter := &healthAdapter{channel: payment.ChannelWechat, queryErr: tc.err}
+			gateway := payment.NewPaymentGateway(adapter)
+			checker := NewChecker(gateway, configs.HealthConfig{Probe: true, ProbeInterval: time.Minute, ProbeTimeout: time.Second},
+				func() *configs.Config { return &configs.Config{} })
+
+			for i := 0; i < 3; i++ {
+				report := checker.Ready(context.Background())
+				probe := report.Components[0].Checks[len(report.Components[0].Checks)-1]
+				if probe.Name != "probe" || probe.Status != tc.status {
+					t.Fatalf("probe = %+v, want %s", probe, tc.status)
+				}
+			}
+			if adapter.queries != 1 {
+				t.Fatalf("channel queried %d times, want 1", adapter.queries)
+			}
+		})
+	}
+}
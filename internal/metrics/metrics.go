@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace 指标名前缀
+const namespace = "payment_gateway"
+
+// Metrics 网关 Prometheus 指标
+// 渠道调用指标通过网关中间件采集，覆盖所有适配器
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	inFlight  *prometheus.GaugeVec
+	notifyLag *prometheus.HistogramVec
+}
+
+// New 创建指标并注册到独立的 registry，同时注册 Go 运行时和进程指标
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "channel_requests_total",
+			Help:      "Channel calls by channel, scene, operation and gateway error code (0 for success).",
+		}, []string{"channel", "scene", "operation", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "channel_request_duration_seconds",
+			Help:      "Channel call latency including retries.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+		}, []string{"channel", "scene", "operation"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "channel_in_flight_requests",
+			Help:      "Channel calls currently in progress.",
+		}, []string{"channel", "operation"}),
+		notifyLag: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "notify_lag_seconds",
+			Help:      "Time from channel payment completion to notification processed by the gateway.",
+			Buckets:   []float64{0.5, 1, 2, 5, 10, 30, 60, 300, 900, 3600},
+		}, []string{"channel"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.inFlight,
+		m.notifyLag,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Register 注册其他组件的指标，如限流统计
+func (m *Metrics) Register(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
+
+// Handler 指标 HTTP 处理器
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware 渠道调用指标中间件
+// 应注册在最外层，耗时包含重试，被限流和熔断拒绝的调用按对应错误码计数
+func (m *Metrics) Middleware() payment.Middleware {
+	return func(next payment.Handler) payment.Handler {
+		return func(ctx context.Context, call *payment.Call) error {
+			channel := string(call.Channel)
+			operation := string(call.Operation)
+			scene := sceneOf(call)
+
+			inFlight := m.inFlight.WithLabelValues(channel, operation)
+			inFlight.Inc()
+			start := time.Now()
+
+			err := next(ctx, call)
+
+			inFlight.Dec()
+			m.duration.WithLabelValues(channel, scene, operation).Observe(time.Since(start).Seconds())
+			m.requests.WithLabelValues(channel, scene, operation, payment.ErrorCodeOf(err).Code).Inc()
+
+			if err == nil && call.Operation == payment.OpNotify {
+				if result, ok := call.Result.(*payment.NotifyResult); ok && result != nil && result.PayTime != nil {
+					m.notifyLag.WithLabelValues(channel).Observe(time.Since(*result.PayTime).Seconds())
+				}
+			}
+			return err
+		}
+	}
+}
+
+// sceneOf 下单调用的支付场景，其他操作为空
+func sceneOf(call *payment.Call) string {
+	if req, ok := call.Request.(*payment.UnifiedPayRequest); ok {
+		return string(req.Scene)
+	}
+	return ""
+}
+
+// rateLimitCollector 限流统计指标，按限流维度汇总，不按 app key 或 IP 展开以控制基数
+type rateLimitCollector struct {
+	desc     *prometheus.Desc
+	inbound  *ratelimit.Inbound
+	outbound *ratelimit.Outbound
+}
+
+// NewRateLimitCollector 创建限流统计指标，inbound、outbound 可为空
+func NewRateLimitCollector(inbound *ratelimit.Inbound, outbound *ratelimit.Outbound) prometheus.Collector {
+	return &rateLimitCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "rate_limited_total"),
+			"Requests rejected by rate limiting, by direction and limit scope.",
+			[]string{"direction", "scope"}, nil),
+		inbound:  inbound,
+		outbound: outbound,
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *rateLimitCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect 实现 prometheus.Collector
+func (c *rateLimitCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.inbound != nil {
+		c.collect(ch, "inbound", c.inbound.Totals())
+	}
+	if c.outbound != nil {
+		c.collect(ch, "outbound", c.outbound.Totals())
+	}
+}
+
+// collect 使用限流器的累计次数，按 key 的统计随空闲桶清理，不能用于计数器
+func (c *rateLimitCollector) collect(ch chan<- prometheus.Metric, direction string, totals map[string]uint64) {
+	for scope, total := range totals {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(total), direction, scope)
+	}
+}
+
+// certCollector 证书有效期指标，采集时读取各渠道当前加载的证书，证书轮换后立即反映
+type certCollector struct {
+	daysToExpiry *prometheus.Desc
+	notAfter     *prometheus.Desc
+	gateway      *payment.PaymentGateway
+}
+
+// NewCertCollector 创建证书有效期指标
+func NewCertCollector(gateway *payment.PaymentGateway) prometheus.Collector {
+	labels := []string{"channel", "name", "serial"}
+	return &certCollector{
+		daysToExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cert", "days_to_expiry"),
+			"Days until the loaded channel certificate expires, negative once expired.",
+			labels, nil),
+		notAfter: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cert", "not_after_timestamp_seconds"),
+			"Expiry time of the loaded channel certificate as a Unix timestamp.",
+			labels, nil),
+		gateway: gateway,
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *certCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.daysToExpiry
+	ch <- c.notAfter
+}
+
+// Collect 实现 prometheus.Collector
+func (c *certCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, info := range c.gateway.GetCertInfos(context.Background()) {
+		key := string(info.Channel) + "/" + info.Name + "/" + info.Serial
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		days := info.NotAfter.Sub(now).Hours() / 24
+		ch <- prometheus.MustNewConstMetric(c.daysToExpiry, prometheus.GaugeValue, days, string(info.Channel), info.Name, info.Serial)
+		ch <- prometheus.MustNewConstMetric(c.notAfter, prometheus.GaugeValue, float64(info.NotAfter.Unix()), string(info.Channel), info.Name, info.Serial)
+	}
+}
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	payTime := time.Now().Add(-2 * time.Second)
+	cases := []struct {
+		name      string
+		call      *payment.Call
+		result    interface{} // 下游处理器写入 call.Result 的结果
+		err       error
+		scene     string
+		code      string
+		notifyLag int // 期望的通知延迟序列数，0 表示未记录
+	}{
+		{
+			name:  "pay success",
+			call:  &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpPay, Request: &payment.UnifiedPayRequest{Scene: payment.SceneNative}},
+			scene: "native",
+			code:  payment.Success.Code,
+		},
+		{
+			name: "query system error",
+			call: &payment.Call{Channel: payment.ChannelAlipay, Operation: payment.OpQuery, Request: &payment.QueryRequest{}},
+			err:  fmt.Errorf("query: %w", payment.ErrSystemError),
+			code: payment.ChannelSystemError.Code,
+		},
+		{
+			name:  "pay rate limited",
+			call:  &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpPay, Request: &payment.UnifiedPayRequest{Scene: payment.SceneH5}},
+			err:   &payment.RateLimitError{Scope: "channel:wechat", RetryAfter: time.Second},
+			scene: "h5",
+			code:  payment.TooManyRequests.Code,
+		},
+		{
+			name:      "notify success",
+			call:      &payment.Call{Channel: payment.ChannelAlipay, Operation: payment.OpNotify},
+			result:    &payment.NotifyResult{Success: true, PayTime: &payTime},
+			code:      payment.Success.Code,
+			notifyLag: 1,
+		},
+		{
+			name:   "notify verify failed",
+			call:   &payment.Call{Channel: payment.ChannelAlipay, Operation: payment.OpNotify},
+			result: &payment.NotifyResult{PayTime: &payTime},
+			err:    payment.ErrNotifyVerifyFailed,
+			code:   payment.NotifyVerifyFailed.Code,
+		},
+		{
+			name:   "notify without pay time",
+			call:   &payment.Call{Channel: payment.ChannelAlipay, Operation: payment.OpNotify},
+			result: &payment.NotifyResult{Success: true},
+			code:   payment.Success.Code,
+		},
+		{
+			name:   "query with notify result",
+			call:   &payment.Call{Channel: payment.ChannelAlipay, Operation: payment.OpQuery},
+			result: &payment.NotifyResult{Success: true, PayTime: &payTime},
+			code:   payment.Success.Code,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New()
+			channel, operation := string(tc.call.Channel), string(tc.call.Operation)
+			inFlight := m.inFlight.WithLabelValues(channel, operation)
+
+			handler := m.Middleware()(func(ctx context.Context, call *payment.Call) error {
+				if got := testutil.ToFloat64(inFlight); got != 1 {
+					t.Errorf("in flight during call = %v, want 1", got)
+				}
+				call.Result = tc.result
+				return tc.err
+			})
+			if err := handler(context.Background(), tc.call); !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+
+			if got := testutil.ToFloat64(inFlight); got != 0 {
+				t.Errorf("in flight after call = %v, want 0", got)
+			}
+			if got := testutil.ToFloat64(m.requests.WithLabelValues(channel, tc.scene, operation, tc.code)); got != 1 {
+				t.Errorf("requests{%s,%s,%s,%s} = %v, want 1", channel, tc.scene, operation, tc.code, got)
+			}
+			if got := testutil.CollectAndCount(m.requests); got != 1 {
+				t.Errorf("requests series = %d, want 1", got)
+			}
+			if got := testutil.CollectAndCount(m.duration); got != 1 {
+				t.Errorf("duration series = %d, want 1", got)
+			}
+			if got := testutil.CollectAndCount(m.notifyLag); got != tc.notifyLag {
+				t.Errorf("notify lag series = %d, want %d", got, tc.notifyLag)
+			}
+		})
+	}
+}
+
+func TestRateLimitCollector(t *testing.T) {
+	limit := configs.LimitConfig{Rate: 0.001, Burst: 1}
+	inbound := ratelimit.NewInbound(configs.RateLimitConfig{
+		PerKey:    limit,
+		Endpoints: map[string]configs.LimitConfig{"pay": limit},
+	})
+	outbound := ratelimit.NewOutbound(configs.RateLimitConfig{
+		Channels:    map[string]configs.LimitConfig{"wechat": limit},
+		PerMerchant: limit,
+	})
+
+	// 第二次下单被单接口限额拒绝，查询接口无单接口限额，被总限额拒绝
+	for _, endpoint := range []string{"pay", "pay", "query"} {
+		_ = inbound.Allow("app", endpoint)
+	}
+	// 未鉴权的调用只受渠道限额约束
+	call := outbound.Middleware()(func(ctx context.Context, call *payment.Call) error { return nil })
+	for i := 0; i < 3; i++ {
+		_ = call(context.Background(), &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpPay})
+	}
+
+	cases := []struct {
+		name     string
+		inbound  *ratelimit.Inbound
+		outbound *ratelimit.Outbound
+		want     string
+	}{
+		{"both", inbound, outbound, `
+payment_gateway_rate_limited_total{direction="inbound",scope="endpoint:pay"} 1
+payment_gateway_rate_limited_total{direction="inbound",scope="per_key"} 1
+payment_gateway_rate_limited_total{direction="outbound",scope="channel:wechat"} 2
+payment_gateway_rate_limited_total{direction="outbound",scope="per_merchant"} 0
+`},
+		{"inbound only", inbound, nil, `
+payment_gateway_rate_limited_total{direction="inbound",scope="endpoint:pay"} 1
+payment_gateway_rate_limited_total{direction="inbound",scope="per_key"} 1
+`},
+		{"none", nil, nil, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := ""
+			if tc.want != "" {
+				want = `
+# HELP payment_gateway_rate_limited_total Requests rejected by rate limiting, by direction and limit scope.
+# TYPE payment_gateway_rate_limited_total counter` + tc.want
+			}
+			if err := testutil.CollectAndCompare(NewRateLimitCollector(tc.inbound, tc.outbound), strings.NewReader(want)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// certAdapter 报告预设证书的微信适配器
+type certAdapter struct {
+	certs []payment.CertInfo
+}
+
+func (a *certAdapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) GetChannel() payment.ChannelType {
+	return payment.ChannelWechat
+}
+
+func (a *certAdapter) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) Query(ctx context.Context, req *payment.QueryRequest) (*payment.QueryResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *certAdapter) Close(ctx context.Context, req *payment.CloseRequest) error {
+	return errors.New("not implemented")
+}
+
+func (a *certAdapter) CertInfos(ctx context.Context) []payment.CertInfo {
+	return a.certs
+}
+
+func TestCertCollector(t *testing.T) {
+	notAfter := time.Now().Add(30*24*time.Hour + time.Hour).Truncate(time.Second)
+	platform := payment.CertInfo{Channel: payment.ChannelWechat, Name: "platform", Serial: "0A", NotAfter: notAfter}
+	merchant := payment.CertInfo{Channel: payment.ChannelWechat, Name: "merchant", Serial: "0B", NotAfter: notAfter}
+	// 同一证书可能由多个来源报告，如平台证书同时用于验签和加密
+	collector := NewCertCollector(payment.NewPaymentGateway(&certAdapter{certs: []payment.CertInfo{platform, merchant, platform}}))
+
+	want := fmt.Sprintf(`
+# HELP payment_gateway_cert_not_after_timestamp_seconds Expiry time of the loaded channel certificate as a Unix timestamp.
+# TYPE payment_gateway_cert_not_after_timestamp_seconds gauge
+payment_gateway_cert_not_after_timestamp_seconds{channel="wechat",name="merchant",serial="0B"} %[1]d
+payment_gateway_cert_not_after_timestamp_seconds{channel="wechat",name="platform",serial="0A"} %[1]d
+`, notAfter.Unix())
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want), "payment_gateway_cert_not_after_timestamp_seconds"); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(collector, "payment_gateway_cert_days_to_expiry"); got != 2 {
+		t.Fatalf("days to expiry series = %d, want 2", got)
+	}
+
+	// 剩余天数按采集时刻计算，允许测试执行耗时带来的误差
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "payment_gateway_cert_days_to_expiry" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if days := metric.GetGauge().GetValue(); math.Abs(days-(30+1.0/24)) > 0.01 {
+				t.Errorf("days to expiry = %v, want about 30.04", days)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package order
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// compactThreshold 订单文件行数超过该值且大部分为旧版本时重写文件
+const compactThreshold = 1024
+
+// FileStore 基于本地文件的订单存储，查询和订阅与 MemoryStore 相同
+// 每次保存在文件末尾追加一行订单的完整 JSON，打开时重放文件，同一订单以最后一行为准，重启后订单和 event_url 不丢失
+// 订单全部加载到内存，适用于单实例部署；多实例部署应使用数据库存储
+type FileStore struct {
+	*MemoryStore
+	path  string
+	file  *os.File
+	lines int
+}
+
+// OpenFileStore 打开订单文件，文件不存在时创建
+func OpenFileStore(path string) (*FileStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("create order store directory: %w", err)
+		}
+	}
+
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(nil); err != nil {
+		return nil, err
+	}
+	s.MemoryStore.persist = s.append
+	return s, nil
+}
+
+// load 重放订单文件，末尾不完整的记录（如写入时进程退出）会被忽略
+func (s *FileStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open order store: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var o Order
+			if json.Unmarshal(line, &o) == nil && o.OutTradeNo != "" {
+				s.orders[o.OutTradeNo] = &o
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read order store: %w", err)
+		}
+	}
+}
+
+// compact 每个订单只保留最新版本重写订单文件，先写临时文件再替换
+// pending 为正在保存、尚未写入内存的订单，替换内存中的旧版本
+func (s *FileStore) compact(pending *Order) error {
+	tmp := s.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("create order store: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	lines := 0
+	write := func(o *Order) error {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("marshal order %s: %w", o.OutTradeNo, err)
+		}
+		writer.Write(append(data, '\n'))
+		lines++
+		return nil
+	}
+	for outTradeNo, o := range s.orders {
+		if pending != nil && outTradeNo == pending.OutTradeNo {
+			continue
+		}
+		if err := write(o); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if pending != nil {
+		if err := write(pending); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("write order store: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("sync order store: %w", err)
+	}
+	file.Close()
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replace order store: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("open order store: %w", err)
+	}
+	s.lines = lines
+	return nil
+}
+
+// append 追加订单的最新版本，由 MemoryStore 在持有写锁时调用，写入失败时订单不保存
+func (s *FileStore) append(o *Order) error {
+	if s.lines > compactThreshold && s.lines > 4*len(s.orders) {
+		return s.compact(o)
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("marshal order %s: %w", o.OutTradeNo, err)
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write order store: %w", err)
+	}
+	s.lines++
+	return nil
+}
+
+// Close 同步并关闭订单文件
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("sync order store: %w", err)
+	}
+	return s.file.Close()
+}
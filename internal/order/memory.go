@@ -0,0 +1,159 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore 内存订单存储，进程重启后数据丢失，适用于单实例部署和测试
+type MemoryStore struct {
+	mu       sync.RWMutex
+	orders   map[string]*Order
+	watchers map[string]map[chan *Order]struct{}
+	persist  func(order *Order) error // 保存前调用（持有写锁），返回错误时不保存，见 FileStore
+}
+
+// NewMemoryStore 创建内存订单存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders:   make(map[string]*Order),
+		watchers: make(map[string]map[chan *Order]struct{}),
+	}
+}
+
+// Create 保存新订单
+func (s *MemoryStore) Create(ctx context.Context, order *Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orders[order.OutTradeNo]; exists {
+		return fmt.Errorf("%w: %s", ErrOrderExists, order.OutTradeNo)
+	}
+
+	now := time.Now()
+	stored := order.clone()
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now
+	}
+	stored.UpdatedAt = now
+	if s.persist != nil {
+		if err := s.persist(stored); err != nil {
+			return err
+		}
+	}
+	s.orders[order.OutTradeNo] = stored
+	s.publish(stored)
+	return nil
+}
+
+// Get 获取订单副本
+func (s *MemoryStore) Get(ctx context.Context, outTradeNo string) (*Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, exists := s.orders[outTradeNo]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, outTradeNo)
+	}
+
+	return order.clone(), nil
+}
+
+// Update 更新订单，fn 返回错误时不保存修改
+func (s *MemoryStore) Update(ctx context.Context, outTradeNo string, fn func(order *Order) error) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, exists := s.orders[outTradeNo]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, outTradeNo)
+	}
+
+	updated := order.clone()
+	if err := fn(updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+	if s.persist != nil {
+		if err := s.persist(updated); err != nil {
+			return nil, err
+		}
+	}
+	s.orders[outTradeNo] = updated
+	s.publish(updated)
+
+	return updated.clone(), nil
+}
+
+// Search 遍历全部订单搜索，订单量大时应使用数据库存储
+func (s *MemoryStore) Search(ctx context.Context, filter Filter) ([]*Order, int, error) {
+	s.mu.RLock()
+	var matched []*Order
+	for _, o := range s.orders {
+		if filter.Match(o) {
+			matched = append(matched, o)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].OutTradeNo < matched[j].OutTradeNo
+	})
+
+	total := len(matched)
+	start := min(filter.Offset, total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+	page := make([]*Order, 0, end-start)
+	for _, o := range matched[start:end] {
+		page = append(page, o.clone())
+	}
+	return page, total, nil
+}
+
+// Watch 实现 Watcher
+func (s *MemoryStore) Watch(outTradeNo string) (<-chan *Order, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan *Order, 1)
+	if s.watchers[outTradeNo] == nil {
+		s.watchers[outTradeNo] = make(map[chan *Order]struct{})
+	}
+	s.watchers[outTradeNo][ch] = struct{}{}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			delete(s.watchers[outTradeNo], ch)
+			if len(s.watchers[outTradeNo]) == 0 {
+				delete(s.watchers, outTradeNo)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// publish 向订阅方推送订单副本，调用方持有写锁
+// 通道中还有未消费的副本时替换为最新的，订单副本包含完整时间线，丢弃旧副本不会丢失事件
+func (s *MemoryStore) publish(order *Order) {
+	for ch := range s.watchers[order.OutTradeNo] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- order.clone()
+	}
+}
@@ -0,0 +1,175 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found")
+	ErrOrderExists   = errors.New("order already exists")
+)
+
+// Route 订单的渠道选择结果
+type Route struct {
+	Auto   bool   `json:"auto"`             // 是否由路由引擎自动选择
+	Rule   string `json:"rule,omitempty"`   // 命中的规则名
+	Reason string `json:"reason,omitempty"` // 选择原因
+}
+
+// Order 网关侧的订单记录
+type Order struct {
+	OutTradeNo  string              `json:"out_trade_no"`
+	Channel     payment.ChannelType `json:"channel"`
+	OrderID     string              `json:"order_id,omitempty"` // 渠道订单号
+	MerchantID  string              `json:"merchant_id,omitempty"`
+	Scene       payment.PayScene    `json:"scene"`
+	TotalAmount float64             `json:"total_amount"`
+	Subject     string              `json:"subject"`
+	EventURL    string              `json:"event_url,omitempty"` // 商户接收事件推送的地址
+	Status      payment.TradeStatus `json:"status"`
+	Route       *Route              `json:"route,omitempty"`
+	PaidAt      *time.Time          `json:"paid_at,omitempty"`
+	Timeline    []Event             `json:"timeline,omitempty"` // 按时间顺序的订单事件
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// 订单事件类型
+const (
+	EventCreated  = "created"  // 下单成功，重复下单时同样记录
+	EventNotified = "notified" // 收到渠道异步通知
+	EventSynced   = "synced"   // 主动查询渠道同步状态
+	EventRefund   = "refund"   // 发起退款
+	EventClosed   = "closed"   // 关闭订单
+	EventWebhook  = "webhook"  // 向商户推送事件
+)
+
+// Event 订单时间线中的一个事件
+type Event struct {
+	Time     time.Time              `json:"time"`
+	Type     string                 `json:"type"`
+	From     payment.TradeStatus    `json:"from,omitempty"`     // 状态变化前的状态
+	To       payment.TradeStatus    `json:"to,omitempty"`       // 状态变化后的状态，状态未变化时为空
+	Operator string                 `json:"operator,omitempty"` // 触发方: 渠道名、商户 app key 或 system
+	Message  string                 `json:"message,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// CanTransition 订单状态能否从 from 变为 to，状态只能向前推进
+// 待支付和支付中可变为任意状态；支付失败后只能变为成功、关闭或撤销；支付成功后只能退款；退款、关闭、撤销为终态
+// 渠道通知和查询结果可能乱序到达，迟到的 NOTPAY 等旧状态不能覆盖 SUCCESS
+func CanTransition(from, to payment.TradeStatus) bool {
+	switch from {
+	case "", payment.TradeStatusNotPay, payment.TradeStatusUserPaying:
+		return true
+	case payment.TradeStatusPayError:
+		return to == payment.TradeStatusSuccess || to == payment.TradeStatusClosed || to == payment.TradeStatusRevoked
+	case payment.TradeStatusSuccess:
+		return to == payment.TradeStatusRefund
+	}
+	return false
+}
+
+// Record 追加事件，event.To 与当前状态不同时更新订单状态并记录原状态，返回订单状态是否变化
+// 与当前状态相同（如渠道重试的重复通知）或不允许的状态变化（见 CanTransition）只记录事件，返回 false
+func (o *Order) Record(event Event) bool {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.To == o.Status {
+		event.To = ""
+	}
+	if event.To != "" && !CanTransition(o.Status, event.To) {
+		if event.Data == nil {
+			event.Data = make(map[string]interface{})
+		}
+		event.Data["ignored_status"] = event.To
+		event.Message = fmt.Sprintf("status %s ignored: order is %s", event.To, o.Status)
+		event.To = ""
+	}
+	changed := event.To != ""
+	if changed {
+		event.From = o.Status
+		o.Status = event.To
+		if event.To == payment.TradeStatusSuccess && o.PaidAt == nil {
+			paidAt := event.Time
+			o.PaidAt = &paidAt
+		}
+	}
+	o.Timeline = append(o.Timeline, event)
+	return changed
+}
+
+// clone 返回订单的深拷贝，时间线在副本上追加不影响其他读者
+func (o *Order) clone() *Order {
+	copied := *o
+	if o.Route != nil {
+		route := *o.Route
+		copied.Route = &route
+	}
+	if o.PaidAt != nil {
+		paidAt := *o.PaidAt
+		copied.PaidAt = &paidAt
+	}
+	copied.Timeline = append([]Event(nil), o.Timeline...)
+	return &copied
+}
+
+// Filter 订单搜索条件，空值不参与过滤
+type Filter struct {
+	OutTradeNo string
+	OrderID    string // 渠道订单号
+	Channel    payment.ChannelType
+	Status     payment.TradeStatus
+	MerchantID string
+	From       time.Time // 下单时间下限（含）
+	To         time.Time // 下单时间上限（不含）
+	Offset     int
+	Limit      int
+}
+
+// Match 订单是否满足搜索条件
+func (f *Filter) Match(o *Order) bool {
+	switch {
+	case f.OutTradeNo != "" && o.OutTradeNo != f.OutTradeNo,
+		f.OrderID != "" && o.OrderID != f.OrderID,
+		f.Channel != "" && o.Channel != f.Channel,
+		f.Status != "" && o.Status != f.Status,
+		f.MerchantID != "" && o.MerchantID != f.MerchantID,
+		!f.From.IsZero() && o.CreatedAt.Before(f.From),
+		!f.To.IsZero() && !o.CreatedAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// Store 订单存储
+type Store interface {
+	// Create 保存新订单，商户订单号已存在时返回 ErrOrderExists
+	Create(ctx context.Context, order *Order) error
+	// Get 按商户订单号获取订单
+	Get(ctx context.Context, outTradeNo string) (*Order, error)
+	// Update 更新订单，fn 在存储锁内执行
+	Update(ctx context.Context, outTradeNo string, fn func(order *Order) error) (*Order, error)
+	// Search 按条件搜索订单，按下单时间倒序返回一页结果和满足条件的总数
+	Search(ctx context.Context, filter Filter) ([]*Order, int, error)
+}
+
+// Watcher 订单变更订阅（可选接口），由能够推送变更的存储实现
+type Watcher interface {
+	// Watch 订阅订单变更，每次保存后推送订单副本，消费不及时只保留最新的副本；调用 cancel 后通道关闭
+	Watch(outTradeNo string) (updates <-chan *Order, cancel func())
+}
+
+// RecordEvent 向订单时间线追加事件
+func RecordEvent(ctx context.Context, store Store, outTradeNo string, event Event) (*Order, error) {
+	return store.Update(ctx, outTradeNo, func(o *Order) error {
+		o.Record(event)
+		return nil
+	})
+}
@@ -0,0 +1,289 @@
+package order
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to payment.TradeStatus
+		allowed  bool
+	}{
+		{payment.TradeStatusNotPay, payment.TradeStatusSuccess, true},
+		{payment.TradeStatusNotPay, payment.TradeStatusClosed, true},
+		{payment.TradeStatusNotPay, payment.TradeStatusUserPaying, true},
+		{payment.TradeStatusUserPaying, payment.TradeStatusNotPay, true},
+		{payment.TradeStatusUserPaying, payment.TradeStatusPayError, true},
+		{payment.TradeStatusPayError, payment.TradeStatusSuccess, true},
+		{payment.TradeStatusPayError, payment.TradeStatusNotPay, false},
+		{payment.TradeStatusSuccess, payment.TradeStatusRefund, true},
+		{payment.TradeStatusSuccess, payment.TradeStatusNotPay, false},
+		{payment.TradeStatusSuccess, payment.TradeStatusUserPaying, false},
+		{payment.TradeStatusSuccess, payment.TradeStatusClosed, false},
+		{payment.TradeStatusRefund, payment.TradeStatusSuccess, false},
+		{payment.TradeStatusClosed, payment.TradeStatusSuccess, false},
+		{payment.TradeStatusRevoked, payment.TradeStatusNotPay, false},
+	}
+	for _, tc := range cases {
+		if got := CanTransition(tc.from, tc.to); got != tc.allowed {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.allowed)
+		}
+	}
+}
+
+// TestRecord 状态只能向前推进，重复和迟到的状态只记录在时间线中
+func TestRecord(t *testing.T) {
+	paidAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := &Order{OutTradeNo: "ORDER_1", Status: payment.TradeStatusNotPay}
+
+	steps := []struct {
+		event   Event
+		changed bool
+		ignored bool // 不允许的状态变化，记录 ignored_status
+		status  payment.TradeStatus
+		from    payment.TradeStatus // 时间线中记录的原状态
+		to      payment.TradeStatus // 时间线中记录的新状态
+	}{
+		{Event{Type: EventCreated}, false, false, payment.TradeStatusNotPay, "", ""},
+		{Event{Type: EventNotified, To: payment.TradeStatusSuccess, Time: paidAt}, true, false, payment.TradeStatusSuccess, payment.TradeStatusNotPay, payment.TradeStatusSuccess},
+		{Event{Type: EventNotified, To: payment.TradeStatusSuccess}, false, false, payment.TradeStatusSuccess, "", ""},
+		{Event{Type: EventSynced, To: payment.TradeStatusNotPay}, false, true, payment.TradeStatusSuccess, "", ""},
+		{Event{Type: EventNotified, To: payment.TradeStatusRefund}, true, false, payment.TradeStatusRefund, payment.TradeStatusSuccess, payment.TradeStatusRefund},
+		{Event{Type: EventNotified, To: payment.TradeStatusSuccess}, false, true, payment.TradeStatusRefund, "", ""},
+	}
+	for i, step := range steps {
+		if changed := o.Record(step.event); changed != step.changed {
+			t.Fatalf("step %d: changed = %v, want %v", i, changed, step.changed)
+		}
+		last := o.Timeline[len(o.Timeline)-1]
+		if o.Status != step.status || last.From != step.from || last.To != step.to {
+			t.Fatalf("step %d: status = %s, event %s -> %s, want %s, %s -> %s", i, o.Status, last.From, last.To, step.status, step.from, step.to)
+		}
+		if ignored := last.Data["ignored_status"] != nil; ignored != step.ignored || (ignored && last.Message == "") {
+			t.Fatalf("step %d: ignored event = %+v", i, last)
+		}
+	}
+	if len(o.Timeline) != len(steps) {
+		t.Fatalf("timeline has %d events, want %d", len(o.Timeline), len(steps))
+	}
+	if o.PaidAt == nil || !o.PaidAt.Equal(paidAt) {
+		t.Fatalf("paid_at = %v, want %v", o.PaidAt, paidAt)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, o := range []*Order{
+		{OutTradeNo: "ORDER_1", Channel: payment.ChannelWechat, MerchantID: "m1", Status: payment.TradeStatusNotPay},
+		{OutTradeNo: "ORDER_2", Channel: payment.ChannelAlipay, MerchantID: "m1", Status: payment.TradeStatusSuccess},
+		{OutTradeNo: "ORDER_3", Channel: payment.ChannelWechat, MerchantID: "m2", Status: payment.TradeStatusNotPay},
+		{OutTradeNo: "ORDER_4", Channel: payment.ChannelWechat, MerchantID: "m1", Status: payment.TradeStatusNotPay},
+	} {
+		o.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		if err := store.Create(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Create(ctx, &Order{OutTradeNo: "ORDER_1"}); !errors.Is(err, ErrOrderExists) {
+		t.Fatalf("create duplicate: err = %v, want %v", err, ErrOrderExists)
+	}
+	if _, err := store.Get(ctx, "ORDER_X"); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("get unknown: err = %v, want %v", err, ErrOrderNotFound)
+	}
+	if _, err := store.Update(ctx, "ORDER_X", func(*Order) error { return nil }); !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("update unknown: err = %v, want %v", err, ErrOrderNotFound)
+	}
+
+	// fn 返回错误时不保存修改
+	failed := errors.New("rejected")
+	_, err := store.Update(ctx, "ORDER_1", func(o *Order) error {
+		o.Record(Event{Type: EventNotified, To: payment.TradeStatusSuccess})
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("update: err = %v, want %v", err, failed)
+	}
+	// 返回的副本与存储互不影响
+	got, _ := store.Get(ctx, "ORDER_1")
+	got.Record(Event{Type: EventClosed, To: payment.TradeStatusClosed})
+	if stored, _ := store.Get(ctx, "ORDER_1"); stored.Status != payment.TradeStatusNotPay || len(stored.Timeline) != 0 {
+		t.Fatalf("stored order modified: %+v", stored)
+	}
+
+	cases := []struct {
+		name   string
+		filter Filter
+		orders []string
+		total  int
+	}{
+		{"all newest first", Filter{}, []string{"ORDER_4", "ORDER_3", "ORDER_2", "ORDER_1"}, 4},
+		{"merchant and channel", Filter{MerchantID: "m1", Channel: payment.ChannelWechat}, []string{"ORDER_4", "ORDER_1"}, 2},
+		{"status", Filter{Status: payment.TradeStatusSuccess}, []string{"ORDER_2"}, 1},
+		{"time range", Filter{From: base.Add(time.Hour), To: base.Add(3 * time.Hour)}, []string{"ORDER_3", "ORDER_2"}, 2},
+		{"page", Filter{Offset: 1, Limit: 2}, []string{"ORDER_3", "ORDER_2"}, 4},
+		{"offset past end", Filter{Offset: 10, Limit: 2}, nil, 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, total, err := store.Search(ctx, tc.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, o := range page {
+				got = append(got, o.OutTradeNo)
+			}
+			if total != tc.total || len(got) != len(tc.orders) {
+				t.Fatalf("orders = %v (total %d), want %v (total %d)", got, total, tc.orders, tc.total)
+			}
+			for i := range got {
+				if got[i] != tc.orders[i] {
+					t.Fatalf("orders = %v, want %v", got, tc.orders)
+				}
+			}
+		})
+	}
+}
+
+// TestMemoryStoreWatch 订阅方只保留最新的订单副本，取消后通道关闭
+func TestMemoryStoreWatch(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	if err := store.Create(ctx, &Order{OutTradeNo: "ORDER_1", Status: payment.TradeStatusNotPay}); err != nil {
+		t.Fatal(err)
+	}
+
+	updates, cancel := store.Watch("ORDER_1")
+	for _, status := range []payment.TradeStatus{payment.TradeStatusUserPaying, payment.TradeStatusSuccess} {
+		if _, err := RecordEvent(ctx, store, "ORDER_1", Event{Type: EventNotified, To: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if o := <-updates; o.Status != payment.TradeStatusSuccess || len(o.Timeline) != 2 {
+		t.Fatalf("update = %+v, want latest order", o)
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-updates; ok {
+		t.Fatal("updates not closed after cancel")
+	}
+	if _, err := RecordEvent(ctx, store, "ORDER_1", Event{Type: EventSynced}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileStore 重启后订单、推送地址和时间线从文件恢复，末尾不完整的记录被忽略
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, no := range []string{"ORDER_1", "ORDER_2"} {
+		o := &Order{OutTradeNo: no, Channel: payment.ChannelWechat, MerchantID: "m1", EventURL: "https://merchant.example.com/events", Status: payment.TradeStatusNotPay}
+		if err := store.Create(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := RecordEvent(ctx, store, "ORDER_1", Event{Type: EventNotified, To: payment.TradeStatusSuccess}); err != nil {
+		t.Fatal(err)
+	}
+	// fn 返回错误时不写入文件
+	if _, err := store.Update(ctx, "ORDER_2", func(o *Order) error {
+		o.Record(Event{Type: EventClosed, To: payment.TradeStatusClosed})
+		return errors.New("rejected")
+	}); err == nil {
+		t.Fatal("update: want error")
+	}
+	store.Close()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"out_trade_no":"ORDER_1","status":"REFUND"`)
+	file.Close()
+
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	cases := []struct {
+		outTradeNo string
+		status     payment.TradeStatus
+		events     int
+	}{
+		{"ORDER_1", payment.TradeStatusSuccess, 1},
+		{"ORDER_2", payment.TradeStatusNotPay, 0},
+	}
+	for _, tc := range cases {
+		o, err := store.Get(ctx, tc.outTradeNo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != tc.status || len(o.Timeline) != tc.events || o.EventURL == "" || o.MerchantID != "m1" {
+			t.Fatalf("%s = %+v, want status %s with %d events", tc.outTradeNo, o, tc.status, tc.events)
+		}
+	}
+	if err := store.Create(ctx, &Order{OutTradeNo: "ORDER_1"}); !errors.Is(err, ErrOrderExists) {
+		t.Fatalf("create duplicate: err = %v, want %v", err, ErrOrderExists)
+	}
+}
+
+// TestFileStoreCompact 订单文件中旧版本过多时重写文件，每个订单只保留最新版本
+func TestFileStoreCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.jsonl")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Create(ctx, &Order{OutTradeNo: "ORDER_1", Status: payment.TradeStatusNotPay}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < compactThreshold+10; i++ {
+		if _, err := RecordEvent(ctx, store, "ORDER_1", Event{Type: EventSynced}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines > compactThreshold {
+		t.Fatalf("order file has %d lines after %d updates, want compacted", lines, compactThreshold+10)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if o, _ := reopened.Get(ctx, "ORDER_1"); o == nil || len(o.Timeline) != compactThreshold+10 {
+		t.Fatalf("reopened order = %+v", o)
+	}
+}
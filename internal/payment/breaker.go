@@ -0,0 +1,280 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// breakerBuckets 统计窗口划分的桶数
+const breakerBuckets = 10
+
+// BreakerState 熔断器状态
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // 正常放行
+	BreakerOpen     BreakerState = "open"      // 熔断，直接拒绝
+	BreakerHalfOpen BreakerState = "half_open" // 放行少量探测请求
+)
+
+// BreakerStatus 渠道熔断器状态
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Requests  int          `json:"requests"` // 窗口内请求数
+	ErrorRate float64      `json:"error_rate"`
+	SlowRate  float64      `json:"slow_rate"`
+	OpenedAt  *time.Time   `json:"opened_at,omitempty"`
+}
+
+// BreakerOptions 熔断参数
+type BreakerOptions struct {
+	Window           time.Duration // 错误率统计窗口
+	MinRequests      int           // 窗口内请求数达到该值才判断是否熔断
+	ErrorRate        float64       // 错误率阈值
+	SlowCall         time.Duration // 慢调用耗时阈值
+	SlowCallRate     float64       // 慢调用比例阈值，0 表示不按耗时熔断
+	OpenTimeout      time.Duration // 熔断持续时间，之后进入半开状态
+	HalfOpenRequests int           // 半开状态放行的探测请求数
+}
+
+// CircuitBreaker 按渠道熔断
+// 窗口内错误率或慢调用比例超过阈值时熔断，直接返回 ErrCircuitOpen；
+// 经过 open_timeout 进入半开状态，放行少量探测请求，全部成功后恢复，任一失败则重新熔断
+// 调用方取消的调用不反映渠道状态，既不计为成功也不计为失败，半开状态下释放探测名额
+// 适配器重建不重置熔断状态，证书轮换不能绕过熔断
+type CircuitBreaker struct {
+	cfg        BreakerOptions
+	bucketSize time.Duration
+	now        func() time.Time
+
+	mu       sync.Mutex
+	channels map[ChannelType]*channelBreaker
+}
+
+// channelBreaker 单个渠道的熔断状态
+// generation 每次状态切换时递增，切换前放行的调用结果不计入新状态
+type channelBreaker struct {
+	state            BreakerState
+	generation       uint64
+	openedAt         time.Time
+	buckets          [breakerBuckets]breakerBucket
+	halfOpenInFlight int
+	halfOpenSuccess  int
+}
+
+// breakerBucket 统计桶
+type breakerBucket struct {
+	start    time.Time
+	total    int
+	failures int
+	slow     int
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(cfg BreakerOptions) *CircuitBreaker {
+	bucketSize := cfg.Window / breakerBuckets
+	if bucketSize <= 0 {
+		bucketSize = time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+
+	return &CircuitBreaker{
+		cfg:        cfg,
+		bucketSize: bucketSize,
+		now:        time.Now,
+		channels:   make(map[ChannelType]*channelBreaker),
+	}
+}
+
+// SetCircuitBreaker 启用熔断，熔断器作为中间件加入调用链，状态在渠道状态中展示
+func (g *PaymentGateway) SetCircuitBreaker(cb *CircuitBreaker) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.breaker = cb
+	g.middlewares = append(g.middlewares, cb.Middleware())
+}
+
+// Middleware 熔断中间件，只作用于调用渠道接口的操作
+func (cb *CircuitBreaker) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if !call.Operation.Outbound() {
+				return next(ctx, call)
+			}
+
+			generation, err := cb.allow(call.Channel)
+			if err != nil {
+				return err
+			}
+
+			start := cb.now()
+			err = next(ctx, call)
+			if errors.Is(err, context.Canceled) {
+				cb.release(call.Channel, generation)
+				return err
+			}
+			cb.record(call.Channel, generation, cb.now().Sub(start), IsChannelFailure(err))
+			return err
+		}
+	}
+}
+
+// Status 返回渠道熔断器状态
+func (cb *CircuitBreaker) Status(channel ChannelType) BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	b := cb.get(channel)
+	cb.advance(b, now)
+
+	total, failures, slow := cb.counts(b, now)
+	status := BreakerStatus{
+		State:    b.state,
+		Requests: total,
+	}
+	if total > 0 {
+		status.ErrorRate = float64(failures) / float64(total)
+		status.SlowRate = float64(slow) / float64(total)
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow 判断是否放行请求，返回放行时的状态代数，调用结束后传给 record
+func (cb *CircuitBreaker) allow(channel ChannelType) (uint64, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b := cb.get(channel)
+	cb.advance(b, cb.now())
+
+	switch b.state {
+	case BreakerOpen:
+		return 0, fmt.Errorf("%w: %s", ErrCircuitOpen, channel)
+	case BreakerHalfOpen:
+		if b.halfOpenInFlight >= cb.cfg.HalfOpenRequests {
+			return 0, fmt.Errorf("%w: %s is probing", ErrCircuitOpen, channel)
+		}
+		b.halfOpenInFlight++
+	}
+	return b.generation, nil
+}
+
+// record 记录调用结果并判断是否熔断或恢复
+// 放行后状态已切换的调用不计入，例如熔断前放行、半开后才返回的调用不算作探测
+func (cb *CircuitBreaker) record(channel ChannelType, generation uint64, duration time.Duration, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := cb.now()
+	b := cb.get(channel)
+	cb.advance(b, now)
+	if b.generation != generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.halfOpenInFlight--
+		if failed {
+			cb.trip(b, now)
+			return
+		}
+		b.halfOpenSuccess++
+		if b.halfOpenSuccess >= cb.cfg.HalfOpenRequests {
+			*b = channelBreaker{state: BreakerClosed, generation: b.generation + 1}
+		}
+	case BreakerClosed:
+		bucket := cb.bucket(b, now)
+		bucket.total++
+		if failed {
+			bucket.failures++
+		}
+		if cb.cfg.SlowCall > 0 && duration >= cb.cfg.SlowCall {
+			bucket.slow++
+		}
+
+		total, failures, slow := cb.counts(b, now)
+		if total < cb.cfg.MinRequests {
+			return
+		}
+		if float64(failures)/float64(total) >= cb.cfg.ErrorRate ||
+			(cb.cfg.SlowCallRate > 0 && float64(slow)/float64(total) >= cb.cfg.SlowCallRate) {
+			cb.trip(b, now)
+		}
+	}
+}
+
+// release 放弃放行的调用，不记录结果，半开状态下归还探测名额
+func (cb *CircuitBreaker) release(channel ChannelType, generation uint64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b := cb.get(channel)
+	cb.advance(b, cb.now())
+	if b.generation == generation && b.state == BreakerHalfOpen {
+		b.halfOpenInFlight--
+	}
+}
+
+// trip 进入熔断状态
+func (cb *CircuitBreaker) trip(b *channelBreaker, now time.Time) {
+	*b = channelBreaker{
+		state:      BreakerOpen,
+		openedAt:   now,
+		generation: b.generation + 1,
+	}
+}
+
+// advance 熔断超时后转为半开
+func (cb *CircuitBreaker) advance(b *channelBreaker, now time.Time) {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= cb.cfg.OpenTimeout {
+		b.state = BreakerHalfOpen
+		b.generation++
+		b.halfOpenInFlight = 0
+		b.halfOpenSuccess = 0
+	}
+}
+
+// get 获取渠道熔断状态，调用方需持有锁
+func (cb *CircuitBreaker) get(channel ChannelType) *channelBreaker {
+	b, exists := cb.channels[channel]
+	if !exists {
+		b = &channelBreaker{state: BreakerClosed}
+		cb.channels[channel] = b
+	}
+	return b
+}
+
+// bucket 返回当前时间所在的统计桶，过期的桶会被清空
+func (cb *CircuitBreaker) bucket(b *channelBreaker, now time.Time) *breakerBucket {
+	start := now.Truncate(cb.bucketSize)
+	bucket := &b.buckets[(start.UnixNano()/int64(cb.bucketSize))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// counts 汇总窗口内的请求数、失败数和慢调用数
+func (cb *CircuitBreaker) counts(b *channelBreaker, now time.Time) (total, failures, slow int) {
+	cutoff := now.Add(-cb.bucketSize * breakerBuckets)
+	for _, bucket := range b.buckets {
+		if bucket.start.After(cutoff) {
+			total += bucket.total
+			failures += bucket.failures
+			slow += bucket.slow
+		}
+	}
+	return total, failures, slow
+}
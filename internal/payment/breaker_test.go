@@ -0,0 +1,262 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestBreaker 使用手动时钟的熔断器
+func newTestBreaker() (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cb := NewCircuitBreaker(BreakerOptions{
+		Window:           10 * time.Second,
+		MinRequests:      4,
+		ErrorRate:        0.5,
+		SlowCall:         time.Second,
+		SlowCallRate:     0.5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 2,
+	})
+	cb.now = clock.Now
+	return cb, clock
+}
+
+// call 放行并立即记录一次调用
+func call(t *testing.T, cb *CircuitBreaker, duration time.Duration, failed bool) error {
+	t.Helper()
+
+	generation, err := cb.allow(ChannelWechat)
+	if err != nil {
+		return err
+	}
+	cb.record(ChannelWechat, generation, duration, failed)
+	return nil
+}
+
+func TestBreakerTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		outcomes []bool // true 表示失败
+		slow     int    // 前 slow 次为慢调用
+		want     BreakerState
+	}{
+		{"below min requests", []bool{true, true, true}, 0, BreakerClosed},
+		{"error rate below threshold", []bool{true, false, false, false, false}, 0, BreakerClosed},
+		{"error rate reached", []bool{false, true, false, true}, 0, BreakerOpen},
+		{"slow calls", []bool{false, false, false, false}, 2, BreakerOpen},
+		{"fast successes", []bool{false, false, false, false}, 1, BreakerClosed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cb, _ := newTestBreaker()
+			for i, failed := range tc.outcomes {
+				duration := 10 * time.Millisecond
+				if i < tc.slow {
+					duration = 2 * time.Second
+				}
+				if err := call(t, cb, duration, failed); err != nil {
+					t.Fatalf("call %d rejected: %v", i, err)
+				}
+			}
+			if state := cb.Status(ChannelWechat).State; state != tc.want {
+				t.Fatalf("state = %s, want %s", state, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	cb, clock := newTestBreaker()
+	for i := 0; i < 3; i++ {
+		call(t, cb, 0, true)
+	}
+
+	// 窗口外的失败不再计入
+	clock.Advance(11 * time.Second)
+	call(t, cb, 0, true)
+	if status := cb.Status(ChannelWechat); status.State != BreakerClosed || status.Requests != 1 {
+		t.Fatalf("status = %+v, want closed with 1 request", status)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	cases := []struct {
+		name   string
+		probes []bool // 探测结果，true 表示失败
+		want   BreakerState
+	}{
+		{"all probes succeed", []bool{false, false}, BreakerClosed},
+		{"one probe fails", []bool{false, true}, BreakerOpen},
+		{"probe pending", []bool{false}, BreakerHalfOpen},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cb, clock := newTestBreaker()
+			for i := 0; i < 4; i++ {
+				call(t, cb, 0, true)
+			}
+			if err := call(t, cb, 0, false); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("err = %v while open, want %v", err, ErrCircuitOpen)
+			}
+
+			clock.Advance(30 * time.Second)
+			var generations []uint64
+			for range tc.probes {
+				generation, err := cb.allow(ChannelWechat)
+				if err != nil {
+					t.Fatalf("probe rejected: %v", err)
+				}
+				generations = append(generations, generation)
+			}
+			if len(tc.probes) == 2 {
+				if _, err := cb.allow(ChannelWechat); !errors.Is(err, ErrCircuitOpen) {
+					t.Fatalf("err = %v beyond half_open_requests, want %v", err, ErrCircuitOpen)
+				}
+			}
+
+			for i, failed := range tc.probes {
+				cb.record(ChannelWechat, generations[i], 0, failed)
+			}
+			if state := cb.Status(ChannelWechat).State; state != tc.want {
+				t.Fatalf("state = %s, want %s", state, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreakerIgnoresStaleCalls(t *testing.T) {
+	cb, clock := newTestBreaker()
+
+	// 熔断前放行的慢调用
+	stale, err := cb.allow(ChannelWechat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		call(t, cb, 0, true)
+	}
+	clock.Advance(30 * time.Second)
+
+	probe, err := cb.allow(ChannelWechat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 半开后才返回的旧调用既不算探测，也不释放探测名额
+	cb.record(ChannelWechat, stale, 0, false)
+	cb.record(ChannelWechat, stale, 0, true)
+	b := cb.channels[ChannelWechat]
+	if b.state != BreakerHalfOpen || b.halfOpenInFlight != 1 || b.halfOpenSuccess != 0 {
+		t.Fatalf("state = %s, in flight = %d, success = %d after stale calls", b.state, b.halfOpenInFlight, b.halfOpenSuccess)
+	}
+
+	cb.record(ChannelWechat, probe, 0, false)
+	if err := call(t, cb, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if state := cb.Status(ChannelWechat).State; state != BreakerClosed {
+		t.Fatalf("state = %s, want closed", state)
+	}
+	// 恢复后返回的探测结果同样不计入
+	cb.record(ChannelWechat, probe, 0, true)
+	if status := cb.Status(ChannelWechat); status.State != BreakerClosed || status.Requests != 0 {
+		t.Fatalf("status = %+v after stale probe", status)
+	}
+}
+
+// TestBreakerHalfOpenCanceled 调用方取消的探测不计入结果，归还探测名额
+func TestBreakerHalfOpenCanceled(t *testing.T) {
+	cb, clock := newTestBreaker()
+	adapter := &fakeAdapter{channel: ChannelWechat, err: NewChannelError(ChannelWechat, "SYSTEMERROR", "系统错误", ErrSystemError)}
+	gateway := NewPaymentGateway(adapter)
+	gateway.SetCircuitBreaker(cb)
+	ctx := context.Background()
+	query := func() error {
+		_, err := gateway.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+		return err
+	}
+
+	for i := 0; i < 4; i++ {
+		query()
+	}
+	clock.Advance(30 * time.Second)
+
+	// 超过 half_open_requests 次取消的探测，名额均被归还，熔断器保持半开
+	adapter.err = fmt.Errorf("wechat query: %w", context.Canceled)
+	for i := 0; i < 3; i++ {
+		if err := query(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("probe %d err = %v, want %v", i, err, context.Canceled)
+		}
+	}
+	if state := cb.Status(ChannelWechat).State; state != BreakerHalfOpen {
+		t.Fatalf("state = %s after canceled probes, want half_open", state)
+	}
+
+	adapter.err = nil
+	for i := 0; i < 2; i++ {
+		if err := query(); err != nil {
+			t.Fatalf("probe %d err = %v", i, err)
+		}
+	}
+	if state := cb.Status(ChannelWechat).State; state != BreakerClosed {
+		t.Fatalf("state = %s after successful probes, want closed", state)
+	}
+
+	// 关闭状态下取消的调用不计入窗口
+	adapter.err = fmt.Errorf("wechat query: %w", context.Canceled)
+	query()
+	if requests := cb.Status(ChannelWechat).Requests; requests != 0 {
+		t.Fatalf("requests = %d after canceled call, want 0", requests)
+	}
+}
+
+func TestBreakerMiddleware(t *testing.T) {
+	cb, _ := newTestBreaker()
+	adapter := &fakeAdapter{channel: ChannelWechat}
+	gateway := NewPaymentGateway(adapter)
+	gateway.SetCircuitBreaker(cb)
+	ctx := context.Background()
+
+	// 业务错误不计入熔断
+	adapter.err = NewChannelError(ChannelWechat, "ORDERPAID", "订单已支付", ErrOrderPaid)
+	for i := 0; i < 4; i++ {
+		gateway.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+	}
+	if state := cb.Status(ChannelWechat).State; state != BreakerClosed {
+		t.Fatalf("state = %s after business errors, want closed", state)
+	}
+
+	adapter.err = NewChannelError(ChannelWechat, "SYSTEMERROR", "系统错误", ErrSystemError)
+	for i := 0; i < 4; i++ {
+		gateway.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+	}
+	calls := adapter.calls
+	if _, err := gateway.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want %v", err, ErrCircuitOpen)
+	}
+	if adapter.calls != calls {
+		t.Fatal("adapter called while circuit open")
+	}
+
+	// 适配器重建不关闭熔断
+	gateway.SetAdapter(&fakeAdapter{channel: ChannelWechat})
+	if state := gateway.GetChannelStatus(ChannelWechat).Breaker.State; state != BreakerOpen {
+		t.Fatalf("state = %s after adapter reload, want open", state)
+	}
+}
@@ -0,0 +1,49 @@
+package payment
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// CertInfo 已加载证书的摘要信息
+type CertInfo struct {
+	Channel     ChannelType `json:"channel"`
+	Name        string      `json:"name"`        // 证书用途: merchant、platform、sign、root 等
+	Serial      string      `json:"serial"`      // 十六进制序列号
+	Fingerprint string      `json:"fingerprint"` // SHA-256 指纹
+	Subject     string      `json:"subject"`
+	Issuer      string      `json:"issuer"`
+	NotBefore   time.Time   `json:"not_before"`
+	NotAfter    time.Time   `json:"not_after"`
+}
+
+// CertInfoProvider 报告适配器当前加载的证书（可选接口）
+type CertInfoProvider interface {
+	CertInfos(ctx context.Context) []CertInfo
+}
+
+// NewCertInfo 从 X.509 证书生成证书信息
+func NewCertInfo(channel ChannelType, name string, cert *x509.Certificate) CertInfo {
+	sum := sha256.Sum256(cert.Raw)
+	return CertInfo{
+		Channel:     channel,
+		Name:        name,
+		Serial:      fmt.Sprintf("%X", cert.SerialNumber),
+		Fingerprint: strings.ToUpper(hex.EncodeToString(sum[:])),
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotBefore:   cert.NotBefore,
+		NotAfter:    cert.NotAfter,
+	}
+}
+
+// DaysToExpiry 证书剩余有效天数，不足一天按 0 计，已过期为负数
+func (c CertInfo) DaysToExpiry(now time.Time) int {
+	return int(math.Floor(c.NotAfter.Sub(now).Hours() / 24))
+}
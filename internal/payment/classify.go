@@ -0,0 +1,101 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ChannelError 渠道返回的错误
+// 保留渠道原始错误码和描述，Unwrap 返回归类后的标准错误，便于 errors.Is 判断
+type ChannelError struct {
+	Channel ChannelType
+	Code    string // 渠道原始错误码，如 ORDERPAID、ACQ.TRADE_NOT_EXIST
+	Message string // 渠道原始错误描述
+	Kind    error  // 归类后的标准错误，如 ErrOrderPaid、ErrSystemError
+}
+
+// Error 实现 error 接口
+func (e *ChannelError) Error() string {
+	return fmt.Sprintf("%s error %s: %s", e.Channel, e.Code, e.Message)
+}
+
+// Unwrap 返回归类后的标准错误
+func (e *ChannelError) Unwrap() error {
+	return e.Kind
+}
+
+// NewChannelError 创建渠道错误
+func NewChannelError(channel ChannelType, code, message string, kind error) *ChannelError {
+	return &ChannelError{
+		Channel: channel,
+		Code:    code,
+		Message: message,
+		Kind:    kind,
+	}
+}
+
+// RateLimitError 限流错误，Unwrap 返回 ErrRateLimited
+type RateLimitError struct {
+	Scope      string        // 触发限流的维度，如 key:pay、wechat:merchant
+	RetryAfter time.Duration // 建议的重试等待时间，为 0 表示未知
+}
+
+// Error 实现 error 接口
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited on %s, retry after %s", e.Scope, e.RetryAfter)
+}
+
+// Unwrap 返回 ErrRateLimited
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// ClassifyTransportError 将网络层错误归类为 ErrTimeout 或 ErrNetworkError，其他错误原样返回
+func ClassifyTransportError(err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) || errors.Is(err, ErrNetworkError) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &netErr) || errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%w: %w", ErrNetworkError, err)
+	}
+	return err
+}
+
+// ClassifyHTTPStatus 按渠道接口的 HTTP 状态码归类，5xx 视为渠道系统错误
+func ClassifyHTTPStatus(channel ChannelType, statusCode int, kind error) error {
+	if statusCode >= 500 {
+		kind = ErrSystemError
+	}
+	return NewChannelError(channel, fmt.Sprintf("HTTP_%d", statusCode), "unexpected http status", kind)
+}
+
+// IsChannelFailure 判断错误是否说明渠道异常，用于熔断和路由成功率统计
+// 业务错误和参数错误说明渠道工作正常，调用方取消、限流和渠道未实现的操作也不计入
+func IsChannelFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !IsBusinessError(err) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, ErrRateLimited) &&
+		!errors.Is(err, ErrNotSupported) &&
+		!errors.Is(err, ErrInvalidChannel) &&
+		!errors.Is(err, ErrInvalidParameter) &&
+		!errors.Is(err, ErrMissingParameter) &&
+		!errors.Is(err, ErrInvalidAmount) &&
+		!errors.Is(err, ErrInvalidScene)
+}
@@ -0,0 +1,65 @@
+package payment
+
+import "errors"
+
+// errorCodes 标准错误与错误码的对应关系，按顺序匹配，具体的错误在前
+var errorCodes = []struct {
+	err  error
+	code *ErrorCode
+}{
+	{ErrRateLimited, TooManyRequests},
+	{ErrCircuitOpen, CircuitOpen},
+	{ErrChannelUnavailable, ChannelUnavailable},
+	{ErrInvalidChannel, InvalidChannel},
+	{ErrInvalidScene, InvalidScene},
+	{ErrInvalidAmount, InvalidAmount},
+	{ErrMissingParameter, MissingParameter},
+	{ErrInvalidParameter, InvalidParameter},
+	{ErrOrderNotFound, OrderNotFound},
+	{ErrOrderClosed, OrderClosed},
+	{ErrOrderPaid, OrderPaid},
+	{ErrOrderExpired, OrderExpired},
+	{ErrRefundNotAllowed, RefundNotAllowed},
+	{ErrInsufficientBalance, InsufficientBalance},
+	{ErrNotSupported, NotSupported},
+	{ErrInvalidSignature, InvalidSignature},
+	{ErrSignatureFailed, SignatureFailed},
+	{ErrInvalidNotify, InvalidNotify},
+	{ErrNotifyVerifyFailed, NotifyVerifyFailed},
+	{ErrTimeout, ChannelTimeout},
+	{ErrNetworkError, ChannelNetworkError},
+	{ErrSystemError, ChannelSystemError},
+	{ErrWechatError, WechatError},
+	{ErrAlipayError, AlipayError},
+	{ErrUnionPayError, UnionPayError},
+}
+
+// ErrorCodeOf 将错误转换为对外的错误码
+// 错误信息固定取自错误码目录，不暴露 SDK 原始信息；渠道错误的原始错误码、限流错误的限流维度放在 Details 中；
+// 未归类的错误返回 InternalServerError
+func ErrorCodeOf(err error) *ErrorCode {
+	if err == nil {
+		return Success
+	}
+
+	var code *ErrorCode
+	if errors.As(err, &code) {
+		return code
+	}
+
+	var details string
+	var channelErr *ChannelError
+	var limitErr *RateLimitError
+	if errors.As(err, &channelErr) {
+		details = string(channelErr.Channel) + ":" + channelErr.Code
+	} else if errors.As(err, &limitErr) {
+		details = limitErr.Scope
+	}
+
+	for _, entry := range errorCodes {
+		if errors.Is(err, entry.err) {
+			return NewErrorCodeWithDetails(entry.code.Code, entry.code.Message, details)
+		}
+	}
+	return NewErrorCodeWithDetails(InternalServerError.Code, InternalServerError.Message, details)
+}
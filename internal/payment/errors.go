@@ -16,6 +16,10 @@ var (
 	ErrSystemError         = errors.New("system error")
 	ErrNetworkError        = errors.New("network error")
 	ErrTimeout             = errors.New("request timeout")
+	ErrChannelUnavailable  = errors.New("payment channel unavailable")
+	ErrCircuitOpen         = errors.New("circuit breaker open")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrNotSupported        = errors.New("operation not supported")
 	
 	// 业务错误
 	ErrOrderNotFound       = errors.New("order not found")
@@ -81,7 +85,9 @@ var (
 	MethodNotAllowed    = NewErrorCode("405", "method not allowed")
 	RequestTimeout      = NewErrorCode("408", "request timeout")
 	Conflict            = NewErrorCode("409", "conflict")
+	RequestTooLarge     = NewErrorCode("413", "request entity too large")
 	UnprocessableEntity = NewErrorCode("422", "unprocessable entity")
+	TooManyRequests     = NewErrorCode("429", "too many requests")
 	
 	// 服务器错误 (5xx)
 	InternalServerError = NewErrorCode("500", "internal server error")
@@ -100,11 +106,17 @@ var (
 	OrderExpired        = NewErrorCode("1009", "order expired")
 	RefundNotAllowed    = NewErrorCode("1010", "refund not allowed")
 	InsufficientBalance = NewErrorCode("1011", "insufficient balance")
+	NotSupported        = NewErrorCode("1012", "operation not supported by channel")
 	
 	// 渠道错误码
 	WechatError   = NewErrorCode("2001", "wechat pay error")
 	AlipayError   = NewErrorCode("2002", "alipay error")
 	UnionPayError = NewErrorCode("2003", "unionpay error")
+	ChannelUnavailable  = NewErrorCode("2004", "payment channel unavailable")
+	CircuitOpen         = NewErrorCode("2005", "circuit breaker open")
+	ChannelNetworkError = NewErrorCode("2006", "channel network error")
+	ChannelTimeout      = NewErrorCode("2007", "channel timeout")
+	ChannelSystemError  = NewErrorCode("2008", "channel system error")
 	
 	// 签名错误码
 	InvalidSignature = NewErrorCode("3001", "invalid signature")
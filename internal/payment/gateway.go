@@ -3,6 +3,9 @@ package payment
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Define interfaces for the adapters to avoid import cycles
@@ -15,41 +18,187 @@ type PaymentAdapter interface {
 	Close(ctx context.Context, req *CloseRequest) error
 }
 
+// PayFormProvider 前台跳转支付表单（可选接口，如银联 H5/PC 支付）
+// 表单按下单时生成的不可猜测令牌获取，不能按商户订单号获取
+type PayFormProvider interface {
+	GetPayForm(ctx context.Context, token string) (string, error)
+}
+
+// ReturnHandler 同步跳转通知处理（可选接口）
+type ReturnHandler interface {
+	HandleReturn(ctx context.Context, data []byte) (*ReturnResult, error)
+}
+
+// RefundQuerier 退款查询（可选接口）
+type RefundQuerier interface {
+	QueryRefund(ctx context.Context, req *RefundQueryRequest) (*RefundResponse, error)
+}
+
+// BillDownloader 对账单下载（可选接口）
+type BillDownloader interface {
+	DownloadBill(ctx context.Context, req *BillRequest) (*BillResponse, error)
+}
+
 type PaymentGateway struct {
-	adapters map[ChannelType]PaymentAdapter
+	mu          sync.RWMutex
+	adapters    map[ChannelType]PaymentAdapter
+	status      map[ChannelType]ChannelStatus
+	middlewares []Middleware
+	breaker     *CircuitBreaker
 }
 
 func NewPaymentGateway(adapters ...PaymentAdapter) *PaymentGateway {
 	gateway := &PaymentGateway{
 		adapters: make(map[ChannelType]PaymentAdapter),
+		status:   make(map[ChannelType]ChannelStatus),
 	}
 
 	for _, adapter := range adapters {
-		gateway.adapters[adapter.GetChannel()] = adapter
+		gateway.SetAdapter(adapter)
 	}
 
 	return gateway
 }
 
+// getAdapter 获取渠道当前的适配器
+// 调用方拿到的是替换前的快照，正在处理的请求不受适配器热替换影响
+func (g *PaymentGateway) getAdapter(channel ChannelType) (PaymentAdapter, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	adapter, exists := g.adapters[channel]
+	return adapter, exists
+}
+
+// adapterFor 获取渠道适配器，渠道已启用但初始化失败时返回 ErrChannelUnavailable
+func (g *PaymentGateway) adapterFor(channel ChannelType) (PaymentAdapter, error) {
+	adapter, exists := g.getAdapter(channel)
+	if exists {
+		return adapter, nil
+	}
+	if g.ChannelState(channel) == ChannelStateFailed {
+		return nil, fmt.Errorf("%w: %s", ErrChannelUnavailable, channel)
+	}
+	return nil, fmt.Errorf("%w: %s is not supported", ErrInvalidChannel, channel)
+}
+
+// SetAdapter 注册或原子替换渠道适配器
+func (g *PaymentGateway) SetAdapter(adapter PaymentAdapter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	channel := adapter.GetChannel()
+	g.adapters[channel] = adapter
+	g.status[channel] = ChannelStatus{
+		Channel:   channel,
+		State:     ChannelStateReady,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// RemoveAdapter 移除渠道适配器
+func (g *PaymentGateway) RemoveAdapter(channel ChannelType) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.adapters, channel)
+	delete(g.status, channel)
+}
+
+// GetCertInfos 获取所有渠道当前加载的证书信息
+func (g *PaymentGateway) GetCertInfos(ctx context.Context) []CertInfo {
+	g.mu.RLock()
+	providers := make([]CertInfoProvider, 0, len(g.adapters))
+	for _, adapter := range g.adapters {
+		if provider, ok := adapter.(CertInfoProvider); ok {
+			providers = append(providers, provider)
+		}
+	}
+	g.mu.RUnlock()
+
+	var infos []CertInfo
+	for _, provider := range providers {
+		infos = append(infos, provider.CertInfos(ctx)...)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Channel != infos[j].Channel {
+			return infos[i].Channel < infos[j].Channel
+		}
+		if infos[i].Name != infos[j].Name {
+			return infos[i].Name < infos[j].Name
+		}
+		return infos[i].Serial < infos[j].Serial
+	})
+	return infos
+}
+
 func (g *PaymentGateway) Pay(ctx context.Context, req *UnifiedPayRequest) (*UnifiedPayResponse, error) {
-	adapter, exists := g.adapters[req.Channel]
-	if !exists {
-		return nil, fmt.Errorf("unsupported payment channel: %s", req.Channel)
+	adapter, err := g.adapterFor(req.Channel)
+	if err != nil {
+		return nil, err
 	}
 
-	return adapter.Pay(ctx, req)
+	var resp *UnifiedPayResponse
+	err = g.invoke(ctx, &Call{Channel: req.Channel, Operation: OpPay, Request: req}, func(ctx context.Context, call *Call) error {
+		var err error
+		resp, err = adapter.Pay(ctx, req)
+		call.Result = resp
+		return err
+	})
+	return resp, err
 }
 
 func (g *PaymentGateway) HandleNotify(ctx context.Context, channel ChannelType, data []byte) (*NotifyResult, error) {
-	adapter, exists := g.adapters[channel]
-	if !exists {
-		return nil, fmt.Errorf("unsupported payment channel: %s", channel)
+	adapter, err := g.adapterFor(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *NotifyResult
+	err = g.invoke(ctx, &Call{Channel: channel, Operation: OpNotify, Request: data}, func(ctx context.Context, call *Call) error {
+		var err error
+		result, err = adapter.HandleNotify(ctx, data)
+		call.Result = result
+		return err
+	})
+	return result, err
+}
+
+// GetPayForm 按表单令牌获取前台支付表单
+func (g *PaymentGateway) GetPayForm(ctx context.Context, channel ChannelType, token string) (string, error) {
+	adapter, err := g.adapterFor(channel)
+	if err != nil {
+		return "", err
+	}
+
+	provider, ok := adapter.(PayFormProvider)
+	if !ok {
+		return "", fmt.Errorf("%w: %s does not support pay form", ErrInvalidChannel, channel)
+	}
+
+	return provider.GetPayForm(ctx, token)
+}
+
+// HandleReturn 处理同步跳转通知
+func (g *PaymentGateway) HandleReturn(ctx context.Context, channel ChannelType, data []byte) (*ReturnResult, error) {
+	adapter, err := g.adapterFor(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	handler, ok := adapter.(ReturnHandler)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not support return", ErrInvalidChannel, channel)
 	}
 
-	return adapter.HandleNotify(ctx, data)
+	return handler.HandleReturn(ctx, data)
 }
 
 func (g *PaymentGateway) GetSupportedChannels() []ChannelType {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
 	channels := make([]ChannelType, 0, len(g.adapters))
 	for channel := range g.adapters {
 		channels = append(channels, channel)
@@ -59,30 +208,90 @@ func (g *PaymentGateway) GetSupportedChannels() []ChannelType {
 
 // Refund method that was missing - this fixes the compilation error
 func (g *PaymentGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
-	adapter, exists := g.adapters[req.Channel]
-	if !exists {
-		return nil, fmt.Errorf("unsupported payment channel: %s", req.Channel)
+	adapter, err := g.adapterFor(req.Channel)
+	if err != nil {
+		return nil, err
 	}
 
-	return adapter.Refund(ctx, req)
+	var resp *RefundResponse
+	err = g.invoke(ctx, &Call{Channel: req.Channel, Operation: OpRefund, Request: req}, func(ctx context.Context, call *Call) error {
+		var err error
+		resp, err = adapter.Refund(ctx, req)
+		call.Result = resp
+		return err
+	})
+	return resp, err
 }
 
 // Query method implementation
 func (g *PaymentGateway) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
-	adapter, exists := g.adapters[req.Channel]
-	if !exists {
-		return nil, fmt.Errorf("unsupported payment channel: %s", req.Channel)
+	adapter, err := g.adapterFor(req.Channel)
+	if err != nil {
+		return nil, err
 	}
 
-	return adapter.Query(ctx, req)
+	var resp *QueryResponse
+	err = g.invoke(ctx, &Call{Channel: req.Channel, Operation: OpQuery, Request: req}, func(ctx context.Context, call *Call) error {
+		var err error
+		resp, err = adapter.Query(ctx, req)
+		call.Result = resp
+		return err
+	})
+	return resp, err
 }
 
 // Close method implementation
 func (g *PaymentGateway) Close(ctx context.Context, req *CloseRequest) error {
-	adapter, exists := g.adapters[req.Channel]
-	if !exists {
-		return fmt.Errorf("unsupported payment channel: %s", req.Channel)
+	adapter, err := g.adapterFor(req.Channel)
+	if err != nil {
+		return err
+	}
+
+	return g.invoke(ctx, &Call{Channel: req.Channel, Operation: OpClose, Request: req}, func(ctx context.Context, call *Call) error {
+		return adapter.Close(ctx, req)
+	})
+}
+
+// QueryRefund 查询退款状态
+func (g *PaymentGateway) QueryRefund(ctx context.Context, req *RefundQueryRequest) (*RefundResponse, error) {
+	adapter, err := g.adapterFor(req.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	querier, ok := adapter.(RefundQuerier)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not support refund query", ErrNotSupported, req.Channel)
+	}
+
+	var resp *RefundResponse
+	err = g.invoke(ctx, &Call{Channel: req.Channel, Operation: OpRefundQuery, Request: req}, func(ctx context.Context, call *Call) error {
+		var err error
+		resp, err = querier.QueryRefund(ctx, req)
+		call.Result = resp
+		return err
+	})
+	return resp, err
+}
+
+// DownloadBill 下载对账单
+func (g *PaymentGateway) DownloadBill(ctx context.Context, req *BillRequest) (*BillResponse, error) {
+	adapter, err := g.adapterFor(req.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	downloader, ok := adapter.(BillDownloader)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not support bill download", ErrNotSupported, req.Channel)
 	}
 
-	return adapter.Close(ctx, req)
+	var resp *BillResponse
+	err = g.invoke(ctx, &Call{Channel: req.Channel, Operation: OpBill, Request: req}, func(ctx context.Context, call *Call) error {
+		var err error
+		resp, err = downloader.DownloadBill(ctx, req)
+		call.Result = resp
+		return err
+	})
+	return resp, err
 }
@@ -0,0 +1,55 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KeyChecker 检查适配器运行时依赖的密钥和证书是否可用（可选接口），如微信平台证书是否已下载
+type KeyChecker interface {
+	CheckKeys(ctx context.Context) error
+}
+
+// CheckKeys 检查渠道密钥，适配器未实现 KeyChecker 时只要求适配器已构建
+func (g *PaymentGateway) CheckKeys(ctx context.Context, channel ChannelType) error {
+	adapter, err := g.adapterFor(channel)
+	if err != nil {
+		return err
+	}
+	if checker, ok := adapter.(KeyChecker); ok {
+		return checker.CheckKeys(ctx)
+	}
+	return nil
+}
+
+// ChannelCertInfos 获取渠道当前加载的证书信息
+func (g *PaymentGateway) ChannelCertInfos(ctx context.Context, channel ChannelType) []CertInfo {
+	adapter, exists := g.getAdapter(channel)
+	if !exists {
+		return nil
+	}
+	if provider, ok := adapter.(CertInfoProvider); ok {
+		return provider.CertInfos(ctx)
+	}
+	return nil
+}
+
+// Probe 查询一笔不存在的订单，验证渠道网络连通、商户凭证和签名可用
+// 渠道返回订单不存在即为正常；直接调用适配器，不经过中间件，不计入指标、熔断和审计
+func (g *PaymentGateway) Probe(ctx context.Context, channel ChannelType) error {
+	adapter, err := g.adapterFor(channel)
+	if err != nil {
+		return err
+	}
+
+	_, err = adapter.Query(ctx, &QueryRequest{
+		Channel:    channel,
+		OutTradeNo: fmt.Sprintf("PROBE%d", time.Now().UnixNano()),
+	})
+	if err == nil || errors.Is(err, ErrOrderNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package payment
+
+import "context"
+
+// Operation 渠道操作类型
+type Operation string
+
+const (
+	OpPay         Operation = "pay"
+	OpQuery       Operation = "query"
+	OpRefund      Operation = "refund"
+	OpRefundQuery Operation = "refund_query"
+	OpClose       Operation = "close"
+	OpBill        Operation = "bill"
+	OpNotify      Operation = "notify"
+)
+
+// Outbound 是否为调用渠道接口的操作，通知处理只在本地验签
+func (o Operation) Outbound() bool {
+	return o != OpNotify
+}
+
+// Call 一次渠道调用
+type Call struct {
+	Channel   ChannelType
+	Operation Operation
+	Request   interface{} // 原始请求，如 *UnifiedPayRequest、*RefundRequest
+	Result    interface{} // 调用成功后的结果，如 *UnifiedPayResponse、*NotifyResult，供外层中间件读取
+}
+
+// Handler 执行渠道调用
+type Handler func(ctx context.Context, call *Call) error
+
+// Middleware 渠道调用中间件，用于熔断、重试、监控等横切逻辑
+type Middleware func(next Handler) Handler
+
+// Use 追加中间件，先追加的在外层
+func (g *PaymentGateway) Use(middlewares ...Middleware) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// invoke 经过中间件链执行渠道调用
+func (g *PaymentGateway) invoke(ctx context.Context, call *Call, fn Handler) error {
+	g.mu.RLock()
+	middlewares := g.middlewares
+	g.mu.RUnlock()
+
+	handler := fn
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler(ctx, call)
+}
@@ -9,7 +9,6 @@ type UnifiedPayRequest struct {
 	TotalAmount float64     // 金额（元）
 	Subject     string      // 商品标题
 	Body        string      // 商品描述
-	NotifyURL   string      // 异步通知地址
 	ReturnURL   string      // 同步跳转地址（H5用）
 	Scene       PayScene    // 支付场景: "app", "h5", "jsapi", "native" 等
 	OpenID      string      // 微信 JSAPI 必填
@@ -37,6 +36,13 @@ type NotifyResult struct {
 	Channel     ChannelType
 	OrderID     string
 	PayTime     *time.Time
+	Attach      string // 下单时传入的附加数据
+}
+
+// ReturnResult 同步跳转结果
+type ReturnResult struct {
+	NotifyResult
+	ReturnURL string // 商户前端跳转地址
 }
 
 // QueryRequest 查询请求
@@ -87,3 +93,27 @@ type CloseRequest struct {
 	OrderID    string
 	OutTradeNo string
 }
+
+// RefundQueryRequest 退款查询请求
+type RefundQueryRequest struct {
+	Channel     ChannelType
+	OrderID     string
+	OutTradeNo  string
+	OutRefundNo string
+}
+
+// BillRequest 对账单下载请求
+type BillRequest struct {
+	Channel  ChannelType
+	Date     time.Time // 账单日期，按天下载
+	BillType string    // 账单类型，为空时下载交易账单；其他值按渠道的账单类型原样传递
+}
+
+// BillResponse 对账单
+type BillResponse struct {
+	Channel  ChannelType
+	Date     time.Time
+	BillType string
+	FileName string // 建议的文件名，扩展名与渠道返回的格式一致
+	Data     []byte
+}
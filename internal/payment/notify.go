@@ -61,7 +61,7 @@ func (nm *NotifyManager) RegisterProcessor(name string, processor NotifyProcesso
 // HandleNotify 处理异步通知
 func (nm *NotifyManager) HandleNotify(ctx context.Context, channel ChannelType, body []byte) (*NotifyResult, error) {
 	// 获取适配器
-	adapter, exists := nm.gateway.adapters[channel]
+	adapter, exists := nm.gateway.getAdapter(channel)
 	if !exists {
 		return nil, fmt.Errorf("get adapter failed: unsupported channel %s", channel)
 	}
@@ -127,7 +127,7 @@ func (h *DefaultNotifyHandler) Handle(ctx context.Context, channel ChannelType,
 // Verify 验证通知签名
 func (h *DefaultNotifyHandler) Verify(ctx context.Context, channel ChannelType, body []byte) error {
 	// 获取适配器
-	adapter, exists := h.gateway.adapters[channel]
+	adapter, exists := h.gateway.getAdapter(channel)
 	if !exists {
 		return fmt.Errorf("unsupported channel: %s", channel)
 	}
@@ -165,7 +165,7 @@ type Logger interface {
 	Warn(ctx context.Context, msg string, fields ...interface{})
 }
 
-// NewLoggingProcessor 创建日志处理器
+// NewLoggingProcessor 创建日志处理器，logger 可使用 logs.ContextLogger
 func NewLoggingProcessor(logger Logger) *LoggingProcessor {
 	return &LoggingProcessor{
 		logger: logger,
@@ -174,14 +174,14 @@ func NewLoggingProcessor(logger Logger) *LoggingProcessor {
 
 // Process 处理通知日志
 func (p *LoggingProcessor) Process(ctx context.Context, result *NotifyResult) error {
-	//p.logger.Info(ctx, "processing notify",
-	//	"channel", result.Channel,
-	//	"order_id", result.OrderID,
-	//	"out_trade_no", result.OutTradeNo,
-	//	"trade_status", result.TradeStatus,
-	//	"total_amount", result.TotalAmount,
-	//	"pay_time", result.PayTime,
-	//)
+	p.logger.Info(ctx, "processing notify",
+		"channel", result.Channel,
+		"order_id", result.OrderID,
+		"out_trade_no", result.OutTradeNo,
+		"trade_status", result.TradeStatus,
+		"total_amount", result.TotalAmount,
+		"pay_time", result.PayTime,
+	)
 
 	return nil
 }
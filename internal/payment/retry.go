@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryOptions 重试参数
+type RetryOptions struct {
+	MaxAttempts    int           // 最大尝试次数，包含首次调用
+	InitialBackoff time.Duration // 首次重试前的等待时间，之后每次翻倍
+	MaxBackoff     time.Duration // 单次等待时间上限
+	Jitter         float64       // 等待时间随机浮动比例
+}
+
+// RetryMiddleware 重试中间件
+// 只重试幂等操作：查询、关单，以及带商户退款单号的退款（渠道按退款单号去重）；
+// 下单不重试，避免重复创建订单。只有 IsRetryable 的错误才会重试，
+// 放在熔断中间件外层时每次尝试都计入熔断统计，熔断后返回的 ErrCircuitOpen 不再重试
+func RetryMiddleware(cfg RetryOptions) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) error {
+			if !idempotent(call) {
+				return next(ctx, call)
+			}
+
+			var err error
+			for attempt := 1; ; attempt++ {
+				err = next(ctx, call)
+				if attempt >= cfg.MaxAttempts || !IsRetryable(err) {
+					return err
+				}
+
+				timer := time.NewTimer(backoff(cfg, attempt))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return err
+				case <-timer.C:
+				}
+			}
+		}
+	}
+}
+
+// idempotent 判断调用是否可以安全重试
+func idempotent(call *Call) bool {
+	switch call.Operation {
+	case OpQuery, OpRefundQuery, OpClose, OpBill:
+		return true
+	case OpRefund:
+		req, ok := call.Request.(*RefundRequest)
+		return ok && req.OutRefundNo != ""
+	default:
+		return false
+	}
+}
+
+// backoff 第 attempt 次失败后的等待时间，按指数增长并加入随机抖动
+func backoff(cfg RetryOptions, attempt int) time.Duration {
+	d := cfg.InitialBackoff
+	for i := 1; i < attempt && d < cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > cfg.MaxBackoff {
+		d = cfg.MaxBackoff
+	}
+	if cfg.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * cfg.Jitter * float64(d))
+	}
+	return d
+}
@@ -0,0 +1,99 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	opts := RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	ctx := context.Background()
+
+	cases := []struct {
+		name  string
+		err   error
+		call  func(g *PaymentGateway) error
+		calls int
+	}{
+		{"query retried", ErrTimeout, func(g *PaymentGateway) error {
+			_, err := g.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+			return err
+		}, 3},
+		{"close retried", ErrNetworkError, func(g *PaymentGateway) error {
+			return g.Close(ctx, &CloseRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+		}, 3},
+		{"refund with out_refund_no retried", ErrSystemError, func(g *PaymentGateway) error {
+			_, err := g.Refund(ctx, &RefundRequest{Channel: ChannelWechat, OutTradeNo: "T1", OutRefundNo: "R1"})
+			return err
+		}, 3},
+		{"refund without out_refund_no", ErrSystemError, func(g *PaymentGateway) error {
+			_, err := g.Refund(ctx, &RefundRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+			return err
+		}, 1},
+		{"pay not retried", ErrTimeout, func(g *PaymentGateway) error {
+			_, err := g.Pay(ctx, &UnifiedPayRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+			return err
+		}, 1},
+		{"business error not retried", ErrOrderPaid, func(g *PaymentGateway) error {
+			_, err := g.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+			return err
+		}, 1},
+		{"success", nil, func(g *PaymentGateway) error {
+			_, err := g.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"})
+			return err
+		}, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			adapter := &fakeAdapter{channel: ChannelWechat, err: tc.err}
+			gateway := NewPaymentGateway(adapter)
+			gateway.Use(RetryMiddleware(opts))
+
+			if err := tc.call(gateway); !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if adapter.calls != tc.calls {
+				t.Fatalf("calls = %d, want %d", adapter.calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	adapter := &fakeAdapter{channel: ChannelWechat, err: ErrTimeout}
+	gateway := NewPaymentGateway(adapter)
+	gateway.Use(RetryMiddleware(RetryOptions{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := gateway.Query(ctx, &QueryRequest{Channel: ChannelWechat, OutTradeNo: "T1"}); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want %v", err, ErrTimeout)
+	}
+	if adapter.calls != 1 {
+		t.Fatalf("calls = %d, want 1", adapter.calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	opts := RetryOptions{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := backoff(opts, attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+
+	opts.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := backoff(opts, 2); got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("backoff with jitter = %s, want within [100ms, 300ms]", got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"sort"
+	"time"
+)
+
+// ChannelState 渠道运行状态
+type ChannelState string
+
+const (
+	ChannelStateReady    ChannelState = "ready"    // 适配器可用
+	ChannelStateFailed   ChannelState = "failed"   // 初始化失败，后台重试中
+	ChannelStateDisabled ChannelState = "disabled" // 配置中未启用
+)
+
+// ChannelStatus 渠道状态
+type ChannelStatus struct {
+	Channel   ChannelType    `json:"channel"`
+	State     ChannelState   `json:"state"`
+	Error     string         `json:"error,omitempty"`    // 最近一次初始化失败原因
+	Attempts  int            `json:"attempts,omitempty"` // 连续失败次数
+	Breaker   *BreakerStatus `json:"breaker,omitempty"`  // 熔断器状态，未启用熔断时为空
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// MarkFailed 记录渠道初始化失败，已有可用适配器时保持原状态
+func (g *PaymentGateway) MarkFailed(channel ChannelType, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.adapters[channel]; exists {
+		return
+	}
+
+	status := g.status[channel]
+	if status.State != ChannelStateFailed {
+		status.Attempts = 0
+	}
+	status.Channel = channel
+	status.State = ChannelStateFailed
+	status.Error = err.Error()
+	status.Attempts++
+	status.UpdatedAt = time.Now()
+	g.status[channel] = status
+}
+
+// MarkDisabled 禁用渠道并移除其适配器
+func (g *PaymentGateway) MarkDisabled(channel ChannelType) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.adapters, channel)
+	g.status[channel] = ChannelStatus{
+		Channel:   channel,
+		State:     ChannelStateDisabled,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// ChannelState 返回渠道当前状态，未知渠道返回空字符串
+func (g *PaymentGateway) ChannelState(channel ChannelType) ChannelState {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.status[channel].State
+}
+
+// GetChannelStatus 获取渠道状态，包含熔断器状态
+func (g *PaymentGateway) GetChannelStatus(channel ChannelType) ChannelStatus {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	status, exists := g.status[channel]
+	if !exists {
+		return ChannelStatus{Channel: channel, State: ChannelStateDisabled}
+	}
+	return g.withBreaker(status)
+}
+
+// GetChannelStatuses 获取所有已知渠道的状态，按渠道名排序
+func (g *PaymentGateway) GetChannelStatuses() []ChannelStatus {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	statuses := make([]ChannelStatus, 0, len(g.status))
+	for _, status := range g.status {
+		statuses = append(statuses, g.withBreaker(status))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Channel < statuses[j].Channel
+	})
+	return statuses
+}
+
+// withBreaker 填充可用渠道的熔断器状态，调用方需持有锁
+func (g *PaymentGateway) withBreaker(status ChannelStatus) ChannelStatus {
+	if g.breaker != nil && status.State == ChannelStateReady {
+		breaker := g.breaker.Status(status.Channel)
+		status.Breaker = &breaker
+	}
+	return status
+}
+
+// Degraded 是否有已启用的渠道不可用或处于熔断状态
+func (g *PaymentGateway) Degraded() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, status := range g.status {
+		if status.State == ChannelStateFailed {
+			return true
+		}
+		if g.breaker != nil && status.State == ChannelStateReady &&
+			g.breaker.Status(status.Channel).State != BreakerClosed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeAdapter 返回预设结果的适配器
+type fakeAdapter struct {
+	channel ChannelType
+	err     error // 各操作返回的错误
+	calls   int
+}
+
+func (a *fakeAdapter) Pay(ctx context.Context, req *UnifiedPayRequest) (*UnifiedPayResponse, error) {
+	a.calls++
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &UnifiedPayResponse{Code: "0", OutTradeNo: req.OutTradeNo, Channel: a.channel}, nil
+}
+
+func (a *fakeAdapter) HandleNotify(ctx context.Context, data []byte) (*NotifyResult, error) {
+	a.calls++
+	return &NotifyResult{Channel: a.channel}, a.err
+}
+
+func (a *fakeAdapter) GetChannel() ChannelType {
+	return a.channel
+}
+
+func (a *fakeAdapter) Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
+	a.calls++
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &RefundResponse{Code: "0", OutRefundNo: req.OutRefundNo, Channel: a.channel}, nil
+}
+
+func (a *fakeAdapter) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
+	a.calls++
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &QueryResponse{Code: "0", OutTradeNo: req.OutTradeNo, TradeStatus: TradeStatusNotPay, Channel: a.channel}, nil
+}
+
+func (a *fakeAdapter) Close(ctx context.Context, req *CloseRequest) error {
+	a.calls++
+	return a.err
+}
+
+func TestChannelStates(t *testing.T) {
+	gateway := NewPaymentGateway(&fakeAdapter{channel: ChannelWechat})
+	gateway.MarkFailed(ChannelAlipay, errors.New("load key failed"))
+	gateway.MarkFailed(ChannelAlipay, errors.New("load key failed again"))
+	gateway.MarkDisabled(ChannelUnionPay)
+
+	cases := []struct {
+		channel  ChannelType
+		state    ChannelState
+		attempts int
+		wantErr  error
+	}{
+		{ChannelWechat, ChannelStateReady, 0, nil},
+		{ChannelAlipay, ChannelStateFailed, 2, ErrChannelUnavailable},
+		{ChannelUnionPay, ChannelStateDisabled, 0, ErrInvalidChannel},
+		{"paypal", "", 0, ErrInvalidChannel},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.channel), func(t *testing.T) {
+			if state := gateway.ChannelState(tc.channel); state != tc.state {
+				t.Errorf("state = %q, want %q", state, tc.state)
+			}
+			if status := gateway.GetChannelStatus(tc.channel); status.Attempts != tc.attempts {
+				t.Errorf("attempts = %d, want %d", status.Attempts, tc.attempts)
+			}
+
+			_, err := gateway.Query(context.Background(), &QueryRequest{Channel: tc.channel, OutTradeNo: "T1"})
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("query err = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	if !gateway.Degraded() {
+		t.Error("gateway with failed channel is not degraded")
+	}
+	if channels := gateway.GetSupportedChannels(); len(channels) != 1 || channels[0] != ChannelWechat {
+		t.Errorf("supported channels = %v", channels)
+	}
+	if statuses := gateway.GetChannelStatuses(); len(statuses) != 3 || statuses[0].Channel != ChannelAlipay {
+		t.Errorf("statuses = %+v", statuses)
+	}
+}
+
+func TestChannelRecovery(t *testing.T) {
+	gateway := NewPaymentGateway()
+	gateway.MarkFailed(ChannelAlipay, errors.New("load key failed"))
+
+	gateway.SetAdapter(&fakeAdapter{channel: ChannelAlipay})
+	status := gateway.GetChannelStatus(ChannelAlipay)
+	if status.State != ChannelStateReady || status.Error != "" || status.Attempts != 0 {
+		t.Fatalf("status = %+v, want ready", status)
+	}
+	if gateway.Degraded() {
+		t.Fatal("gateway is degraded after channel recovered")
+	}
+
+	// 已有可用适配器时重建失败不影响渠道状态
+	gateway.MarkFailed(ChannelAlipay, errors.New("rebuild failed"))
+	if state := gateway.ChannelState(ChannelAlipay); state != ChannelStateReady {
+		t.Fatalf("state = %s after failed rebuild, want ready", state)
+	}
+
+	gateway.MarkDisabled(ChannelAlipay)
+	if _, err := gateway.Pay(context.Background(), &UnifiedPayRequest{Channel: ChannelAlipay}); !errors.Is(err, ErrInvalidChannel) {
+		t.Fatalf("pay err = %v, want %v", err, ErrInvalidChannel)
+	}
+}
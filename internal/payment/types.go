@@ -85,4 +85,15 @@ func (p PayScene) IsValid() bool {
 	default:
 		return false
 	}
-}
\ No newline at end of file
+}
+
+// IsValid 检查交易状态是否为网关定义的状态
+func (t TradeStatus) IsValid() bool {
+	switch t {
+	case TradeStatusSuccess, TradeStatusRefund, TradeStatusNotPay, TradeStatusClosed,
+		TradeStatusRevoked, TradeStatusUserPaying, TradeStatusPayError:
+		return true
+	default:
+		return false
+	}
+}
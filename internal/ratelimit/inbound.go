@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// Inbound 网关 API 的入站限流，按 app key 计总限额和单接口限额
+type Inbound struct {
+	perKey    *Limiter
+	endpoints map[string]*Limiter
+}
+
+// NewInbound 创建入站限流
+func NewInbound(cfg configs.RateLimitConfig) *Inbound {
+	in := &Inbound{
+		perKey:    NewLimiter(cfg.PerKey),
+		endpoints: make(map[string]*Limiter, len(cfg.Endpoints)),
+	}
+	for endpoint, limit := range cfg.Endpoints {
+		if l := NewLimiter(limit); l != nil {
+			in.endpoints[endpoint] = l
+		}
+	}
+	return in
+}
+
+// Allow 检查 key 调用 endpoint 是否超限，超限时返回 *payment.RateLimitError
+// 先检查单接口限额，被单接口拒绝的请求不消耗总限额；被总限额拒绝时归还单接口令牌，被拒绝的请求不消耗任何限额
+func (in *Inbound) Allow(key, endpoint string) error {
+	limiter := in.endpoints[endpoint]
+	if ok, wait := limiter.Allow(key); !ok {
+		return &payment.RateLimitError{Scope: "endpoint:" + endpoint, RetryAfter: wait}
+	}
+	if ok, wait := in.perKey.Allow(key); !ok {
+		limiter.refund(key)
+		return &payment.RateLimitError{Scope: "per_key", RetryAfter: wait}
+	}
+	return nil
+}
+
+// Stats 入站限流统计
+func (in *Inbound) Stats() []Stat {
+	result := stats("per_key", in.perKey)
+	for _, endpoint := range sortedKeys(in.endpoints) {
+		result = append(result, stats("endpoint:"+endpoint, in.endpoints[endpoint])...)
+	}
+	return result
+}
+
+// Totals 按维度汇总的累计拒绝次数
+func (in *Inbound) Totals() map[string]uint64 {
+	totals := make(map[string]uint64)
+	addTotal(totals, "per_key", in.perKey)
+	for endpoint, l := range in.endpoints {
+		addTotal(totals, "endpoint:"+endpoint, l)
+	}
+	return totals
+}
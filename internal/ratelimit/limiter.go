@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+)
+
+// idleTimeout 桶空闲超过该时长后被清理，清理后的桶等同于满桶，按 key 的拒绝次数随桶一起清理
+const idleTimeout = 10 * time.Minute
+
+// bucket 令牌桶
+type bucket struct {
+	tokens    float64
+	last      time.Time
+	throttled uint64 // 桶创建以来被拒绝的次数
+}
+
+// Limiter 按 key 分桶的令牌桶限流器
+// 每个 key 独立一个桶，以 rate 的速度补充令牌，最多 burst 个
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	total     uint64 // 所有 key 累计被拒绝的次数，不随桶清理
+	lastSweep time.Time
+}
+
+// NewLimiter 创建限流器，rate 不大于 0 时返回 nil，nil 限流器放行所有请求
+func NewLimiter(cfg configs.LimitConfig) *Limiter {
+	if cfg.Rate <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(cfg.Rate)
+	}
+	return &Limiter{
+		rate:    cfg.Rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow 从 key 的桶中取一个令牌，桶空时返回 false 和预计可用的等待时间
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.allowAt(key, time.Now())
+}
+
+func (l *Limiter) allowAt(key string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= idleTimeout {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	b.throttled++
+	l.total++
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// refund 归还 key 取得的令牌，用于依次检查多个限流器时，后检查的限流器拒绝了请求
+func (l *Limiter) refund(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+// Throttled 近期活跃的 key 被拒绝的次数，空闲超过 idleTimeout 的 key 不再列出
+func (l *Limiter) Throttled() map[string]uint64 {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]uint64)
+	for key, b := range l.buckets {
+		if b.throttled > 0 {
+			counts[key] = b.throttled
+		}
+	}
+	return counts
+}
+
+// Total 所有 key 累计被拒绝的次数
+func (l *Limiter) Total() uint64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total
+}
+
+// sweep 清理空闲的桶，调用方需持有锁
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Stat 某一维度的限流统计
+type Stat struct {
+	Scope     string `json:"scope"` // 限流维度，如 per_key、endpoint:pay、channel:wechat
+	Key       string `json:"key"`   // app key、商户号或客户端 IP
+	Throttled uint64 `json:"throttled"`
+}
+
+// stats 将限流器的统计转换为 Stat 列表
+func stats(scope string, l *Limiter) []Stat {
+	counts := l.Throttled()
+	result := make([]Stat, 0, len(counts))
+	for key, n := range counts {
+		result = append(result, Stat{Scope: scope, Key: key, Throttled: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// addTotal 将限流器的累计拒绝次数计入维度，未配置的限流器不计入
+func addTotal(totals map[string]uint64, scope string, l *Limiter) {
+	if l != nil {
+		totals[scope] += l.Total()
+	}
+}
+
+// sortedKeys 按字典序返回限流器的名称
+func sortedKeys(limiters map[string]*Limiter) []string {
+	keys := make([]string, 0, len(limiters))
+	for key := range limiters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// testStart 测试的起始时间
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestLimiter(t *testing.T) {
+	type step struct {
+		at      time.Duration // 相对 testStart
+		allowed bool
+		wait    time.Duration // 被拒绝时预计的等待时间
+	}
+	cases := []struct {
+		name  string
+		limit configs.LimitConfig
+		steps []step
+	}{
+		{"burst then reject", configs.LimitConfig{Rate: 1, Burst: 2}, []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, time.Second},
+			{500 * time.Millisecond, false, 500 * time.Millisecond},
+		}},
+		{"refill at rate", configs.LimitConfig{Rate: 10, Burst: 1}, []step{
+			{0, true, 0},
+			{50 * time.Millisecond, false, 50 * time.Millisecond},
+			{100 * time.Millisecond, true, 0},
+			{150 * time.Millisecond, false, 50 * time.Millisecond},
+		}},
+		{"refill capped at burst", configs.LimitConfig{Rate: 1, Burst: 2}, []step{
+			{0, true, 0},
+			{time.Hour, true, 0},
+			{time.Hour, true, 0},
+			{time.Hour, false, time.Second},
+		}},
+		{"burst defaults to rate", configs.LimitConfig{Rate: 1.5}, []step{
+			{0, true, 0},
+			{0, true, 0},
+			{0, false, time.Second * 2 / 3},
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLimiter(tc.limit)
+			for i, s := range tc.steps {
+				allowed, wait := l.allowAt("k", testStart.Add(s.at))
+				if allowed != s.allowed || (wait-s.wait).Abs() > time.Millisecond {
+					t.Fatalf("step %d: allowed = %v, wait = %s, want %v, %s", i, allowed, wait, s.allowed, s.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	l := NewLimiter(configs.LimitConfig{Rate: 1, Burst: 1})
+	if ok, _ := l.allowAt("a", testStart); !ok {
+		t.Fatal("first request of a rejected")
+	}
+	if ok, _ := l.allowAt("b", testStart); !ok {
+		t.Fatal("key b shares the bucket of key a")
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	l := NewLimiter(configs.LimitConfig{})
+	if l != nil {
+		t.Fatal("limiter without rate should be nil")
+	}
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("k"); !ok {
+			t.Fatal("nil limiter rejected")
+		}
+	}
+	if l.Throttled() != nil || l.Total() != 0 {
+		t.Fatal("nil limiter has stats")
+	}
+}
+
+// TestLimiterSweep 按 key 的拒绝次数随空闲桶清理，累计次数不减少
+func TestLimiterSweep(t *testing.T) {
+	l := NewLimiter(configs.LimitConfig{Rate: 1, Burst: 1})
+	for i := 0; i < 1000; i++ {
+		key := "ip:10.0.0." + string(rune('a'+i%26)) + string(rune('a'+i/26))
+		l.allowAt(key, testStart)
+		l.allowAt(key, testStart)
+	}
+	l.allowAt("active", testStart.Add(idleTimeout-time.Second))
+	l.allowAt("active", testStart.Add(idleTimeout-time.Second))
+	if n := len(l.Throttled()); n != 1001 {
+		t.Fatalf("throttled keys = %d, want 1001", n)
+	}
+
+	l.allowAt("other", testStart.Add(idleTimeout))
+	if throttled := l.Throttled(); len(throttled) != 1 || throttled["active"] != 1 {
+		t.Fatalf("throttled = %v after sweep, want only active", throttled)
+	}
+	if len(l.buckets) != 2 {
+		t.Fatalf("buckets = %d after sweep, want 2", len(l.buckets))
+	}
+	if total := l.Total(); total != 1001 {
+		t.Fatalf("total = %d, want 1001", total)
+	}
+}
+
+func TestInbound(t *testing.T) {
+	in := NewInbound(configs.RateLimitConfig{
+		PerKey: configs.LimitConfig{Rate: 0.001, Burst: 3},
+		Endpoints: map[string]configs.LimitConfig{
+			"pay":   {Rate: 0.001, Burst: 1},
+			"query": {},
+		},
+	})
+
+	cases := []struct {
+		key      string
+		endpoint string
+		scope    string // 为空表示放行
+	}{
+		{"app", "pay", ""},
+		{"app", "pay", "endpoint:pay"},
+		{"app", "pay", "endpoint:pay"},
+		{"other", "pay", ""}, // 按 app key 分别计数
+		{"app", "query", ""}, // 接口未配置限额，只受总限额约束
+		{"app", "refund", ""},
+		{"app", "query", "per_key"}, // 被单接口拒绝的请求不消耗总限额，总限额在第 4 个请求用尽
+	}
+	for i, tc := range cases {
+		err := in.Allow(tc.key, tc.endpoint)
+		var limited *payment.RateLimitError
+		switch {
+		case tc.scope == "" && err != nil:
+			t.Fatalf("request %d (%s %s): err = %v, want allowed", i, tc.key, tc.endpoint, err)
+		case tc.scope != "" && (!errors.As(err, &limited) || limited.Scope != tc.scope || limited.RetryAfter <= 0):
+			t.Fatalf("request %d (%s %s): err = %v, want rate limited by %s", i, tc.key, tc.endpoint, err, tc.scope)
+		}
+	}
+
+	stats := in.Stats()
+	want := []Stat{{Scope: "per_key", Key: "app", Throttled: 1}, {Scope: "endpoint:pay", Key: "app", Throttled: 2}}
+	if len(stats) != len(want) || stats[0] != want[0] || stats[1] != want[1] {
+		t.Fatalf("stats = %+v, want %+v", stats, want)
+	}
+	if totals := in.Totals(); totals["per_key"] != 1 || totals["endpoint:pay"] != 2 || len(totals) != 2 {
+		t.Fatalf("totals = %v", totals)
+	}
+}
+
+// TestRefundOnDenial 被后检查的限额拒绝的请求归还先检查的限额的令牌
+func TestRefundOnDenial(t *testing.T) {
+	limit := configs.LimitConfig{Rate: 0.001, Burst: 1}
+	in := NewInbound(configs.RateLimitConfig{
+		PerKey:    limit,
+		Endpoints: map[string]configs.LimitConfig{"pay": {Rate: 0.001, Burst: 2}},
+	})
+	if err := in.Allow("app", "query"); err != nil {
+		t.Fatal(err)
+	}
+	// 总限额已用尽，单接口令牌被归还，单接口限额的拒绝次数不变
+	for i := 0; i < 3; i++ {
+		var limited *payment.RateLimitError
+		if err := in.Allow("app", "pay"); !errors.As(err, &limited) || limited.Scope != "per_key" {
+			t.Fatalf("request %d: err = %v, want rate limited by per_key", i, err)
+		}
+	}
+	if tokens := in.endpoints["pay"].buckets["app"].tokens; tokens != 2 {
+		t.Fatalf("endpoint tokens = %v, want 2", tokens)
+	}
+	if totals := in.Totals(); totals["per_key"] != 3 || totals["endpoint:pay"] != 0 {
+		t.Fatalf("totals = %v", totals)
+	}
+
+	out := NewOutbound(configs.RateLimitConfig{
+		Channels:    map[string]configs.LimitConfig{"wechat": limit},
+		PerMerchant: configs.LimitConfig{Rate: 0.001, Burst: 2},
+	})
+	handler := out.Middleware()(func(ctx context.Context, call *payment.Call) error { return nil })
+	if err := handler(context.Background(), &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpPay}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := auth.WithCredential(context.Background(), &auth.Credential{AppKey: "m1", MerchantID: "m1"})
+	for i := 0; i < 3; i++ {
+		var limited *payment.RateLimitError
+		if err := handler(ctx, &payment.Call{Channel: payment.ChannelWechat, Operation: payment.OpPay}); !errors.As(err, &limited) || limited.Scope != "channel:wechat" {
+			t.Fatalf("call %d: err = %v, want rate limited by channel:wechat", i, err)
+		}
+	}
+	if tokens := out.perMerchant.buckets["wechat:m1"].tokens; tokens != 2 {
+		t.Fatalf("merchant tokens = %v, want 2", tokens)
+	}
+}
+
+func TestOutboundMiddleware(t *testing.T) {
+	out := NewOutbound(configs.RateLimitConfig{
+		Channels:    map[string]configs.LimitConfig{"wechat": {Rate: 0.001, Burst: 2}},
+		PerMerchant: configs.LimitConfig{Rate: 0.001, Burst: 1},
+	})
+	var calls int
+	handler := out.Middleware()(func(ctx context.Context, call *payment.Call) error {
+		calls++
+		return nil
+	})
+	merchant := func(id string) context.Context {
+		return auth.WithCredential(context.Background(), &auth.Credential{AppKey: id, MerchantID: id})
+	}
+
+	cases := []struct {
+		ctx       context.Context
+		channel   payment.ChannelType
+		operation payment.Operation
+		scope     string
+	}{
+		{merchant("m1"), payment.ChannelWechat, payment.OpPay, ""},
+		{merchant("m1"), payment.ChannelWechat, payment.OpQuery, "merchant:wechat"},
+		{merchant("m2"), payment.ChannelWechat, payment.OpPay, ""},
+		{context.Background(), payment.ChannelWechat, payment.OpPay, "channel:wechat"},
+		{context.Background(), payment.ChannelAlipay, payment.OpPay, ""}, // 渠道未配置限额
+		{merchant("m1"), payment.ChannelWechat, payment.OpNotify, ""},    // 通知不调用渠道接口
+	}
+	for i, tc := range cases {
+		before := calls
+		err := handler(tc.ctx, &payment.Call{Channel: tc.channel, Operation: tc.operation})
+		var limited *payment.RateLimitError
+		switch {
+		case tc.scope == "" && (err != nil || calls != before+1):
+			t.Fatalf("call %d: err = %v, want passed to channel", i, err)
+		case tc.scope != "" && (!errors.As(err, &limited) || limited.Scope != tc.scope || calls != before):
+			t.Fatalf("call %d: err = %v, want rate limited by %s without calling channel", i, err, tc.scope)
+		}
+	}
+	if totals := out.Totals(); totals["per_merchant"] != 1 || totals["channel:wechat"] != 1 {
+		t.Fatalf("totals = %v", totals)
+	}
+}
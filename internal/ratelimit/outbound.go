@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/auth"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// Outbound 调用渠道接口的出站限流
+// 渠道限额对应渠道侧的 QPS 配额，所有商户共用；商户限额按渠道 + 商户计，避免单个商户占满渠道配额
+type Outbound struct {
+	channels    map[string]*Limiter
+	perMerchant *Limiter
+}
+
+// NewOutbound 创建出站限流
+func NewOutbound(cfg configs.RateLimitConfig) *Outbound {
+	out := &Outbound{
+		channels:    make(map[string]*Limiter, len(cfg.Channels)),
+		perMerchant: NewLimiter(cfg.PerMerchant),
+	}
+	for channel, limit := range cfg.Channels {
+		if l := NewLimiter(limit); l != nil {
+			out.channels[channel] = l
+		}
+	}
+	return out
+}
+
+// Middleware 出站限流中间件，超限时不调用渠道，直接返回 *payment.RateLimitError
+// 商户取自请求上下文中的凭证，未鉴权的调用只受渠道限额约束
+// 应在重试和熔断之外注册，被限流的调用不重试也不计入熔断统计
+func (out *Outbound) Middleware() payment.Middleware {
+	return func(next payment.Handler) payment.Handler {
+		return func(ctx context.Context, call *payment.Call) error {
+			if call.Operation.Outbound() {
+				if err := out.allow(ctx, string(call.Channel)); err != nil {
+					return err
+				}
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+// allow 先检查商户限额，避免超限商户的请求消耗渠道配额；被渠道限额拒绝时归还商户令牌
+func (out *Outbound) allow(ctx context.Context, channel string) error {
+	key := ""
+	if merchant := auth.MerchantFromContext(ctx); merchant != "" {
+		key = channel + ":" + merchant
+		if ok, wait := out.perMerchant.Allow(key); !ok {
+			return &payment.RateLimitError{Scope: "merchant:" + channel, RetryAfter: wait}
+		}
+	}
+	if ok, wait := out.channels[channel].Allow(channel); !ok {
+		if key != "" {
+			out.perMerchant.refund(key)
+		}
+		return &payment.RateLimitError{Scope: "channel:" + channel, RetryAfter: wait}
+	}
+	return nil
+}
+
+// Stats 出站限流统计
+func (out *Outbound) Stats() []Stat {
+	result := stats("per_merchant", out.perMerchant)
+	for _, channel := range sortedKeys(out.channels) {
+		result = append(result, stats("channel:"+channel, out.channels[channel])...)
+	}
+	return result
+}
+
+// Totals 按维度汇总的累计拒绝次数
+func (out *Outbound) Totals() map[string]uint64 {
+	totals := make(map[string]uint64)
+	addTotal(totals, "per_merchant", out.perMerchant)
+	for channel, l := range out.channels {
+		addTotal(totals, "channel:"+channel, l)
+	}
+	return totals
+}
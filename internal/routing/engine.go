@@ -0,0 +1,238 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// ChannelAuto 由路由引擎自动选择渠道
+const ChannelAuto payment.ChannelType = "auto"
+
+// ruleDefault 未命中任何规则时的规则名
+const ruleDefault = "default"
+
+// allChannels 未配置默认候选渠道时的兜底顺序
+var allChannels = []payment.ChannelType{
+	payment.ChannelWechat,
+	payment.ChannelAlipay,
+	payment.ChannelUnionPay,
+}
+
+// HealthSource 渠道可用状态，由 PaymentGateway 实现
+type HealthSource interface {
+	GetChannelStatus(channel payment.ChannelType) payment.ChannelStatus
+}
+
+// Request 路由输入
+type Request struct {
+	Scene      payment.PayScene
+	Amount     float64
+	UserAgent  string
+	App        string
+	MerchantID string
+}
+
+// Candidate 候选渠道的评估结果
+type Candidate struct {
+	Channel     payment.ChannelType `json:"channel"`
+	Eligible    bool                `json:"eligible"`
+	Reason      string              `json:"reason,omitempty"`
+	SuccessRate float64             `json:"success_rate"`
+	Samples     int                 `json:"samples"`
+}
+
+// Decision 路由结果及决策过程
+type Decision struct {
+	Channel    payment.ChannelType `json:"channel,omitempty"`
+	Rule       string              `json:"rule"`
+	Candidates []Candidate         `json:"candidates"`
+	Explain    []string            `json:"explain"`
+}
+
+// Reason 选择原因的简短描述
+func (d *Decision) Reason() string {
+	if len(d.Explain) == 0 {
+		return ""
+	}
+	return d.Explain[len(d.Explain)-1]
+}
+
+// Engine 规则路由引擎
+// 按顺序匹配规则得到候选渠道，再按商户偏好调整顺序，过滤不可用和成功率过低的渠道后取第一个
+type Engine struct {
+	cfg    configs.RoutingConfig
+	health HealthSource
+	stats  *Stats
+}
+
+// NewEngine 创建路由引擎
+func NewEngine(cfg configs.RoutingConfig, health HealthSource) *Engine {
+	return &Engine{
+		cfg:    cfg,
+		health: health,
+		stats:  NewStats(cfg.Window),
+	}
+}
+
+// Record 记录渠道下单结果，用于成功率统计
+// 只有渠道、网络和熔断错误计为失败；参数错误和业务错误不说明渠道质量，不计入统计
+func (e *Engine) Record(channel payment.ChannelType, err error) {
+	if err != nil && !payment.IsChannelFailure(err) {
+		return
+	}
+	e.stats.Record(channel, err == nil)
+}
+
+// Route 选择渠道
+// 没有可用渠道时返回 ErrChannelUnavailable，同时返回决策过程便于排查
+func (e *Engine) Route(req *Request) (*Decision, error) {
+	decision := &Decision{Rule: ruleDefault}
+
+	candidates := e.defaultChannels()
+	for _, rule := range e.cfg.Rules {
+		if ok, reason := matchRule(rule, req); !ok {
+			decision.explain("rule %s skipped: %s", rule.Name, reason)
+			continue
+		}
+		decision.Rule = rule.Name
+		candidates = toChannels(rule.Channels)
+		decision.explain("rule %s matched, candidates %v", rule.Name, candidates)
+		break
+	}
+	if decision.Rule == ruleDefault {
+		decision.explain("no rule matched, default candidates %v", candidates)
+	}
+
+	if preferred, ok := e.cfg.Merchants[req.MerchantID]; ok && req.MerchantID != "" {
+		candidates = prefer(candidates, toChannels(preferred))
+		decision.explain("merchant %s prefers %v, candidates %v", req.MerchantID, preferred, candidates)
+	}
+
+	for _, channel := range candidates {
+		candidate := e.evaluate(channel)
+		decision.Candidates = append(decision.Candidates, candidate)
+		if decision.Channel == "" && candidate.Eligible {
+			decision.Channel = channel
+		}
+	}
+
+	if decision.Channel == "" {
+		decision.explain("no eligible channel")
+		return decision, fmt.Errorf("%w: no eligible channel for auto routing", payment.ErrChannelUnavailable)
+	}
+
+	decision.explain("selected %s by rule %s", decision.Channel, decision.Rule)
+	return decision, nil
+}
+
+// evaluate 检查渠道状态和成功率
+func (e *Engine) evaluate(channel payment.ChannelType) Candidate {
+	rate, samples := e.stats.SuccessRate(channel)
+	candidate := Candidate{
+		Channel:     channel,
+		SuccessRate: rate,
+		Samples:     samples,
+	}
+
+	switch status := e.health.GetChannelStatus(channel); {
+	case status.State != payment.ChannelStateReady:
+		candidate.Reason = fmt.Sprintf("channel %s", status.State)
+	case status.Breaker != nil && status.Breaker.State == payment.BreakerOpen:
+		candidate.Reason = "circuit breaker open"
+	case e.cfg.MinSuccessRate > 0 && samples >= e.cfg.MinSamples && rate < e.cfg.MinSuccessRate:
+		candidate.Reason = fmt.Sprintf("success rate %.2f below %.2f", rate, e.cfg.MinSuccessRate)
+	default:
+		candidate.Eligible = true
+	}
+	return candidate
+}
+
+// defaultChannels 未命中规则时的候选渠道
+func (e *Engine) defaultChannels() []payment.ChannelType {
+	if len(e.cfg.Default) > 0 {
+		return toChannels(e.cfg.Default)
+	}
+	return allChannels
+}
+
+func (d *Decision) explain(format string, args ...interface{}) {
+	d.Explain = append(d.Explain, fmt.Sprintf(format, args...))
+}
+
+// matchRule 判断请求是否命中规则，未命中时返回原因
+func matchRule(rule configs.RoutingRule, req *Request) (bool, string) {
+	if len(rule.Scenes) > 0 && !contains(rule.Scenes, string(req.Scene)) {
+		return false, fmt.Sprintf("scene %s not in %v", req.Scene, rule.Scenes)
+	}
+	if req.Amount < rule.MinAmount {
+		return false, fmt.Sprintf("amount %.2f below %.2f", req.Amount, rule.MinAmount)
+	}
+	if rule.MaxAmount > 0 && req.Amount >= rule.MaxAmount {
+		return false, fmt.Sprintf("amount %.2f not below %.2f", req.Amount, rule.MaxAmount)
+	}
+	if len(rule.UserAgents) > 0 && !containsKeyword(req.UserAgent, rule.UserAgents) {
+		return false, fmt.Sprintf("user agent does not contain any of %v", rule.UserAgents)
+	}
+	if len(rule.Apps) > 0 && !contains(rule.Apps, req.App) {
+		return false, fmt.Sprintf("app %q not in %v", req.App, rule.Apps)
+	}
+	if len(rule.Merchants) > 0 && !contains(rule.Merchants, req.MerchantID) {
+		return false, fmt.Sprintf("merchant %q not in %v", req.MerchantID, rule.Merchants)
+	}
+	return true, ""
+}
+
+// prefer 把偏好渠道按偏好顺序提到前面，其余渠道保持原顺序
+func prefer(candidates, preferred []payment.ChannelType) []payment.ChannelType {
+	result := make([]payment.ChannelType, 0, len(candidates))
+	for _, channel := range preferred {
+		if containsChannel(candidates, channel) {
+			result = append(result, channel)
+		}
+	}
+	for _, channel := range candidates {
+		if !containsChannel(result, channel) {
+			result = append(result, channel)
+		}
+	}
+	return result
+}
+
+func toChannels(names []string) []payment.ChannelType {
+	channels := make([]payment.ChannelType, len(names))
+	for i, name := range names {
+		channels[i] = payment.ChannelType(name)
+	}
+	return channels
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsChannel(channels []payment.ChannelType, channel payment.ChannelType) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKeyword(s string, keywords []string) bool {
+	s = strings.ToLower(s)
+	for _, keyword := range keywords {
+		if strings.Contains(s, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// fakeHealth 预设的渠道状态，未设置的渠道视为可用
+type fakeHealth map[payment.ChannelType]payment.ChannelStatus
+
+func (h fakeHealth) GetChannelStatus(channel payment.ChannelType) payment.ChannelStatus {
+	if status, ok := h[channel]; ok {
+		return status
+	}
+	return payment.ChannelStatus{Channel: channel, State: payment.ChannelStateReady}
+}
+
+func TestRoute(t *testing.T) {
+	cfg := configs.RoutingConfig{
+		Default:        []string{"alipay", "wechat", "unionpay"},
+		Merchants:      map[string][]string{"m-unionpay": {"unionpay"}},
+		MinSuccessRate: 0.5,
+		MinSamples:     4,
+		Window:         time.Minute,
+		Rules: []configs.RoutingRule{
+			{Name: "wechat-browser", UserAgents: []string{"MicroMessenger"}, Channels: []string{"wechat"}},
+			{Name: "large-pc", Scenes: []string{"pc"}, MinAmount: 1000, Channels: []string{"unionpay", "alipay"}},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		health fakeHealth
+		req    Request
+		want   payment.ChannelType
+		rule   string
+	}{
+		{"default", nil, Request{Scene: payment.SceneH5, Amount: 10}, payment.ChannelAlipay, ruleDefault},
+		{"user agent rule", nil, Request{Scene: payment.SceneH5, Amount: 10, UserAgent: "Mozilla micromessenger/8.0"}, payment.ChannelWechat, "wechat-browser"},
+		{"amount below rule", nil, Request{Scene: payment.ScenePC, Amount: 999.99}, payment.ChannelAlipay, ruleDefault},
+		{"amount rule", nil, Request{Scene: payment.ScenePC, Amount: 1000}, payment.ChannelUnionPay, "large-pc"},
+		{"merchant preference", nil, Request{Scene: payment.SceneH5, Amount: 10, MerchantID: "m-unionpay"}, payment.ChannelUnionPay, ruleDefault},
+		{"skip failed channel", fakeHealth{
+			payment.ChannelAlipay: {State: payment.ChannelStateFailed},
+		}, Request{Scene: payment.SceneH5, Amount: 10}, payment.ChannelWechat, ruleDefault},
+		{"skip open breaker", fakeHealth{
+			payment.ChannelUnionPay: {State: payment.ChannelStateReady, Breaker: &payment.BreakerStatus{State: payment.BreakerOpen}},
+		}, Request{Scene: payment.ScenePC, Amount: 1000}, payment.ChannelAlipay, "large-pc"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			decision, err := NewEngine(cfg, tc.health).Route(&tc.req)
+			if err != nil {
+				t.Fatalf("route: %v\n%v", err, decision.Explain)
+			}
+			if decision.Channel != tc.want || decision.Rule != tc.rule {
+				t.Fatalf("selected %s by %s, want %s by %s\n%v", decision.Channel, decision.Rule, tc.want, tc.rule, decision.Explain)
+			}
+		})
+	}
+}
+
+func TestRouteNoEligibleChannel(t *testing.T) {
+	health := fakeHealth{}
+	for _, channel := range allChannels {
+		health[channel] = payment.ChannelStatus{State: payment.ChannelStateDisabled}
+	}
+
+	decision, err := NewEngine(configs.RoutingConfig{}, health).Route(&Request{Scene: payment.SceneH5, Amount: 1})
+	if !errors.Is(err, payment.ErrChannelUnavailable) {
+		t.Fatalf("err = %v, want %v", err, payment.ErrChannelUnavailable)
+	}
+	if len(decision.Candidates) != len(allChannels) || decision.Reason() != "no eligible channel" {
+		t.Fatalf("decision = %+v", decision)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		// 记录 4 次后的样本数和成功率
+		samples int
+		rate    float64
+	}{
+		{"success", nil, 4, 1},
+		{"network error", fmt.Errorf("%w: connection reset", payment.ErrNetworkError), 4, 0},
+		{"channel system error", payment.NewChannelError(payment.ChannelAlipay, "ACQ.SYSTEM_ERROR", "系统错误", payment.ErrSystemError), 4, 0},
+		{"circuit open", fmt.Errorf("%w: alipay", payment.ErrCircuitOpen), 4, 0},
+		{"order paid", payment.NewChannelError(payment.ChannelAlipay, "ACQ.TRADE_HAS_SUCCESS", "交易已支付", payment.ErrOrderPaid), 0, 1},
+		{"invalid parameter", payment.NewChannelError(payment.ChannelAlipay, "ACQ.INVALID_PARAMETER", "参数无效", payment.ErrInvalidParameter), 0, 1},
+		{"invalid amount", payment.ErrInvalidAmount, 0, 1},
+		{"rate limited", &payment.RateLimitError{Scope: "alipay"}, 0, 1},
+		{"canceled", context.Canceled, 0, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := NewEngine(configs.RoutingConfig{Window: time.Minute}, fakeHealth{})
+			for i := 0; i < 4; i++ {
+				engine.Record(payment.ChannelAlipay, tc.err)
+			}
+
+			rate, samples := engine.stats.SuccessRate(payment.ChannelAlipay)
+			if samples != tc.samples || rate != tc.rate {
+				t.Fatalf("rate = %.2f with %d samples, want %.2f with %d", rate, samples, tc.rate, tc.samples)
+			}
+		})
+	}
+}
+
+func TestRecordFiltersLowSuccessRate(t *testing.T) {
+	cfg := configs.RoutingConfig{
+		Default:        []string{"alipay", "wechat"},
+		MinSuccessRate: 0.5,
+		MinSamples:     4,
+		Window:         time.Minute,
+	}
+	engine := NewEngine(cfg, fakeHealth{})
+	req := &Request{Scene: payment.SceneH5, Amount: 10}
+
+	// 业务错误不影响渠道选择
+	for i := 0; i < 4; i++ {
+		engine.Record(payment.ChannelAlipay, payment.ErrOrderPaid)
+	}
+	if decision, _ := engine.Route(req); decision.Channel != payment.ChannelAlipay {
+		t.Fatalf("selected %s after business errors, want alipay", decision.Channel)
+	}
+
+	for i := 0; i < 4; i++ {
+		engine.Record(payment.ChannelAlipay, payment.ErrTimeout)
+	}
+	decision, err := engine.Route(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Channel != payment.ChannelWechat || decision.Candidates[0].Eligible {
+		t.Fatalf("selected %s after channel failures, want wechat\n%v", decision.Channel, decision.Explain)
+	}
+}
@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// outcome 一次下单结果
+type outcome struct {
+	at      time.Time
+	success bool
+}
+
+// Stats 按渠道统计滑动窗口内的下单成功率
+type Stats struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	outcomes map[payment.ChannelType][]outcome
+}
+
+// NewStats 创建成功率统计
+func NewStats(window time.Duration) *Stats {
+	return &Stats{
+		window:   window,
+		outcomes: make(map[payment.ChannelType][]outcome),
+	}
+}
+
+// Record 记录一次下单结果
+func (s *Stats) Record(channel payment.ChannelType, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.outcomes[channel] = append(s.prune(channel, now), outcome{at: now, success: success})
+}
+
+// SuccessRate 返回窗口内的成功率和样本数，没有样本时成功率为 1
+func (s *Stats) SuccessRate(channel payment.ChannelType) (float64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outcomes := s.prune(channel, time.Now())
+	s.outcomes[channel] = outcomes
+	if len(outcomes) == 0 {
+		return 1, 0
+	}
+
+	var succeeded int
+	for _, o := range outcomes {
+		if o.success {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(len(outcomes)), len(outcomes)
+}
+
+// prune 丢弃窗口外的记录，调用方需持有锁
+func (s *Stats) prune(channel payment.ChannelType, now time.Time) []outcome {
+	outcomes := s.outcomes[channel]
+	cutoff := now.Add(-s.window)
+
+	i := 0
+	for i < len(outcomes) && outcomes[i].at.Before(cutoff) {
+		i++
+	}
+	return outcomes[i:]
+}
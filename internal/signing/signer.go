@@ -0,0 +1,157 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
+)
+
+// 网关签名相关响应头，事件推送使用相同的请求头
+const (
+	HeaderSignature = "X-Gateway-Signature" // base64 编码的 Ed25519 签名
+	HeaderTimestamp = "X-Gateway-Timestamp" // Unix 秒
+	HeaderKeyID     = "X-Gateway-Key-Id"    // 签名密钥标识，对应公钥列表中的 key_id
+)
+
+// Algorithm 签名算法
+const Algorithm = "Ed25519"
+
+// WellKnownPath 网关公钥发布地址
+const WellKnownPath = "/.well-known/payment-gateway-keys.json"
+
+// PublicKey 对外发布的公钥
+type PublicKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"` // base64 编码的 32 字节公钥
+}
+
+// KeySet 公钥列表
+type KeySet struct {
+	Keys []PublicKey `json:"keys"`
+}
+
+// Message 待签名内容：时间戳、换行、原始 body
+func Message(timestamp string, body []byte) []byte {
+	msg := make([]byte, 0, len(timestamp)+1+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, '\n')
+	return append(msg, body...)
+}
+
+// Verify 校验签名
+func Verify(key ed25519.PublicKey, timestamp string, body []byte, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key, Message(timestamp, body), sig)
+}
+
+// Fingerprint 公钥指纹，用作默认的 key_id
+func Fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Signer 网关签名器
+type Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewSigner 创建签名器，keyID 为空时使用公钥指纹
+func NewSigner(keyID string, key ed25519.PrivateKey) *Signer {
+	if keyID == "" {
+		keyID = Fingerprint(key.Public().(ed25519.PublicKey))
+	}
+	return &Signer{keyID: keyID, key: key}
+}
+
+// GenerateSigner 生成临时密钥的签名器，重启后密钥会变化，仅用于开发环境
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key failed: %w", err)
+	}
+	return NewSigner("", key), nil
+}
+
+// LoadSigner 按配置加载签名器，未配置私钥时生成临时密钥
+func LoadSigner(ctx context.Context, cfg configs.SigningConfig, secrets secret.SecretProvider) (*Signer, error) {
+	if cfg.PrivateKey == "" {
+		return GenerateSigner()
+	}
+
+	data, err := secret.LoadFile(ctx, secrets, cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("load signing key failed: %w", err)
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(cfg.KeyID, key), nil
+}
+
+// ParsePrivateKey 解析 PKCS#8 PEM 格式的 Ed25519 私钥
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key failed: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key must be Ed25519, got %T", parsed)
+	}
+	return key, nil
+}
+
+// KeyID 密钥标识
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign 对时间戳和 body 签名，返回 base64 编码的签名
+func (s *Signer) Sign(timestamp string, body []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, Message(timestamp, body)))
+}
+
+// SignHeaders 以当前时间签名并设置签名相关的头
+func (s *Signer) SignHeaders(header http.Header, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header.Set(HeaderTimestamp, timestamp)
+	header.Set(HeaderKeyID, s.keyID)
+	header.Set(HeaderSignature, s.Sign(timestamp, body))
+}
+
+// PublicKey 对外发布的公钥
+func (s *Signer) PublicKey() PublicKey {
+	return PublicKey{
+		KeyID:     s.keyID,
+		Algorithm: Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+	}
+}
+
+// KeySet 对外发布的公钥列表
+func (s *Signer) KeySet() KeySet {
+	return KeySet{Keys: []PublicKey{s.PublicKey()}}
+}
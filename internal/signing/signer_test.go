@@ -0,0 +1,201 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/configs"
+)
+
+// newKey 生成测试用的 Ed25519 私钥
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writePEM 将私钥以 PKCS#8 PEM 格式写入临时文件，返回文件路径
+func writePEM(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestMessage 待签名内容为时间戳、换行和原始 body，body 不做任何规范化
+func TestMessage(t *testing.T) {
+	cases := []struct {
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"1700000000", `{"id":"evt_1"}`, "1700000000\n{\"id\":\"evt_1\"}"},
+		{"1700000000", "", "1700000000\n"},
+		{"1700000000", "{ \"b\":1,\n\"a\":2 }\n", "1700000000\n{ \"b\":1,\n\"a\":2 }\n"},
+	}
+	for _, tc := range cases {
+		if got := string(Message(tc.timestamp, []byte(tc.body))); got != tc.want {
+			t.Errorf("Message(%q, %q) = %q, want %q", tc.timestamp, tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := newKey(t)
+	signer := NewSigner("", key)
+	public := key.Public().(ed25519.PublicKey)
+	body := []byte(`{"id":"evt_1"}`)
+	signature := signer.Sign("1700000000", body)
+
+	// 签名是对 Message 的 Ed25519 签名，验签方可以自行拼接待签名内容
+	if raw, _ := base64.StdEncoding.DecodeString(signature); !ed25519.Verify(public, Message("1700000000", body), raw) {
+		t.Fatal("signature is not an Ed25519 signature of Message")
+	}
+
+	cases := []struct {
+		name      string
+		key       ed25519.PublicKey
+		timestamp string
+		body      string
+		signature string
+		valid     bool
+	}{
+		{"valid", public, "1700000000", string(body), signature, true},
+		{"body changed", public, "1700000000", `{"id":"evt_2"}`, signature, false},
+		{"timestamp changed", public, "1700000001", string(body), signature, false},
+		{"other key", newKey(t).Public().(ed25519.PublicKey), "1700000000", string(body), signature, false},
+		{"not base64", public, "1700000000", string(body), "!" + signature, false},
+		{"empty signature", public, "1700000000", string(body), "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Verify(tc.key, tc.timestamp, []byte(tc.body), tc.signature); got != tc.valid {
+				t.Fatalf("Verify = %v, want %v", got, tc.valid)
+			}
+		})
+	}
+}
+
+// TestKeyID 未配置 key_id 时取公钥指纹，轮换密钥后 key_id 随之变化，验签方按 key_id 选择公钥
+func TestKeyID(t *testing.T) {
+	oldKey, rotated := newKey(t), newKey(t)
+	oldSigner, newSigner := NewSigner("", oldKey), NewSigner("", rotated)
+
+	if oldSigner.KeyID() != Fingerprint(oldKey.Public().(ed25519.PublicKey)) || len(oldSigner.KeyID()) != 16 {
+		t.Fatalf("key id = %q, want public key fingerprint", oldSigner.KeyID())
+	}
+	if oldSigner.KeyID() == newSigner.KeyID() {
+		t.Fatalf("rotated key has the same key id %q", newSigner.KeyID())
+	}
+	if got := NewSigner("2024-01", rotated).KeyID(); got != "2024-01" {
+		t.Fatalf("configured key id = %q, want 2024-01", got)
+	}
+
+	// 轮换期间验签方同时持有新旧公钥，按请求头中的 key_id 选择
+	keys := make(map[string]ed25519.PublicKey)
+	for _, s := range []*Signer{oldSigner, newSigner} {
+		pk := s.PublicKey()
+		raw, err := base64.StdEncoding.DecodeString(pk.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[pk.KeyID] = raw
+	}
+	body := []byte(`{"id":"evt_1"}`)
+	for _, s := range []*Signer{oldSigner, newSigner} {
+		header := http.Header{}
+		s.SignHeaders(header, body)
+		if header.Get(HeaderKeyID) != s.KeyID() {
+			t.Fatalf("%s = %q, want %q", HeaderKeyID, header.Get(HeaderKeyID), s.KeyID())
+		}
+		if !Verify(keys[header.Get(HeaderKeyID)], header.Get(HeaderTimestamp), body, header.Get(HeaderSignature)) {
+			t.Fatalf("signature from key %s not verified", s.KeyID())
+		}
+	}
+	header := http.Header{}
+	newSigner.SignHeaders(header, body)
+	if Verify(keys[oldSigner.KeyID()], header.Get(HeaderTimestamp), body, header.Get(HeaderSignature)) {
+		t.Fatal("signature from the new key verified with the old key")
+	}
+}
+
+// TestKeySet 公钥列表文档的 JSON 格式是对外契约，SDK 按该格式解析
+func TestKeySet(t *testing.T) {
+	key := newKey(t)
+	signer := NewSigner("2024-01", key)
+
+	data, err := json.Marshal(signer.KeySet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"keys":[{"key_id":"2024-01","algorithm":"Ed25519","public_key":"` +
+		base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)) + `"}]}`
+	if string(data) != want {
+		t.Fatalf("key set = %s, want %s", data, want)
+	}
+	if WellKnownPath != "/.well-known/payment-gateway-keys.json" {
+		t.Fatalf("well-known path = %s", WellKnownPath)
+	}
+}
+
+func TestLoadSigner(t *testing.T) {
+	key := newKey(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(notPEM, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		cfg   configs.SigningConfig
+		keyID string // 为空时不检查
+		err   bool
+	}{
+		{"configured key", configs.SigningConfig{KeyID: "2024-01", PrivateKey: writePEM(t, key)}, "2024-01", false},
+		{"fingerprint key id", configs.SigningConfig{PrivateKey: writePEM(t, key)}, Fingerprint(key.Public().(ed25519.PublicKey)), false},
+		{"generated key", configs.SigningConfig{}, "", false},
+		{"missing file", configs.SigningConfig{PrivateKey: filepath.Join(t.TempDir(), "missing.pem")}, "", true},
+		{"not pem", configs.SigningConfig{PrivateKey: notPEM}, "", true},
+		{"rsa key", configs.SigningConfig{PrivateKey: writePEM(t, rsaKey)}, "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer, err := LoadSigner(context.Background(), tc.cfg, nil)
+			if tc.err {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.keyID != "" && signer.KeyID() != tc.keyID {
+				t.Fatalf("key id = %q, want %q", signer.KeyID(), tc.keyID)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware 为每次渠道调用创建 span，适配器中 SDK 发出的 HTTP 请求作为其子 span
+// 应注册在重试之外，一次调用的所有尝试归在同一个 span 下
+func Middleware() payment.Middleware {
+	return func(next payment.Handler) payment.Handler {
+		return func(ctx context.Context, call *payment.Call) error {
+			attrs := []attribute.KeyValue{
+				attribute.String("payment.channel", string(call.Channel)),
+				attribute.String("payment.operation", string(call.Operation)),
+			}
+			if req, ok := call.Request.(*payment.UnifiedPayRequest); ok {
+				attrs = append(attrs,
+					attribute.String("payment.scene", string(req.Scene)),
+					attribute.String("payment.out_trade_no", req.OutTradeNo))
+			}
+
+			ctx, span := Tracer().Start(ctx, "payment."+string(call.Operation),
+				trace.WithSpanKind(trace.SpanKindInternal),
+				trace.WithAttributes(attrs...))
+			defer span.End()
+
+			err := next(ctx, call)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				span.SetAttributes(attribute.String("payment.error_code", payment.ErrorCodeOf(err).Code))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupInMemory 使用内存导出器初始化全局 TracerProvider，所有 span 同步导出
+func setupInMemory(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+// attr span 上指定属性的值
+func attr(span tracetest.SpanStub, key attribute.Key) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestMiddleware(t *testing.T) {
+	cases := []struct {
+		name   string
+		call   *payment.Call
+		err    error
+		status codes.Code
+		attrs  map[attribute.Key]string
+	}{
+		{"pay", &payment.Call{
+			Channel:   payment.ChannelWechat,
+			Operation: payment.OpPay,
+			Request:   &payment.UnifiedPayRequest{OutTradeNo: "ORDER_1", Scene: payment.SceneNative},
+		}, nil, codes.Unset, map[attribute.Key]string{
+			"payment.channel":      "wechat",
+			"payment.operation":    "pay",
+			"payment.scene":        "native",
+			"payment.out_trade_no": "ORDER_1",
+		}},
+		{"refund error", &payment.Call{
+			Channel:   payment.ChannelAlipay,
+			Operation: payment.OpRefund,
+			Request:   &payment.RefundRequest{OutTradeNo: "ORDER_1"},
+		}, payment.RefundNotAllowed, codes.Error, map[attribute.Key]string{
+			"payment.channel":    "alipay",
+			"payment.operation":  "refund",
+			"payment.error_code": "1010",
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter := setupInMemory(t)
+			var inner trace.SpanContext
+			handler := Middleware()(func(ctx context.Context, call *payment.Call) error {
+				inner = trace.SpanContextFromContext(ctx)
+				return tc.err
+			})
+			if err := handler(context.Background(), tc.call); err != tc.err {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("spans = %d, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name != "payment."+string(tc.call.Operation) || span.Status.Code != tc.status {
+				t.Fatalf("span %s status = %v, want %v", span.Name, span.Status.Code, tc.status)
+			}
+			if span.SpanContext.SpanID() != inner.SpanID() {
+				t.Fatal("span not passed to next handler")
+			}
+			for key, want := range tc.attrs {
+				if got := attr(span, key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestTransport 渠道请求创建 client span，并通过 traceparent 把该 span 传给渠道
+func TestTransport(t *testing.T) {
+	exporter := setupInMemory(t)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	ctx, parent := Tracer().Start(context.Background(), "payment.pay")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL, nil)
+	resp, err := HTTPClient(0).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d, want 2", len(spans))
+	}
+	client := spans[0]
+	if client.SpanKind != trace.SpanKindClient || client.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("client span = %s (%v), parent %s", client.Name, client.SpanKind, client.Parent.SpanID())
+	}
+	want := "00-" + parent.SpanContext().TraceID().String() + "-" + client.SpanContext.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Fatalf("traceparent = %q, want %q", traceparent, want)
+	}
+}
+
+func TestTraceID(t *testing.T) {
+	setupInMemory(t)
+	if id := TraceID(context.Background()); id != "" {
+		t.Fatalf("trace id without span = %q", id)
+	}
+	ctx, span := Tracer().Start(context.Background(), "test")
+	defer span.End()
+	if id := TraceID(ctx); id != span.SpanContext().TraceID().String() {
+		t.Fatalf("trace id = %q, want %s", id, span.SpanContext().TraceID())
+	}
+}
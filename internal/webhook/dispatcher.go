@@ -0,0 +1,327 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/signing"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	logger "github.com/ymqzj/payment-gateway/logs"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// maxAttempts 单个事件的最大投递次数
+	maxAttempts = 5
+	// minBackoff 首次重投前的等待时间，之后每次翻倍
+	minBackoff = 2 * time.Second
+	// deliveryTimeout 单次投递超时
+	deliveryTimeout = 10 * time.Second
+	// idleInterval 没有待投递事件时检查队列的间隔
+	idleInterval = time.Minute
+)
+
+// HeaderEventID 事件 ID 请求头，商户可用于去重
+const HeaderEventID = "X-Gateway-Event-Id"
+
+// 事件类型
+const (
+	EventPaymentUpdated = "payment.updated" // 收到渠道支付通知
+	EventCertExpiring   = "cert.expiring"   // 渠道证书即将过期或已过期
+)
+
+// PaymentData payment.updated 事件数据
+type PaymentData struct {
+	OutTradeNo  string              `json:"out_trade_no"`
+	OrderID     string              `json:"order_id,omitempty"` // 渠道订单号
+	MerchantID  string              `json:"merchant_id,omitempty"`
+	Channel     payment.ChannelType `json:"channel"`
+	Success     bool                `json:"success"`
+	TradeStatus string              `json:"trade_status"`
+	TotalAmount float64             `json:"total_amount"`
+	PayTime     *time.Time          `json:"pay_time,omitempty"`
+}
+
+// Event 推送给商户的事件
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// NewEvent 创建事件
+func NewEvent(eventType string, data interface{}) *Event {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return &Event{
+		ID:        "evt_" + hex.EncodeToString(buf),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+}
+
+// Dispatcher 商户事件推送，请求体使用网关密钥签名
+// 商户返回 2xx 视为成功，否则按指数退避重投；待投递事件保存在队列中，重启后继续投递
+type Dispatcher struct {
+	client  *http.Client
+	signer  *signing.Signer
+	policy  Policy
+	queue   Queue
+	now     func() time.Time
+	backoff time.Duration
+
+	mu       sync.Mutex
+	observe  func(Delivery)
+	inflight map[string]bool
+	wake     chan struct{}
+}
+
+// NewDispatcher 创建事件推送器，推送地址受 policy 限制，queue 为空时只能使用 Deliver 直接投递
+func NewDispatcher(signer *signing.Signer, policy Policy, queue Queue) *Dispatcher {
+	return &Dispatcher{
+		client: &http.Client{
+			Timeout:   deliveryTimeout,
+			Transport: tracing.Transport(policy.transport()),
+			// 不跟随重定向，避免经由商户地址跳转到内网
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		signer:   signer,
+		policy:   policy,
+		queue:    queue,
+		now:      time.Now,
+		backoff:  minBackoff,
+		inflight: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// CheckURL 校验推送地址，下单时调用以便尽早拒绝不允许的地址
+func (d *Dispatcher) CheckURL(url string) error {
+	return d.policy.Check(url)
+}
+
+// OnDelivery 设置投递结果回调，每次投递后调用，用于记录投递历史；需要在 Start 之前设置
+func (d *Dispatcher) OnDelivery(observe func(Delivery)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.observe = observe
+}
+
+// Deliver 投递一次事件，不重投
+func (d *Dispatcher) Deliver(ctx context.Context, url string, event *Event) error {
+	if err := d.policy.Check(url); err != nil {
+		return err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event failed: %w", err)
+	}
+	return d.post(ctx, url, event.ID, body)
+}
+
+// post 发送签名后的事件请求
+func (d *Dispatcher) post(ctx context.Context, url, eventID string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, eventID)
+	d.signer.SignHeaders(req.Header, body)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver webhook failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("deliver webhook failed with status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Delivery 一次投递的结果
+type Delivery struct {
+	EventID string
+	URL     string
+	Ref     string // Dispatch 时传入的业务标识
+	Attempt int
+	Time    time.Time
+	Err     error
+	Final   bool // 投递成功或重投次数已用尽，之后不再投递
+}
+
+// Dispatch 校验地址后将事件写入队列，由 Start 启动的后台任务投递，失败时重投
+// ref 为关联的业务标识，随投递结果返回给 OnDelivery 设置的回调
+func (d *Dispatcher) Dispatch(url string, event *Event, ref string) error {
+	if d.queue == nil {
+		return errors.New("webhook queue is not configured")
+	}
+	if err := d.policy.Check(url); err != nil {
+		return err
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event failed: %w", err)
+	}
+
+	err = d.queue.Save(&Pending{
+		EventID: event.ID,
+		URL:     url,
+		Ref:     ref,
+		Body:    body,
+		NextAt:  d.now(),
+	})
+	if err != nil {
+		return fmt.Errorf("enqueue event failed: %w", err)
+	}
+	d.notify()
+	return nil
+}
+
+// notify 唤醒后台任务检查队列
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start 启动后台投递任务，先投递重启前未完成的事件，ctx 取消后停止
+func (d *Dispatcher) Start(ctx context.Context) {
+	if d.queue == nil {
+		return
+	}
+	if pending, err := d.queue.List(); err == nil && len(pending) > 0 {
+		logger.GetLogger().Info("resuming webhook deliveries", zap.Int("pending", len(pending)))
+	}
+	go d.run(ctx)
+}
+
+// run 投递到期的事件，然后等待下一个事件到期或有新事件入队
+func (d *Dispatcher) run(ctx context.Context) {
+	for {
+		wait := idleInterval
+		if next, ok := d.deliverDue(ctx); ok {
+			wait = next.Sub(d.now())
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// deliverDue 在后台投递所有到期且未在投递中的事件，返回最早的下次投递时间
+func (d *Dispatcher) deliverDue(ctx context.Context) (time.Time, bool) {
+	pending, err := d.queue.List()
+	if err != nil {
+		logger.GetLogger().Error("list webhook queue failed", zap.Error(err))
+		return time.Time{}, false
+	}
+
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var next time.Time
+	var waiting bool
+	for _, p := range pending {
+		if d.inflight[p.EventID] {
+			continue
+		}
+		if p.NextAt.After(now) {
+			if !waiting || p.NextAt.Before(next) {
+				next, waiting = p.NextAt, true
+			}
+			continue
+		}
+		d.inflight[p.EventID] = true
+		go d.attempt(ctx, p)
+	}
+	return next, waiting
+}
+
+// attempt 投递一次并更新队列，成功或次数用尽后移出队列，否则按退避时间重新排队
+func (d *Dispatcher) attempt(ctx context.Context, p *Pending) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.inflight, p.EventID)
+		d.mu.Unlock()
+		d.notify()
+	}()
+
+	attemptCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	err := d.post(attemptCtx, p.URL, p.EventID, p.Body)
+	cancel()
+	if ctx.Err() != nil {
+		// 停止时中断的投递不计次数，重启后重新投递
+		return
+	}
+
+	log := logger.GetLogger().With(
+		zap.String("event_id", p.EventID),
+		zap.String("url", p.URL))
+
+	p.Attempt++
+	final := err == nil || p.Attempt >= maxAttempts || errors.Is(err, ErrURLNotAllowed)
+	var qerr error
+	if final {
+		qerr = d.queue.Remove(p.EventID)
+	} else {
+		p.NextAt = d.now().Add(d.backoff << (p.Attempt - 1))
+		qerr = d.queue.Save(p)
+	}
+	if qerr != nil {
+		log.Error("update webhook queue failed", zap.Error(qerr))
+	}
+
+	switch {
+	case err == nil:
+		log.Info("webhook delivered", zap.Int("attempt", p.Attempt))
+	case final:
+		log.Error("webhook delivery failed", zap.Int("attempts", p.Attempt), zap.Error(err))
+	default:
+		log.Warn("webhook delivery failed, will retry",
+			zap.Int("attempt", p.Attempt),
+			zap.Time("next_at", p.NextAt),
+			zap.Error(err))
+	}
+
+	d.mu.Lock()
+	observe := d.observe
+	d.mu.Unlock()
+	if observe != nil {
+		observe(Delivery{
+			EventID: p.EventID,
+			URL:     p.URL,
+			Ref:     p.Ref,
+			Attempt: p.Attempt,
+			Time:    d.now(),
+			Err:     err,
+			Final:   final,
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/signing"
+	"github.com/ymqzj/payment-gateway/pkg/sdk"
+)
+
+// localPolicy 允许推送到 httptest 的本地地址
+var localPolicy = Policy{Schemes: []string{"http"}, AllowPrivate: true}
+
+// merchant 模拟商户的事件接收地址，前 failures 次返回 500，收到的请求体经 SDK 验签后写入 received
+type merchant struct {
+	*httptest.Server
+	failures int32
+	requests atomic.Int32
+	received chan *sdk.Event
+}
+
+func newMerchant(t *testing.T, signer *signing.Signer, failures int32) *merchant {
+	t.Helper()
+
+	key := signer.PublicKey()
+	verifier, err := sdk.NewVerifier(sdk.PublicKey{KeyID: key.KeyID, Algorithm: key.Algorithm, PublicKey: key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &merchant{failures: failures, received: make(chan *sdk.Event, 16)}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event, err := verifier.ParseWebhook(r)
+		if err != nil {
+			t.Errorf("verify webhook: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get(HeaderEventID) != event.ID {
+			t.Errorf("event id header = %q, want %q", r.Header.Get(HeaderEventID), event.ID)
+		}
+		if m.requests.Add(1) <= m.failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		m.received <- event
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// startDispatcher 使用毫秒级退避的推送器，投递结果写入返回的通道
+func startDispatcher(t *testing.T, signer *signing.Signer, queue Queue) (*Dispatcher, chan Delivery) {
+	t.Helper()
+
+	d := NewDispatcher(signer, localPolicy, queue)
+	d.backoff = time.Millisecond
+	deliveries := make(chan Delivery, 16)
+	d.OnDelivery(func(delivery Delivery) { deliveries <- delivery })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	d.Start(ctx)
+	return d, deliveries
+}
+
+func newSigner(t *testing.T) *signing.Signer {
+	t.Helper()
+	signer, err := signing.GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// waitDelivery 等待下一次投递结果
+func waitDelivery(t *testing.T, deliveries chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delivery")
+		return Delivery{}
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	cases := []struct {
+		name      string
+		failures  int32
+		attempts  int
+		delivered bool
+	}{
+		{"first attempt", 0, 1, true},
+		{"after retries", 2, 3, true},
+		{"gives up", maxAttempts, maxAttempts, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer := newSigner(t)
+			m := newMerchant(t, signer, tc.failures)
+			queue := NewMemoryQueue()
+			d, deliveries := startDispatcher(t, signer, queue)
+
+			event := NewEvent(EventPaymentUpdated, &PaymentData{OutTradeNo: "ORDER_1", Success: true})
+			if err := d.Dispatch(m.URL, event, "ORDER_1"); err != nil {
+				t.Fatal(err)
+			}
+
+			for attempt := 1; attempt <= tc.attempts; attempt++ {
+				delivery := waitDelivery(t, deliveries)
+				final := attempt == tc.attempts
+				if delivery.Attempt != attempt || delivery.Final != final || delivery.Ref != "ORDER_1" || delivery.EventID != event.ID {
+					t.Fatalf("delivery = %+v, want attempt %d final %v", delivery, attempt, final)
+				}
+				if failed := delivery.Err != nil; failed != (!tc.delivered || !final) {
+					t.Fatalf("attempt %d err = %v", attempt, delivery.Err)
+				}
+			}
+
+			if tc.delivered {
+				if got := <-m.received; got.ID != event.ID || got.Type != EventPaymentUpdated {
+					t.Fatalf("received event = %+v", got)
+				}
+			}
+			if pending, _ := queue.List(); len(pending) != 0 {
+				t.Fatalf("queue = %+v after final delivery", pending)
+			}
+		})
+	}
+}
+
+func TestDispatchRejectsURL(t *testing.T) {
+	d := NewDispatcher(newSigner(t), Policy{}, NewMemoryQueue())
+	for _, url := range []string{"http://merchant.example.com/events", "https://10.0.0.1/events", "https://127.0.0.1:8080/events"} {
+		if err := d.Dispatch(url, NewEvent(EventPaymentUpdated, nil), ""); !errors.Is(err, ErrURLNotAllowed) {
+			t.Errorf("dispatch to %s: err = %v, want %v", url, err, ErrURLNotAllowed)
+		}
+	}
+	if pending, _ := d.queue.List(); len(pending) != 0 {
+		t.Fatalf("rejected events queued: %+v", pending)
+	}
+}
+
+// TestDeliverChecksDialedAddress 地址校验通过但连接到内网地址时拒绝，防止域名解析到内网
+func TestDeliverChecksDialedAddress(t *testing.T) {
+	signer := newSigner(t)
+	m := newMerchant(t, signer, 0)
+
+	d := NewDispatcher(signer, Policy{Schemes: []string{"http"}}, nil)
+	err := d.post(context.Background(), m.URL, "evt_1", []byte(`{}`))
+	if !errors.Is(err, ErrURLNotAllowed) {
+		t.Fatalf("err = %v, want %v", err, ErrURLNotAllowed)
+	}
+	if m.requests.Load() != 0 {
+		t.Fatal("request reached private address")
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	signer := newSigner(t)
+	target := newMerchant(t, signer, 0)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+
+	d := NewDispatcher(signer, localPolicy, nil)
+	if err := d.Deliver(context.Background(), redirect.URL, NewEvent(EventCertExpiring, nil)); err == nil {
+		t.Fatal("redirect treated as delivered")
+	}
+	if target.requests.Load() != 0 {
+		t.Fatal("redirect followed")
+	}
+}
+
+// TestDispatchResumesFromQueue 重启前未完成的事件在启动后继续投递，请求体与入队时相同
+func TestDispatchResumesFromQueue(t *testing.T) {
+	signer := newSigner(t)
+	m := newMerchant(t, signer, 0)
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	queue, err := OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := NewEvent(EventPaymentUpdated, &PaymentData{OutTradeNo: "ORDER_1"})
+	// 未启动后台任务，模拟入队后进程退出
+	if err := NewDispatcher(signer, localPolicy, queue).Dispatch(m.URL, event, "ORDER_1"); err != nil {
+		t.Fatal(err)
+	}
+	queue.Close()
+
+	queue, err = OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	_, deliveries := startDispatcher(t, signer, queue)
+
+	if d := waitDelivery(t, deliveries); d.Err != nil || d.Attempt != 1 || d.Ref != "ORDER_1" {
+		t.Fatalf("delivery = %+v", d)
+	}
+	if got := <-m.received; got.ID != event.ID {
+		t.Fatalf("received event %s, want %s", got.ID, event.ID)
+	}
+	if pending, _ := queue.List(); len(pending) != 0 {
+		t.Fatalf("queue = %+v after delivery", pending)
+	}
+}
+
+func TestFileQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	queue, err := OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range []*Pending{
+		{EventID: "evt_a", URL: "https://a.example.com", Body: json.RawMessage(`{"id":"evt_a"}`), NextAt: now.Add(time.Minute)},
+		{EventID: "evt_b", URL: "https://b.example.com", Body: json.RawMessage(`{"id":"evt_b"}`), NextAt: now},
+		{EventID: "evt_a", URL: "https://a.example.com", Body: json.RawMessage(`{"id":"evt_a"}`), Attempt: 2, NextAt: now.Add(time.Minute)},
+		{EventID: "evt_c", URL: "https://c.example.com", Body: json.RawMessage(`{"id":"evt_c"}`), NextAt: now.Add(time.Second)},
+	} {
+		if err := queue.Save(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Remove("evt_b"); err != nil {
+		t.Fatal(err)
+	}
+	queue.Close()
+
+	// 末尾不完整的记录被忽略
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"done":"evt_a"`)
+	file.Close()
+
+	queue, err = OpenFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+
+	pending, err := queue.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].EventID != "evt_c" || pending[1].EventID != "evt_a" || pending[1].Attempt != 2 {
+		t.Fatalf("pending = %+v, want evt_c then evt_a with 2 attempts", pending)
+	}
+	if string(pending[1].Body) != `{"id":"evt_a"}` {
+		t.Fatalf("body = %s", pending[1].Body)
+	}
+
+	// 重新打开时去掉已完成和重复的记录
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Fatalf("queue file has %d lines after compaction, want 2", lines)
+	}
+}
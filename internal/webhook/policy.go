@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrURLNotAllowed 推送地址不在允许范围内
+var ErrURLNotAllowed = errors.New("webhook url not allowed")
+
+// reservedNets net.IP 方法未覆盖的非公网地址段
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级 NAT
+	"192.0.0.0/24",  // IETF 协议分配
+	"198.18.0.0/15", // 基准测试
+	"240.0.0.0/4",   // 保留
+	"64:ff9b::/96",  // NAT64，可映射到内网 IPv4
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+// Policy 推送地址限制，推送地址由商户下单时提供，需要防止网关被用来访问内网
+// 下单时校验协议和主机，建立连接时再校验解析出的 IP，防止域名解析到内网地址
+type Policy struct {
+	Schemes      []string // 允许的协议，为空时只允许 https
+	Hosts        []string // 允许的主机，*.example.com 匹配其子域名，为空时不限制主机
+	AllowPrivate bool     // 是否允许内网、回环、链路本地等非公网地址
+}
+
+// Check 校验推送地址的协议和主机，主机为 IP 时同时校验地址范围
+func (p Policy) Check(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	if !p.schemeAllowed(u.Scheme) {
+		return fmt.Errorf("%w: scheme %q", ErrURLNotAllowed, u.Scheme)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrURLNotAllowed)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: userinfo is not allowed", ErrURLNotAllowed)
+	}
+	if !p.hostAllowed(host) {
+		return fmt.Errorf("%w: host %q", ErrURLNotAllowed, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(ip)
+	}
+	if !p.AllowPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")) {
+		return fmt.Errorf("%w: host %q", ErrURLNotAllowed, host)
+	}
+	return nil
+}
+
+func (p Policy) schemeAllowed(scheme string) bool {
+	if len(p.Schemes) == 0 {
+		return scheme == "https"
+	}
+	for _, s := range p.Schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) hostAllowed(host string) bool {
+	if len(p.Hosts) == 0 {
+		return true
+	}
+	for _, pattern := range p.Hosts {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIP 校验 IP 是否为公网地址
+func (p Policy) checkIP(ip net.IP) error {
+	if p.AllowPrivate || isPublic(ip) {
+		return nil
+	}
+	return fmt.Errorf("%w: non-public address %s", ErrURLNotAllowed, ip)
+}
+
+// isPublic 是否为公网单播地址
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// control 在建立连接前校验实际连接的 IP
+func (p Policy) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: unresolved address %s", ErrURLNotAllowed, address)
+	}
+	return p.checkIP(ip)
+}
+
+// transport 按限制建立连接的 Transport，不使用代理，以便校验的是商户地址本身
+func (p Policy) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}).DialContext
+	return t
+}
@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// compactThreshold 队列文件行数超过该值且大部分为已完成记录时重写文件
+const compactThreshold = 1024
+
+// Pending 待投递的事件，请求体在入队时生成，每次投递内容相同
+type Pending struct {
+	EventID string          `json:"event_id"`
+	URL     string          `json:"url"`
+	Ref     string          `json:"ref,omitempty"` // 关联的业务标识，如商户订单号
+	Body    json.RawMessage `json:"body"`
+	Attempt int             `json:"attempt"` // 已投递次数
+	NextAt  time.Time       `json:"next_at"` // 下次投递时间
+}
+
+// Queue 待投递事件存储，进程重启后从中恢复未完成的投递
+type Queue interface {
+	// Save 新增或更新待投递事件
+	Save(p *Pending) error
+	// Remove 删除已完成的事件
+	Remove(eventID string) error
+	// List 返回全部待投递事件的副本，按下次投递时间排序
+	List() ([]*Pending, error)
+}
+
+// sortPending 按下次投递时间排序
+func sortPending(list []*Pending) {
+	sort.Slice(list, func(i, j int) bool { return list[i].NextAt.Before(list[j].NextAt) })
+}
+
+// MemoryQueue 内存队列，进程退出后丢失，用于测试
+type MemoryQueue struct {
+	mu      sync.Mutex
+	pending map[string]Pending
+}
+
+// NewMemoryQueue 创建内存队列
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{pending: make(map[string]Pending)}
+}
+
+// Save 新增或更新待投递事件
+func (q *MemoryQueue) Save(p *Pending) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending[p.EventID] = *p
+	return nil
+}
+
+// Remove 删除已完成的事件
+func (q *MemoryQueue) Remove(eventID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, eventID)
+	return nil
+}
+
+// List 返回全部待投递事件
+func (q *MemoryQueue) List() ([]*Pending, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]*Pending, 0, len(q.pending))
+	for _, p := range q.pending {
+		p := p
+		list = append(list, &p)
+	}
+	sortPending(list)
+	return list, nil
+}
+
+// queueRecord 队列文件中的一行，Pending 为新增或更新，Done 为已完成的事件 ID
+type queueRecord struct {
+	Pending *Pending `json:"pending,omitempty"`
+	Done    string   `json:"done,omitempty"`
+}
+
+// FileQueue 基于本地文件的队列，每行一条 JSON 记录，只追加写入
+// 打开时重放文件得到待投递事件并重写文件，去掉已完成的记录
+type FileQueue struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	lines   int
+	pending map[string]Pending
+}
+
+// OpenFileQueue 打开队列文件，文件不存在时创建
+func OpenFileQueue(path string) (*FileQueue, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("create webhook queue directory: %w", err)
+		}
+	}
+
+	q := &FileQueue{path: path, pending: make(map[string]Pending)}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load 重放队列文件，末尾不完整的记录（如写入时进程退出）会被忽略
+func (q *FileQueue) load() error {
+	file, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open webhook queue: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var record queueRecord
+			if json.Unmarshal(line, &record) == nil {
+				q.apply(&record)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read webhook queue: %w", err)
+		}
+	}
+}
+
+func (q *FileQueue) apply(record *queueRecord) {
+	switch {
+	case record.Pending != nil:
+		q.pending[record.Pending.EventID] = *record.Pending
+	case record.Done != "":
+		delete(q.pending, record.Done)
+	}
+}
+
+// compact 只保留待投递事件重写队列文件，先写临时文件再替换
+func (q *FileQueue) compact() error {
+	tmp := q.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("create webhook queue: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, p := range q.pending {
+		p := p
+		data, err := json.Marshal(queueRecord{Pending: &p})
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("marshal webhook queue record: %w", err)
+		}
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("write webhook queue: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("sync webhook queue: %w", err)
+	}
+	file.Close()
+	if err := os.Rename(tmp, q.path); err != nil {
+		return fmt.Errorf("replace webhook queue: %w", err)
+	}
+
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file, err = os.OpenFile(q.path, os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("open webhook queue: %w", err)
+	}
+	q.lines = len(q.pending)
+	return nil
+}
+
+// append 追加一条记录并更新内存状态
+func (q *FileQueue) append(record *queueRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal webhook queue record: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, err := q.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write webhook queue: %w", err)
+	}
+	q.apply(record)
+	q.lines++
+	if q.lines > compactThreshold && q.lines > 4*len(q.pending) {
+		return q.compact()
+	}
+	return nil
+}
+
+// Save 新增或更新待投递事件
+func (q *FileQueue) Save(p *Pending) error {
+	return q.append(&queueRecord{Pending: p})
+}
+
+// Remove 删除已完成的事件
+func (q *FileQueue) Remove(eventID string) error {
+	return q.append(&queueRecord{Done: eventID})
+}
+
+// List 返回全部待投递事件
+func (q *FileQueue) List() ([]*Pending, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]*Pending, 0, len(q.pending))
+	for _, p := range q.pending {
+		p := p
+		list = append(list, &p)
+	}
+	sortPending(list)
+	return list, nil
+}
+
+// Close 同步并关闭队列文件
+func (q *FileQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.file.Sync(); err != nil {
+		q.file.Close()
+		return fmt.Errorf("sync webhook queue: %w", err)
+	}
+	return q.file.Close()
+}
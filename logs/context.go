@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// HeaderRequestID 请求 ID 请求头，调用方传入时沿用，否则由网关生成，并在响应中返回
+const HeaderRequestID = "X-Request-Id"
+
+// WithLogger 将请求级日志放入上下文
+func WithLogger(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext 返回上下文中的请求级日志，没有时返回全局日志
+// 上下文中有有效 span 时附加 trace_id，便于日志与链路关联
+func FromContext(ctx context.Context) *zap.Logger {
+	l, ok := ctx.Value(contextKey{}).(*zap.Logger)
+	if !ok {
+		l = GetLogger()
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		l = l.With(zap.String("trace_id", sc.TraceID().String()))
+	}
+	return l
+}
+
+// ContextLogger 基于上下文日志实现键值对风格的日志接口，如 payment.Logger
+type ContextLogger struct{}
+
+// Info 记录 info 日志，fields 为交替的键和值
+func (ContextLogger) Info(ctx context.Context, msg string, fields ...interface{}) {
+	FromContext(ctx).Sugar().Infow(msg, fields...)
+}
+
+// Warn 记录 warn 日志
+func (ContextLogger) Warn(ctx context.Context, msg string, fields ...interface{}) {
+	FromContext(ctx).Sugar().Warnw(msg, fields...)
+}
+
+// Error 记录 error 日志
+func (ContextLogger) Error(ctx context.Context, msg string, fields ...interface{}) {
+	FromContext(ctx).Sugar().Errorw(msg, fields...)
+}
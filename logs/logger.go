@@ -1,34 +1,99 @@
 package logger
 
 import (
+	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
+
+	"github.com/ymqzj/payment-gateway/configs"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var log *zap.Logger
+var log atomic.Pointer[zap.Logger]
 var once sync.Once
 
+// InitLogger 使用默认配置初始化日志：info 级别、JSON 格式、输出到标准输出
 func InitLogger() {
 	once.Do(func() {
-		config := zap.NewProductionConfig()
-		config.EncoderConfig.TimeKey = "time"
-		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		config.EncoderConfig.MessageKey = "msg"
-		config.EncoderConfig.LevelKey = "level"
-
-		var err error
-		log, err = config.Build()
+		if log.Load() != nil {
+			return
+		}
+		l, err := New(configs.LoggingConfig{Level: "info", Format: "json", Output: "stdout"})
 		if err != nil {
 			panic(err)
 		}
+		log.CompareAndSwap(nil, l)
+	})
+}
+
+// Init 按配置初始化全局日志，替换之前的日志实例
+func Init(cfg configs.LoggingConfig) error {
+	l, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	if old := log.Swap(l); old != nil {
+		_ = old.Sync()
+	}
+	return nil
+}
+
+// New 按配置创建日志，敏感字段自动脱敏
+func New(cfg configs.LoggingConfig) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = "time"
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	encoderConfig.MessageKey = "msg"
+	encoderConfig.LevelKey = "level"
+
+	var encoder zapcore.Encoder
+	switch cfg.Format {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, fmt.Errorf("invalid log format: %s", cfg.Format)
+	}
+
+	core := zapcore.NewCore(encoder, writer(cfg), level)
+	return zap.New(&maskingCore{Core: core},
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.ErrorLevel),
+	), nil
+}
+
+// writer 日志输出，文件输出按大小轮转
+func writer(cfg configs.LoggingConfig) zapcore.WriteSyncer {
+	switch cfg.Output {
+	case "", "stdout":
+		return zapcore.Lock(os.Stdout)
+	case "stderr":
+		return zapcore.Lock(os.Stderr)
+	}
+	return zapcore.AddSync(&lumberjack.Logger{
+		Filename:   cfg.Output,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
 	})
 }
 
 func GetLogger() *zap.Logger {
-	if log == nil {
-		InitLogger()
+	if l := log.Load(); l != nil {
+		return l
 	}
-	return log
+	InitLogger()
+	return log.Load()
 }
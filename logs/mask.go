@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Masked 完全脱敏后的占位符
+const Masked = "******"
+
+// sensitiveKeys 需要脱敏的字段名，按 normalizeKey 归一化后匹配
+// true 表示保留首尾几位用于排查（用户标识、卡号等），false 表示完全隐藏（密钥、签名、CVN2 等）
+var sensitiveKeys = map[string]bool{
+	// 用户标识
+	"openid":       true,
+	"subopenid":    true,
+	"buyerid":      true,
+	"buyerlogonid": true,
+	"buyeruserid":  true,
+	"phoneno":      true,
+	"certifid":     true,
+	// 银行卡
+	"accno":        true,
+	"cardno":       true,
+	"pan":          true,
+	"cvn2":         false,
+	"cvv":          false,
+	"expired":      false,
+	"customerinfo": false,
+	// 密钥和签名
+	"key":           false,
+	"apikey":        false,
+	"apiv3key":      false,
+	"secret":        false,
+	"appsecret":     false,
+	"privatekey":    false,
+	"password":      false,
+	"passphrase":    false,
+	"token":         false,
+	"authorization": false,
+	"sign":          false,
+	"signature":     false,
+	"xsignature":    false,
+	"paysign":       false,
+	"signaturedata": false,
+}
+
+// normalizeKey 字段名转小写并去掉下划线和连字符，使 accNo、acc_no、Acc-No 匹配同一规则
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// sensitiveSuffixes 以这些后缀结尾的字段完全隐藏，如 Wechatpay-Signature、app_secret
+var sensitiveSuffixes = []string{"signature", "secret", "password", "privatekey", "token"}
+
+// lookup 返回字段是否敏感以及是否保留首尾几位
+func lookup(key string) (partial, ok bool) {
+	normalized := normalizeKey(key)
+	if partial, ok := sensitiveKeys[normalized]; ok {
+		return partial, true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// IsSensitive 字段是否需要脱敏
+func IsSensitive(key string) bool {
+	_, ok := lookup(key)
+	return ok
+}
+
+// MaskValue 按字段名脱敏，非敏感字段原样返回
+func MaskValue(key, value string) string {
+	partial, ok := lookup(key)
+	if !ok || value == "" {
+		return value
+	}
+	if !partial {
+		return Masked
+	}
+	return MaskPartial(value)
+}
+
+// MaskPartial 保留首尾几位，中间以 * 代替，过短的值完全隐藏
+func MaskPartial(value string) string {
+	n := len(value)
+	switch {
+	case n < 8:
+		return Masked
+	case n < 16:
+		return value[:2] + "****" + value[n-2:]
+	default:
+		return value[:4] + "****" + value[n-4:]
+	}
+}
+
+// MaskMap 返回脱敏后的副本，递归处理嵌套的对象和数组
+func MaskMap(m map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		masked[k] = maskAny(k, v)
+	}
+	return masked
+}
+
+// MaskStringMap 返回脱敏后的副本，用于表单参数、请求头等
+func MaskStringMap(m map[string]string) map[string]string {
+	masked := make(map[string]string, len(m))
+	for k, v := range m {
+		masked[k] = MaskValue(k, v)
+	}
+	return masked
+}
+
+// MaskHeader 返回脱敏后的请求头副本
+func MaskHeader(h map[string][]string) map[string][]string {
+	masked := make(map[string][]string, len(h))
+	for k, values := range h {
+		copied := make([]string, len(values))
+		for i, v := range values {
+			copied[i] = MaskValue(k, v)
+		}
+		masked[k] = copied
+	}
+	return masked
+}
+
+func maskAny(key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return MaskMap(val)
+	case map[string]string:
+		return MaskStringMap(val)
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = maskAny(key, item)
+		}
+		return items
+	case string:
+		return MaskValue(key, val)
+	case nil:
+		return nil
+	default:
+		if IsSensitive(key) {
+			return MaskValue(key, fmt.Sprint(val))
+		}
+		return val
+	}
+}
+
+// maskingCore 写日志前按字段名脱敏
+type maskingCore struct {
+	zapcore.Core
+}
+
+// With 实现 zapcore.Core，通过 logger.With 添加的字段同样脱敏
+func (c *maskingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &maskingCore{Core: c.Core.With(maskFields(fields))}
+}
+
+// Check 实现 zapcore.Core，使 Write 经过脱敏
+func (c *maskingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write 实现 zapcore.Core
+func (c *maskingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, maskFields(fields))
+}
+
+// maskFields 脱敏敏感的字符串字段以及 map 类型字段中的敏感键
+func maskFields(fields []zapcore.Field) []zapcore.Field {
+	var masked []zapcore.Field
+	for i, f := range fields {
+		replaced, ok := maskField(f)
+		if !ok {
+			continue
+		}
+		if masked == nil {
+			masked = make([]zapcore.Field, len(fields))
+			copy(masked, fields)
+		}
+		masked[i] = replaced
+	}
+	if masked == nil {
+		return fields
+	}
+	return masked
+}
+
+// maskField 返回脱敏后的字段，无需脱敏时返回 false
+// 错误信息不做脱敏，记录错误时不应包含敏感数据
+func maskField(f zapcore.Field) (zapcore.Field, bool) {
+	sensitive := IsSensitive(f.Key)
+	switch f.Type {
+	case zapcore.ErrorType:
+		return f, false
+	case zapcore.StringType:
+		if sensitive {
+			return zap.String(f.Key, MaskValue(f.Key, f.String)), true
+		}
+		return f, false
+	case zapcore.ReflectType:
+		switch val := f.Interface.(type) {
+		case map[string]interface{}:
+			return zap.Any(f.Key, MaskMap(val)), true
+		case map[string]string:
+			return zap.Any(f.Key, MaskStringMap(val)), true
+		case map[string][]string:
+			return zap.Any(f.Key, MaskHeader(val)), true
+		case http.Header:
+			return zap.Any(f.Key, MaskHeader(val)), true
+		case url.Values:
+			return zap.Any(f.Key, MaskHeader(val)), true
+		}
+	}
+	if sensitive {
+		return zap.String(f.Key, Masked), true
+	}
+	return f, false
+}
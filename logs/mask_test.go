@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMaskValue(t *testing.T) {
+	cases := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"openid", "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", "oUpF****eS6o"},
+		{"acc_no", "6216261000000000018", "6216****0018"},
+		{"AccNo", "6216261000000000018", "6216****0018"},
+		{"phoneNo", "13800138000", "13****00"},
+		{"cardNo", "1234567", Masked},
+		{"cvn2", "123", Masked},
+		{"apiV3Key", "0123456789abcdef0123456789abcdef", Masked},
+		{"Authorization", "WECHATPAY2-SHA256-RSA2048 mchid=\"1900000001\"", Masked},
+		{"Wechatpay-Signature", "c2lnbmF0dXJl", Masked},
+		{"app_secret", "s3cret", Masked},
+		{"refresh_token", "tok", Masked},
+		{"signature", "", ""},
+		{"out_trade_no", "ORDER_1", "ORDER_1"},
+		{"keyword", "coffee", "coffee"},
+	}
+	for _, tc := range cases {
+		if got := MaskValue(tc.key, tc.value); got != tc.want {
+			t.Errorf("MaskValue(%q, %q) = %q, want %q", tc.key, tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestMaskMap(t *testing.T) {
+	in := map[string]interface{}{
+		"out_trade_no": "ORDER_1",
+		"sign":         "c2lnbmF0dXJl",
+		"payer":        map[string]interface{}{"openid": "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},
+		"cards":        []interface{}{map[string]interface{}{"accNo": "6216261000000000018"}},
+		"accNo":        []interface{}{"6216261000000000018"},
+		"cvn2":         123,
+		"amount":       100,
+		"extra":        nil,
+	}
+	want := map[string]interface{}{
+		"out_trade_no": "ORDER_1",
+		"sign":         Masked,
+		"payer":        map[string]interface{}{"openid": "oUpF****eS6o"},
+		"cards":        []interface{}{map[string]interface{}{"accNo": "6216****0018"}},
+		"accNo":        []interface{}{"6216****0018"},
+		"cvn2":         Masked,
+		"amount":       100,
+		"extra":        nil,
+	}
+	if got := MaskMap(in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("MaskMap = %v, want %v", got, want)
+	}
+	if in["sign"] != "c2lnbmF0dXJl" {
+		t.Fatal("MaskMap modified its input")
+	}
+}
+
+// newObserved 经过脱敏的日志，返回写入的日志记录
+func newObserved() (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return zap.New(&maskingCore{Core: core}), logs
+}
+
+func TestMaskingCore(t *testing.T) {
+	cases := []struct {
+		name  string
+		field zap.Field
+		want  interface{}
+	}{
+		{"string", zap.String("accNo", "6216261000000000018"), "6216****0018"},
+		{"plain string", zap.String("out_trade_no", "ORDER_1"), "ORDER_1"},
+		{"non string", zap.Int("cvn2", 123), Masked},
+		{"plain int", zap.Int("amount", 100), int64(100)},
+		{"map", zap.Any("params", map[string]interface{}{"sign": "abc", "txnAmt": "100"}),
+			map[string]interface{}{"sign": Masked, "txnAmt": "100"}},
+		{"string map", zap.Any("form", map[string]string{"certId": "69026276696", "signature": "abc"}),
+			map[string]string{"certId": "69026276696", "signature": Masked}},
+		{"header", zap.Any("header", http.Header{"Authorization": {"Bearer x"}, "Content-Type": {"application/json"}}),
+			map[string][]string{"Authorization": {Masked}, "Content-Type": {"application/json"}}},
+		{"url values", zap.Any("query", url.Values{"openid": {"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"}}),
+			map[string][]string{"openid": {"oUpF****eS6o"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			log, logs := newObserved()
+			log.Info("test", tc.field)
+
+			got := logs.All()[0].ContextMap()[tc.field.Key]
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("%s = %#v, want %#v", tc.field.Key, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestMaskingCoreWith 通过 With、Sugar 和上下文日志添加的字段同样脱敏，错误信息原样记录
+func TestMaskingCoreWith(t *testing.T) {
+	log, logs := newObserved()
+
+	log.With(zap.String("openid", "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o")).Info("with")
+	log.Sugar().Infow("sugar", "password", "p@ssw0rd", "out_trade_no", "ORDER_1")
+	ctx := WithLogger(context.Background(), log)
+	ContextLogger{}.Warn(ctx, "context", "secret", "s3cret")
+	log.Error("error", zap.Error(errors.New("sign mismatch")))
+
+	want := []map[string]interface{}{
+		{"openid": "oUpF****eS6o"},
+		{"password": Masked, "out_trade_no": "ORDER_1"},
+		{"secret": Masked},
+		{"error": "sign mismatch"},
+	}
+	entries := logs.All()
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %d, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if got := entry.ContextMap(); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("%s: fields = %v, want %v", entry.Message, got, want[i])
+		}
+	}
+}
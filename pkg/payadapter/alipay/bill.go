@@ -0,0 +1,74 @@
+package alipay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/smartwalle/alipay/v3"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// billTypeTrade 支付宝交易账单类型
+const billTypeTrade = "trade"
+
+// DownloadBill 下载日账单
+// 先查询账单下载地址再下载，账单为 zip 压缩的 CSV 文件；BillType 为 trade 或 signcustomer，为空时为 trade
+func (c *Client) DownloadBill(ctx context.Context, req *payment.BillRequest) (*payment.BillResponse, error) {
+	billType := req.BillType
+	if billType == "" {
+		billType = billTypeTrade
+	}
+
+	var p = alipay.BillDownloadURLQuery{}
+	p.BillType = billType
+	p.BillDate = req.Date.Format(time.DateOnly)
+
+	resp, err := c.client.BillDownloadURLQuery(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("alipay query bill download url failed: %w", classifyError(err))
+	}
+
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("alipay query bill download url failed: %w", classifyResponse(resp.Error))
+	}
+
+	data, err := c.download(ctx, resp.BillDownloadURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment.BillResponse{
+		Channel:  payment.ChannelAlipay,
+		Date:     req.Date,
+		BillType: billType,
+		FileName: fmt.Sprintf("alipay_%s_%s.zip", billType, req.Date.Format("20060102")),
+		Data:     data,
+	}, nil
+}
+
+// download 下载账单文件，下载地址自带签名，有效期 30 秒
+func (c *Client) download(ctx context.Context, downloadURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create alipay bill download request failed: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("alipay download bill failed: %w", payment.ClassifyTransportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alipay download bill failed: %w", payment.ClassifyHTTPStatus(payment.ChannelAlipay, resp.StatusCode, payment.ErrAlipayError))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read alipay bill failed: %w", payment.ClassifyTransportError(err))
+	}
+	return data, nil
+}
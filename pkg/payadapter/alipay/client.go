@@ -3,6 +3,8 @@ package alipay
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,37 +12,150 @@ import (
 
 	"github.com/smartwalle/alipay/v3"
 	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/audit"
 	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
 )
 
 type Client struct {
-	client *alipay.Client
-	config *Config
+	client     *alipay.Client
+	httpClient *http.Client // 与 SDK 共用，用于下载账单文件
+	config     *Config
+	certInfos  []payment.CertInfo // 公钥证书模式下加载的证书
 }
 
 // NewAdapter 创建支付宝适配器
-func NewAdapter(cfg *configs.Config) (*Client, error) {
+// 应用私钥、支付宝公钥及证书通过 secrets 加载，secrets 为空时使用默认的 file/env 提供者
+func NewAdapter(cfg *configs.Config, secrets secret.SecretProvider) (*Client, error) {
 	config := NewConfig(cfg)
+	if secrets == nil {
+		secrets = secret.NewRegistry()
+	}
+
+	ctx := context.Background()
+
+	// SDK 只支持 UTF-8 编码和 RSA2 签名，配置了其他值时直接报错而不是静默忽略
+	if !config.charsetSupported() {
+		return nil, fmt.Errorf("unsupported alipay charset: %s", config.Charset)
+	}
+	if !config.signTypeSupported() {
+		return nil, fmt.Errorf("unsupported alipay sign type: %s", config.SignType)
+	}
+
+	// 渠道请求作为网关调用 span 的子 span 上报，报文写入审计记录，与 SDK 默认的 http.DefaultClient 一样不设超时，由调用方 context 控制
+	httpClient := &http.Client{Transport: tracing.Transport(audit.Transport(nil))}
+	opts := []alipay.OptionFunc{
+		alipay.WithHTTPClient(httpClient),
+	}
+	if config.GatewayURL != "" {
+		if config.IsSandbox {
+			opts = append(opts, alipay.WithSandboxGateway(config.GatewayURL))
+		} else {
+			opts = append(opts, alipay.WithProductionGateway(config.GatewayURL))
+		}
+	}
 
-	client, err := alipay.New(config.AppID, config.PrivateKey, !config.IsSandbox)
+	privateKey, err := secret.LoadValue(ctx, secrets, config.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alipay private key: %w", err)
+	}
+
+	client, err := alipay.New(config.AppID, privateKey, !config.IsSandbox, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create alipay client: %w", err)
 	}
 
-	// Load Alipay public key for verification
-	if err := client.LoadAliPayPublicKey(config.AlipayPublicKey); err != nil {
-		return nil, fmt.Errorf("failed to load alipay public key: %w", err)
+	var certInfos []payment.CertInfo
+	if config.IsCertMode() {
+		certInfos, err = loadCerts(ctx, client, config, secrets)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		publicKey, err := secret.LoadValue(ctx, secrets, config.AlipayPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load alipay public key: %w", err)
+		}
+
+		// Load Alipay public key for verification
+		if err := client.LoadAliPayPublicKey(publicKey); err != nil {
+			return nil, fmt.Errorf("failed to load alipay public key: %w", err)
+		}
 	}
 
 	// Note: In the newer version of the SDK, we don't set global return/notify URLs
 	// These are set per request instead
 
 	return &Client{
-		client: client,
-		config: config,
+		client:     client,
+		httpClient: httpClient,
+		config:     config,
+		certInfos:  certInfos,
 	}, nil
 }
 
+// loadCerts 公钥证书模式: 加载应用公钥证书、支付宝公钥证书和支付宝根证书
+// 资金类接口（如转账）要求使用公钥证书模式
+func loadCerts(ctx context.Context, client *alipay.Client, config *Config, secrets secret.SecretProvider) ([]payment.CertInfo, error) {
+	if !config.hasAllCerts() {
+		return nil, fmt.Errorf("alipay cert mode requires app_public_cert_path, alipay_public_cert_path and alipay_root_cert_path")
+	}
+
+	appCert, err := secret.LoadFile(ctx, secrets, config.AppPublicCertPath)
+	if err == nil {
+		err = client.LoadAppCertPublicKey(string(appCert))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load app public cert: %w", err)
+	}
+
+	alipayCert, err := secret.LoadFile(ctx, secrets, config.AlipayPublicCertPath)
+	if err == nil {
+		err = client.LoadAlipayCertPublicKey(string(alipayCert))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alipay public cert: %w", err)
+	}
+
+	rootCert, err := secret.LoadFile(ctx, secrets, config.AlipayRootCertPath)
+	if err == nil {
+		err = client.LoadAliPayRootCert(string(rootCert))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alipay root cert: %w", err)
+	}
+
+	var infos []payment.CertInfo
+	infos = append(infos, parseCertInfos("app", appCert)...)
+	infos = append(infos, parseCertInfos("alipay", alipayCert)...)
+	infos = append(infos, parseCertInfos("root", rootCert)...)
+	return infos, nil
+}
+
+// parseCertInfos 解析 PEM 数据中的所有证书，支付宝根证书文件包含多张证书
+func parseCertInfos(name string, data []byte) []payment.CertInfo {
+	var infos []payment.CertInfo
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, payment.NewCertInfo(payment.ChannelAlipay, name, cert))
+	}
+	return infos
+}
+
+// CertInfos 报告公钥证书模式下加载的证书
+func (c *Client) CertInfos(ctx context.Context) []payment.CertInfo {
+	return c.certInfos
+}
+
 // Pay 实现支付接口
 func (c *Client) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
 	// 创建支付宝支付请求
@@ -130,7 +245,7 @@ func (c *Client) HandleNotify(ctx context.Context, data []byte) (*payment.Notify
 	// 解析并验签通知
 	noti, err := c.client.GetTradeNotification(req)
 	if err != nil {
-		return nil, fmt.Errorf("handle notify failed: %w", err)
+		return nil, fmt.Errorf("handle notify failed: %w", classifyNotifyError(err))
 	}
 
 	// Convert string amount to float64
@@ -173,14 +288,12 @@ func (c *Client) Refund(ctx context.Context, req *payment.RefundRequest) (*payme
 
 	resp, err := c.client.TradeRefund(ctx, p)
 	if err != nil {
-		return nil, fmt.Errorf("alipay refund failed: %w", err)
+		return nil, fmt.Errorf("alipay refund failed: %w", classifyError(err))
 	}
 
-	if resp.Code != "10000" {
-		return &payment.RefundResponse{
-			Code:    "1",
-			Message: fmt.Sprintf("支付宝退款失败: %s - %s", resp.Code, resp.Msg),
-		}, nil
+	// 业务失败同样返回错误，不能当作退款成功；渠道系统错误使用相同的 OutRequestNo 重试不会重复退款
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("alipay refund failed: %w", classifyResponse(resp.Error))
 	}
 
 	refundAmount, _ := strconv.ParseFloat(resp.RefundFee, 64)
@@ -200,14 +313,61 @@ func (c *Client) Refund(ctx context.Context, req *payment.RefundRequest) (*payme
 	}, nil
 }
 
+// QueryRefund 查询退款，支付宝按退款请求号（退款时的 OutRequestNo）查询
+func (c *Client) QueryRefund(ctx context.Context, req *payment.RefundQueryRequest) (*payment.RefundResponse, error) {
+	var p = alipay.TradeFastPayRefundQuery{}
+	p.OutTradeNo = req.OutTradeNo
+	p.TradeNo = req.OrderID
+	p.OutRequestNo = req.OutRefundNo
+
+	resp, err := c.client.TradeFastPayRefundQuery(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("alipay query refund failed: %w", classifyError(err))
+	}
+
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("alipay query refund failed: %w", classifyResponse(resp.Error))
+	}
+
+	// 未返回退款状态表示退款请求未收到或退款失败
+	status := "FAIL"
+	if resp.RefundStatus == "REFUND_SUCCESS" {
+		status = "SUCCESS"
+	}
+
+	refundAmount, _ := strconv.ParseFloat(resp.RefundAmount, 64)
+
+	var refundTime *time.Time
+	if resp.GMTRefundPay != "" {
+		if t, err := time.Parse("2006-01-02 15:04:05", resp.GMTRefundPay); err == nil {
+			refundTime = &t
+		}
+	}
+
+	return &payment.RefundResponse{
+		Code:         "0",
+		Message:      "success",
+		RefundID:     resp.TradeNo,
+		OutRefundNo:  req.OutRefundNo,
+		RefundAmount: refundAmount,
+		RefundStatus: status,
+		RefundTime:   refundTime,
+		Channel:      payment.ChannelAlipay,
+	}, nil
+}
+
 // Close 关闭订单接口
 func (c *Client) Close(ctx context.Context, req *payment.CloseRequest) error {
 	var p = alipay.TradeClose{}
 	p.OutTradeNo = req.OutTradeNo
 
-	_, err := c.client.TradeClose(ctx, p)
+	resp, err := c.client.TradeClose(ctx, p)
 	if err != nil {
-		return fmt.Errorf("alipay close order failed: %w", err)
+		return fmt.Errorf("alipay close order failed: %w", classifyError(err))
+	}
+
+	if !resp.IsSuccess() {
+		return fmt.Errorf("alipay close order failed: %w", classifyResponse(resp.Error))
 	}
 
 	return nil
@@ -220,11 +380,11 @@ func (c *Client) Query(ctx context.Context, req *payment.QueryRequest) (*payment
 
 	resp, err := c.client.TradeQuery(ctx, p)
 	if err != nil {
-		return nil, fmt.Errorf("alipay query order failed: %w", err)
+		return nil, fmt.Errorf("alipay query order failed: %w", classifyError(err))
 	}
 
-	if resp.Code != "10000" {
-		return nil, fmt.Errorf("alipay query order failed: %s - %s", resp.Code, resp.Msg)
+	if !resp.IsSuccess() {
+		return nil, fmt.Errorf("alipay query order failed: %w", classifyResponse(resp.Error))
 	}
 
 	status := payment.TradeStatusNotPay
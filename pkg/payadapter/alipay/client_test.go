@@ -0,0 +1,415 @@
+package alipay
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// testKey 测试用的密钥，同时作为应用私钥和支付宝公钥，所有用例共用以减少生成密钥的耗时
+var testKey = mustGenerateKey()
+
+func mustGenerateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// testConfig 普通公钥模式的沙箱配置，gatewayURL 为空时使用 SDK 默认地址
+func testConfig(t *testing.T, gatewayURL string) *configs.Config {
+	t.Helper()
+	private, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&testKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &configs.Config{Alipay: configs.AlipayConfig{
+		Enabled:         true,
+		AppID:           "2021000000000000",
+		PrivateKey:      base64.StdEncoding.EncodeToString(private),
+		AlipayPublicKey: base64.StdEncoding.EncodeToString(public),
+		GatewayURL:      gatewayURL,
+		Sandbox:         true,
+		NotifyURL:       "https://gateway.example.com/api/v1/notify/alipay",
+	}}
+}
+
+// sign 按支付宝规则用 testKey 签名，返回 base64 编码的 RSA2 签名
+func sign(t *testing.T, content []byte) string {
+	t.Helper()
+	digest := sha256.Sum256(content)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, testKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// respondWith 模拟支付宝网关，field 为应答中的业务字段名（如 alipay_trade_refund_response），biz 为业务应答
+// signed 为 false 时不带签名，与支付宝对部分错误应答的处理相同
+func respondWith(t *testing.T, field, biz string, signed bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := `{"` + field + `":` + biz
+		if signed {
+			body += `,"sign":"` + sign(t, []byte(biz)) + `"`
+		}
+		w.Header().Set("Content-Type", "application/json;charset=utf-8")
+		w.Write([]byte(body + "}"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestAdapter 请求发往 gatewayURL 的适配器
+func newTestAdapter(t *testing.T, gatewayURL string) *Client {
+	t.Helper()
+	client, err := NewAdapter(testConfig(t, gatewayURL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestRefund 退款业务失败返回错误而不是带失败码的应答，错误按支付宝子码归类
+func TestRefund(t *testing.T) {
+	const field = "alipay_trade_refund_response"
+	req := &payment.RefundRequest{OutTradeNo: "ORDER_1", OutRefundNo: "R1", RefundAmount: 0.01, TotalAmount: 0.01}
+
+	cases := []struct {
+		name      string
+		biz       string
+		signed    bool
+		err       error // 为空时期望成功
+		retryable bool
+	}{
+		{"success", `{"code":"10000","msg":"Success","trade_no":"2024010122001","out_trade_no":"ORDER_1","refund_fee":"0.01","fund_change":"Y"}`, true, nil, false},
+		{"refund not allowed", `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_ALLOW_REFUND","sub_msg":"交易不允许退款"}`, true, payment.ErrRefundNotAllowed, false},
+		{"insufficient balance", `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.SELLER_BALANCE_NOT_ENOUGH","sub_msg":"卖家余额不足"}`, true, payment.ErrInsufficientBalance, false},
+		{"unknown sub code", `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.UNKNOWN","sub_msg":"未知错误"}`, true, payment.ErrAlipayError, false},
+		{"system error", `{"code":"20000","msg":"Service Currently Unavailable","sub_code":"ACQ.SYSTEM_ERROR","sub_msg":"系统错误"}`, true, payment.ErrSystemError, true},
+		{"unsigned failure", `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_EXIST","sub_msg":"交易不存在"}`, false, payment.ErrOrderNotFound, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestAdapter(t, respondWith(t, field, tc.biz, tc.signed).URL)
+			resp, err := client.Refund(context.Background(), req)
+			if tc.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Code != "0" || resp.RefundStatus != "SUCCESS" || resp.RefundID != "2024010122001" || resp.RefundAmount != 0.01 {
+					t.Fatalf("resp = %+v", resp)
+				}
+				return
+			}
+			if resp != nil || !errors.Is(err, tc.err) {
+				t.Fatalf("resp = %+v, err = %v, want %v", resp, err, tc.err)
+			}
+			var channelErr *payment.ChannelError
+			if !errors.As(err, &channelErr) || channelErr.Code == "" {
+				t.Fatalf("err = %v, want channel error with code", err)
+			}
+			if payment.IsRetryable(err) != tc.retryable {
+				t.Fatalf("retryable = %v, want %v", payment.IsRetryable(err), tc.retryable)
+			}
+		})
+	}
+}
+
+// issueCert 签发证书，parent 为空时自签名，证书公钥均为 testKey 的公钥
+func issueCert(t *testing.T, template, parent *x509.Certificate, signer *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &testKey.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// writeCerts 在临时目录中写入 PEM 格式的证书文件，返回文件路径
+func writeCerts(t *testing.T, dir, name string, certs ...*x509.Certificate) string {
+	t.Helper()
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// certModeConfig 公钥证书模式的沙箱配置：根证书文件包含两张根证书，应用证书和支付宝证书由第一张根证书签发
+func certModeConfig(t *testing.T) *configs.Config {
+	t.Helper()
+	dir := t.TempDir()
+	root := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Alipay Test Root"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, testKey)
+	otherRoot := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Alipay Test Root G2"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, testKey)
+	app := issueCert(t, &x509.Certificate{SerialNumber: big.NewInt(10), Subject: pkix.Name{CommonName: "2021000000000000"}}, root, testKey)
+	alipayCert := issueCert(t, &x509.Certificate{SerialNumber: big.NewInt(11), Subject: pkix.Name{CommonName: "Alipay Test Public Key"}}, root, testKey)
+
+	cfg := testConfig(t, "")
+	cfg.Alipay.AlipayPublicKey = ""
+	cfg.Alipay.AppPublicCertPath = writeCerts(t, dir, "appCertPublicKey.crt", app)
+	cfg.Alipay.AlipayPublicCertPath = writeCerts(t, dir, "alipayCertPublicKey_RSA2.crt", alipayCert)
+	cfg.Alipay.AlipayRootCertPath = writeCerts(t, dir, "alipayRootCert.crt", root, otherRoot)
+	return cfg
+}
+
+// TestNewAdapter 普通公钥模式和公钥证书模式的配置加载，证书模式报告加载的全部证书
+func TestNewAdapter(t *testing.T) {
+	cases := []struct {
+		name   string
+		config func(t *testing.T) *configs.Config
+		certs  []string // 期望报告的证书名称
+		err    string   // 期望的错误信息片段，为空时期望成功
+	}{
+		{"public key mode", func(t *testing.T) *configs.Config { return testConfig(t, "") }, nil, ""},
+		{"cert mode", certModeConfig, []string{"app", "alipay", "root", "root"}, ""},
+		{"partial cert mode", func(t *testing.T) *configs.Config {
+			cfg := certModeConfig(t)
+			cfg.Alipay.AlipayRootCertPath = ""
+			return cfg
+		}, nil, "requires app_public_cert_path"},
+		{"missing cert file", func(t *testing.T) *configs.Config {
+			cfg := certModeConfig(t)
+			cfg.Alipay.AppPublicCertPath = filepath.Join(t.TempDir(), "missing.crt")
+			return cfg
+		}, nil, "failed to load app public cert"},
+		{"invalid alipay cert", func(t *testing.T) *configs.Config {
+			cfg := certModeConfig(t)
+			cfg.Alipay.AlipayPublicCertPath = cfg.Alipay.AlipayPublicCertPath + ".bad"
+			if err := os.WriteFile(cfg.Alipay.AlipayPublicCertPath, []byte("not a certificate"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			return cfg
+		}, nil, "failed to load alipay public cert"},
+		{"invalid public key", func(t *testing.T) *configs.Config {
+			cfg := testConfig(t, "")
+			cfg.Alipay.AlipayPublicKey = "not a key"
+			return cfg
+		}, nil, "failed to load alipay public key"},
+		{"unsupported sign type", func(t *testing.T) *configs.Config {
+			cfg := testConfig(t, "")
+			cfg.Alipay.SignType = "RSA"
+			return cfg
+		}, nil, "unsupported alipay sign type"},
+		{"unsupported charset", func(t *testing.T) *configs.Config {
+			cfg := testConfig(t, "")
+			cfg.Alipay.Charset = "GBK"
+			return cfg
+		}, nil, "unsupported alipay charset"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewAdapter(tc.config(t), nil)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("err = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, info := range client.CertInfos(context.Background()) {
+				if info.Channel != payment.ChannelAlipay {
+					t.Errorf("cert %s channel = %s", info.Name, info.Channel)
+				}
+				names = append(names, info.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.certs, ",") {
+				t.Fatalf("certs = %v, want %v", names, tc.certs)
+			}
+		})
+	}
+}
+
+// TestGatewaySelection 沙箱配置使用沙箱网关，gateway_url 覆盖默认地址
+func TestGatewaySelection(t *testing.T) {
+	cases := []struct {
+		name    string
+		sandbox bool
+		gateway string
+		want    string
+	}{
+		{"sandbox default", true, "", "https://openapi-sandbox.dl.alipaydev.com/gateway.do"},
+		{"production default", false, "", "https://openapi.alipay.com/gateway.do"},
+		{"sandbox custom", true, "https://openapi.alipaydev.com/gateway.do", "https://openapi.alipaydev.com/gateway.do"},
+		{"production custom", false, "https://alipay-proxy.example.com/gateway.do", "https://alipay-proxy.example.com/gateway.do"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testConfig(t, tc.gateway)
+			cfg.Alipay.Sandbox = tc.sandbox
+			client, err := NewAdapter(cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Pay(context.Background(), &payment.UnifiedPayRequest{
+				Channel: payment.ChannelAlipay, OutTradeNo: "ORDER_1", TotalAmount: 0.01, Subject: "test", Scene: payment.PayScene("pc"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			payURL, err := url.Parse(resp.PayData.(map[string]string)["pay_url"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := payURL.Scheme + "://" + payURL.Host + payURL.Path; got != tc.want {
+				t.Fatalf("gateway = %s, want %s", got, tc.want)
+			}
+			if payURL.Query().Get("app_id") != "2021000000000000" || payURL.Query().Get("sign") == "" {
+				t.Fatalf("pay url = %s", payURL)
+			}
+		})
+	}
+}
+
+// signNotify 按支付宝规则用 testKey 签名异步通知：除 sign、sign_type 外的非空参数按键排序拼接后签名
+func signNotify(t *testing.T, values url.Values) []byte {
+	t.Helper()
+	var pairs []string
+	for key := range values {
+		if key == "sign" || key == "sign_type" || values.Get(key) == "" {
+			continue
+		}
+		pairs = append(pairs, key+"="+values.Get(key))
+	}
+	sort.Strings(pairs)
+	values.Set("sign_type", "RSA2")
+	values.Set("sign", sign(t, []byte(strings.Join(pairs, "&"))))
+	return []byte(values.Encode())
+}
+
+func notifyValues() url.Values {
+	return url.Values{
+		"app_id":       {"2021000000000000"},
+		"notify_type":  {"trade_status_sync"},
+		"notify_id":    {"2024010100222120000000000000000000"},
+		"out_trade_no": {"ORDER_1"},
+		"trade_no":     {"2024010122001"},
+		"trade_status": {"TRADE_SUCCESS"},
+		"total_amount": {"12.34"},
+		"gmt_payment":  {"2024-01-01 12:00:00"},
+	}
+}
+
+// TestHandleNotify 通知验签及字段转换，签名不匹配或无法解码时返回 ErrInvalidSignature
+func TestHandleNotify(t *testing.T) {
+	valid := signNotify(t, notifyValues())
+	waiting := notifyValues()
+	waiting.Set("trade_status", "WAIT_BUYER_PAY")
+
+	cases := []struct {
+		name    string
+		config  func(t *testing.T) *configs.Config
+		body    []byte
+		success bool
+		status  string
+		err     error // 为空时期望验签通过
+	}{
+		{"paid", func(t *testing.T) *configs.Config { return testConfig(t, "") }, valid, true, "TRADE_SUCCESS", nil},
+		{"waiting", func(t *testing.T) *configs.Config { return testConfig(t, "") }, signNotify(t, waiting), false, "WAIT_BUYER_PAY", nil},
+		{"cert mode", certModeConfig, valid, true, "TRADE_SUCCESS", nil},
+		{"amount tampered", func(t *testing.T) *configs.Config { return testConfig(t, "") },
+			[]byte(strings.Replace(string(valid), "total_amount=12.34", "total_amount=1234", 1)), false, "", payment.ErrInvalidSignature},
+		{"signature not base64", func(t *testing.T) *configs.Config { return testConfig(t, "") },
+			[]byte(url.Values{"out_trade_no": {"ORDER_1"}, "sign": {"!!!"}}.Encode()), false, "", payment.ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewAdapter(tc.config(t), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := client.HandleNotify(context.Background(), tc.body)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("err = %v, want %v", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			switch {
+			case result.Success != tc.success || result.TradeStatus != tc.status:
+				t.Errorf("success = %v, status = %s", result.Success, result.TradeStatus)
+			case result.Channel != payment.ChannelAlipay || result.OutTradeNo != "ORDER_1" || result.OrderID != "2024010122001":
+				t.Errorf("result = %+v", result)
+			case result.TotalAmount != 12.34:
+				t.Errorf("total amount = %v", result.TotalAmount)
+			case result.PayTime == nil || !result.PayTime.Equal(payTime):
+				t.Errorf("pay time = %v", result.PayTime)
+			}
+		})
+	}
+}
+
+// TestHandleNotifyUnknownCert 沙箱环境不会下载未知的支付宝公钥证书，通知中的证书序列号未加载时拒绝
+func TestHandleNotifyUnknownCert(t *testing.T) {
+	client, err := NewAdapter(certModeConfig(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := notifyValues()
+	values.Set("alipay_cert_sn", "0123456789abcdef")
+	if result, err := client.HandleNotify(context.Background(), signNotify(t, values)); err == nil {
+		t.Fatalf("result = %+v, want error", result)
+	}
+}
@@ -1,25 +1,61 @@
 // pkg/payadapter/alipay/config.go
 package alipay
 
-import "github.com/ymqzj/payment-gateway/configs"
+import (
+	"strings"
+
+	"github.com/ymqzj/payment-gateway/configs"
+)
 
 type Config struct {
-	AppID           string // 应用ID
-	PrivateKey      string // 应用私钥（PKCS#1 或 PKCS#8 格式字符串）
-	AlipayPublicKey string // 支付宝公钥（用于验签）
-	NotifyURL       string // 默认异步通知地址
-	ReturnURL       string // 默认同步跳转地址（H5用）
-	IsSandbox       bool   // 是否沙箱环境
+	AppID                string // 应用ID
+	PrivateKey           string // 应用私钥（PKCS#1 或 PKCS#8 格式字符串），可为机密引用
+	AlipayPublicKey      string // 支付宝公钥（普通公钥模式，用于验签），可为机密引用
+	AppPublicCertPath    string // 应用公钥证书路径（公钥证书模式）
+	AlipayPublicCertPath string // 支付宝公钥证书路径（公钥证书模式）
+	AlipayRootCertPath   string // 支付宝根证书路径（公钥证书模式）
+	GatewayURL           string // 网关地址，为空时使用 SDK 默认地址
+	Charset              string // 请求编码
+	SignType             string // 签名类型
+	NotifyURL            string // 默认异步通知地址
+	ReturnURL            string // 默认同步跳转地址（H5用）
+	IsSandbox            bool   // 是否沙箱环境
 }
 
 // NewConfig 从全局配置创建支付宝配置
 func NewConfig(config *configs.Config) *Config {
 	return &Config{
-		AppID:           config.Alipay.AppID,
-		PrivateKey:      config.Alipay.PrivateKey,
-		AlipayPublicKey: config.Alipay.AlipayPublicKey,
-		NotifyURL:       config.Alipay.NotifyURL,
-		ReturnURL:       config.Alipay.ReturnURL,
-		IsSandbox:       false, // 可根据环境变量设置
+		AppID:                config.Alipay.AppID,
+		PrivateKey:           config.Alipay.PrivateKey,
+		AlipayPublicKey:      config.Alipay.AlipayPublicKey,
+		AppPublicCertPath:    config.Alipay.AppPublicCertPath,
+		AlipayPublicCertPath: config.Alipay.AlipayPublicCertPath,
+		AlipayRootCertPath:   config.Alipay.AlipayRootCertPath,
+		GatewayURL:           config.Alipay.GatewayURL,
+		Charset:              config.Alipay.Charset,
+		SignType:             config.Alipay.SignType,
+		NotifyURL:            config.Alipay.NotifyURL,
+		ReturnURL:            config.Alipay.ReturnURL,
+		IsSandbox:            config.Alipay.Sandbox,
 	}
-}
\ No newline at end of file
+}
+
+// IsCertMode 是否配置了公钥证书模式
+func (c *Config) IsCertMode() bool {
+	return c.AppPublicCertPath != "" || c.AlipayPublicCertPath != "" || c.AlipayRootCertPath != ""
+}
+
+// hasAllCerts 公钥证书模式需要同时配置三个证书
+func (c *Config) hasAllCerts() bool {
+	return c.AppPublicCertPath != "" && c.AlipayPublicCertPath != "" && c.AlipayRootCertPath != ""
+}
+
+// charsetSupported SDK 固定使用 UTF-8 编码
+func (c *Config) charsetSupported() bool {
+	return c.Charset == "" || strings.EqualFold(c.Charset, "utf-8")
+}
+
+// signTypeSupported SDK 固定使用 RSA2 (SHA256WithRSA) 签名
+func (c *Config) signTypeSupported() bool {
+	return c.SignType == "" || strings.EqualFold(c.SignType, "RSA2")
+}
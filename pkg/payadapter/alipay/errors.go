@@ -0,0 +1,68 @@
+package alipay
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/smartwalle/alipay/v3"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// subCodeKinds 支付宝业务子码与标准错误的对应关系
+var subCodeKinds = map[string]error{
+	"ACQ.SYSTEM_ERROR":               payment.ErrSystemError,
+	"ACQ.INVALID_PARAMETER":          payment.ErrInvalidParameter,
+	"ACQ.TRADE_NOT_EXIST":            payment.ErrOrderNotFound,
+	"ACQ.TRADE_HAS_SUCCESS":          payment.ErrOrderPaid,
+	"ACQ.TRADE_HAS_CLOSE":            payment.ErrOrderClosed,
+	"ACQ.TRADE_HAS_FINISHED":         payment.ErrOrderClosed,
+	"ACQ.TRADE_NOT_ALLOW_REFUND":     payment.ErrRefundNotAllowed,
+	"ACQ.SELLER_BALANCE_NOT_ENOUGH":  payment.ErrInsufficientBalance,
+	"ACQ.REFUND_FEE_ERROR":           payment.ErrInvalidAmount,
+	"ACQ.REFUND_AMT_NOT_EQUAL_TOTAL": payment.ErrInvalidAmount,
+}
+
+// codeKinds 支付宝网关公共错误码与标准错误的对应关系，子码未命中时使用
+var codeKinds = map[alipay.Code]error{
+	"20000": payment.ErrSystemError,      // 服务不可用
+	"20001": payment.ErrSignatureFailed,  // 授权权限不足
+	"40001": payment.ErrMissingParameter, // 缺少必选参数
+	"40002": payment.ErrInvalidParameter, // 非法的参数
+}
+
+// classifyNotifyError 归类通知验签错误，签名不匹配或无法解码时为 ErrInvalidSignature
+func classifyNotifyError(err error) error {
+	var corrupt base64.CorruptInputError
+	if errors.Is(err, rsa.ErrVerification) || errors.As(err, &corrupt) {
+		return fmt.Errorf("%w: %v", payment.ErrInvalidSignature, err)
+	}
+	return err
+}
+
+// classifyError 归类支付宝 SDK 返回的错误，包括网关错误应答和网络错误
+func classifyError(err error) error {
+	var apiErr *alipay.Error
+	if errors.As(err, &apiErr) {
+		return classifyResponse(*apiErr)
+	}
+	return payment.ClassifyTransportError(err)
+}
+
+// classifyResponse 将支付宝业务应答中的错误码归类为标准错误
+func classifyResponse(e alipay.Error) error {
+	kind, ok := subCodeKinds[e.SubCode]
+	if !ok {
+		kind, ok = codeKinds[e.Code]
+	}
+	if !ok {
+		kind = payment.ErrAlipayError
+	}
+
+	code, message := e.SubCode, e.SubMsg
+	if code == "" {
+		code, message = string(e.Code), e.Msg
+	}
+	return payment.NewChannelError(payment.ChannelAlipay, code, message, kind)
+}
@@ -3,56 +3,103 @@ package unionpay
 import (
 	"context"
 	"fmt"
-	"time"
+	"net/url"
+	"strings"
 
 	"github.com/ymqzj/payment-gateway/configs"
 	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
 )
 
 // Adapter 银联支付适配器
 type Adapter struct {
-	client *Client
-	config *Config
+	client    *Client
+	config    *Config
+	forms     *FormStore
+	publicURL string // 网关对外访问地址，用于生成前台支付表单链接
 }
 
 // NewAdapter 创建银联支付适配器
-func NewAdapter(cfg *configs.Config) (*Adapter, error) {
+// 签名证书、证书密码和验签证书通过 secrets 加载，secrets 为空时使用默认的 file/env 提供者
+// forms 为前台支付表单缓存，需要在适配器重建后保留表单时传入同一个缓存，为空时创建新的缓存
+func NewAdapter(cfg *configs.Config, secrets secret.SecretProvider, forms *FormStore) (*Adapter, error) {
 	// 创建银联配置
 	unionpayConfig := NewConfig(cfg)
 
 	// 使用新的客户端创建函数，自动加载密钥
-	client, err := NewClientWithKeys(unionpayConfig)
+	client, err := NewClientWithKeys(unionpayConfig, secrets)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create unionpay client: %w", err)
 	}
+	if forms == nil {
+		forms = NewFormStore()
+	}
 
 	return &Adapter{
-		client: client,
-		config: unionpayConfig,
+		client:    client,
+		config:    unionpayConfig,
+		forms:     forms,
+		publicURL: strings.TrimRight(cfg.Server.PublicURL, "/"),
 	}, nil
 }
 
+// CertInfos 报告签名证书、验签根证书/中级证书以及已校验的银联签名证书
+func (a *Adapter) CertInfos(ctx context.Context) []payment.CertInfo {
+	var infos []payment.CertInfo
+	if a.client.SignCert != nil {
+		infos = append(infos, payment.NewCertInfo(payment.ChannelUnionPay, "sign", a.client.SignCert))
+	}
+
+	if a.client.Verifier != nil {
+		root, middle, verified := a.client.Verifier.Certificates()
+		infos = append(infos, payment.NewCertInfo(payment.ChannelUnionPay, "root", root))
+		if middle != nil {
+			infos = append(infos, payment.NewCertInfo(payment.ChannelUnionPay, "middle", middle))
+		}
+		for _, cert := range verified {
+			infos = append(infos, payment.NewCertInfo(payment.ChannelUnionPay, "verify", cert))
+		}
+	}
+	return infos
+}
+
+// CheckKeys 检查签名私钥、签名证书和验签公钥是否已加载
+func (a *Adapter) CheckKeys(ctx context.Context) error {
+	switch {
+	case a.client.PrivateKey == nil:
+		return fmt.Errorf("unionpay sign private key is not loaded")
+	case a.client.SignCert == nil:
+		return fmt.Errorf("unionpay sign cert is not loaded")
+	case a.client.PublicKey == nil && a.client.Verifier == nil:
+		return fmt.Errorf("neither unionpay public key nor root cert is loaded")
+	}
+	return nil
+}
+
 // Pay 实现支付接口
 func (a *Adapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	// H5 和 PC 网页支付通过前台跳转表单完成
+	if req.Scene == payment.SceneH5 || req.Scene == payment.ScenePC {
+		return a.frontPay(ctx, req)
+	}
+
 	// 创建银联订单请求
 	unionpayReq := CreateOrderRequest{
 		OutTradeNo:  req.OutTradeNo,
 		TotalAmount: req.TotalAmount,
 		Subject:     req.Subject,
 		Body:        req.Body,
-		NotifyUrl:   req.NotifyURL,
 		ReturnUrl:   req.ReturnURL,
-		TraceID:     fmt.Sprintf("trace_%s", req.OutTradeNo),
+		TraceID:     tracing.TraceID(ctx),
 		Scene:       string(req.Scene),
+		Attach:      req.Attach,
 	}
 
 	// 调用银联创建订单
 	resp, err := a.client.CreateOrder(ctx, unionpayReq)
 	if err != nil {
-		return &payment.UnifiedPayResponse{
-			Code:    "1",
-			Message: fmt.Sprintf("银联下单失败: %v", err),
-		}, nil
+		return nil, fmt.Errorf("unionpay create order failed: %w", err)
 	}
 
 	// 根据支付场景返回不同的支付数据
@@ -68,6 +115,61 @@ func (a *Adapter) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*pay
 	}, nil
 }
 
+// frontPay 生成前台支付表单，并返回可直接打开的表单地址
+func (a *Adapter) frontPay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	form, err := a.client.BuildFrontForm(ctx, FrontPayRequest{
+		OutTradeNo:  req.OutTradeNo,
+		TotalAmount: req.TotalAmount,
+		Scene:       req.Scene,
+		ReturnURL:   req.ReturnURL,
+		Attach:      req.Attach,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unionpay build front form failed: %w", err)
+	}
+
+	token, err := a.forms.Save(form)
+	if err != nil {
+		return nil, fmt.Errorf("unionpay save front form failed: %w", err)
+	}
+	payURL := fmt.Sprintf("%s/api/v1/pay/form/%s/%s", a.publicURL, payment.ChannelUnionPay, url.PathEscape(token))
+
+	return &payment.UnifiedPayResponse{
+		Code:       "0",
+		Message:    "success",
+		OutTradeNo: req.OutTradeNo,
+		PayData: map[string]string{
+			"pay_url": payURL,
+			"form":    form.HTML,
+		},
+		Channel: payment.ChannelUnionPay,
+		PayURL:  payURL,
+	}, nil
+}
+
+// GetPayForm 按表单令牌获取前台支付表单，令牌不存在或表单已过期时返回 payment.ErrOrderNotFound
+func (a *Adapter) GetPayForm(ctx context.Context, token string) (string, error) {
+	form, ok := a.forms.Get(token)
+	if !ok {
+		return "", payment.ErrOrderNotFound
+	}
+	return form.HTML, nil
+}
+
+// HandleReturn 处理前台同步跳转，返回验签后的结果和商户跳转地址
+func (a *Adapter) HandleReturn(ctx context.Context, data []byte) (*payment.ReturnResult, error) {
+	result, err := a.client.HandleReturn(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+
+	returnResult := &payment.ReturnResult{NotifyResult: *result}
+	if form, ok := a.forms.Lookup(result.OutTradeNo); ok {
+		returnResult.ReturnURL = form.ReturnURL
+	}
+	return returnResult, nil
+}
+
 // HandleNotify 处理异步通知
 func (a *Adapter) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
 	return a.client.HandleNotify(ctx, data)
@@ -78,43 +180,18 @@ func (a *Adapter) GetChannel() payment.ChannelType {
 	return payment.ChannelUnionPay
 }
 
-// Refund 退款接口
+// Refund 退款接口，尚未对接银联退款交易，返回 payment.ErrNotSupported，避免向商户报告未发生的退款
 func (a *Adapter) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResponse, error) {
-	// 实现银联退款逻辑
-	refundTime := time.Now()
-
-	return &payment.RefundResponse{
-		Code:         "0",
-		Message:      "success",
-		RefundID:     fmt.Sprintf("refund_%s", req.OutRefundNo),
-		OutRefundNo:  req.OutRefundNo,
-		RefundAmount: req.RefundAmount,
-		RefundStatus: "SUCCESS",
-		RefundTime:   &refundTime,
-		Channel:      payment.ChannelUnionPay,
-	}, nil
+	return nil, fmt.Errorf("unionpay refund: %w", payment.ErrNotSupported)
 }
 
-// Close 关闭订单接口
+// Close 关闭订单接口，尚未对接银联，返回 payment.ErrNotSupported，避免订单在渠道未关闭时被标记为已关闭
 func (a *Adapter) Close(ctx context.Context, req *payment.CloseRequest) error {
-	// 实现银联关闭订单逻辑
-	// This is a placeholder implementation
-	return nil
+	return fmt.Errorf("unionpay close: %w", payment.ErrNotSupported)
 }
 
-// Query 查询订单
+// Query 查询订单，尚未对接银联交易状态查询，返回 payment.ErrNotSupported
+// 不能返回固定结果，否则手动同步会把未支付的订单标记为已支付；订单状态以银联异步通知为准
 func (a *Adapter) Query(ctx context.Context, req *payment.QueryRequest) (*payment.QueryResponse, error) {
-	// 实现银联查询订单逻辑
-	payTime := time.Now()
-
-	return &payment.QueryResponse{
-		Code:        "0",
-		Message:     "success",
-		OrderID:     req.OrderID,
-		OutTradeNo:  req.OutTradeNo,
-		TradeStatus: payment.TradeStatusSuccess,
-		TotalAmount: 0.01, // placeholder amount
-		PayTime:     &payTime,
-		Channel:     payment.ChannelUnionPay,
-	}, nil
+	return nil, fmt.Errorf("unionpay query: %w", payment.ErrNotSupported)
 }
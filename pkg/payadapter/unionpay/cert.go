@@ -0,0 +1,182 @@
+package unionpay
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// unionPayCNName 生产环境银联签名证书 CN 中的机构名称
+	unionPayCNName = "中国银联股份有限公司"
+	// unionPayTestCNName 测试环境银联签名证书 CN 中的标识
+	unionPayTestCNName = "00040000:SIGN"
+)
+
+// CertID 返回证书的 certId（十进制序列号）
+func CertID(cert *x509.Certificate) string {
+	if cert == nil || cert.SerialNumber == nil {
+		return ""
+	}
+	return cert.SerialNumber.String()
+}
+
+// CertVerifier 银联 5.1.0 验签证书校验器
+// 报文中的 signPubKeyCert 需要通过银联根证书和中级证书的证书链校验后才能用于验签
+type CertVerifier struct {
+	root          *x509.Certificate
+	middle        *x509.Certificate
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	strictCN      bool
+	now           func() time.Time
+
+	mu       sync.RWMutex
+	verified map[string]*verifiedCert // 已校验通过的证书，按 PEM 内容缓存
+}
+
+// verifiedCert 已校验通过的签名证书及证书链的有效期
+// 有效期取证书链中各证书有效期的交集，超出后缓存失效，需要重新校验
+type verifiedCert struct {
+	cert      *x509.Certificate
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// validAt 证书链在 t 时刻是否仍在有效期内
+func (c *verifiedCert) validAt(t time.Time) bool {
+	return !t.Before(c.notBefore) && !t.After(c.notAfter)
+}
+
+// NewCertVerifier 创建证书校验器
+// strictCN 为 true 时只接受生产环境的银联签名证书
+func NewCertVerifier(root, middle *x509.Certificate, strictCN bool) *CertVerifier {
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	intermediates := x509.NewCertPool()
+	if middle != nil {
+		intermediates.AddCert(middle)
+	}
+
+	return &CertVerifier{
+		root:          root,
+		middle:        middle,
+		roots:         roots,
+		intermediates: intermediates,
+		strictCN:      strictCN,
+		now:           time.Now,
+		verified:      make(map[string]*verifiedCert),
+	}
+}
+
+// NewCertVerifierFromFiles 从根证书和中级证书文件创建证书校验器
+func NewCertVerifierFromFiles(rootPath, middlePath string, strictCN bool) (*CertVerifier, error) {
+	root, err := LoadCertificateFromFile(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load root cert: %w", err)
+	}
+
+	var middle *x509.Certificate
+	if middlePath != "" {
+		middle, err = LoadCertificateFromFile(middlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load middle cert: %w", err)
+		}
+	}
+
+	return NewCertVerifier(root, middle, strictCN), nil
+}
+
+// VerifySignPubKeyCert 校验报文中的 signPubKeyCert 并返回其公钥
+// 命中缓存时仍检查证书链的有效期，已过期的证书从缓存中移除并重新校验
+func (v *CertVerifier) VerifySignPubKeyCert(certPEM string) (*rsa.PublicKey, error) {
+	now := v.now()
+	v.mu.RLock()
+	cached, ok := v.verified[certPEM]
+	v.mu.RUnlock()
+
+	if ok && !cached.validAt(now) {
+		v.mu.Lock()
+		delete(v.verified, certPEM)
+		v.mu.Unlock()
+		ok = false
+	}
+
+	if !ok {
+		var err error
+		cached, err = v.verify(certPEM, now)
+		if err != nil {
+			return nil, err
+		}
+
+		v.mu.Lock()
+		v.verified[certPEM] = cached
+		v.mu.Unlock()
+	}
+
+	pub, ok := cached.cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("signPubKeyCert is not RSA public key")
+	}
+	return pub, nil
+}
+
+// verify 校验证书链、now 时刻的有效期和证书持有者
+func (v *CertVerifier) verify(certPEM string, now time.Time) (*verifiedCert, error) {
+	cert, err := ParseCertificate([]byte(certPEM))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signPubKeyCert: %w", err)
+	}
+
+	chains, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: v.intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verify signPubKeyCert chain failed: %w", err)
+	}
+
+	// CN 格式如 041@Z12@中国银联股份有限公司@00000001，第三段为证书持有者
+	cn := certIdentity(cert)
+	if cn != unionPayCNName && (v.strictCN || cn != unionPayTestCNName) {
+		return nil, fmt.Errorf("unexpected signPubKeyCert owner: %q", cert.Subject.CommonName)
+	}
+
+	verified := &verifiedCert{cert: cert, notBefore: cert.NotBefore, notAfter: cert.NotAfter}
+	for _, c := range chains[0] {
+		if c.NotBefore.After(verified.notBefore) {
+			verified.notBefore = c.NotBefore
+		}
+		if c.NotAfter.Before(verified.notAfter) {
+			verified.notAfter = c.NotAfter
+		}
+	}
+	return verified, nil
+}
+
+// certIdentity 从证书 CN 中提取持有者标识
+func certIdentity(cert *x509.Certificate) string {
+	parts := strings.Split(cert.Subject.CommonName, "@")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// Certificates 返回根证书、中级证书以及已校验通过的银联签名证书
+func (v *CertVerifier) Certificates() (root, middle *x509.Certificate, signCerts []*x509.Certificate) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, verified := range v.verified {
+		signCerts = append(signCerts, verified.cert)
+	}
+	return v.root, v.middle, signCerts
+}
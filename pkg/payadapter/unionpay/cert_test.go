@@ -0,0 +1,259 @@
+package unionpay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// testChain 测试用的银联根证书、中级证书和签名证书
+type testChain struct {
+	root, middle, sign *x509.Certificate
+	signKey            *rsa.PrivateKey
+}
+
+// newTestChain 生成根证书 -> 中级证书 -> 签名证书的证书链，签名证书的 CN 为 cn
+func newTestChain(t *testing.T, cn string) *testChain {
+	t.Helper()
+
+	rootKey, middleKey := mustGenerateKey(), mustGenerateKey()
+	root := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CFCA TEST ROOT"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, &rootKey.PublicKey, rootKey)
+	middle := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "CFCA TEST OCA1"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, root, &middleKey.PublicKey, rootKey)
+	sign := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(69629715588),
+		Subject:      pkix.Name{CommonName: cn},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, middle, &testKey.PublicKey, middleKey)
+
+	return &testChain{root: root, middle: middle, sign: sign, signKey: testKey}
+}
+
+// issueCert 签发证书，parent 为空时自签名
+func issueCert(t *testing.T, template, parent *x509.Certificate, pub *rsa.PublicKey, signer *rsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(24 * time.Hour)
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func certPEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+func TestSignDigest(t *testing.T) {
+	params := map[string]string{
+		"version":   "5.1.0",
+		"merId":     "777290058110048",
+		"orderId":   "ORDER20240101001",
+		"txnAmt":    "1234",
+		"signature": "ignored",
+		"empty":     "",
+	}
+
+	signString := buildSignString(params)
+	if want := "merId=777290058110048&orderId=ORDER20240101001&txnAmt=1234&version=5.1.0"; signString != want {
+		t.Fatalf("sign string = %q, want %q", signString, want)
+	}
+
+	// 5.1.0: sha256(hex(sha256(signString)))
+	first := sha256.Sum256([]byte(signString))
+	want := sha256.Sum256([]byte(hex.EncodeToString(first[:])))
+	if got := signDigest(params); string(got) != string(want[:]) {
+		t.Fatalf("digest = %x, want %x", got, want)
+	}
+}
+
+func TestSignParams(t *testing.T) {
+	client := newTestClient()
+	params := map[string]string{"orderId": "ORDER20240101001", "txnAmt": "1234"}
+	if err := client.signParams(params); err != nil {
+		t.Fatal(err)
+	}
+
+	if params["certId"] != client.CertId || params["signMethod"] != signMethodRSA {
+		t.Fatalf("certId = %q, signMethod = %q", params["certId"], params["signMethod"])
+	}
+	if !VerifySign(params, params["signature"], &testKey.PublicKey) {
+		t.Fatal("signature does not verify")
+	}
+
+	// certId 参与签名
+	params["certId"] = "1"
+	if VerifySign(params, params["signature"], &testKey.PublicKey) {
+		t.Fatal("signature verifies after certId changed")
+	}
+	if VerifySign(params, "not base64!", &testKey.PublicKey) || VerifySign(params, params["signature"], nil) {
+		t.Fatal("invalid signature or nil key verifies")
+	}
+}
+
+func TestLoadSignCertFromPfx(t *testing.T) {
+	chain := newTestChain(t, "041@Z12@00040000:SIGN@00000001")
+	pfx, err := pkcs12.Modern.Encode(chain.signKey, chain.sign, []*x509.Certificate{chain.middle}, "000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, cert, err := LoadSignCertFromPfx(pfx, "000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(chain.signKey) {
+		t.Error("private key does not match")
+	}
+	if got := CertID(cert); got != "69629715588" {
+		t.Errorf("certId = %s, want 69629715588", got)
+	}
+
+	if _, _, err := LoadSignCertFromPfx(pfx, "wrong"); err == nil {
+		t.Error("wrong password accepted")
+	}
+	if CertID(nil) != "" {
+		t.Error("certId of nil cert is not empty")
+	}
+}
+
+func TestVerifySignPubKeyCert(t *testing.T) {
+	prod := newTestChain(t, "041@Z12@"+unionPayCNName+"@00000001")
+	test := newTestChain(t, "041@Z12@"+unionPayTestCNName+"@00000001")
+	other := newTestChain(t, "041@Z12@其他机构@00000001")
+
+	cases := []struct {
+		name     string
+		chain    *testChain
+		cert     string
+		strictCN bool
+		wantErr  string
+	}{
+		{"production owner", prod, certPEM(prod.sign), true, ""},
+		{"test owner", test, certPEM(test.sign), false, ""},
+		{"test owner in production", test, certPEM(test.sign), true, "unexpected signPubKeyCert owner"},
+		{"other owner", other, certPEM(other.sign), false, "unexpected signPubKeyCert owner"},
+		{"untrusted chain", prod, certPEM(test.sign), false, "verify signPubKeyCert chain failed"},
+		{"not a certificate", prod, "garbage", false, "invalid signPubKeyCert"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier := NewCertVerifier(tc.chain.root, tc.chain.middle, tc.strictCN)
+			pub, err := verifier.VerifySignPubKeyCert(tc.cert)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !pub.Equal(&tc.chain.signKey.PublicKey) {
+				t.Fatal("public key does not match sign cert")
+			}
+			if _, _, verified := verifier.Certificates(); len(verified) != 1 {
+				t.Fatalf("verified certs = %d, want 1", len(verified))
+			}
+		})
+	}
+}
+
+// TestVerifySignPubKeyCertCacheExpiry 缓存的证书超出有效期后不再使用，从缓存中移除
+func TestVerifySignPubKeyCertCacheExpiry(t *testing.T) {
+	chain := newTestChain(t, "041@Z12@"+unionPayCNName+"@00000001")
+	verifier := NewCertVerifier(chain.root, chain.middle, true)
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	if _, err := verifier.VerifySignPubKeyCert(certPEM(chain.sign)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		at    time.Time
+		valid bool
+	}{
+		{"cached", now.Add(23 * time.Hour), true},
+		{"expired", chain.sign.NotAfter.Add(time.Second), false},
+		{"not yet valid", chain.sign.NotBefore.Add(-time.Second), false},
+		{"valid again", now, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			now = tc.at
+			_, err := verifier.VerifySignPubKeyCert(certPEM(chain.sign))
+			if tc.valid != (err == nil) {
+				t.Fatalf("err = %v, want valid %v", err, tc.valid)
+			}
+			if !tc.valid && !strings.Contains(err.Error(), "verify signPubKeyCert chain failed") {
+				t.Fatalf("err = %v, want chain verification error", err)
+			}
+			want := 0
+			if tc.valid {
+				want = 1
+			}
+			if _, _, verified := verifier.Certificates(); len(verified) != want {
+				t.Fatalf("verified certs = %d, want %d", len(verified), want)
+			}
+		})
+	}
+}
+
+func TestHandleNotifySignPubKeyCert(t *testing.T) {
+	chain := newTestChain(t, "041@Z12@"+unionPayCNName+"@00000001")
+	client := newTestClient()
+	client.PublicKey = nil
+	client.Verifier = NewCertVerifier(chain.root, chain.middle, true)
+
+	params := notifyParams()
+	params["signPubKeyCert"] = certPEM(chain.sign)
+	if _, err := client.HandleNotify(context.Background(), signedForm(t, client, params)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 证书链不可信时不回退到配置的公钥
+	client.PublicKey = &testKey.PublicKey
+	untrusted := newTestChain(t, "041@Z12@"+unionPayCNName+"@00000001")
+	params = notifyParams()
+	params["signPubKeyCert"] = certPEM(untrusted.sign)
+	_, err := client.HandleNotify(context.Background(), signedForm(t, client, params))
+	if !errors.Is(err, payment.ErrNotifyVerifyFailed) {
+		t.Fatalf("err = %v, want %v", err, payment.ErrNotifyVerifyFailed)
+	}
+}
@@ -2,6 +2,7 @@ package unionpay
 
 import (
 	"crypto/rsa"
+	"crypto/x509"
 
 	"github.com/ymqzj/payment-gateway/configs"
 )
@@ -14,11 +15,14 @@ type Config struct {
 	CertPwd        string
 	PrivateKeyPath string
 	PublicKeyPath  string
+	RootCertPath   string // 银联根证书，用于校验 signPubKeyCert
+	MiddleCertPath string // 银联中级证书，用于校验 signPubKeyCert
 	Gateway        string
 	FrontUrl       string
 	BackUrl        string
 	PrivateKey     *rsa.PrivateKey
 	PublicKey      *rsa.PublicKey
+	SignCert       *x509.Certificate // 商户签名证书，序列号即 certId
 }
 
 // NewConfig 从全局配置创建银联配置
@@ -30,6 +34,8 @@ func NewConfig(config *configs.Config) *Config {
 		CertPwd:        config.UnionPay.CertPwd,
 		PrivateKeyPath: config.UnionPay.PrivateKeyPath,
 		PublicKeyPath:  config.UnionPay.PublicKeyPath,
+		RootCertPath:   config.UnionPay.RootCertPath,
+		MiddleCertPath: config.UnionPay.MiddleCertPath,
 		Gateway:        config.UnionPay.Gateway,
 		BackUrl:        config.UnionPay.BackURL,
 		FrontUrl:       config.UnionPay.FrontURL,
@@ -0,0 +1,25 @@
+package unionpay
+
+import (
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// respCodeKinds 银联应答码与标准错误的对应关系，未列出的归为 ErrUnionPayError
+var respCodeKinds = map[string]error{
+	"03": payment.ErrSystemError,      // 交易通讯超时
+	"04": payment.ErrSystemError,      // 交易状态未明
+	"05": payment.ErrSystemError,      // 交易已受理，请稍后查询
+	"06": payment.ErrSystemError,      // 系统繁忙
+	"10": payment.ErrInvalidParameter, // 报文格式错误
+	"11": payment.ErrInvalidSignature, // 验证签名失败
+	"34": payment.ErrOrderNotFound,    // 查无此交易
+}
+
+// classifyError 将银联应答码归类为标准错误
+func classifyError(respCode, respMsg string) error {
+	kind, ok := respCodeKinds[respCode]
+	if !ok {
+		kind = payment.ErrUnionPayError
+	}
+	return payment.NewChannelError(payment.ChannelUnionPay, respCode, respMsg, kind)
+}
@@ -0,0 +1,211 @@
+package unionpay
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+const (
+	// channelTypeMobile 渠道类型: 07 手机网页(H5)
+	channelTypeMobile = "07"
+	// channelTypePC 渠道类型: 08 PC网页
+	channelTypePC = "08"
+
+	// defaultFormTTL 前台支付表单的缓存时长
+	defaultFormTTL = 30 * time.Minute
+)
+
+// frontFormTemplate 自动提交到 frontTransReq.do 的表单页面
+var frontFormTemplate = template.Must(template.New("front").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"><title>银联支付</title></head>
+<body onload="document.forms[0].submit()">
+<form id="pay_form" action="{{.Action}}" method="post">
+{{- range .Fields}}
+<input type="hidden" name="{{.Name}}" value="{{.Value}}"/>
+{{- end}}
+</form>
+</body>
+</html>
+`))
+
+// FrontPayRequest 前台(跳转)支付请求
+type FrontPayRequest struct {
+	OutTradeNo  string           // 商户订单号
+	TotalAmount float64          // 金额（元）
+	Scene       payment.PayScene // 支付场景: h5 / pc
+	ReturnURL   string           // 支付完成后最终跳转的商户页面
+	Attach      string           // 附加数据
+}
+
+// FrontForm 已签名的前台支付表单
+type FrontForm struct {
+	OutTradeNo string
+	HTML       string
+	ReturnURL  string
+	ExpireAt   time.Time
+}
+
+type formField struct {
+	Name  string
+	Value string
+}
+
+// BuildFrontForm 构建自动提交到银联 frontTransReq.do 的签名表单
+func (c *Client) BuildFrontForm(ctx context.Context, req FrontPayRequest) (*FrontForm, error) {
+	channelType := channelTypeMobile
+	if req.Scene == payment.ScenePC {
+		channelType = channelTypePC
+	}
+
+	params := map[string]string{
+		"version":      "5.1.0",
+		"encoding":     "UTF-8",
+		"txnType":      "01",     // 消费
+		"txnSubType":   "01",     // 自助消费
+		"bizType":      "000201", // B2C网关支付
+		"channelType":  channelType,
+		"accessType":   "0",
+		"merId":        c.MerId,
+		"orderId":      req.OutTradeNo,
+		"txnTime":      time.Now().In(cstZone).Format(txnTimeLayout),
+		"txnAmt":       fmt.Sprintf("%.0f", req.TotalAmount*100), // 单位：分
+		"currencyCode": "156",
+		"frontUrl":     c.FrontUrl,
+		"backUrl":      c.BackUrl,
+		"reqReserved":  req.Attach,
+	}
+
+	if err := c.signParams(params); err != nil {
+		return nil, fmt.Errorf("%w: %v", payment.ErrSignatureFailed, err)
+	}
+
+	html, err := renderFrontForm(c.Gateway+"api/frontTransReq.do", params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", payment.ErrUnionPayError, err)
+	}
+
+	return &FrontForm{
+		OutTradeNo: req.OutTradeNo,
+		HTML:       html,
+		ReturnURL:  req.ReturnURL,
+		ExpireAt:   time.Now().Add(defaultFormTTL),
+	}, nil
+}
+
+// renderFrontForm 渲染表单页面，空值字段不提交
+func renderFrontForm(action string, params map[string]string) (string, error) {
+	fields := make([]formField, 0, len(params))
+	for k, v := range params {
+		if v != "" {
+			fields = append(fields, formField{Name: k, Value: v})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	var buf bytes.Buffer
+	err := frontFormTemplate.Execute(&buf, struct {
+		Action string
+		Fields []formField
+	}{Action: action, Fields: fields})
+	if err != nil {
+		return "", fmt.Errorf("render front form failed: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// HandleReturn 处理银联前台通知(frontUrl 同步跳转)
+// 前台通知与后台通知报文格式相同，同样需要验签
+func (c *Client) HandleReturn(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
+	return c.HandleNotify(ctx, data)
+}
+
+// FormStore 前台支付表单的内存缓存，按不可猜测的表单令牌索引
+// 表单页面无需鉴权即可访问，令牌是唯一凭据，不能用商户订单号等可枚举的值代替
+// 由网关持有并注入每次构建的适配器，证书热加载重建适配器后已生成的表单链接仍然有效
+type FormStore struct {
+	mu     sync.Mutex
+	forms  map[string]*FrontForm // 表单令牌 -> 表单
+	tokens map[string]string     // 商户订单号 -> 表单令牌
+}
+
+// NewFormStore 创建前台支付表单缓存
+func NewFormStore() *FormStore {
+	return &FormStore{
+		forms:  make(map[string]*FrontForm),
+		tokens: make(map[string]string),
+	}
+}
+
+// Save 保存表单并返回表单令牌，同一订单重新下单时替换旧表单，同时清理已过期的表单
+func (s *FormStore) Save(form *FrontForm) (string, error) {
+	token, err := newFormToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, f := range s.forms {
+		if now.After(f.ExpireAt) {
+			s.remove(k, f)
+		}
+	}
+	if old, ok := s.tokens[form.OutTradeNo]; ok {
+		s.remove(old, s.forms[old])
+	}
+	s.forms[token] = form
+	s.tokens[form.OutTradeNo] = token
+	return token, nil
+}
+
+// Get 按表单令牌获取未过期的表单
+func (s *FormStore) Get(token string) (*FrontForm, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form, ok := s.forms[token]
+	if !ok || time.Now().After(form.ExpireAt) {
+		return nil, false
+	}
+	return form, true
+}
+
+// Lookup 按商户订单号获取未过期的表单，用于前台通知验签后取回商户跳转地址
+func (s *FormStore) Lookup(outTradeNo string) (*FrontForm, bool) {
+	s.mu.Lock()
+	token, ok := s.tokens[outTradeNo]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return s.Get(token)
+}
+
+// remove 删除表单及其订单索引，调用方需持有锁
+func (s *FormStore) remove(token string, form *FrontForm) {
+	delete(s.forms, token)
+	if form != nil && s.tokens[form.OutTradeNo] == token {
+		delete(s.tokens, form.OutTradeNo)
+	}
+}
+
+// newFormToken 生成 128 位随机表单令牌
+func newFormToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate form token failed: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
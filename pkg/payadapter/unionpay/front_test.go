@@ -0,0 +1,203 @@
+package unionpay
+
+import (
+	"context"
+	"errors"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// formToken 支付地址中的表单令牌
+var formToken = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// formInput 表单页面中的隐藏字段
+var formInput = regexp.MustCompile(`<input type="hidden" name="([^"]+)" value="([^"]*)"/>`)
+
+// parseFrontForm 从表单页面中解析提交地址和字段
+func parseFrontForm(t *testing.T, page string) (string, map[string]string) {
+	t.Helper()
+
+	action := regexp.MustCompile(`action="([^"]+)"`).FindStringSubmatch(page)
+	if action == nil {
+		t.Fatalf("no form action in %s", page)
+	}
+	fields := make(map[string]string)
+	for _, m := range formInput.FindAllStringSubmatch(page, -1) {
+		fields[m[1]] = html.UnescapeString(m[2])
+	}
+	return html.UnescapeString(action[1]), fields
+}
+
+func TestBuildFrontForm(t *testing.T) {
+	client := newTestClient()
+
+	for scene, channelType := range map[payment.PayScene]string{payment.SceneH5: channelTypeMobile, payment.ScenePC: channelTypePC} {
+		t.Run(string(scene), func(t *testing.T) {
+			form, err := client.BuildFrontForm(context.Background(), FrontPayRequest{
+				OutTradeNo:  "ORDER20240101001",
+				TotalAmount: 12.34,
+				Scene:       scene,
+				ReturnURL:   "https://merchant.example.com/done",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			action, fields := parseFrontForm(t, form.HTML)
+			if action != SANDBOX_GATEWAY+"api/frontTransReq.do" {
+				t.Errorf("action = %s", action)
+			}
+			for k, want := range map[string]string{
+				"channelType": channelType,
+				"orderId":     "ORDER20240101001",
+				"txnAmt":      "1234",
+				"frontUrl":    client.FrontUrl,
+				"backUrl":     client.BackUrl,
+				"certId":      client.CertId,
+				"version":     "5.1.0",
+			} {
+				if fields[k] != want {
+					t.Errorf("%s = %q, want %q", k, fields[k], want)
+				}
+			}
+			if _, ok := fields["reqReserved"]; ok {
+				t.Error("empty reqReserved is submitted")
+			}
+			if !VerifySign(fields, fields["signature"], &testKey.PublicKey) {
+				t.Error("form signature does not verify")
+			}
+			if form.ReturnURL != "https://merchant.example.com/done" || !form.ExpireAt.After(time.Now()) {
+				t.Errorf("return_url = %s, expire_at = %s", form.ReturnURL, form.ExpireAt)
+			}
+		})
+	}
+}
+
+// TestAdapterFrontPay 支付地址使用随机表单令牌，不能按商户订单号获取表单
+func TestAdapterFrontPay(t *testing.T) {
+	adapter := &Adapter{
+		client:    newTestClient(),
+		forms:     NewFormStore(),
+		publicURL: "https://gateway.example.com",
+	}
+
+	pay := func(outTradeNo string) string {
+		t.Helper()
+		resp, err := adapter.Pay(context.Background(), &payment.UnifiedPayRequest{
+			Channel:     payment.ChannelUnionPay,
+			OutTradeNo:  outTradeNo,
+			TotalAmount: 1,
+			Scene:       payment.ScenePC,
+			ReturnURL:   "https://merchant.example.com/done",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, ok := strings.CutPrefix(resp.PayURL, "https://gateway.example.com/api/v1/pay/form/unionpay/")
+		if !ok || !formToken.MatchString(token) {
+			t.Fatalf("pay_url = %s, want form token", resp.PayURL)
+		}
+		return token
+	}
+	token := pay("ORDER/1")
+
+	page, err := adapter.GetPayForm(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, fields := parseFrontForm(t, page); fields["orderId"] != "ORDER/1" {
+		t.Fatalf("orderId = %s", fields["orderId"])
+	}
+	for _, guess := range []string{"ORDER/1", "ORDER%2F1", "ORDER/2", ""} {
+		if _, err := adapter.GetPayForm(context.Background(), guess); !errors.Is(err, payment.ErrOrderNotFound) {
+			t.Fatalf("GetPayForm(%q) err = %v, want %v", guess, err, payment.ErrOrderNotFound)
+		}
+	}
+
+	// 重新下单生成新令牌，旧链接失效
+	if again := pay("ORDER/1"); again == token {
+		t.Fatal("token reused for a new form")
+	} else if _, err := adapter.GetPayForm(context.Background(), again); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adapter.GetPayForm(context.Background(), token); !errors.Is(err, payment.ErrOrderNotFound) {
+		t.Fatalf("replaced form err = %v, want %v", err, payment.ErrOrderNotFound)
+	}
+
+	// 前台通知验签后带回下单时的商户跳转地址
+	params := notifyParams()
+	params["orderId"] = "ORDER/1"
+	result, err := adapter.HandleReturn(context.Background(), signedForm(t, adapter.client, params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReturnURL != "https://merchant.example.com/done" || !result.Success {
+		t.Fatalf("return_url = %s, success = %v", result.ReturnURL, result.Success)
+	}
+
+	other := newTestClient()
+	other.PrivateKey = mustGenerateKey()
+	if _, err := adapter.HandleReturn(context.Background(), signedForm(t, other, notifyParams())); !errors.Is(err, payment.ErrInvalidSignature) {
+		t.Fatalf("err = %v, want %v", err, payment.ErrInvalidSignature)
+	}
+}
+
+// TestAdapterFormStore 表单缓存由创建方注入，适配器之间不共享
+func TestAdapterFormStore(t *testing.T) {
+	shared := NewFormStore()
+	token, err := shared.Save(&FrontForm{OutTradeNo: "ORDER_1", HTML: "<form></form>", ExpireAt: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 热加载重建的适配器注入同一个缓存，已生成的链接仍然有效
+	rebuilt := &Adapter{client: newTestClient(), forms: shared}
+	if page, err := rebuilt.GetPayForm(context.Background(), token); err != nil || page != "<form></form>" {
+		t.Fatalf("page = %q, err = %v", page, err)
+	}
+	other := &Adapter{client: newTestClient(), forms: NewFormStore()}
+	if _, err := other.GetPayForm(context.Background(), token); !errors.Is(err, payment.ErrOrderNotFound) {
+		t.Fatalf("err = %v, want %v", err, payment.ErrOrderNotFound)
+	}
+}
+
+func TestFormStoreExpiry(t *testing.T) {
+	store := NewFormStore()
+	save := func(outTradeNo string, ttl time.Duration) string {
+		t.Helper()
+		token, err := store.Save(&FrontForm{OutTradeNo: outTradeNo, ExpireAt: time.Now().Add(ttl)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	expired := save("expired", -time.Second)
+	valid := save("valid", time.Minute)
+
+	if _, ok := store.Get(expired); ok {
+		t.Error("expired form returned")
+	}
+	if _, ok := store.Lookup("expired"); ok {
+		t.Error("expired form returned by out_trade_no")
+	}
+	if _, ok := store.Get(valid); !ok {
+		t.Error("valid form not returned")
+	}
+	if form, ok := store.Lookup("valid"); !ok || form.OutTradeNo != "valid" {
+		t.Error("valid form not returned by out_trade_no")
+	}
+
+	// 保存时清理过期表单及其订单索引
+	save("other", time.Minute)
+	if _, ok := store.forms[expired]; ok {
+		t.Error("expired form not removed")
+	}
+	if _, ok := store.tokens["expired"]; ok {
+		t.Error("expired form index not removed")
+	}
+}
@@ -2,51 +2,130 @@
 package unionpay
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
-	"go.uber.org/zap"
+	"github.com/ymqzj/payment-gateway/internal/payment"
 )
 
-func (c *Client) HandleNotifyRequest(r *http.Request) (map[string]string, error) {
-	log := zap.NewExample().Sugar()
-	defer log.Sync()
+const (
+	// txnTimeLayout 银联交易时间格式: YYYYMMDDHHMMSS
+	txnTimeLayout = "20060102150405"
+)
+
+// cstZone 银联报文中的时间均为北京时间
+var cstZone = time.FixedZone("CST", 8*60*60)
 
-	err := r.ParseForm()
+// HandleNotify 处理银联后台异步通知
+// 银联以 application/x-www-form-urlencoded 方式 POST 通知报文
+func (c *Client) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
+	params, err := parseFormParams(data)
 	if err != nil {
-		log.Error("解析表单失败", zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", payment.ErrInvalidNotify, err)
+	}
+
+	if err := c.verifyParams(params); err != nil {
 		return nil, err
 	}
 
-	params := make(map[string]string)
-	for k, v := range r.Form {
+	return buildNotifyResult(params), nil
+}
+
+// HandleNotifyRequest 从 HTTP 请求中处理银联异步通知
+func (c *Client) HandleNotifyRequest(r *http.Request) (*payment.NotifyResult, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read notify body failed: %w", err)
+	}
+
+	return c.HandleNotify(r.Context(), data)
+}
+
+// parseFormParams 解析表单报文，每个字段只取第一个值
+func parseFormParams(data []byte) (map[string]string, error) {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse form failed: %w", err)
+	}
+
+	params := make(map[string]string, len(values))
+	for k, v := range values {
 		if len(v) > 0 {
 			params[k] = v[0]
 		}
 	}
 
-	// 验签
+	if len(params) == 0 {
+		return nil, fmt.Errorf("empty notify body")
+	}
+
+	return params, nil
+}
+
+// verifyParams 验证报文签名
+// 5.1.0 报文携带 signPubKeyCert，校验证书链后使用其公钥验签；否则回退到配置的银联公钥
+func (c *Client) verifyParams(params map[string]string) error {
 	signature := params["signature"]
-	delete(params, "signature") // 验签时排除 signature 字段
+	if signature == "" {
+		return fmt.Errorf("%w: missing signature", payment.ErrNotifyVerifyFailed)
+	}
 
-	if !VerifySign(params, signature, c.PublicKey) {
-		log.Error("银联回调验签失败", zap.Any("params", params))
-		return nil, errors.New("invalid signature")
+	publicKey := c.PublicKey
+	if certPEM := params["signPubKeyCert"]; certPEM != "" && c.Verifier != nil {
+		pub, err := c.Verifier.VerifySignPubKeyCert(certPEM)
+		if err != nil {
+			return fmt.Errorf("%w: %v", payment.ErrNotifyVerifyFailed, err)
+		}
+		publicKey = pub
 	}
 
-	// 检查状态
-	if params["respCode"] == "00" && params["queryRespCode"] == "00" {
-		log.Info("银联支付成功回调",
-			zap.String("orderId", params["orderId"]),
-			zap.String("merOrderId", params["merOrderId"]),
-			zap.String("txnAmt", params["txnAmt"]),
-		)
-	} else {
-		log.Warn("银联支付未成功",
-			zap.String("respCode", params["respCode"]),
-			zap.String("respMsg", params["respMsg"]),
-		)
+	if publicKey == nil {
+		return fmt.Errorf("%w: no public key to verify signature", payment.ErrNotifyVerifyFailed)
 	}
 
-	return params, nil
+	if !VerifySign(params, signature, publicKey) {
+		return payment.ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// buildNotifyResult 将银联通知报文映射为统一通知结果
+func buildNotifyResult(params map[string]string) *payment.NotifyResult {
+	result := &payment.NotifyResult{
+		Channel:    payment.ChannelUnionPay,
+		OutTradeNo: params["orderId"], // 商户订单号
+		OrderID:    params["queryId"], // 银联交易流水号
+		Attach:     params["reqReserved"],
+	}
+
+	// 银联金额单位是分，需要转换为元
+	if txnAmt := params["txnAmt"]; txnAmt != "" {
+		if amount, err := strconv.ParseInt(txnAmt, 10, 64); err == nil {
+			result.TotalAmount = float64(amount) / 100
+		}
+	}
+
+	if txnTime := params["txnTime"]; txnTime != "" {
+		if t, err := time.ParseInLocation(txnTimeLayout, txnTime, cstZone); err == nil {
+			result.PayTime = &t
+		}
+	}
+
+	// 00: 成功; A6: 部分成功
+	switch params["respCode"] {
+	case "00", "A6":
+		result.Success = true
+		result.TradeStatus = string(payment.TradeStatusSuccess)
+	default:
+		result.Success = false
+		result.TradeStatus = string(payment.TradeStatusPayError)
+	}
+
+	return result
 }
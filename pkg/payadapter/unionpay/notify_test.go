@@ -0,0 +1,164 @@
+package unionpay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// testKey 测试用的签名密钥，所有用例共用以减少生成密钥的耗时
+var testKey = mustGenerateKey()
+
+func mustGenerateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// newTestClient 使用 testKey 签名并用其公钥验签的客户端
+func newTestClient() *Client {
+	return &Client{
+		MerId:      "777290058110048",
+		CertId:     "69629715588",
+		PrivateKey: testKey,
+		PublicKey:  &testKey.PublicKey,
+		Gateway:    SANDBOX_GATEWAY,
+		FrontUrl:   "https://gateway.example.com/api/v1/return/unionpay",
+		BackUrl:    "https://gateway.example.com/api/v1/notify/unionpay",
+	}
+}
+
+// signedForm 对报文签名并编码为表单
+func signedForm(t *testing.T, c *Client, params map[string]string) []byte {
+	t.Helper()
+
+	if err := c.signParams(params); err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	return []byte(values.Encode())
+}
+
+func notifyParams() map[string]string {
+	return map[string]string{
+		"version":     "5.1.0",
+		"encoding":    "UTF-8",
+		"merId":       "777290058110048",
+		"orderId":     "ORDER20240101001",
+		"queryId":     "732402191658289183008",
+		"txnAmt":      "1234",
+		"txnTime":     "20240101120000",
+		"reqReserved": "attach-data",
+		"respCode":    "00",
+		"respMsg":     "success",
+	}
+}
+
+func TestHandleNotify(t *testing.T) {
+	client := newTestClient()
+
+	result, err := client.HandleNotify(context.Background(), signedForm(t, client, notifyParams()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payTime := time.Date(2024, 1, 1, 12, 0, 0, 0, cstZone)
+	switch {
+	case result.Channel != payment.ChannelUnionPay:
+		t.Errorf("channel = %s", result.Channel)
+	case result.OutTradeNo != "ORDER20240101001" || result.OrderID != "732402191658289183008":
+		t.Errorf("out_trade_no = %s, order_id = %s", result.OutTradeNo, result.OrderID)
+	case result.TotalAmount != 12.34:
+		t.Errorf("total_amount = %v, want 12.34", result.TotalAmount)
+	case result.PayTime == nil || !result.PayTime.Equal(payTime):
+		t.Errorf("pay_time = %v, want %v", result.PayTime, payTime)
+	case result.Attach != "attach-data":
+		t.Errorf("attach = %s", result.Attach)
+	case !result.Success || result.TradeStatus != string(payment.TradeStatusSuccess):
+		t.Errorf("success = %v, trade_status = %s", result.Success, result.TradeStatus)
+	}
+}
+
+func TestHandleNotifyRespCode(t *testing.T) {
+	client := newTestClient()
+
+	for code, success := range map[string]bool{"00": true, "A6": true, "01": false, "99": false} {
+		params := notifyParams()
+		params["respCode"] = code
+
+		result, err := client.HandleNotify(context.Background(), signedForm(t, client, params))
+		if err != nil {
+			t.Fatalf("respCode %s: %v", code, err)
+		}
+		if result.Success != success {
+			t.Errorf("respCode %s: success = %v, want %v", code, result.Success, success)
+		}
+		if !success && result.TradeStatus != string(payment.TradeStatusPayError) {
+			t.Errorf("respCode %s: trade_status = %s", code, result.TradeStatus)
+		}
+	}
+}
+
+func TestHandleNotifyRejected(t *testing.T) {
+	client := newTestClient()
+	other := newTestClient()
+	other.PrivateKey = mustGenerateKey()
+
+	tampered := func() []byte {
+		params := notifyParams()
+		if err := client.signParams(params); err != nil {
+			t.Fatal(err)
+		}
+		params["txnAmt"] = "1"
+		values := url.Values{}
+		for k, v := range params {
+			values.Set(k, v)
+		}
+		return []byte(values.Encode())
+	}
+
+	cases := []struct {
+		name string
+		body []byte
+		want error
+	}{
+		{"empty body", nil, payment.ErrInvalidNotify},
+		{"malformed form", []byte("a=%zz"), payment.ErrInvalidNotify},
+		{"json body", []byte(`{"orderId":"ORDER20240101001"}`), payment.ErrNotifyVerifyFailed},
+		{"missing signature", []byte(url.Values{"orderId": {"ORDER20240101001"}}.Encode()), payment.ErrNotifyVerifyFailed},
+		{"tampered amount", tampered(), payment.ErrInvalidSignature},
+		{"signed by other key", signedForm(t, other, notifyParams()), payment.ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := client.HandleNotify(context.Background(), tc.body)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			if result != nil {
+				t.Errorf("result = %+v, want nil", result)
+			}
+		})
+	}
+}
+
+func TestHandleNotifyWithoutPublicKey(t *testing.T) {
+	client := newTestClient()
+	body := signedForm(t, client, notifyParams())
+	client.PublicKey = nil
+
+	if _, err := client.HandleNotify(context.Background(), body); !errors.Is(err, payment.ErrNotifyVerifyFailed) {
+		t.Fatalf("err = %v, want %v", err, payment.ErrNotifyVerifyFailed)
+	}
+}
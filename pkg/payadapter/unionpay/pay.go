@@ -4,15 +4,20 @@ package unionpay
 import (
 	"context"
 	"crypto/rsa"
-	"encoding/json"
-	"errors"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/audit"
 	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	logger "github.com/ymqzj/payment-gateway/logs"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
 	"go.uber.org/zap"
 )
 
@@ -24,21 +29,29 @@ const (
 type Client struct {
 	MerId      string
 	AppId      string
+	CertId     string // 签名证书序列号
 	PrivateKey *rsa.PrivateKey
-	PublicKey  *rsa.PublicKey // 银联公钥，用于验签
+	PublicKey  *rsa.PublicKey // 银联公钥，报文未携带 signPubKeyCert 时用于验签
+	Verifier   *CertVerifier  // 5.1.0 signPubKeyCert 证书链校验器
+	SignCert   *x509.Certificate
 	Gateway    string
 	FrontUrl   string
 	BackUrl    string
 }
 
+// httpClient 调用银联网关的 HTTP 客户端，请求作为网关调用 span 的子 span 上报，报文写入审计记录
+var httpClient = &http.Client{Transport: tracing.Transport(audit.Transport(nil))}
+
 func NewClient(config *Config) *Client {
 	gateway := SANDBOX_GATEWAY
-	if config.Gateway == "prod" {
+	if config.Gateway == configs.UnionPayGatewayProd {
 		gateway = PROD_GATEWAY
 	}
 	return &Client{
 		MerId:      config.MerId,
 		AppId:      config.AppId,
+		CertId:     CertID(config.SignCert),
+		SignCert:   config.SignCert,
 		PrivateKey: config.PrivateKey,
 		PublicKey:  config.PublicKey,
 		Gateway:    gateway,
@@ -47,45 +60,120 @@ func NewClient(config *Config) *Client {
 	}
 }
 
-// NewClientWithKeys 创建新的客户端，自动从文件加载私钥和公钥
-func NewClientWithKeys(config *Config) (*Client, error) {
+// NewClientWithKeys 创建新的客户端，通过 secrets 加载签名证书、私钥和验签证书
+// 配置项可以是本地文件路径，也可以是机密引用
+func NewClientWithKeys(config *Config, secrets secret.SecretProvider) (*Client, error) {
 	gateway := SANDBOX_GATEWAY
-	if config.Gateway == "prod" {
+	if config.Gateway == configs.UnionPayGatewayProd {
 		gateway = PROD_GATEWAY
 	}
+	if secrets == nil {
+		secrets = secret.NewRegistry()
+	}
+
+	ctx := context.Background()
 
-	// 加载私钥
-	privateKey, err := LoadPrivateKeyFromFile(config.PrivateKeyPath, config.CertPwd)
+	certPwd, err := secret.LoadValue(ctx, secrets, config.CertPwd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load private key: %w", err)
+		return nil, fmt.Errorf("failed to load cert password: %w", err)
 	}
 
-	// 加载公钥
-	publicKey, err := LoadPublicKeyFromFile(config.PublicKeyPath)
+	// 加载签名私钥: 优先使用 PFX 签名证书，可同时得到 certId
+	var (
+		privateKey *rsa.PrivateKey
+		signCert   *x509.Certificate
+	)
+	if config.CertPath != "" {
+		var data []byte
+		data, err = secret.LoadFile(ctx, secrets, config.CertPath)
+		if err == nil {
+			privateKey, signCert, err = LoadSignCertFromPfx(data, certPwd)
+		}
+	} else {
+		var data []byte
+		data, err = secret.LoadFile(ctx, secrets, config.PrivateKeyPath)
+		if err == nil {
+			privateKey, err = LoadPrivateKeyFromPfx(data, certPwd)
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to load public key: %w", err)
+		return nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+
+	// 5.1.0 验签: 使用根证书和中级证书校验报文中的 signPubKeyCert
+	var verifier *CertVerifier
+	if config.RootCertPath != "" {
+		verifier, err = loadCertVerifier(ctx, secrets, config, gateway == PROD_GATEWAY)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load verify certs: %w", err)
+		}
+	}
+
+	// 兼容静态银联公钥文件
+	var publicKey *rsa.PublicKey
+	if config.PublicKeyPath != "" {
+		var data []byte
+		data, err = secret.LoadFile(ctx, secrets, config.PublicKeyPath)
+		if err == nil {
+			publicKey, err = ParsePublicKey(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key: %w", err)
+		}
+	}
+
+	if verifier == nil && publicKey == nil {
+		return nil, fmt.Errorf("either root_cert_path or public_key_path is required for signature verification")
 	}
 
 	return &Client{
 		MerId:      config.MerId,
 		AppId:      config.AppId,
+		CertId:     CertID(signCert),
 		PrivateKey: privateKey,
 		PublicKey:  publicKey,
+		Verifier:   verifier,
+		SignCert:   signCert,
 		Gateway:    gateway,
 		FrontUrl:   config.FrontUrl,
 		BackUrl:    config.BackUrl,
 	}, nil
 }
 
+// loadCertVerifier 加载银联根证书和中级证书
+func loadCertVerifier(ctx context.Context, secrets secret.SecretProvider, config *Config, strictCN bool) (*CertVerifier, error) {
+	data, err := secret.LoadFile(ctx, secrets, config.RootCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load root cert: %w", err)
+	}
+	root, err := ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load root cert: %w", err)
+	}
+
+	var middle *x509.Certificate
+	if config.MiddleCertPath != "" {
+		data, err = secret.LoadFile(ctx, secrets, config.MiddleCertPath)
+		if err == nil {
+			middle, err = ParseCertificate(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load middle cert: %w", err)
+		}
+	}
+
+	return NewCertVerifier(root, middle, strictCN), nil
+}
+
 type CreateOrderRequest struct {
 	OutTradeNo  string  // 商户订单号
 	TotalAmount float64 // 金额（元）
 	Subject     string  // 商品标题
 	Body        string  // 商品描述
-	NotifyUrl   string  // 异步回调
 	ReturnUrl   string  // 同步跳转
-	TraceID     string  // 全链路追踪ID
+	TraceID     string  // 全链路追踪ID，取自请求上下文中的 OpenTelemetry trace ID
 	Scene       string  // 支付场景
+	Attach      string  // 附加数据，通过 reqReserved 原样返回
 }
 
 type CreateOrderResponse struct {
@@ -96,10 +184,7 @@ type CreateOrderResponse struct {
 }
 
 func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*CreateOrderResponse, error) {
-	// Create a simple logger
-	log := zap.NewExample().Sugar()
-	defer log.Sync()
-
+	log := logger.FromContext(ctx).With(zap.String("channel", "unionpay"), zap.String("out_trade_no", req.OutTradeNo))
 	log.Info("开始创建银联支付订单")
 
 	params := map[string]string{
@@ -113,7 +198,7 @@ func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Crea
 		"txnAmt":       fmt.Sprintf("%.0f", req.TotalAmount*100), // 单位：分
 		"currencyCode": "156",
 		"orderDesc":    req.Subject,
-		"reqReserved":  req.Body,
+		"reqReserved":  req.Attach,
 		"backUrl":      c.BackUrl,
 		"frontUrl":     c.FrontUrl,
 		"channelType":  "07", // 07=移动端，08=PC
@@ -122,12 +207,10 @@ func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Crea
 	}
 
 	// 生成签名
-	sign, err := GenerateSign(params, c.PrivateKey)
-	if err != nil {
-		log.Error("生成签名失败", err)
-		return nil, fmt.Errorf("generate sign failed: %w", err)
+	if err := c.signParams(params); err != nil {
+		log.Error("生成签名失败", zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", payment.ErrSignatureFailed, err)
 	}
-	params["signature"] = sign
 
 	// POST 请求到银联
 	formData := url.Values{}
@@ -135,41 +218,52 @@ func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Crea
 		formData.Set(k, v)
 	}
 
-	resp, err := http.PostForm(c.Gateway+"api/PayTransReq.do", formData)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Gateway+"api/PayTransReq.do", strings.NewReader(formData.Encode()))
 	if err != nil {
-		log.Error("请求银联网关失败", err)
-		return nil, fmt.Errorf("request unionpay failed: %w", err)
+		return nil, fmt.Errorf("%w: build request failed: %v", payment.ErrUnionPayError, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		log.Warn("请求银联网关失败", zap.Error(err))
+		return nil, fmt.Errorf("request unionpay failed: %w", payment.ClassifyTransportError(err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		log.Warn("银联网关返回异常状态", zap.Int("status", resp.StatusCode))
+		return nil, fmt.Errorf("request unionpay failed: %w",
+			payment.ClassifyHTTPStatus(payment.ChannelUnionPay, resp.StatusCode, payment.ErrUnionPayError))
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Error("读取响应体失败", err)
-		return nil, err
+		log.Warn("读取响应体失败", zap.Error(err))
+		return nil, fmt.Errorf("read unionpay response failed: %w", payment.ClassifyTransportError(err))
 	}
 
-	var result map[string]string
-	err = json.Unmarshal(body, &result)
+	// 银联同步应答同样为 key=value&key=value 格式
+	result, err := parseFormParams(body)
 	if err != nil {
-		log.Error("解析银联响应失败", string(body), err)
-		return nil, err
+		log.Error("解析银联响应失败", zap.Int("body_size", len(body)), zap.Error(err))
+		return nil, fmt.Errorf("%w: %v", payment.ErrUnionPayError, err)
 	}
 
 	// 验签
-	if !VerifySign(result, result["signature"], c.PublicKey) {
-		log.Error("银联响应验签失败", result)
-		return nil, errors.New("verify signature failed")
+	if err := c.verifyParams(result); err != nil {
+		log.Error("银联响应验签失败", zap.Any("response", result), zap.Error(err))
+		return nil, fmt.Errorf("%w: verify response failed: %v", payment.ErrInvalidSignature, err)
 	}
 
 	if result["respCode"] != "00" {
-		log.Error("银联下单失败",
-			"respCode", result["respCode"],
-			"respMsg", result["respMsg"],
-		)
-		return nil, fmt.Errorf("unionpay error: %s", result["respMsg"])
+		log.Warn("银联下单失败",
+			zap.String("resp_code", result["respCode"]),
+			zap.String("resp_msg", result["respMsg"]))
+		return nil, classifyError(result["respCode"], result["respMsg"])
 	}
 
-	log.Info("银联下单成功", "tn", result["tn"])
+	log.Info("银联下单成功", zap.String("order_id", result["orderId"]))
 
 	return &CreateOrderResponse{
 		Tn:         result["tn"],
@@ -181,25 +275,46 @@ func (c *Client) CreateOrder(ctx context.Context, req CreateOrderRequest) (*Crea
 
 // Pay 实现支付接口
 func (c *Client) Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error) {
+	// H5和PC支付返回自动提交的前台表单
+	if req.Scene == payment.SceneH5 || req.Scene == payment.ScenePC {
+		form, err := c.BuildFrontForm(ctx, FrontPayRequest{
+			OutTradeNo:  req.OutTradeNo,
+			TotalAmount: req.TotalAmount,
+			Scene:       req.Scene,
+			ReturnURL:   req.ReturnURL,
+			Attach:      req.Attach,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unionpay build front form failed: %w", err)
+		}
+
+		return &payment.UnifiedPayResponse{
+			Code:       "0",
+			Message:    "success",
+			OutTradeNo: req.OutTradeNo,
+			PayData: map[string]string{
+				"form": form.HTML,
+			},
+			Channel: payment.ChannelUnionPay,
+		}, nil
+	}
+
 	// 创建银联订单请求
 	unionpayReq := CreateOrderRequest{
 		OutTradeNo:  req.OutTradeNo,
 		TotalAmount: req.TotalAmount,
 		Subject:     req.Subject,
 		Body:        req.Body,
-		NotifyUrl:   req.NotifyURL,
 		ReturnUrl:   req.ReturnURL,
-		TraceID:     fmt.Sprintf("trace_%s", req.OutTradeNo),
+		TraceID:     tracing.TraceID(ctx),
 		Scene:       string(req.Scene),
+		Attach:      req.Attach,
 	}
 
 	// 调用银联创建订单
 	resp, err := c.CreateOrder(ctx, unionpayReq)
 	if err != nil {
-		return &payment.UnifiedPayResponse{
-			Code:    "1",
-			Message: fmt.Sprintf("银联下单失败: %v", err),
-		}, nil
+		return nil, fmt.Errorf("unionpay create order failed: %w", err)
 	}
 
 	// 根据支付场景返回不同的支付数据
@@ -223,11 +338,6 @@ func (c *Client) buildPayData(resp *CreateOrderResponse, scene payment.PayScene)
 		return map[string]string{
 			"tn": resp.Tn,
 		}
-	case payment.SceneH5, payment.ScenePC:
-		// H5和PC支付返回支付URL
-		return map[string]string{
-			"pay_url": fmt.Sprintf("%s?tn=%s", c.Gateway, resp.Tn),
-		}
 	case payment.SceneNative:
 		// 扫码支付返回二维码链接
 		return map[string]string{
@@ -241,70 +351,6 @@ func (c *Client) buildPayData(resp *CreateOrderResponse, scene payment.PayScene)
 	}
 }
 
-// HandleNotify 处理异步通知
-func (c *Client) HandleNotify(ctx context.Context, data []byte) (*payment.NotifyResult, error) {
-	// 解析通知数据
-	params := make(map[string]string)
-	err := json.Unmarshal(data, &params)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse notify data: %w", err)
-	}
-
-	// 验签
-	signature := params["signature"]
-	delete(params, "signature") // 验签时排除 signature 字段
-
-	if !VerifySign(params, signature, c.PublicKey) {
-		return nil, errors.New("invalid signature")
-	}
-
-	// 检查响应码
-	respCode := params["respCode"]
-	queryRespCode := params["queryRespCode"]
-
-	// 构造返回结果
-	result := &payment.NotifyResult{
-		Channel: payment.ChannelUnionPay,
-	}
-
-	// 设置订单号
-	if orderId := params["orderId"]; orderId != "" {
-		result.OutTradeNo = orderId
-	}
-
-	// 设置银联订单号
-	if tn := params["tn"]; tn != "" {
-		result.OrderID = tn
-	}
-
-	// 解析支付金额
-	if txnAmt := params["txnAmt"]; txnAmt != "" {
-		// 银联金额单位是分，需要转换为元
-		if amount, err := fmt.Sscanf(txnAmt, "%f", &result.TotalAmount); err == nil && amount == 1 {
-			result.TotalAmount = result.TotalAmount / 100.0
-		}
-	}
-
-	// 解析支付时间
-	if payTimeStr := params["payTime"]; payTimeStr != "" {
-		// 银联时间格式: YYYYMMDDHHMMSS
-		if payTime, err := time.Parse("20060102150405", payTimeStr); err == nil {
-			result.PayTime = &payTime
-		}
-	}
-
-	// 判断支付是否成功
-	if (respCode == "00" || respCode == "") && (queryRespCode == "00" || queryRespCode == "") {
-		result.Success = true
-		result.TradeStatus = string(payment.TradeStatusSuccess)
-	} else {
-		result.Success = false
-		result.TradeStatus = string(payment.TradeStatusPayError)
-	}
-
-	return result, nil
-}
-
 // GetChannel 获取渠道标识
 func (c *Client) GetChannel() payment.ChannelType {
 	return payment.ChannelUnionPay
@@ -0,0 +1,159 @@
+package unionpay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// respondWith 模拟银联网关的同步应答
+func respondWith(t *testing.T, status int, body func(c *Client) []byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/PayTransReq.do" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write(body(newTestClient()))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// signedResponse 银联签名的同步应答
+func signedResponse(t *testing.T, respCode string) func(c *Client) []byte {
+	return func(c *Client) []byte {
+		return signedForm(t, c, map[string]string{
+			"version":  "5.1.0",
+			"orderId":  "ORDER1",
+			"tn":       "768212345678901234567",
+			"respCode": respCode,
+			"respMsg":  "resp " + respCode,
+		})
+	}
+}
+
+func TestAdapterPay(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   func(c *Client) []byte
+		err    error
+		code   string
+	}{
+		{"success", http.StatusOK, signedResponse(t, "00"), nil, "0"},
+		{"system busy", http.StatusOK, signedResponse(t, "06"), payment.ErrSystemError, "2008"},
+		{"invalid parameter", http.StatusOK, signedResponse(t, "10"), payment.ErrInvalidParameter, "1005"},
+		{"unlisted resp code", http.StatusOK, signedResponse(t, "12"), payment.ErrUnionPayError, "2003"},
+		{"tampered response", http.StatusOK, func(c *Client) []byte {
+			values, _ := url.ParseQuery(string(signedResponse(t, "00")(c)))
+			values.Set("tn", "1")
+			return []byte(values.Encode())
+		}, payment.ErrInvalidSignature, "3001"},
+		{"unsigned response", http.StatusOK, func(c *Client) []byte {
+			return []byte("respCode=00&tn=1")
+		}, payment.ErrInvalidSignature, "3001"},
+		{"malformed response", http.StatusOK, func(c *Client) []byte {
+			return []byte("%zz")
+		}, payment.ErrUnionPayError, "2003"},
+		{"server error", http.StatusBadGateway, func(c *Client) []byte { return nil }, payment.ErrSystemError, "2008"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClient()
+			client.Gateway = respondWith(t, tc.status, tc.body).URL + "/"
+			adapter := &Adapter{client: client, forms: NewFormStore()}
+
+			resp, err := adapter.Pay(context.Background(), &payment.UnifiedPayRequest{
+				Channel:     payment.ChannelUnionPay,
+				OutTradeNo:  "ORDER1",
+				TotalAmount: 0.01,
+				Subject:     "test",
+				Scene:       payment.SceneApp,
+			})
+			if tc.err == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, _ := resp.PayData.(map[string]string); resp.Code != "0" || data["tn"] != "768212345678901234567" {
+					t.Fatalf("resp = %+v", resp)
+				}
+				return
+			}
+
+			if resp != nil {
+				t.Fatalf("resp = %+v on failure, want nil", resp)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if code := payment.ErrorCodeOf(err).Code; code != tc.code {
+				t.Fatalf("error code = %s, want %s", code, tc.code)
+			}
+		})
+	}
+}
+
+func TestAdapterPayNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	client := newTestClient()
+	client.Gateway = srv.URL + "/"
+	adapter := &Adapter{client: client, forms: NewFormStore()}
+
+	_, err := adapter.Pay(context.Background(), &payment.UnifiedPayRequest{
+		Channel:     payment.ChannelUnionPay,
+		OutTradeNo:  "ORDER1",
+		TotalAmount: 0.01,
+		Scene:       payment.SceneApp,
+	})
+	if !errors.Is(err, payment.ErrNetworkError) || !payment.IsRetryable(err) {
+		t.Fatalf("err = %v, want retryable %v", err, payment.ErrNetworkError)
+	}
+}
+
+func TestPayFailureReturnsError(t *testing.T) {
+	unsigned := newTestClient()
+	unsigned.PrivateKey = nil
+	rejected := newTestClient()
+	rejected.Gateway = respondWith(t, http.StatusOK, signedResponse(t, "12")).URL + "/"
+
+	cases := []struct {
+		name  string
+		pay   func(ctx context.Context, req *payment.UnifiedPayRequest) (*payment.UnifiedPayResponse, error)
+		scene payment.PayScene
+		err   error
+		code  string
+	}{
+		{"adapter front form", (&Adapter{client: unsigned, forms: NewFormStore()}).Pay, payment.SceneH5, payment.ErrSignatureFailed, "3002"},
+		{"client front form", unsigned.Pay, payment.ScenePC, payment.ErrSignatureFailed, "3002"},
+		{"client create order", rejected.Pay, payment.SceneApp, payment.ErrUnionPayError, "2003"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := tc.pay(context.Background(), &payment.UnifiedPayRequest{
+				Channel:     payment.ChannelUnionPay,
+				OutTradeNo:  "ORDER1",
+				TotalAmount: 0.01,
+				Scene:       tc.scene,
+			})
+			if resp != nil {
+				t.Fatalf("resp = %+v on failure, want nil", resp)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if code := payment.ErrorCodeOf(err).Code; code != tc.code {
+				t.Fatalf("error code = %s, want %s", code, tc.code)
+			}
+		})
+	}
+}
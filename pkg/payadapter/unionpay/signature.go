@@ -6,69 +6,82 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"sort"
+	"strings"
 )
 
-// GenerateSign 生成签名
-func GenerateSign(params map[string]string, privateKey *rsa.PrivateKey) (string, error) {
-	// 1. 过滤空值 + 排序
+const (
+	// signMethodRSA 签名方法: 01 表示 RSA 证书方式
+	signMethodRSA = "01"
+)
+
+// buildSignString 构造待签名字符串: 过滤 signature 和空值字段，按键名排序后拼接为 key1=value1&key2=value2...
+func buildSignString(params map[string]string) string {
 	var keys []string
 	for k := range params {
-		if params[k] != "" {
+		if k != "signature" && params[k] != "" {
 			keys = append(keys, k)
 		}
 	}
 	sort.Strings(keys)
 
-	// 2. 拼接成 key1=value1&key2=value2...
-	var signStr string
-	for _, k := range keys {
-		signStr += k + "=" + params[k] + "&"
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(params[k])
 	}
-	signStr = signStr[:len(signStr)-1] // 去掉最后一个 &
+	return b.String()
+}
 
-	// 3. SHA256 + RSA 签名
-	h := sha256.New()
-	h.Write([]byte(signStr))
-	digest := h.Sum(nil)
+// signDigest 按 5.1.0 规范计算摘要: 先对待签名串做 SHA-256 得到十六进制字符串，再对该字符串做 SHA-256
+func signDigest(params map[string]string) []byte {
+	first := sha256.Sum256([]byte(buildSignString(params)))
+	second := sha256.Sum256([]byte(hex.EncodeToString(first[:])))
+	return second[:]
+}
 
-	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest)
+// GenerateSign 生成签名 (signMethod=01, RSA-SHA256)
+func GenerateSign(params map[string]string, privateKey *rsa.PrivateKey) (string, error) {
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, signDigest(params))
 	if err != nil {
 		return "", err
 	}
 
-	// 4. Base64 编码
 	return base64.StdEncoding.EncodeToString(signature), nil
 }
 
 // VerifySign 验证签名
 func VerifySign(params map[string]string, signature string, publicKey *rsa.PublicKey) bool {
-	// 同样构造待验签字符串
-	var keys []string
-	for k := range params {
-		if k != "signature" && params[k] != "" {
-			keys = append(keys, k)
-		}
-	}
-	sort.Strings(keys)
-
-	var signStr string
-	for _, k := range keys {
-		signStr += k + "=" + params[k] + "&"
+	if publicKey == nil {
+		return false
 	}
-	signStr = signStr[:len(signStr)-1]
 
-	// 解码签名
 	sigBytes, err := base64.StdEncoding.DecodeString(signature)
 	if err != nil {
 		return false
 	}
 
-	// 验证
-	h := sha256.New()
-	h.Write([]byte(signStr))
-	digest := h.Sum(nil)
+	return rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, signDigest(params), sigBytes) == nil
+}
 
-	err = rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, sigBytes)
-	return err == nil
-}
\ No newline at end of file
+// signParams 填充 certId、signMethod 后对报文签名
+func (c *Client) signParams(params map[string]string) error {
+	if c.PrivateKey == nil {
+		return errors.New("sign private key is not loaded")
+	}
+	params["certId"] = c.CertId
+	params["signMethod"] = signMethodRSA
+
+	sign, err := GenerateSign(params, c.PrivateKey)
+	if err != nil {
+		return err
+	}
+	params["signature"] = sign
+	return nil
+}
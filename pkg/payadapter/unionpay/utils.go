@@ -8,8 +8,11 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"os"
 
 	"errors"
+
+	"software.sslmate.com/src/go-pkcs12"
 )
 
 // Pkcs8PrivateKey 解析 PKCS#8 私钥结构
@@ -81,9 +84,62 @@ func LoadPrivateKeyFromPfx(pfxData []byte, password string) (*rsa.PrivateKey, er
 		}
 	}
 
-	// 如果不是PEM格式，可能是二进制PKCS#12格式
-	// 这里需要导入额外的库来解析PKCS#12，目前先返回错误
-	return nil, errors.New("PKCS#12 format not supported directly, please convert to PEM format first")
+	// 如果不是PEM格式，按二进制PKCS#12格式解析
+	key, _, err := LoadSignCertFromPfx(pfxData, password)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LoadSignCertFromPfx 从二进制PKCS#12证书中同时加载签名私钥和签名证书
+// 签名证书的序列号即为报文中的 certId
+func LoadSignCertFromPfx(pfxData []byte, password string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, cert, _, err := pkcs12.DecodeChain(pfxData, password)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode PKCS#12 data: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("not RSA private key")
+	}
+
+	return rsaKey, cert, nil
+}
+
+// LoadSignCertFromFile 从PFX文件加载签名私钥和签名证书
+func LoadSignCertFromFile(filePath, password string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read pfx file: %w", err)
+	}
+
+	return LoadSignCertFromPfx(data, password)
+}
+
+// LoadCertificateFromFile 从文件加载X.509证书，支持PEM和DER格式
+// 用于加载银联根证书(acp_*_root.cer)和中级证书(acp_*_middle.cer)
+func LoadCertificateFromFile(filePath string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+
+	return ParseCertificate(data)
+}
+
+// ParseCertificate 解析PEM或DER格式的X.509证书
+func ParseCertificate(data []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, nil
 }
 
 // LoadPrivateKeyFromFile 从文件加载私钥，支持PEM格式
@@ -103,6 +159,11 @@ func LoadPublicKeyFromFile(filePath string) (*rsa.PublicKey, error) {
 		return nil, fmt.Errorf("failed to read public key file: %w", err)
 	}
 
+	return ParsePublicKey(data)
+}
+
+// ParsePublicKey 解析PEM格式的公钥
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
 	block, _ := pem.Decode(data)
 	if block == nil {
 		return nil, errors.New("failed to parse PEM block containing public key")
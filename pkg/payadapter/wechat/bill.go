@@ -0,0 +1,115 @@
+package wechat
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wechatpay-apiv3/wechatpay-go/core/consts"
+	"github.com/wechatpay-apiv3/wechatpay-go/utils"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// billTypeAll 微信交易账单类型，包含成功支付和退款的订单
+const billTypeAll = "ALL"
+
+// tradeBill 申请交易账单的应答
+type tradeBill struct {
+	HashType    string `json:"hash_type"`
+	HashValue   string `json:"hash_value"`
+	DownloadURL string `json:"download_url"`
+}
+
+// DownloadBill 下载交易账单
+// 先申请账单获取下载地址，再下载账单文件并按应答中的摘要校验；BillType 为 ALL、SUCCESS 或 REFUND，为空时为 ALL
+func (c *Client) DownloadBill(ctx context.Context, req *payment.BillRequest) (*payment.BillResponse, error) {
+	billType := req.BillType
+	if billType == "" {
+		billType = billTypeAll
+	}
+	date := req.Date.Format(time.DateOnly)
+
+	query := url.Values{}
+	query.Set("bill_date", date)
+	query.Set("bill_type", billType)
+	result, err := c.client.Get(ctx, consts.WechatPayAPIServer+"/v3/bill/tradebill?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("wechat apply trade bill failed: %w", classifyError(err))
+	}
+	defer result.Response.Body.Close()
+
+	var bill tradeBill
+	if err := json.NewDecoder(result.Response.Body).Decode(&bill); err != nil {
+		return nil, fmt.Errorf("decode wechat trade bill failed: %w", err)
+	}
+
+	data, err := c.download(ctx, bill.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(bill.HashType, "SHA1") {
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), bill.HashValue) {
+			return nil, fmt.Errorf("wechat trade bill hash mismatch")
+		}
+	}
+
+	return &payment.BillResponse{
+		Channel:  payment.ChannelWechat,
+		Date:     req.Date,
+		BillType: billType,
+		FileName: fmt.Sprintf("wechat_%s_%s.csv", strings.ToLower(billType), req.Date.Format("20060102")),
+		Data:     data,
+	}, nil
+}
+
+// download 下载账单文件
+// 下载地址同样需要商户签名，但应答不带微信支付签名，不能通过 SDK 的客户端请求
+func (c *Client) download(ctx context.Context, downloadURL string) ([]byte, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wechat bill download url: %w", err)
+	}
+
+	nonce, err := utils.GenerateNonce()
+	if err != nil {
+		return nil, fmt.Errorf("generate nonce failed: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	signature, err := c.client.Sign(ctx, fmt.Sprintf("%s\n%s\n%d\n%s\n\n", http.MethodGet, u.RequestURI(), timestamp, nonce))
+	if err != nil {
+		return nil, fmt.Errorf("sign wechat bill download request failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create wechat bill download request failed: %w", err)
+	}
+	req.Header.Set(consts.Authorization, fmt.Sprintf(
+		`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",timestamp="%d",serial_no="%s",signature="%s"`,
+		c.config.MchID, nonce, timestamp, signature.CertificateSerialNo, signature.Signature))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("wechat download bill failed: %w", payment.ClassifyTransportError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wechat download bill failed: %w", payment.ClassifyHTTPStatus(payment.ChannelWechat, resp.StatusCode, payment.ErrWechatError))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read wechat bill failed: %w", payment.ClassifyTransportError(err))
+	}
+	return data, nil
+}
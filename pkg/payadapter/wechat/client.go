@@ -2,14 +2,15 @@ package wechat
 
 import (
 	"context"
-	"crypto/rsa"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
-	"os"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/consts"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/downloader"
 	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/app"
@@ -17,8 +18,12 @@ import (
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/jsapi"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/native"
 	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
+	"github.com/wechatpay-apiv3/wechatpay-go/utils"
 	"github.com/ymqzj/payment-gateway/configs"
+	"github.com/ymqzj/payment-gateway/internal/audit"
 	"github.com/ymqzj/payment-gateway/internal/payment"
+	"github.com/ymqzj/payment-gateway/internal/tracing"
+	"github.com/ymqzj/payment-gateway/pkg/secret"
 )
 
 const (
@@ -26,22 +31,52 @@ const (
 )
 
 type Client struct {
-	client *core.Client
-	config *Config
+	client       *core.Client
+	httpClient   *http.Client // 与 SDK 共用，用于下载账单文件等 SDK 未封装的请求
+	config       *Config
+	merchantCert *x509.Certificate // 商户API证书，未配置时为空
 }
 
 // NewAdapter 创建微信支付适配器
-func NewAdapter(cfg *configs.Config) (*Client, error) {
+// 商户私钥和 APIv3 密钥通过 secrets 加载，secrets 为空时使用默认的 file/env 提供者
+func NewAdapter(cfg *configs.Config, secrets secret.SecretProvider) (*Client, error) {
 	config := NewConfig(cfg)
+	if secrets == nil {
+		secrets = secret.NewRegistry()
+	}
+
+	ctx := context.Background()
 
-	mchPrivateKey, err := loadPrivateKey(config.PrivateKey)
+	mchPrivateKey, err := secret.LoadPrivateKey(ctx, secrets, config.PrivateKey)
 	if err != nil {
 		return nil, fmt.Errorf("load private key failed: %w", err)
 	}
 
-	ctx := context.Background()
+	apiV3Key, err := secret.LoadValue(ctx, secrets, config.APIv3Key)
+	if err != nil {
+		return nil, fmt.Errorf("load api v3 key failed: %w", err)
+	}
+
+	// 商户API证书仅用于核对序列号和报告证书信息
+	var merchantCert *x509.Certificate
+	if config.CertPath != "" {
+		merchantCert, err = loadMerchantCert(ctx, secrets, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 每次创建都重新注册平台证书下载器，私钥轮换后下载器也使用新私钥
+	mgr := downloader.MgrInstance()
+	if err := mgr.RegisterDownloaderWithPrivateKey(ctx, mchPrivateKey, config.SerialNo, config.MchID, apiV3Key); err != nil {
+		return nil, fmt.Errorf("register platform cert downloader failed: %w", err)
+	}
+
+	// 渠道请求作为网关调用 span 的子 span 上报，报文写入审计记录
+	httpClient := &http.Client{Timeout: consts.DefaultTimeout, Transport: tracing.Transport(audit.Transport(nil))}
 	opts := []core.ClientOption{
-		option.WithWechatPayAutoAuthCipher(config.MchID, config.SerialNo, mchPrivateKey, config.APIv3Key),
+		option.WithWechatPayAutoAuthCipherUsingDownloaderMgr(config.MchID, config.SerialNo, mchPrivateKey, mgr),
+		option.WithHTTPClient(httpClient),
 	}
 
 	client, err := core.NewClient(ctx, opts...)
@@ -50,29 +85,51 @@ func NewAdapter(cfg *configs.Config) (*Client, error) {
 	}
 
 	return &Client{
-		client: client,
-		config: config,
+		client:       client,
+		httpClient:   httpClient,
+		config:       config,
+		merchantCert: merchantCert,
 	}, nil
 }
 
-func loadPrivateKey(file string) (*rsa.PrivateKey, error) {
-	data, err := os.ReadFile(file)
+// loadMerchantCert 加载商户API证书，并核对与配置的证书序列号是否一致
+func loadMerchantCert(ctx context.Context, secrets secret.SecretProvider, config *Config) (*x509.Certificate, error) {
+	data, err := secret.LoadFile(ctx, secrets, config.CertPath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("load merchant cert failed: %w", err)
 	}
-	block, _ := pem.Decode(data)
-	if block == nil {
-		return nil, fmt.Errorf("decode private key error")
-	}
-	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+	cert, err := utils.LoadCertificate(string(data))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("parse merchant cert failed: %w", err)
 	}
-	rsaKey, ok := key.(*rsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("not rsa private key")
+
+	if serial := utils.GetCertificateSerialNumber(*cert); config.SerialNo != "" && !strings.EqualFold(serial, config.SerialNo) {
+		return nil, fmt.Errorf("merchant cert serial %s does not match cert_serial_no %s", serial, config.SerialNo)
 	}
-	return rsaKey, nil
+
+	return cert, nil
+}
+
+// CertInfos 报告商户API证书和已下载的平台证书
+func (c *Client) CertInfos(ctx context.Context) []payment.CertInfo {
+	var infos []payment.CertInfo
+	if c.merchantCert != nil {
+		infos = append(infos, payment.NewCertInfo(payment.ChannelWechat, "merchant", c.merchantCert))
+	}
+
+	for _, cert := range downloader.MgrInstance().GetCertificateMap(ctx, c.config.MchID) {
+		infos = append(infos, payment.NewCertInfo(payment.ChannelWechat, "platform", cert))
+	}
+	return infos
+}
+
+// CheckKeys 检查平台证书是否已下载，没有平台证书时无法验证应答和通知签名
+func (c *Client) CheckKeys(ctx context.Context) error {
+	if len(downloader.MgrInstance().GetCertificateMap(ctx, c.config.MchID)) == 0 {
+		return fmt.Errorf("no wechat platform certificate downloaded for merchant %s", c.config.MchID)
+	}
+	return nil
 }
 
 // Pay 实现支付接口
@@ -109,14 +166,14 @@ func (c *Client) appPay(ctx context.Context, req *payment.UnifiedPayRequest) (*p
 			Mchid:       stringPtr(c.config.MchID),
 			Description: stringPtr(req.Subject),
 			OutTradeNo:  stringPtr(req.OutTradeNo),
-			NotifyUrl:   stringPtr(req.NotifyURL),
+			NotifyUrl:   stringPtr(c.config.NotifyURL),
 			Amount:      &app.Amount{Total: int64Ptr(int64(req.TotalAmount * 100))},
 			SceneInfo:   &app.SceneInfo{PayerClientIp: stringPtr("127.0.0.1")},
 		},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("wechat app pay failed: %w", err)
+		return nil, fmt.Errorf("wechat app pay failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 200 {
@@ -146,7 +203,7 @@ func (c *Client) h5Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*pa
 			Mchid:       stringPtr(c.config.MchID),
 			Description: stringPtr(req.Subject),
 			OutTradeNo:  stringPtr(req.OutTradeNo),
-			NotifyUrl:   stringPtr(req.NotifyURL),
+			NotifyUrl:   stringPtr(c.config.NotifyURL),
 			Amount:      &h5.Amount{Total: int64Ptr(int64(req.TotalAmount * 100))},
 			SceneInfo: &h5.SceneInfo{
 				PayerClientIp: stringPtr("127.0.0.1"),
@@ -156,7 +213,7 @@ func (c *Client) h5Pay(ctx context.Context, req *payment.UnifiedPayRequest) (*pa
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("wechat h5 pay failed: %w", err)
+		return nil, fmt.Errorf("wechat h5 pay failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 200 {
@@ -188,14 +245,14 @@ func (c *Client) jsapiPay(ctx context.Context, req *payment.UnifiedPayRequest) (
 			Mchid:       stringPtr(c.config.MchID),
 			Description: stringPtr(req.Subject),
 			OutTradeNo:  stringPtr(req.OutTradeNo),
-			NotifyUrl:   stringPtr(req.NotifyURL),
+			NotifyUrl:   stringPtr(c.config.NotifyURL),
 			Amount:      &jsapi.Amount{Total: int64Ptr(int64(req.TotalAmount * 100))},
 			Payer:       &jsapi.Payer{Openid: stringPtr(req.OpenID)},
 		},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("wechat jsapi pay failed: %w", err)
+		return nil, fmt.Errorf("wechat jsapi pay failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 200 {
@@ -224,13 +281,13 @@ func (c *Client) nativePay(ctx context.Context, req *payment.UnifiedPayRequest)
 			Mchid:       stringPtr(c.config.MchID),
 			Description: stringPtr(req.Subject),
 			OutTradeNo:  stringPtr(req.OutTradeNo),
-			NotifyUrl:   stringPtr(req.NotifyURL),
+			NotifyUrl:   stringPtr(c.config.NotifyURL),
 			Amount:      &native.Amount{Total: int64Ptr(int64(req.TotalAmount * 100))},
 		},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("wechat native pay failed: %w", err)
+		return nil, fmt.Errorf("wechat native pay failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 200 {
@@ -321,7 +378,7 @@ func (c *Client) Refund(ctx context.Context, req *payment.RefundRequest) (*payme
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("wechat refund failed: %w", err)
+		return nil, fmt.Errorf("wechat refund failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 200 {
@@ -357,6 +414,47 @@ func (c *Client) Refund(ctx context.Context, req *payment.RefundRequest) (*payme
 	return refundResp, nil
 }
 
+// QueryRefund 按商户退款单号查询退款
+func (c *Client) QueryRefund(ctx context.Context, req *payment.RefundQueryRequest) (*payment.RefundResponse, error) {
+	svc := refunddomestic.RefundsApiService{Client: c.client}
+	resp, result, err := svc.QueryByOutRefundNo(ctx,
+		refunddomestic.QueryByOutRefundNoRequest{
+			OutRefundNo: stringPtr(req.OutRefundNo),
+		},
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("wechat query refund failed: %w", classifyError(err))
+	}
+
+	if result.Response.StatusCode != 200 {
+		return nil, fmt.Errorf("wechat query refund failed with status: %d", result.Response.StatusCode)
+	}
+
+	refundResp := &payment.RefundResponse{
+		Code:         "0",
+		Message:      "success",
+		OutRefundNo:  req.OutRefundNo,
+		RefundStatus: "PROCESSING",
+		RefundTime:   resp.SuccessTime,
+		Channel:      payment.ChannelWechat,
+	}
+
+	if resp.RefundId != nil {
+		refundResp.RefundID = *resp.RefundId
+	}
+
+	if resp.Status != nil {
+		refundResp.RefundStatus = string(*resp.Status)
+	}
+
+	if resp.Amount != nil && resp.Amount.Refund != nil {
+		refundResp.RefundAmount = float64(*resp.Amount.Refund) / 100
+	}
+
+	return refundResp, nil
+}
+
 // Close 关闭订单接口
 func (c *Client) Close(ctx context.Context, req *payment.CloseRequest) error {
 	svc := native.NativeApiService{Client: c.client}
@@ -368,7 +466,7 @@ func (c *Client) Close(ctx context.Context, req *payment.CloseRequest) error {
 	)
 
 	if err != nil {
-		return fmt.Errorf("wechat close order failed: %w", err)
+		return fmt.Errorf("wechat close order failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 204 && result.Response.StatusCode != 200 {
@@ -389,7 +487,7 @@ func (c *Client) Query(ctx context.Context, req *payment.QueryRequest) (*payment
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("wechat query order failed: %w", err)
+		return nil, fmt.Errorf("wechat query order failed: %w", classifyError(err))
 	}
 
 	if result.Response.StatusCode != 200 {
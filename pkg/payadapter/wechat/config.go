@@ -5,10 +5,12 @@ import "github.com/ymqzj/payment-gateway/configs"
 type Config struct {
 	AppID        string // 公众号/小程序/APP 的 appid
 	MchID        string // 商户号
-	APIv3Key     string // APIv3 密钥（在微信商户平台设置）
+	APIv3Key     string // APIv3 密钥（在微信商户平台设置），可为机密引用
 	SerialNo     string // 证书序列号
-	PrivateKey   string // 商户私钥 (pem 格式内容 或 路径)
+	PrivateKey   string // 商户私钥文件路径或机密引用
+	CertPath     string // 商户API证书路径（可选，用于核对序列号）
 	CertFilePath string // 平台证书路径（用于回调验签，可选）
+	NotifyURL    string // 网关接收微信支付通知的地址
 }
 
 // NewConfig 从全局配置创建微信配置
@@ -19,5 +21,7 @@ func NewConfig(config *configs.Config) *Config {
 		APIv3Key:   config.Wechat.APIV3Key,
 		SerialNo:   config.Wechat.CertSerialNo,
 		PrivateKey: config.Wechat.KeyPath,
+		CertPath:   config.Wechat.CertPath,
+		NotifyURL:  config.Wechat.NotifyURL,
 	}
 }
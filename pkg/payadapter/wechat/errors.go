@@ -0,0 +1,41 @@
+package wechat
+
+import (
+	"errors"
+
+	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/ymqzj/payment-gateway/internal/payment"
+)
+
+// errorCodeKinds 微信支付错误码与标准错误的对应关系，未列出的按 HTTP 状态码归类
+var errorCodeKinds = map[string]error{
+	"SYSTEM_ERROR":        payment.ErrSystemError,
+	"BANK_ERROR":          payment.ErrSystemError,
+	"ORDERPAID":           payment.ErrOrderPaid,
+	"ORDER_NOT_EXIST":     payment.ErrOrderNotFound,
+	"ORDERNOTEXIST":       payment.ErrOrderNotFound,
+	"RESOURCE_NOT_EXISTS": payment.ErrOrderNotFound,
+	"ORDER_CLOSED":        payment.ErrOrderClosed,
+	"NOT_ENOUGH":          payment.ErrInsufficientBalance,
+	"PARAM_ERROR":         payment.ErrInvalidParameter,
+	"INVALID_REQUEST":     payment.ErrInvalidParameter,
+	"SIGN_ERROR":          payment.ErrSignatureFailed,
+	"FREQUENCY_LIMITED":   payment.ErrRateLimited,
+}
+
+// classifyError 归类微信支付 SDK 返回的错误，包括接口错误应答和网络错误
+func classifyError(err error) error {
+	var apiErr *core.APIError
+	if !errors.As(err, &apiErr) {
+		return payment.ClassifyTransportError(err)
+	}
+
+	kind, ok := errorCodeKinds[apiErr.Code]
+	if !ok {
+		kind = payment.ErrWechatError
+		if apiErr.StatusCode >= 500 {
+			kind = payment.ErrSystemError
+		}
+	}
+	return payment.NewChannelError(payment.ChannelWechat, apiErr.Code, apiErr.Message, kind)
+}
@@ -0,0 +1,188 @@
+// Package sdk 支付网关的商户端 Go SDK
+// 提供带类型的接口调用（请求自动签名）以及网关响应和事件推送的签名校验，只依赖标准库
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 请求签名相关的头，与 internal/auth 保持一致
+const (
+	HeaderAppKey    = "X-App-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// APIError 网关返回的错误，Code 为网关错误码，调用方应按 Code 处理
+type APIError struct {
+	HTTPStatus int
+	Code       int
+	Message    string
+	Details    string // 渠道原始错误码或参数校验信息
+}
+
+func (e *APIError) Error() string {
+	if e.Details == "" {
+		return fmt.Sprintf("payment gateway error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("payment gateway error %d: %s (%s)", e.Code, e.Message, e.Details)
+}
+
+// Client 网关 API 客户端
+type Client struct {
+	baseURL    string
+	appKey     string
+	secret     []byte
+	httpClient *http.Client
+	verifier   *Verifier
+}
+
+// Option 客户端选项
+type Option func(*Client)
+
+// WithHTTPClient 使用自定义 HTTP 客户端
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithVerifier 校验每个响应的网关签名，校验失败时返回错误
+func WithVerifier(verifier *Verifier) Option {
+	return func(c *Client) {
+		c.verifier = verifier
+	}
+}
+
+// NewClient 创建客户端，baseURL 为网关地址，如 https://pay.example.com
+func NewClient(baseURL, appKey, appSecret string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		appKey:     appKey,
+		secret:     []byte(appSecret),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Pay 下单
+func (c *Client) Pay(ctx context.Context, req *PayRequest) (*PayResult, error) {
+	var result PayResult
+	if err := c.do(ctx, "/api/v1/pay", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Query 查询订单
+func (c *Client) Query(ctx context.Context, req *QueryRequest) (*QueryResult, error) {
+	var result QueryResult
+	if err := c.do(ctx, "/api/v1/query", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Refund 退款
+func (c *Client) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	var result RefundResult
+	if err := c.do(ctx, "/api/v1/refund", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Close 关闭订单
+func (c *Client) Close(ctx context.Context, req *CloseRequest) error {
+	return c.do(ctx, "/api/v1/close", req, nil)
+}
+
+// do 发送签名请求，校验响应签名并解析 data
+func (c *Client) do(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("marshal request failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req, body)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response failed: %w", err)
+	}
+	if c.verifier != nil {
+		if err := c.verifier.Verify(resp.Header, respBody); err != nil {
+			return err
+		}
+	}
+
+	var r response
+	if err := json.Unmarshal(respBody, &r); err != nil {
+		return fmt.Errorf("decode response failed with status %d: %w", resp.StatusCode, err)
+	}
+	if r.Code != 0 {
+		return &APIError{
+			HTTPStatus: resp.StatusCode,
+			Code:       r.Code,
+			Message:    r.Message,
+			Details:    r.Details,
+		}
+	}
+	if out != nil && len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, out); err != nil {
+			return fmt.Errorf("decode response data failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// sign 设置请求签名头
+// 待签名串为方法、请求 URI、时间戳、nonce、body SHA-256 以换行连接，与 internal/auth.StringToSign 保持一致
+func (c *Client) sign(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	nonce := hex.EncodeToString(buf)
+
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(strings.Join([]string{
+		req.Method,
+		req.URL.RequestURI(),
+		timestamp,
+		nonce,
+		hex.EncodeToString(sum[:]),
+	}, "\n")))
+
+	req.Header.Set(HeaderAppKey, c.appKey)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PayRequest 下单请求，channel 为 auto 时由网关路由规则选择渠道
+type PayRequest struct {
+	Channel     string  `json:"channel"`
+	OutTradeNo  string  `json:"out_trade_no"`
+	TotalAmount float64 `json:"total_amount"`
+	Subject     string  `json:"subject"`
+	Scene       string  `json:"scene"`
+	EventURL    string  `json:"event_url"` // 接收网关事件推送的地址
+	ReturnURL   string  `json:"return_url,omitempty"`
+	OpenID      string  `json:"openid,omitempty"`
+	Attach      string  `json:"attach,omitempty"`
+	App         string  `json:"app,omitempty"`
+	MerchantID  string  `json:"merchant_id,omitempty"`
+}
+
+// PayResult 下单结果
+type PayResult struct {
+	OrderID    string          `json:"order_id"`
+	OutTradeNo string          `json:"out_trade_no"`
+	PayData    json.RawMessage `json:"pay_data,omitempty"` // 渠道支付参数，结构随渠道和场景不同
+	QRCode     string          `json:"qr_code,omitempty"`
+	PayURL     string          `json:"pay_url,omitempty"`
+	Channel    string          `json:"channel"`
+	Route      json.RawMessage `json:"route,omitempty"` // channel=auto 时的路由决策过程
+}
+
+// QueryRequest 查询请求
+type QueryRequest struct {
+	Channel    string `json:"channel"`
+	OrderID    string `json:"order_id,omitempty"`
+	OutTradeNo string `json:"out_trade_no,omitempty"`
+}
+
+// QueryResult 查询结果
+type QueryResult struct {
+	OrderID     string  `json:"order_id"`
+	OutTradeNo  string  `json:"out_trade_no"`
+	TradeStatus string  `json:"trade_status"`
+	TotalAmount float64 `json:"total_amount"`
+	PayTime     string  `json:"pay_time,omitempty"` // RFC 3339
+	Channel     string  `json:"channel"`
+}
+
+// RefundRequest 退款请求，相同 out_refund_no 重复提交不会重复退款
+type RefundRequest struct {
+	Channel      string  `json:"channel"`
+	OrderID      string  `json:"order_id,omitempty"`
+	OutTradeNo   string  `json:"out_trade_no,omitempty"`
+	OutRefundNo  string  `json:"out_refund_no"`
+	RefundAmount float64 `json:"refund_amount"`
+	TotalAmount  float64 `json:"total_amount"`
+	RefundReason string  `json:"refund_reason,omitempty"`
+}
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundID     string  `json:"refund_id"`
+	OutRefundNo  string  `json:"out_refund_no"`
+	RefundAmount float64 `json:"refund_amount"`
+	RefundStatus string  `json:"refund_status"`
+	RefundTime   string  `json:"refund_time,omitempty"` // RFC 3339
+	Channel      string  `json:"channel"`
+}
+
+// CloseRequest 关单请求
+type CloseRequest struct {
+	Channel    string `json:"channel"`
+	OrderID    string `json:"order_id,omitempty"`
+	OutTradeNo string `json:"out_trade_no,omitempty"`
+}
+
+// 事件类型
+const (
+	EventPaymentUpdated = "payment.updated"
+)
+
+// Event 网关推送的事件
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// PaymentData payment.updated 事件数据
+type PaymentData struct {
+	OutTradeNo  string     `json:"out_trade_no"`
+	OrderID     string     `json:"order_id,omitempty"`
+	MerchantID  string     `json:"merchant_id,omitempty"`
+	Channel     string     `json:"channel"`
+	Success     bool       `json:"success"`
+	TradeStatus string     `json:"trade_status"`
+	TotalAmount float64    `json:"total_amount"`
+	PayTime     *time.Time `json:"pay_time,omitempty"`
+}
+
+// PaymentData 解析 payment.updated 事件数据
+func (e *Event) PaymentData() (*PaymentData, error) {
+	var data PaymentData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// response 网关响应
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Details string          `json:"details,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
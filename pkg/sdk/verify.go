@@ -0,0 +1,158 @@
+package sdk
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 网关签名相关的头，与 internal/signing 保持一致
+const (
+	HeaderGatewaySignature = "X-Gateway-Signature"
+	HeaderGatewayTimestamp = "X-Gateway-Timestamp"
+	HeaderGatewayKeyID     = "X-Gateway-Key-Id"
+	HeaderEventID          = "X-Gateway-Event-Id"
+)
+
+// WellKnownPath 网关公钥发布地址
+const WellKnownPath = "/.well-known/payment-gateway-keys.json"
+
+// DefaultMaxSkew 签名时间戳与本地时间允许的最大偏差
+const DefaultMaxSkew = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("missing gateway signature")
+	ErrUnknownKey       = errors.New("unknown gateway key")
+	ErrSignatureExpired = errors.New("gateway signature expired")
+	ErrInvalidSignature = errors.New("invalid gateway signature")
+)
+
+// PublicKey 网关公钥
+type PublicKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"` // base64 编码的 Ed25519 公钥
+}
+
+// Verifier 校验网关响应和事件推送的签名
+type Verifier struct {
+	keys    map[string]ed25519.PublicKey
+	maxSkew time.Duration
+	now     func() time.Time
+}
+
+// NewVerifier 使用已知公钥创建校验器
+func NewVerifier(keys ...PublicKey) (*Verifier, error) {
+	v := &Verifier{
+		keys:    make(map[string]ed25519.PublicKey, len(keys)),
+		maxSkew: DefaultMaxSkew,
+		now:     time.Now,
+	}
+	for _, key := range keys {
+		if key.Algorithm != "" && key.Algorithm != "Ed25519" {
+			return nil, fmt.Errorf("unsupported algorithm %s of key %s", key.Algorithm, key.KeyID)
+		}
+		raw, err := base64.StdEncoding.DecodeString(key.PublicKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key %s", key.KeyID)
+		}
+		v.keys[key.KeyID] = ed25519.PublicKey(raw)
+	}
+	return v, nil
+}
+
+// FetchVerifier 从网关的公钥发布地址获取公钥并创建校验器
+// 公钥应通过可信的 HTTPS 地址获取，获取后可缓存，密钥轮换时重新获取
+func FetchVerifier(ctx context.Context, client *http.Client, baseURL string) (*Verifier, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+WellKnownPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch gateway keys failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch gateway keys failed with status: %d", resp.StatusCode)
+	}
+
+	var keySet struct {
+		Keys []PublicKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&keySet); err != nil {
+		return nil, fmt.Errorf("decode gateway keys failed: %w", err)
+	}
+	return NewVerifier(keySet.Keys...)
+}
+
+// SetMaxSkew 设置签名时间戳允许的最大偏差
+func (v *Verifier) SetMaxSkew(d time.Duration) {
+	v.maxSkew = d
+}
+
+// Verify 校验签名头和 body
+// 待签名内容为时间戳、换行、原始 body，与 internal/signing.Message 保持一致
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	signature := header.Get(HeaderGatewaySignature)
+	timestamp := header.Get(HeaderGatewayTimestamp)
+	keyID := header.Get(HeaderGatewayKeyID)
+	if signature == "" || timestamp == "" || keyID == "" {
+		return ErrMissingSignature
+	}
+
+	key, ok := v.keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrInvalidSignature, timestamp)
+	}
+	if skew := v.now().Sub(time.Unix(ts, 0)); skew > v.maxSkew || skew < -v.maxSkew {
+		return fmt.Errorf("%w: skew %s", ErrSignatureExpired, skew.Truncate(time.Second))
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	msg := make([]byte, 0, len(timestamp)+1+len(body))
+	msg = append(append(append(msg, timestamp...), '\n'), body...)
+	if !ed25519.Verify(key, msg, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParseWebhook 校验并解析网关推送的事件
+// 同一事件可能重复推送，商户应按事件 ID 去重
+func (v *Verifier) ParseWebhook(r *http.Request) (*Event, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read webhook body failed: %w", err)
+	}
+	if err := v.Verify(r.Header, body); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("decode webhook event failed: %w", err)
+	}
+	return &event, nil
+}
@@ -0,0 +1,126 @@
+package sdk
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ymqzj/payment-gateway/internal/signing"
+)
+
+// newTestVerifier 网关签名器及信任其公钥的校验器
+func newTestVerifier(t *testing.T) (*signing.Signer, *Verifier) {
+	t.Helper()
+
+	signer, err := signing.GenerateSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := signer.PublicKey()
+	verifier, err := NewVerifier(PublicKey{KeyID: key.KeyID, Algorithm: key.Algorithm, PublicKey: key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, verifier
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"code":0,"message":"success"}`)
+	now := time.Now()
+
+	cases := []struct {
+		name   string
+		header func(signer *signing.Signer) http.Header
+		body   []byte
+		err    error
+	}{
+		{"valid", func(signer *signing.Signer) http.Header {
+			h := http.Header{}
+			signer.SignHeaders(h, body)
+			return h
+		}, body, nil},
+		{"tampered body", func(signer *signing.Signer) http.Header {
+			h := http.Header{}
+			signer.SignHeaders(h, body)
+			return h
+		}, []byte(`{"code":0,"message":"success "}`), ErrInvalidSignature},
+		{"missing signature", func(signer *signing.Signer) http.Header {
+			h := http.Header{}
+			signer.SignHeaders(h, body)
+			h.Del(HeaderGatewaySignature)
+			return h
+		}, body, ErrMissingSignature},
+		{"unknown key", func(signer *signing.Signer) http.Header {
+			h := http.Header{}
+			signer.SignHeaders(h, body)
+			h.Set(HeaderGatewayKeyID, "rotated")
+			return h
+		}, body, ErrUnknownKey},
+		{"expired", func(signer *signing.Signer) http.Header {
+			timestamp := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+			return http.Header{
+				HeaderGatewayTimestamp: {timestamp},
+				HeaderGatewayKeyID:     {signer.KeyID()},
+				HeaderGatewaySignature: {signer.Sign(timestamp, body)},
+			}
+		}, body, ErrSignatureExpired},
+		{"timestamp not signed", func(signer *signing.Signer) http.Header {
+			h := http.Header{}
+			signer.SignHeaders(h, body)
+			ts, _ := strconv.ParseInt(h.Get(HeaderGatewayTimestamp), 10, 64)
+			h.Set(HeaderGatewayTimestamp, strconv.FormatInt(ts+1, 10))
+			return h
+		}, body, ErrInvalidSignature},
+		{"malformed signature", func(signer *signing.Signer) http.Header {
+			h := http.Header{}
+			signer.SignHeaders(h, body)
+			h.Set(HeaderGatewaySignature, "not base64!")
+			return h
+		}, body, ErrInvalidSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer, verifier := newTestVerifier(t)
+			if err := verifier.Verify(tc.header(signer), tc.body); !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRejectsInvalidKeys(t *testing.T) {
+	for name, key := range map[string]PublicKey{
+		"algorithm":  {KeyID: "k1", Algorithm: "RSA", PublicKey: "AAAA"},
+		"encoding":   {KeyID: "k1", PublicKey: "not base64!"},
+		"key length": {KeyID: "k1", PublicKey: "AAAA"},
+	} {
+		if _, err := NewVerifier(key); err == nil {
+			t.Errorf("%s: invalid key accepted", name)
+		}
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	signer, verifier := newTestVerifier(t)
+	body := `{"id":"evt_1","type":"payment.updated","created_at":"2024-01-01T00:00:00Z","data":{"out_trade_no":"ORDER_1"}}`
+
+	r := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body))
+	signer.SignHeaders(r.Header, []byte(body))
+	event, err := verifier.ParseWebhook(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.ID != "evt_1" || event.Type != "payment.updated" || !strings.Contains(string(event.Data), "ORDER_1") {
+		t.Fatalf("event = %+v", event)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(strings.Replace(body, "ORDER_1", "ORDER_2", 1)))
+	signer.SignHeaders(r.Header, []byte(body))
+	if _, err := verifier.ParseWebhook(r); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidSignature)
+	}
+}
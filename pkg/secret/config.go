@@ -0,0 +1,33 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ymqzj/payment-gateway/configs"
+)
+
+// defaultPassphraseEnv 默认的密钥库口令环境变量
+const defaultPassphraseEnv = "KEYSTORE_PASSPHRASE"
+
+// NewFromConfig 根据配置创建注册表
+// 始终注册 file/env 提供者，配置了 keystore_path 时注册加密密钥库
+// KMS 提供者需要调用方使用具体的 KMSClient 自行注册
+func NewFromConfig(cfg *configs.Config) (*Registry, error) {
+	registry := NewRegistry()
+
+	if cfg.Secrets.KeystorePath != "" {
+		passphraseEnv := cfg.Secrets.KeystorePassphraseEnv
+		if passphraseEnv == "" {
+			passphraseEnv = defaultPassphraseEnv
+		}
+
+		ks, err := OpenKeystore(cfg.Secrets.KeystorePath, os.Getenv(passphraseEnv))
+		if err != nil {
+			return nil, fmt.Errorf("open keystore failed: %w", err)
+		}
+		registry.Register(SchemeKeystore, ks)
+	}
+
+	return registry, nil
+}
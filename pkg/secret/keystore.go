@@ -0,0 +1,212 @@
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keystoreVersion = 1
+
+	// scrypt 参数
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+var ErrInvalidPassphrase = errors.New("invalid keystore passphrase")
+
+// keystoreFile 加密密钥库的磁盘格式
+type keystoreFile struct {
+	Version int                      `json:"version"`
+	Salt    []byte                   `json:"salt"`
+	Check   []byte                   `json:"check"` // 用于校验口令的固定明文密文
+	Entries map[string]keystoreEntry `json:"entries"`
+}
+
+type keystoreEntry struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Keystore 本地加密密钥库
+// 条目使用 AES-256-GCM 加密，密钥由口令经 scrypt 派生，条目名作为附加认证数据
+type Keystore struct {
+	mu   sync.RWMutex
+	path string
+	aead cipher.AEAD
+	file keystoreFile
+}
+
+// OpenKeystore 打开密钥库，文件不存在时创建空密钥库（调用 Save 后落盘）
+func OpenKeystore(path, passphrase string) (*Keystore, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("keystore passphrase is required")
+	}
+
+	ks := &Keystore{path: path}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &ks.file); err != nil {
+			return nil, fmt.Errorf("parse keystore failed: %w", err)
+		}
+		if ks.file.Version != keystoreVersion {
+			return nil, fmt.Errorf("unsupported keystore version: %d", ks.file.Version)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		ks.file = keystoreFile{
+			Version: keystoreVersion,
+			Salt:    salt,
+			Entries: make(map[string]keystoreEntry),
+		}
+	default:
+		return nil, fmt.Errorf("read keystore failed: %w", err)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), ks.file.Salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	ks.aead, err = newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ks.checkPassphrase(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// checkPassphrase 新密钥库写入校验值，已有密钥库校验口令是否正确
+func (ks *Keystore) checkPassphrase() error {
+	const checkName = "__check__"
+
+	if ks.file.Check == nil {
+		sealed, err := seal(ks.aead, []byte(checkName), []byte(checkName))
+		if err != nil {
+			return err
+		}
+		ks.file.Check = sealed
+		return nil
+	}
+
+	if _, err := open(ks.aead, ks.file.Check, []byte(checkName)); err != nil {
+		return ErrInvalidPassphrase
+	}
+	return nil
+}
+
+// GetSecret 解密并返回条目内容
+func (ks *Keystore) GetSecret(ctx context.Context, name string) ([]byte, error) {
+	ks.mu.RLock()
+	entry, ok := ks.file.Entries[name]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: keystore entry %s", ErrSecretNotFound, name)
+	}
+
+	plaintext, err := ks.aead.Open(nil, entry.Nonce, entry.Ciphertext, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt keystore entry %s failed: %w", name, err)
+	}
+	return plaintext, nil
+}
+
+// Put 加密写入条目
+func (ks *Keystore) Put(name string, data []byte) error {
+	nonce := make([]byte, ks.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.file.Entries[name] = keystoreEntry{
+		Nonce:      nonce,
+		Ciphertext: ks.aead.Seal(nil, nonce, data, []byte(name)),
+	}
+	return nil
+}
+
+// Delete 删除条目
+func (ks *Keystore) Delete(name string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	delete(ks.file.Entries, name)
+}
+
+// Names 返回所有条目名
+func (ks *Keystore) Names() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	names := make([]string, 0, len(ks.file.Entries))
+	for name := range ks.file.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save 将密钥库写入磁盘，先写临时文件再重命名避免写坏
+func (ks *Keystore) Save() error {
+	ks.mu.RLock()
+	data, err := json.MarshalIndent(ks.file, "", "  ")
+	ks.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := ks.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write keystore failed: %w", err)
+	}
+	return os.Rename(tmp, ks.path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密并把 nonce 拼接在密文之前
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open 解密 seal 的输出
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
@@ -0,0 +1,120 @@
+package secret
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// KMSClient 远程密钥管理服务客户端
+// 配置中保存由 KMS 加密后的密文，启动时调用 Decrypt 得到明文，明文不落盘
+type KMSClient interface {
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// KMSProvider 通过 KMS 解密配置中的密文
+// 引用格式: kms:<keyID>/<base64 密文>
+type KMSProvider struct {
+	client KMSClient
+}
+
+// NewKMSProvider 创建 KMS 提供者
+func NewKMSProvider(client KMSClient) *KMSProvider {
+	return &KMSProvider{client: client}
+}
+
+// GetSecret 解析引用并调用 KMS 解密
+func (p *KMSProvider) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	keyID, encoded, ok := strings.Cut(ref, "/")
+	if !ok || keyID == "" || encoded == "" {
+		return nil, fmt.Errorf("invalid kms reference, expect <keyID>/<ciphertext>")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode kms ciphertext failed: %w", err)
+	}
+
+	plaintext, err := p.client.Decrypt(ctx, keyID, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// LocalKMS 进程内的 KMS 替身，用于测试和本地开发
+// 每个 keyID 对应一个随机生成的 AES-256 密钥，仅保存在内存中
+type LocalKMS struct {
+	mu   sync.RWMutex
+	keys map[string]cipher.AEAD
+}
+
+// NewLocalKMS 创建本地 KMS
+func NewLocalKMS() *LocalKMS {
+	return &LocalKMS{
+		keys: make(map[string]cipher.AEAD),
+	}
+}
+
+// CreateKey 创建主密钥，已存在时不覆盖
+func (k *LocalKMS) CreateKey(keyID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, exists := k.keys[keyID]; exists {
+		return nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	k.keys[keyID] = aead
+	return nil
+}
+
+// Encrypt 使用主密钥加密
+func (k *LocalKMS) Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	aead, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return seal(aead, plaintext, []byte(keyID))
+}
+
+// Decrypt 使用主密钥解密
+func (k *LocalKMS) Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	aead, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, ciphertext, []byte(keyID))
+}
+
+// Ref 加密明文并返回可直接写入配置的 kms: 引用
+func (k *LocalKMS) Ref(ctx context.Context, keyID string, plaintext []byte) (string, error) {
+	ciphertext, err := k.Encrypt(ctx, keyID, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s/%s", SchemeKMS, keyID, base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+func (k *LocalKMS) key(keyID string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: kms key %s", ErrSecretNotFound, keyID)
+	}
+	return aead, nil
+}
@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKMSEnvelope(t *testing.T) {
+	ctx := context.Background()
+	kms := NewLocalKMS()
+	if err := kms.CreateKey("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kms.CreateKey("key-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := kms.Ref(ctx, "key-1", []byte("alipay-private-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ref, "kms:key-1/") {
+		t.Fatalf("ref = %s", ref)
+	}
+
+	registry := NewRegistry()
+	registry.Register(SchemeKMS, NewKMSProvider(kms))
+
+	got, err := registry.GetSecret(ctx, ref)
+	if err != nil || string(got) != "alipay-private-key" {
+		t.Fatalf("secret = %q, err = %v", got, err)
+	}
+
+	// 已存在的主密钥不会被覆盖
+	if err := kms.CreateKey("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.GetSecret(ctx, ref); err != nil {
+		t.Fatalf("decrypt after CreateKey: %v", err)
+	}
+
+	_, encoded, _ := strings.Cut(ref, "/")
+	ciphertext, _ := base64.StdEncoding.DecodeString(encoded)
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+
+	cases := []struct {
+		name    string
+		ref     string
+		wantErr error
+	}{
+		{"wrong key", "kms:key-2/" + encoded, nil},
+		{"unknown key", "kms:key-3/" + encoded, ErrSecretNotFound},
+		{"tampered ciphertext", "kms:key-1/" + base64.StdEncoding.EncodeToString(tampered), nil},
+		{"truncated ciphertext", "kms:key-1/" + base64.StdEncoding.EncodeToString(ciphertext[:4]), nil},
+		{"invalid base64", "kms:key-1/not base64!", nil},
+		{"missing ciphertext", "kms:key-1", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := registry.GetSecret(ctx, tc.ref)
+			if err == nil {
+				t.Fatalf("decrypted %q", got)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestKMSNotRegistered(t *testing.T) {
+	if _, err := NewRegistry().GetSecret(context.Background(), "kms:key-1/AAAA"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Fatalf("err = %v, want %v", err, ErrUnsupportedScheme)
+	}
+}
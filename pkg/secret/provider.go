@@ -0,0 +1,208 @@
+package secret
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 引用前缀，形如 env:WECHAT_API_V3_KEY、keystore:wechat_key、kms:key-1/BASE64
+const (
+	SchemeFile     = "file"
+	SchemeEnv      = "env"
+	SchemeKeystore = "keystore"
+	SchemeKMS      = "kms"
+)
+
+var (
+	ErrSecretNotFound    = errors.New("secret not found")
+	ErrUnsupportedScheme = errors.New("unsupported secret scheme")
+)
+
+// SecretProvider 机密数据提供者
+type SecretProvider interface {
+	// GetSecret 根据引用获取机密数据，引用不含前缀部分
+	GetSecret(ctx context.Context, ref string) ([]byte, error)
+}
+
+// KeyProvider 私钥提供者
+type KeyProvider interface {
+	SecretProvider
+
+	// PrivateKey 获取 PEM 格式(PKCS#1/PKCS#8)的 RSA 私钥
+	PrivateKey(ctx context.Context, ref string) (*rsa.PrivateKey, error)
+}
+
+// Registry 按引用前缀分发到具体的 SecretProvider
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]SecretProvider
+}
+
+// NewRegistry 创建默认注册表，内置 file 和 env 两种提供者
+func NewRegistry() *Registry {
+	r := &Registry{
+		providers: make(map[string]SecretProvider),
+	}
+	r.Register(SchemeFile, NewFileProvider(""))
+	r.Register(SchemeEnv, NewEnvProvider(""))
+	return r
+}
+
+// Register 注册指定前缀的提供者
+func (r *Registry) Register(scheme string, provider SecretProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.providers[scheme] = provider
+}
+
+// GetSecret 解析 scheme:ref 形式的引用并获取机密数据
+func (r *Registry) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	scheme, rest, ok := splitRef(ref)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, ref)
+	}
+
+	r.mu.RLock()
+	provider, exists := r.providers[scheme]
+	r.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedScheme, scheme)
+	}
+
+	return provider.GetSecret(ctx, rest)
+}
+
+// PrivateKey 获取 RSA 私钥
+func (r *Registry) PrivateKey(ctx context.Context, ref string) (*rsa.PrivateKey, error) {
+	data, err := r.GetSecret(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(data)
+}
+
+// IsRef 判断配置值是否为机密引用
+// 不带已知前缀的值按原有语义处理（文件路径或字面量），保持对旧配置的兼容
+func IsRef(value string) bool {
+	_, _, ok := splitRef(value)
+	return ok
+}
+
+func splitRef(ref string) (scheme, rest string, ok bool) {
+	scheme, rest, found := strings.Cut(ref, ":")
+	if !found {
+		return "", "", false
+	}
+
+	switch scheme {
+	case SchemeFile, SchemeEnv, SchemeKeystore, SchemeKMS:
+		return scheme, strings.TrimPrefix(rest, "//"), true
+	default:
+		return "", "", false
+	}
+}
+
+// LoadFile 加载文件类配置项: 机密引用交给 provider，否则按本地文件路径读取
+func LoadFile(ctx context.Context, provider SecretProvider, value string) ([]byte, error) {
+	if IsRef(value) && provider != nil {
+		return provider.GetSecret(ctx, value)
+	}
+	return os.ReadFile(value)
+}
+
+// LoadValue 加载字面量类配置项: 机密引用交给 provider，否则原样返回
+func LoadValue(ctx context.Context, provider SecretProvider, value string) (string, error) {
+	if IsRef(value) && provider != nil {
+		data, err := provider.GetSecret(ctx, value)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return value, nil
+}
+
+// LoadPrivateKey 加载 PEM 格式 RSA 私钥，value 为机密引用或文件路径
+func LoadPrivateKey(ctx context.Context, provider SecretProvider, value string) (*rsa.PrivateKey, error) {
+	data, err := LoadFile(ctx, provider, value)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(data)
+}
+
+// ParsePrivateKey 解析 PEM 格式的 PKCS#1 或 PKCS#8 RSA 私钥
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("decode private key error")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not rsa private key")
+	}
+	return rsaKey, nil
+}
+
+// FileProvider 从本地文件读取机密数据
+type FileProvider struct {
+	baseDir string
+}
+
+// NewFileProvider 创建文件提供者，baseDir 非空时相对路径基于该目录解析
+func NewFileProvider(baseDir string) *FileProvider {
+	return &FileProvider{baseDir: baseDir}
+}
+
+// GetSecret 读取文件内容
+func (p *FileProvider) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	path := ref
+	if p.baseDir != "" && !strings.HasPrefix(path, "/") {
+		path = strings.TrimRight(p.baseDir, "/") + "/" + path
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: file %s", ErrSecretNotFound, path)
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// EnvProvider 从环境变量读取机密数据
+type EnvProvider struct {
+	prefix string
+}
+
+// NewEnvProvider 创建环境变量提供者，prefix 会拼接在变量名之前
+func NewEnvProvider(prefix string) *EnvProvider {
+	return &EnvProvider{prefix: prefix}
+}
+
+// GetSecret 读取环境变量，变量中的 \n 会还原为换行以便存放 PEM 内容
+func (p *EnvProvider) GetSecret(ctx context.Context, ref string) ([]byte, error) {
+	value, ok := os.LookupEnv(p.prefix + ref)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("%w: env %s", ErrSecretNotFound, p.prefix+ref)
+	}
+	return []byte(strings.ReplaceAll(value, `\n`, "\n")), nil
+}
@@ -0,0 +1,141 @@
+package secret
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "apiv3.key"), []byte("file-secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_TEST_PEM", `line1\nline2`)
+
+	registry := NewRegistry()
+	cases := []struct {
+		ref     string
+		want    string
+		wantErr error
+	}{
+		{"file:" + filepath.Join(dir, "apiv3.key"), "file-secret", nil},
+		{"file://" + filepath.Join(dir, "apiv3.key"), "file-secret", nil},
+		{"env:SECRET_TEST_PEM", "line1\nline2", nil},
+		{"file:" + filepath.Join(dir, "missing.key"), "", ErrSecretNotFound},
+		{"env:SECRET_TEST_MISSING", "", ErrSecretNotFound},
+		{"keystore:wechat_key", "", ErrUnsupportedScheme},
+		{"vault:wechat_key", "", ErrUnsupportedScheme},
+		{"/etc/certs/key.pem", "", ErrUnsupportedScheme},
+	}
+	for _, tc := range cases {
+		t.Run(tc.ref, func(t *testing.T) {
+			got, err := registry.GetSecret(context.Background(), tc.ref)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("secret = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderBaseDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "key"), []byte("relative"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewFileProvider(dir).GetSecret(context.Background(), "key")
+	if err != nil || string(got) != "relative" {
+		t.Fatalf("secret = %q, err = %v", got, err)
+	}
+
+	// 绝对路径不拼接 baseDir
+	got, err = NewFileProvider("/nonexistent").GetSecret(context.Background(), filepath.Join(dir, "key"))
+	if err != nil || string(got) != "relative" {
+		t.Fatalf("secret = %q, err = %v", got, err)
+	}
+}
+
+func TestEnvProviderPrefix(t *testing.T) {
+	t.Setenv("PAYGW_WECHAT_KEY", "prefixed")
+
+	got, err := NewEnvProvider("PAYGW_").GetSecret(context.Background(), "WECHAT_KEY")
+	if err != nil || string(got) != "prefixed" {
+		t.Fatalf("secret = %q, err = %v", got, err)
+	}
+
+	t.Setenv("PAYGW_EMPTY", "")
+	if _, err := NewEnvProvider("PAYGW_").GetSecret(context.Background(), "EMPTY"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrSecretNotFound)
+	}
+}
+
+func TestLoadValueAndFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pwd")
+	if err := os.WriteFile(path, []byte("pfx-password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry()
+
+	// 字面量原样返回，引用去掉首尾空白
+	for value, want := range map[string]string{"literal": "literal", "file:" + path: "pfx-password"} {
+		got, err := LoadValue(context.Background(), registry, value)
+		if err != nil || got != want {
+			t.Errorf("LoadValue(%q) = %q, %v, want %q", value, got, err, want)
+		}
+	}
+
+	// 不带前缀的文件配置按本地路径读取
+	got, err := LoadFile(context.Background(), registry, path)
+	if err != nil || string(got) != "pfx-password\n" {
+		t.Fatalf("LoadFile = %q, %v", got, err)
+	}
+
+	if !IsRef("env:X") || !IsRef("kms:key/abc") || IsRef("C:/certs/key.pem") || IsRef("/etc/key.pem") {
+		t.Fatal("IsRef mismatch")
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, block := range map[string]*pem.Block{
+		"pkcs1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// env 中以 \n 转义的 PEM 也能解析
+			t.Setenv("SECRET_TEST_KEY", strings.ReplaceAll(string(pem.EncodeToMemory(block)), "\n", `\n`))
+
+			got, err := LoadPrivateKey(context.Background(), NewRegistry(), "env:SECRET_TEST_KEY")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(key) {
+				t.Fatal("private key does not match")
+			}
+		})
+	}
+
+	if _, err := ParsePrivateKey([]byte("not a pem")); err == nil {
+		t.Fatal("invalid pem accepted")
+	}
+}
@@ -0,0 +1,15 @@
+#!/bin/bash
+
+# 生成 gRPC 接口代码，修改 api/proto 下的 .proto 文件后执行，生成的代码需一并提交
+# 依赖 protoc 及插件:
+#   go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.36.9
+#   go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.5.1
+
+set -euo pipefail
+
+cd "$(dirname "$0")/.."
+
+protoc -I api/proto \
+  --go_out=api/proto --go_opt=paths=source_relative \
+  --go-grpc_out=api/proto --go-grpc_opt=paths=source_relative \
+  api/proto/payment/v1/payment.proto
@@ -26,7 +26,7 @@ cat > /tmp/pay_wechat.json <<EOF
   "total_amount": 0.01,
   "subject": "Stress Test Payment",
   "scene": "app",
-  "notify_url": "https://example.com/notify"
+  "event_url": "https://example.com/events"
 }
 EOF
 
@@ -37,7 +37,7 @@ cat > /tmp/pay_alipay.json <<EOF
   "total_amount": 0.01,
   "subject": "Stress Test Payment",
   "scene": "app",
-  "notify_url": "https://example.com/notify"
+  "event_url": "https://example.com/events"
 }
 EOF
 
@@ -48,7 +48,7 @@ cat > /tmp/pay_unionpay.json <<EOF
   "total_amount": 0.01,
   "subject": "Stress Test Payment",
   "scene": "app",
-  "notify_url": "https://example.com/notify"
+  "event_url": "https://example.com/events"
 }
 EOF
 
@@ -67,7 +67,7 @@ export default function () {
         total_amount: 0.01,
         subject: 'Stress Test Payment',
         scene: 'app',
-        notify_url: 'https://example.com/notify',
+        event_url: 'https://example.com/events',
       });
 
       res = http.post(`${BASE_URL}/pay`, payPayload, {
@@ -2,19 +2,25 @@ package stress
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/ymqzj/payment-gateway/internal/auth"
 	"github.com/ymqzj/payment-gateway/internal/payment"
 )
 
 // StressTestConfig holds configuration for stress testing
 type StressTestConfig struct {
 	BaseURL         string
+	AppKey          string
+	AppSecret       string
 	ConcurrentUsers int
 	Duration        time.Duration
 }
@@ -35,12 +41,14 @@ type PaymentRequest struct {
 	TotalAmount float64 `json:"total_amount"`
 	Subject     string  `json:"subject"`
 	Scene       string  `json:"scene"`
-	NotifyURL   string  `json:"notify_url"`
+	EventURL    string  `json:"event_url"`
 }
 
 func main() {
 	config := StressTestConfig{
 		BaseURL:         "http://localhost:8080/api/v1",
+		AppKey:          "dev-app",
+		AppSecret:       "dev-secret-change-me",
 		ConcurrentUsers: 100,
 		Duration:        5 * time.Minute,
 	}
@@ -110,11 +118,11 @@ func runUserTest(config StressTestConfig, userID int) TestResult {
 			TotalAmount: 0.01,
 			Subject:     "Stress Test Payment",
 			Scene:       "app",
-			NotifyURL:   "https://example.com/notify",
+			EventURL:    "https://example.com/events",
 		}
 
 		// Send payment request
-		success := sendPaymentRequest(client, config.BaseURL, paymentReq)
+		success := sendPaymentRequest(client, config, paymentReq)
 		result.TotalRequests++
 		if success {
 			result.SuccessRequests++
@@ -129,20 +137,21 @@ func runUserTest(config StressTestConfig, userID int) TestResult {
 	return result
 }
 
-func sendPaymentRequest(client *http.Client, baseURL string, req PaymentRequest) bool {
+func sendPaymentRequest(client *http.Client, config StressTestConfig, req PaymentRequest) bool {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		log.Printf("Error marshaling request: %v", err)
 		return false
 	}
 
-	httpReq, err := http.NewRequest("POST", baseURL+"/pay", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequest("POST", config.BaseURL+"/pay", bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Printf("Error creating request: %v", err)
 		return false
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	signRequest(httpReq, config, jsonData)
 
 	resp, err := client.Do(httpReq)
 	if err != nil {
@@ -153,3 +162,15 @@ func sendPaymentRequest(client *http.Client, baseURL string, req PaymentRequest)
 
 	return resp.StatusCode == http.StatusOK
 }
+
+// signRequest adds the API authentication headers
+func signRequest(req *http.Request, config StressTestConfig, body []byte) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	nonce := hex.EncodeToString(buf)
+	req.Header.Set(auth.HeaderAppKey, config.AppKey)
+	req.Header.Set(auth.HeaderTimestamp, timestamp)
+	req.Header.Set(auth.HeaderNonce, nonce)
+	req.Header.Set(auth.HeaderSignature, auth.Sign([]byte(config.AppSecret), req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+}